@@ -28,6 +28,8 @@ var commands = []*util.Command{
 	nomsDs,
 	nomsLog,
 	nomsMerge,
+	nomsMigrate,
+	nomsPatch,
 	nomsRoot,
 	nomsServe,
 	nomsShow,
@@ -37,6 +39,8 @@ var commands = []*util.Command{
 
 var kingpinCommands = []util.KingpinCommand{
 	nomsBlob,
+	nomsBundle,
+	nomsInit,
 	splore.Cmd,
 }
 
@@ -132,6 +136,8 @@ func addNomsDocs(noms *kingpin.Application) {
 If absolute-path is not provided, then it is read from stdin. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset and absolute-path arguments.
 `)
 	commit.Flag("allow-dupe", "creates a new commit, even if it would be identical (modulo metadata and parents) to the existing HEAD.").Default("0").Int()
+	commit.Flag("expect", "only commit if the head of the dataset is the commit with this hash").String()
+	commit.Flag("sign", "signs the commit with the key in the [signing] section of .nomsconfig").Bool()
 	commit.Flag("date", "alias for -meta 'date=<date>'. '<date>' must be iso8601-formatted. If '<date>' is empty, it defaults to the current date.").String()
 	commit.Flag("message", "alias for -meta 'message=<message>'").String()
 	commit.Flag("meta", "'<key>=<value>' - creates a metadata field called 'key' set to 'value'. Value should be human-readable encoded.").String()
@@ -148,6 +154,12 @@ If absolute-path is not provided, then it is read from stdin. See Spelling Objec
 See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object arguments.
 `)
 	diff.Flag("stat", "Writes a summary of the changes instead").Short('s').Bool()
+	diff.Flag("depth", "with --stat, break down the summary by struct fields and map keys to this depth").Default("0").Int()
+	diff.Flag("json", "with --stat, write the summary as JSON").Bool()
+	diff.Flag("format", "text, json-patch (a JSON array of operations) or ndjson (a JSON object per line)").Default("text").Enum("text", "json-patch", "ndjson")
+	diff.Flag("blob-lines", "with --format=text, show the lines that changed in Blobs that are UTF-8 text").Bool()
+	diff.Flag("include", "only show changes at or under paths matching this pattern, e.g. '.rows[*].price'. May be repeated.").Strings()
+	diff.Flag("exclude", "don't show changes at or under paths matching this pattern, e.g. '.meta'. May be repeated.").Strings()
 	diff.Arg("object1", "").Required().String()
 	diff.Arg("object2", "").Required().String()
 
@@ -156,6 +168,9 @@ See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spell
 See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.
 `)
 	ds.Flag("delete", "dataset to delete").Short('d').String()
+	ds.Flag("expect", "with -d, only delete the dataset if its head is the commit with this hash").String()
+	ds.Flag("schema", "dataset whose schema to show, or to set to the <type> argument").String()
+	ds.Flag("rm-schema", "dataset whose schema to remove").String()
 	ds.Arg("database", "a noms database path").String()
 
 	// log
@@ -168,19 +183,66 @@ See Spelling Values at https://github.com/attic-labs/noms/blob/master/doc/spelli
 	log.Flag("oneline", "show a summary of each commit on a single line").Bool()
 	log.Flag("graph", "show ascii-based commit hierarchy on left side of output").Bool()
 	log.Flag("show-value", "show commit value rather than diff information").Bool()
+	log.Flag("verify", "show whether each commit is signed by a key in the [trusted] section of .nomsconfig").Bool()
 	log.Flag("tz", "display formatted date comments in specified timezone, must be: local or utc").Enum("local", "utc")
+	log.Flag("include", "only show commits that changed paths matching this pattern, e.g. '.rows[*].price'. May be repeated.").Strings()
+	log.Flag("exclude", "don't count changes at or under paths matching this pattern, e.g. '.meta'. May be repeated.").Strings()
 	log.Arg("path-spec", "").Required().String()
 
 	// merge
 	merge := noms.Command("merge", `Merges and commits the head values of two named datasets
 See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.
 You must provide a working database and the names of two Datasets you want to merge. The values at the heads of these Datasets will be merged, put into a new Commit object, and set as the Head of the third provided Dataset name.
+With --into, any number of Datasets are merged into the Dataset it names, in a single Commit with all of their heads as parents.
+With --all-conflicts or --policy p, a merge that has conflicts is kept in the database until it's finished. Resolve each conflict with 'noms merge resolve --ours|--theirs|--value <json> <database> <path>', then commit the merge with --continue, or drop it with --abort. --status shows the conflicts that remain.
 `)
-	merge.Flag("policy", "conflict resolution policy for merging. Defaults to 'n', which means no resolution strategy will be applied. Supported values are 'l' (left), 'r' (right) and 'p' (prompt). 'prompt' will bring up a simple command-line prompt allowing you to resolve conflicts by choosing between 'l' or 'r' on a case-by-case basis.").Default("n").Enum("n", "r", "l", "p")
+	merge.Flag("policy", "conflict resolution policy for merging. Defaults to 'n', which means no resolution strategy will be applied. Supported values are 'l' (left), 'r' (right) and 'p' (prompt). 'prompt' will bring up a simple command-line prompt allowing you to resolve conflicts by choosing between 'l' or 'r' on a case-by-case basis. Conflicts that aren't resolved at the prompt, e.g. because it's interrupted, are kept as with --all-conflicts.").Default("n").Enum("n", "r", "l", "p")
+	merge.Flag("policy-file", "JSON file listing {\"path\": <pattern>, \"resolve\": <resolver>} rules, tried in order before --policy on conflicts at matching paths. Patterns are paths where .* and [*] match any field or index. Resolvers are counter, max, min, concat, lww (last writer wins, by commit date), left and right.").String()
+	merge.Flag("into", "the dataset to commit the merge to, when every other argument after the database is a dataset to merge").String()
+	merge.Flag("all-conflicts", "keep merging after a conflict the policy can't resolve, and report all of them instead of only the first").Bool()
+	merge.Flag("status", "show the conflicts that remain in the merge in progress").Bool()
+	merge.Flag("continue", "commit the merge in progress, once all its conflicts are resolved").Bool()
+	merge.Flag("abort", "drop the merge in progress").Bool()
+	merge.Flag("ours", "with resolve, take the left value").Bool()
+	merge.Flag("theirs", "with resolve, take the right value").Bool()
+	merge.Flag("value", "with resolve, take this JSON value; objects become maps").String()
 	addDatabaseArg(merge)
-	merge.Arg("left-dataset-name", "a dataset").Required().String()
-	merge.Arg("right-dataset-name", "a dataset").Required().String()
-	merge.Arg("output-dataset-name", "a dataset").Required().String()
+	// Not required: --status, --continue, --abort and resolve take fewer arguments.
+	merge.Arg("left-dataset-name", "a dataset").String()
+	merge.Arg("right-dataset-name", "a dataset").String()
+	merge.Arg("output-dataset-name", "a dataset, or with --into, more datasets to merge").Strings()
+
+	// migrate
+	migrate := noms.Command("migrate", `Rewrites the structs in a dataset from one type to another
+The migration file is JSON declaring the nomdl types to migrate from and to, and optionally the paths from which fields of the new type take their values. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.
+`)
+	migrate.Flag("all", "rewrite every commit in the dataset's history, rather than committing a migrated head").Bool()
+	migrate.Flag("date", "alias for -meta 'date=<date>'. '<date>' must be iso8601-formatted. If '<date>' is empty, it defaults to the current date.").String()
+	migrate.Flag("message", "alias for -meta 'message=<message>'").String()
+	migrate.Flag("meta", "'<key>=<value>' - creates a metadata field called 'key' set to 'value'. Value should be human-readable encoded.").String()
+	migrate.Flag("meta-p", "'<key>=<path>' - creates a metadata field called 'key' set to the value at <path>").String()
+	migrate.Arg("migration-file", "a JSON file declaring the migration").Required().String()
+	migrate.Arg("dataset", "the dataset to migrate").Required().String()
+
+	// patch
+	patch := noms.Command("patch", `Creates patches, and applies them to datasets
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.
+`)
+	patchCreate := patch.Command("create", "commits the diff between two objects to a dataset, as a List of Struct Difference {path: String, type: String, old?: Value, new?: Value}")
+	patchCreate.Flag("date", "alias for -meta 'date=<date>'. '<date>' must be iso8601-formatted. If '<date>' is empty, it defaults to the current date.").String()
+	patchCreate.Flag("message", "alias for -meta 'message=<message>'").String()
+	patchCreate.Flag("meta", "'<key>=<value>' - creates a metadata field called 'key' set to 'value'. Value should be human-readable encoded.").String()
+	patchCreate.Flag("meta-p", "'<key>=<path>' - creates a metadata field called 'key' set to the value at <path>").String()
+	patchCreate.Arg("object1", "").Required().String()
+	patchCreate.Arg("object2", "").Required().String()
+	patchCreate.Arg("dataset", "the dataset to commit the patch to").Required().String()
+	patchApply := patch.Command("apply", "applies a patch to the head value of a dataset, and commits the result")
+	patchApply.Flag("date", "alias for -meta 'date=<date>'. '<date>' must be iso8601-formatted. If '<date>' is empty, it defaults to the current date.").String()
+	patchApply.Flag("message", "alias for -meta 'message=<message>'").String()
+	patchApply.Flag("meta", "'<key>=<value>' - creates a metadata field called 'key' set to 'value'. Value should be human-readable encoded.").String()
+	patchApply.Flag("meta-p", "'<key>=<path>' - creates a metadata field called 'key' set to the value at <path>").String()
+	patchApply.Arg("dataset", "the dataset to apply the patch to").Required().String()
+	patchApply.Arg("patch", "a file written by 'noms diff --format=json-patch|ndjson', or an object created by 'noms patch create'").Required().String()
 
 	// root
 	root := noms.Command("root", `Get or set the current root hash of the entire database
@@ -194,6 +256,10 @@ See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spell
 See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.
 `)
 	serve.Flag("port", "port to listen on for HTTP requests").Default("8000").Int()
+	serve.Flag("hook", "an executable to run before each update of a dataset, with the dataset, its old head and its new head as arguments. If it fails, the update is rejected with its output. May be repeated.").Strings()
+	serve.Flag("hook-plugin", "a Go plugin exporting a func CheckUpdate(datas.DatasetUpdate, types.ValueReader) error to call before each update of a dataset. May be repeated.").Strings()
+	serve.Flag("replica-of", "serve a read-only copy of this database, e.g. http://primary:8000, which is kept up to date by pulling from it. Writes are rejected, and how far behind it is can be fetched from /replica/").String()
+	serve.Flag("replica-interval", "how often to pull from the database given to --replica-of").Default("10s").Duration()
 	addDatabaseArg(serve)
 
 	// show
@@ -202,6 +268,7 @@ See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spell
 `)
 	show.Flag("raw", "If true, dumps the raw binary version of the data").Bool()
 	show.Flag("stats", "If true, reports statistics related to the value").Bool()
+	show.Flag("verify", "If true and the object is a commit, reports whether it's signed by a key in the [trusted] section of .nomsconfig").Bool()
 	show.Flag("tz", "display formatted date comments in specified timezone, must be: local or utc").Enum("local", "utc")
 	show.Arg("object", "a noms object").Required().String()
 
@@ -210,6 +277,8 @@ See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spell
 See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.
 `)
 	sync.Flag("parallelism", "").Short('p').Default("512").Int()
+	sync.Flag("resume", "checkpoint the sync to a local database, so that if it's interrupted, running it again with --resume continues from the last checkpoint").Bool()
+	sync.Flag("checkpoint-dir", "directory in which to keep sync checkpoints").String()
 	sync.Arg("source-object", "a noms source object").Required().String()
 	sync.Arg("dest-dataset", "a noms dataset").Required().String()
 
@@ -0,0 +1,103 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func nomsBundle(noms *kingpin.Application) (*kingpin.CmdClause, util.KingpinHandler) {
+	bundle := noms.Command("bundle", `moves values between databases as files
+A bundle holds every chunk reachable from a value, optionally excluding the chunks reachable from an earlier value, so that it can be carried to a database that can't be synced with directly.
+`)
+
+	bundleCreate := bundle.Command("create", "writes a value, usually the head of a dataset, to a bundle file")
+	since := bundleCreate.Flag("since", "a value in the same database, e.g. an earlier commit, whose chunks the bundle leaves out because the importing database already has them").String()
+	createPath := bundleCreate.Arg("path", "the value to bundle, e.g. a dataset").Required().String()
+	createFile := bundleCreate.Arg("file", "the bundle file to write").Required().String()
+
+	bundleImport := bundle.Command("import", "loads a bundle file into a database")
+	importDs := bundleImport.Flag("dataset", "a dataset to fast-forward to the bundled commit").String()
+	importFile := bundleImport.Arg("file", "the bundle file to read").Required().String()
+	importDb := addDatabaseArg(bundleImport)
+
+	return bundle, func(input string) int {
+		switch input {
+		case bundleCreate.FullCommand():
+			return runBundleCreate(*createPath, *since, *createFile)
+		case bundleImport.FullCommand():
+			return runBundleImport(*importFile, *importDb, *importDs)
+		}
+		d.Panic("notreached")
+		return 1
+	}
+}
+
+func runBundleCreate(path, since, file string) int {
+	cfg := config.NewResolver()
+	db, val, err := cfg.GetPath(path)
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+	if val == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("No value at %s", path))
+	}
+
+	sinceRefs := []types.Ref{}
+	if since != "" {
+		absPath, err := spec.NewAbsolutePath(since)
+		d.CheckErrorNoUsage(err)
+		sinceVal := absPath.Resolve(db)
+		if sinceVal == nil {
+			d.CheckErrorNoUsage(fmt.Errorf("No value at %s", since))
+		}
+		sinceRefs = append(sinceRefs, types.NewRef(sinceVal))
+	}
+
+	f, err := os.Create(file)
+	d.CheckErrorNoUsage(err)
+	head := types.NewRef(val)
+	err = datas.WriteBundle(db, head, sinceRefs, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	d.CheckErrorNoUsage(err)
+
+	fmt.Printf("Bundled #%s to %s\n", head.TargetHash(), file)
+	return 0
+}
+
+func runBundleImport(file, dbSpec, dsName string) int {
+	cfg := config.NewResolver()
+	db, err := cfg.GetDatabase(dbSpec)
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+
+	f, err := os.Open(file)
+	d.CheckErrorNoUsage(err)
+	defer f.Close()
+	header, err := datas.ReadBundle(db, f)
+	d.CheckErrorNoUsage(err)
+	fmt.Printf("Imported #%s\n", header.Head)
+
+	if dsName != "" {
+		head := db.ReadValue(header.Head)
+		if !datas.IsCommit(head) {
+			d.CheckErrorNoUsage(fmt.Errorf("Can't fast-forward %s: #%s is not a commit", dsName, header.Head))
+		}
+		_, err = db.FastForward(db.GetDataset(dsName), types.NewRef(head))
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("Fast-forwarded %s to #%s\n", dsName, header.Head)
+	}
+	return 0
+}
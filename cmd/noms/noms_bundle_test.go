@@ -0,0 +1,62 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestNomsBundle(t *testing.T) {
+	suite.Run(t, &nomsBundleTestSuite{})
+}
+
+type nomsBundleTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsBundleTestSuite) TestBundleCreateImport() {
+	sourceDB := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	ds := sourceDB.GetDataset("src")
+	ds, err := sourceDB.CommitValue(ds, types.NewList(sourceDB, types.Number(1), types.Number(2)))
+	s.NoError(err)
+	firstHead := ds.HeadRef().TargetHash()
+	ds, err = sourceDB.CommitValue(ds, types.NewList(sourceDB, types.Number(1), types.Number(2), types.Number(3)))
+	s.NoError(err)
+	secondHead := ds.HeadRef().TargetHash()
+	sourceDB.Close()
+
+	full, incremental := filepath.Join(s.TempDir, "full.nbundle"), filepath.Join(s.TempDir, "incremental.nbundle")
+	sourceSpec := spec.CreateValueSpecString("nbs", s.DBDir, "src")
+	stdout, _ := s.MustRun(main, []string{"bundle", "create", spec.CreateHashSpecString("nbs", s.DBDir, firstHead), full})
+	s.Contains(stdout, "Bundled #"+firstHead.String())
+	stdout, _ = s.MustRun(main, []string{"bundle", "create", "--since", "#" + firstHead.String(), sourceSpec, incremental})
+	s.Contains(stdout, "Bundled #"+secondHead.String())
+
+	sinkSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir2)
+
+	// The sink doesn't have the first head yet.
+	_, _, recoveredErr := s.Run(main, []string{"bundle", "import", incremental, sinkSpec})
+	s.NotNil(recoveredErr)
+
+	stdout, _ = s.MustRun(main, []string{"bundle", "import", "--dataset", "dest", full, sinkSpec})
+	s.Contains(stdout, "Imported #"+firstHead.String())
+	s.Contains(stdout, "Fast-forwarded dest to #"+firstHead.String())
+	stdout, _ = s.MustRun(main, []string{"bundle", "import", "--dataset", "dest", incremental, sinkSpec})
+	s.Contains(stdout, "Fast-forwarded dest to #"+secondHead.String())
+
+	sinkDB := datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	defer sinkDB.Close()
+	dest := sinkDB.GetDataset("dest")
+	s.Equal(secondHead, dest.HeadRef().TargetHash())
+	s.Equal(uint64(3), dest.HeadValue().(types.List).Len())
+}
@@ -9,17 +9,23 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/util/verbose"
 	flag "github.com/juju/gnuflag"
 )
 
-var allowDupe bool
+var (
+	allowDupe  bool
+	expectHead string
+	signCommit bool
+)
 
 var nomsCommit = &util.Command{
 	Run:       runCommit,
@@ -33,6 +39,8 @@ var nomsCommit = &util.Command{
 func setupCommitFlags() *flag.FlagSet {
 	commitFlagSet := flag.NewFlagSet("commit", flag.ExitOnError)
 	commitFlagSet.BoolVar(&allowDupe, "allow-dupe", false, "creates a new commit, even if it would be identical (modulo metadata and parents) to the existing HEAD.")
+	commitFlagSet.StringVar(&expectHead, "expect", "", "only commit if the head of the dataset is the commit with this hash")
+	commitFlagSet.BoolVar(&signCommit, "sign", false, "signs the commit with the key in the [signing] section of .nomsconfig")
 	spec.RegisterCommitMetaFlags(commitFlagSet)
 	verbose.RegisterVerboseFlags(commitFlagSet)
 	return commitFlagSet
@@ -60,6 +68,9 @@ func runCommit(args []string) int {
 		d.CheckErrorNoUsage(errors.New(fmt.Sprintf("Error resolving value: %s", path)))
 	}
 
+	expected, err := parseExpectedHead(expectHead)
+	d.CheckErrorNoUsage(err)
+
 	oldCommitRef, oldCommitExists := ds.MaybeHeadRef()
 	if oldCommitExists {
 		head := ds.HeadValue()
@@ -72,7 +83,13 @@ func runCommit(args []string) int {
 	meta, err := spec.CreateCommitMetaStruct(db, "", "", nil, nil)
 	d.CheckErrorNoUsage(err)
 
-	ds, err = db.Commit(ds, value, datas.CommitOptions{Meta: meta})
+	opts := datas.CommitOptions{Meta: meta, ExpectedHead: expected}
+	if signCommit {
+		opts.SigningKey, err = cfg.SigningKey()
+		d.CheckErrorNoUsage(err)
+	}
+
+	ds, err = db.Commit(ds, value, opts)
 	d.CheckErrorNoUsage(err)
 
 	if oldCommitExists {
@@ -82,3 +99,15 @@ func runCommit(args []string) int {
 	}
 	return 0
 }
+
+// parseExpectedHead parses the argument of an --expect flag, which is a commit hash optionally prefixed by '#'. An empty argument yields the empty hash, meaning any head is expected.
+func parseExpectedHead(s string) (hash.Hash, error) {
+	if s == "" {
+		return hash.Hash{}, nil
+	}
+	h, ok := hash.MaybeParse(strings.TrimPrefix(s, "#"))
+	if !ok {
+		return hash.Hash{}, fmt.Errorf("Invalid hash: %s", s)
+	}
+	return h, nil
+}
@@ -5,14 +5,22 @@
 package main
 
 import (
+	"crypto/rand"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/noms/go/util/signing"
 	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ed25519"
 )
 
 type nomsCommitTestSuite struct {
@@ -214,3 +222,52 @@ func (s *nomsCommitTestSuite) TestNomsCommitInvalidMetadataFieldName() {
 		s.MustRun(main, []string{"commit", "--allow-dupe=1", "--meta=_foo=bar", "#" + ref.TargetHash().String(), sp.String()})
 	})
 }
+
+func (s *nomsCommitTestSuite) TestNomsCommitExpect() {
+	sp, ref := s.setupDataset("commitTestExpect", true)
+	defer sp.Close()
+
+	head := sp.GetDataset().HeadRef().TargetHash().String()
+	db := sp.GetDatabase()
+	other := db.WriteValue(types.String("other"))
+	_, err := db.CommitValue(db.GetDataset("scratch"), other)
+	s.NoError(err)
+
+	_, _, recoveredErr := s.Run(main, []string{"commit", "--expect", "#" + ref.TargetHash().String(), "--allow-dupe=1", "#" + other.TargetHash().String(), sp.String()})
+	s.NotNil(recoveredErr)
+
+	stdout, _ := s.MustRun(main, []string{"commit", "--expect", "#" + head, "#" + other.TargetHash().String(), sp.String()})
+	s.Contains(stdout, "(was #"+head+")")
+}
+
+func (s *nomsCommitTestSuite) TestNomsCommitSign() {
+	sp, ref := s.setupDataset("commitTestSign", false)
+	defer sp.Close()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	s.NoError(err)
+	nomsConfig := fmt.Sprintf("[signing]\n\tkey = \"%s\"\n[trusted]\n\tme = \"%s\"\n", signing.FormatKey(priv), signing.FormatKey(pub))
+	s.NoError(ioutil.WriteFile(filepath.Join(s.TempDir, config.NomsConfigFile), []byte(nomsConfig), 0600))
+	cwd, err := os.Getwd()
+	s.NoError(err)
+	s.NoError(os.Chdir(s.TempDir))
+	defer os.Chdir(cwd)
+
+	s.MustRun(main, []string{"commit", "#" + ref.TargetHash().String(), sp.String()})
+	s.MustRun(main, []string{"commit", "--sign", "--allow-dupe=1", "#" + ref.TargetHash().String(), sp.String()})
+
+	sp, _ = spec.ForDataset(sp.String())
+	defer sp.Close()
+	key, err := datas.VerifyCommit(sp.GetDataset().Head())
+	s.NoError(err)
+	s.Equal(pub, key)
+
+	stdout, _ := s.MustRun(main, []string{"log", "--verify", "--oneline", sp.String()})
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	s.Len(lines, 2)
+	s.Contains(lines[0], "Verified: yes (signed by me)")
+	s.Contains(lines[1], "Verified: no (unsigned)")
+
+	stdout, _ = s.MustRun(main, []string{"show", "--verify", sp.String()})
+	s.Contains(stdout, "// Verified: yes (signed by me)\n")
+}
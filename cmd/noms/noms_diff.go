@@ -6,23 +6,38 @@ package main
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/diff"
+	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/outputpager"
 	"github.com/attic-labs/noms/go/util/verbose"
 	flag "github.com/juju/gnuflag"
 )
 
-var stat bool
+var (
+	stat         bool
+	diffFormat   string
+	blobLines    bool
+	statDepth    int
+	statJSON     bool
+	includePaths stringList
+	excludePaths stringList
+)
+
+var diffJSONFormats = map[string]diff.JSONFormat{
+	"json-patch": diff.JSONPatch,
+	"ndjson":     diff.NDJSON,
+}
 
 var nomsDiff = &util.Command{
 	Run:       runDiff,
-	UsageLine: "diff [--stat] <object1> <object2>",
+	UsageLine: "diff [--stat [--depth=<n>] [--json]] [--format=text|json-patch|ndjson] [--blob-lines] [--include=<pattern>] [--exclude=<pattern>] <object1> <object2>",
 	Short:     "Shows the difference between two objects",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object arguments.",
+	Long:      "Changed Blobs are shown as the ranges of bytes that changed, as @@ -<offset>,<length> +<offset>,<length> @@, and with --blob-lines, as the lines that changed if they're UTF-8. Patterns are spelled like paths, except that .* matches any field and [*] any index, e.g. --exclude .meta --include '.rows[*].price'. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object arguments.",
 	Flags:     setupDiffFlags,
 	Nargs:     2,
 }
@@ -30,6 +45,11 @@ var nomsDiff = &util.Command{
 func setupDiffFlags() *flag.FlagSet {
 	diffFlagSet := flag.NewFlagSet("diff", flag.ExitOnError)
 	diffFlagSet.BoolVar(&stat, "stat", false, "Writes a summary of the changes instead")
+	diffFlagSet.IntVar(&statDepth, "depth", 0, "with --stat, break down the summary by struct fields and map keys to this depth")
+	diffFlagSet.BoolVar(&statJSON, "json", false, "with --stat, write the summary as JSON")
+	diffFlagSet.StringVar(&diffFormat, "format", "text", "text, json-patch (a JSON array of operations) or ndjson (a JSON object per line)")
+	diffFlagSet.BoolVar(&blobLines, "blob-lines", false, "with --format=text, show the lines that changed in Blobs that are UTF-8 text")
+	registerDiffFilterFlags(diffFlagSet)
 	outputpager.RegisterOutputpagerFlags(diffFlagSet)
 	verbose.RegisterVerboseFlags(diffFlagSet)
 
@@ -37,6 +57,29 @@ func setupDiffFlags() *flag.FlagSet {
 }
 
 func runDiff(args []string) int {
+	jsonFormat, isJSON := diffJSONFormats[diffFormat]
+	if !isJSON && diffFormat != "text" {
+		d.CheckErrorNoUsage(fmt.Errorf("Invalid format %s", diffFormat))
+	}
+
+	if stat {
+		if len(includePaths) > 0 || len(excludePaths) > 0 {
+			d.CheckErrorNoUsage(fmt.Errorf("--include and --exclude can't be used with --stat"))
+		}
+		if diffFormat != "text" || blobLines {
+			d.CheckErrorNoUsage(fmt.Errorf("--format and --blob-lines can't be used with --stat"))
+		}
+	} else if statDepth != 0 || statJSON {
+		d.CheckErrorNoUsage(fmt.Errorf("--depth and --json can only be used with --stat"))
+	}
+	if isJSON && blobLines {
+		d.CheckErrorNoUsage(fmt.Errorf("--blob-lines can only be used with --format=text"))
+	}
+
+	opts, err := diffFilterOptions()
+	d.CheckError(err)
+	opts.BlobLines = blobLines
+
 	cfg := config.NewResolver()
 	db1, value1, err := cfg.GetPath(args[0])
 	d.CheckErrorNoUsage(err)
@@ -53,13 +96,45 @@ func runDiff(args []string) int {
 	defer db2.Close()
 
 	if stat {
-		diff.Summary(value1, value2)
+		d.CheckErrorNoUsage(diff.PrintSummary(os.Stdout, db1, db2, value1, value2, diff.SummaryOptions{Depth: statDepth, JSON: statJSON}))
+		return 0
+	}
+
+	if isJSON {
+		// Patches are smaller, and apply to Lists more reliably, with moves.
+		opts.DetectMoves = true
+		d.CheckErrorNoUsage(diff.PrintJSON(os.Stdout, value1, value2, jsonFormat, opts))
 		return 0
 	}
 
 	pgr := outputpager.Start()
 	defer pgr.Stop()
 
-	diff.PrintDiff(pgr.Writer, value1, value2, false)
+	diff.PrintDiffWithOptions(pgr.Writer, value1, value2, opts)
 	return 0
 }
+
+func registerDiffFilterFlags(flags *flag.FlagSet) {
+	includePaths, excludePaths = nil, nil
+	flags.Var(&includePaths, "include", "only show changes at or under paths matching this pattern (may be repeated)")
+	flags.Var(&excludePaths, "exclude", "don't show changes at or under paths matching this pattern (may be repeated)")
+}
+
+// diffFilterOptions returns diff.Options filtered by the --include and
+// --exclude flags.
+func diffFilterOptions() (opts diff.Options, err error) {
+	parse := func(strs []string) (pps []types.PathPattern, err error) {
+		for _, s := range strs {
+			pp, err := types.ParsePathPattern(s)
+			if err != nil {
+				return nil, err
+			}
+			pps = append(pps, pp)
+		}
+		return
+	}
+	if opts.Include, err = parse(includePaths); err == nil {
+		opts.Exclude, err = parse(excludePaths)
+	}
+	return
+}
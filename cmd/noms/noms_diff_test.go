@@ -72,4 +72,106 @@ func (s *nomsDiffTestSuite) TestNomsDiffStat() {
 
 	out, _ = s.MustRun(main, []string{"diff", "--stat", r3, r4})
 	s.Contains(out, "1 insertion (25.00%), 2 deletions (50.00%), 0 changes (0.00%), (4 values vs 3 values)")
+
+	ds, err = db.CommitValue(ds, types.NewStruct("", types.StructData{"a": types.Number(1), "b": types.NewMap(db, types.String("x"), types.Number(1))}))
+	s.NoError(err)
+	r5 := spec.CreateHashSpecString("nbs", s.DBDir, ds.HeadRef().TargetHash())
+
+	ds, err = db.CommitValue(ds, types.NewStruct("", types.StructData{"a": types.Number(1), "b": types.NewMap(db, types.String("x"), types.Number(2), types.String("y"), types.Number(3))}))
+	s.NoError(err)
+	r6 := spec.CreateHashSpecString("nbs", s.DBDir, ds.HeadRef().TargetHash())
+
+	out, _ = s.MustRun(main, []string{"diff", "--stat", "--depth", "2", r5, r6})
+	lines := strings.Split(out, "\n")
+	s.Len(lines, 6, out)
+	s.Equal("Comparing commit values", lines[0])
+	s.True(strings.HasPrefix(lines[1], "0 insertions (0.00%), 0 deletions (0.00%), 1 change (50.00%), (2 fields vs 2 fields), about "), out)
+	s.True(strings.HasPrefix(lines[2], "  .b: 1 insertion (100.00%), 0 deletions (0.00%), 1 change (100.00%), (1 entry vs 2 entries), about "), out)
+	s.True(strings.HasPrefix(lines[3], `    .b["x"]: `), out)
+	s.True(strings.HasPrefix(lines[4], `    .b["y"]: `), out)
+
+	out, _ = s.MustRun(main, []string{"diff", "--stat", "--json", "--depth=1", r5 + ".value", r6 + ".value"})
+	s.True(strings.HasPrefix(out, `{"path":"","adds":0,"removes":0,"changes":1,"oldSize":2,"newSize":2,"oldBytes":`), out)
+	s.Contains(out, `"children":[{"path":".b","adds":1,"removes":0,"changes":1,"oldSize":1,"newSize":2,"oldBytes":`)
+}
+
+func (s *nomsDiffTestSuite) TestNomsDiffFormat() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "diffFormatTest"))
+	s.NoError(err)
+	defer sp.Close()
+
+	ds, err := addCommit(sp.GetDataset(), "first commit")
+	s.NoError(err)
+	r1 := spec.CreateHashSpecString("nbs", s.DBDir, ds.HeadRef().TargetHash()) + ".value"
+
+	ds, err = addCommit(ds, "second commit")
+	s.NoError(err)
+	r2 := spec.CreateHashSpecString("nbs", s.DBDir, ds.HeadRef().TargetHash()) + ".value"
+
+	out, _ := s.MustRun(main, []string{"diff", "--format=json-patch", r1, r2})
+	s.Equal("[\n{\"op\":\"replace\",\"path\":\"\",\"value\":\"second commit\",\"oldValue\":\"first commit\"}\n]\n", out)
+
+	out, _ = s.MustRun(main, []string{"diff", "--format", "ndjson", r1, r2})
+	s.Equal("{\"path\":\"\",\"type\":\"modified\",\"old\":\"first commit\",\"new\":\"second commit\"}\n", out)
+}
+
+func (s *nomsDiffTestSuite) TestNomsDiffFilters() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "diffFilterTest"))
+	s.NoError(err)
+	defer sp.Close()
+
+	db := sp.GetDatabase()
+	ds, err := db.CommitValue(sp.GetDataset(), types.NewStruct("", types.StructData{"a": types.Number(1), "b": types.Number(1)}))
+	s.NoError(err)
+	r1 := spec.CreateHashSpecString("nbs", s.DBDir, ds.HeadRef().TargetHash()) + ".value"
+
+	ds, err = db.CommitValue(ds, types.NewStruct("", types.StructData{"a": types.Number(2), "b": types.Number(2)}))
+	s.NoError(err)
+	r2 := spec.CreateHashSpecString("nbs", s.DBDir, ds.HeadRef().TargetHash()) + ".value"
+
+	out, _ := s.MustRun(main, []string{"diff", "--format=ndjson", "--exclude", ".a", r1, r2})
+	s.Equal("{\"path\":\".b\",\"type\":\"modified\",\"old\":1,\"new\":2}\n", out)
+
+	out, _ = s.MustRun(main, []string{"diff", "--format=ndjson", "--include", ".a", r1, r2})
+	s.Equal("{\"path\":\".a\",\"type\":\"modified\",\"old\":1,\"new\":2}\n", out)
+
+	out, _ = s.MustRun(main, []string{"diff", "--format=ndjson", "--include=.*", "--exclude=.a", "--exclude=.b", r1, r2})
+	s.Equal("", out)
+
+	// Flags that would have no effect are rejected.
+	for _, args := range [][]string{
+		{"--stat", "--include", ".a"},
+		{"--stat", "--format=ndjson"},
+		{"--stat", "--blob-lines"},
+		{"--depth=1"},
+		{"--json"},
+		{"--format=json-patch", "--blob-lines"},
+	} {
+		_, _, recoveredErr := s.Run(main, append(append([]string{"diff"}, args...), r1, r2))
+		s.NotNil(recoveredErr, "%v", args)
+	}
+}
+
+func (s *nomsDiffTestSuite) TestNomsDiffBlob() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "diffBlobTest"))
+	s.NoError(err)
+	defer sp.Close()
+
+	db := sp.GetDatabase()
+	ds, err := db.CommitValue(sp.GetDataset(), types.NewBlob(db, strings.NewReader("one\ntwo\nthree\n")))
+	s.NoError(err)
+	r1 := spec.CreateHashSpecString("nbs", s.DBDir, ds.HeadRef().TargetHash()) + ".value"
+
+	ds, err = db.CommitValue(ds, types.NewBlob(db, strings.NewReader("one\n2\nthree\nfour\n")))
+	s.NoError(err)
+	r2 := spec.CreateHashSpecString("nbs", s.DBDir, ds.HeadRef().TargetHash()) + ".value"
+
+	out, _ := s.MustRun(main, []string{"diff", r1, r2})
+	s.Equal("-   Blob (14 B)\n+   Blob (17 B)\n    @@ -4,3 +4,1 @@\n    @@ -13,0 +11,5 @@\n", out)
+
+	out, _ = s.MustRun(main, []string{"diff", "--blob-lines", r1, r2})
+	s.Equal("-   Blob (14 B)\n+   Blob (17 B)\n    @@ -4,4 +4,2 @@\n    -two\n    +2\n    @@ -8,6 +6,11 @@\n     three\n    +four\n", out)
+
+	out, _ = s.MustRun(main, []string{"diff", "--stat", r1, r2})
+	s.Contains(out, "5 insertions (35.71%), 2 deletions (14.29%), 1 change (7.14%), (14 bytes vs 17 bytes)")
 }
@@ -10,18 +10,24 @@ import (
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/nomdl"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/verbose"
 	flag "github.com/juju/gnuflag"
 )
 
-var toDelete string
+var (
+	toDelete   string
+	schemaDs   string
+	rmSchemaDs string
+	expectDel  string
+)
 
 var nomsDs = &util.Command{
 	Run:       runDs,
-	UsageLine: "ds [<database> | -d <dataset>]",
+	UsageLine: "ds [<database> | -d <dataset> [--expect <hash>] | --schema <dataset> [<type>] | --rm-schema <dataset>]",
 	Short:     "Noms dataset management",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database and dataset arguments.",
+	Long:      "Lists the datasets in <database>, or deletes one. With --schema, shows the schema of <dataset>, or sets it to <type>, a nomdl type which all values committed to <dataset> must be a subtype of.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database and dataset arguments.",
 	Flags:     setupDsFlags,
 	Nargs:     0,
 }
@@ -29,6 +35,9 @@ var nomsDs = &util.Command{
 func setupDsFlags() *flag.FlagSet {
 	dsFlagSet := flag.NewFlagSet("ds", flag.ExitOnError)
 	dsFlagSet.StringVar(&toDelete, "d", "", "dataset to delete")
+	dsFlagSet.StringVar(&expectDel, "expect", "", "with -d, only delete the dataset if its head is the commit with this hash")
+	dsFlagSet.StringVar(&schemaDs, "schema", "", "dataset whose schema to show, or to set to the <type> argument")
+	dsFlagSet.StringVar(&rmSchemaDs, "rm-schema", "", "dataset whose schema to remove")
 	verbose.RegisterVerboseFlags(dsFlagSet)
 	return dsFlagSet
 }
@@ -45,10 +54,46 @@ func runDs(args []string) int {
 			d.CheckError(fmt.Errorf("Dataset %v not found", set.ID()))
 		}
 
-		_, err = set.Database().Delete(set)
+		expected, err := parseExpectedHead(expectDel)
 		d.CheckError(err)
 
+		// Unlike Database.Delete(), a Batch only deletes the head it was given.
+		batch := set.Database().Batch()
+		if expected.IsEmpty() {
+			batch.Delete(set)
+		} else {
+			batch.DeleteExpecting(set, expected)
+		}
+		d.CheckError(batch.Apply())
+
 		fmt.Printf("Deleted %v (was #%v)\n", toDelete, oldCommitRef.TargetHash().String())
+	} else if schemaDs != "" {
+		db, set, err := cfg.GetDataset(schemaDs)
+		d.CheckError(err)
+		defer db.Close()
+
+		if len(args) == 0 {
+			schema, ok := db.DatasetSchema(set.ID())
+			if !ok {
+				d.CheckError(fmt.Errorf("Dataset %v has no schema", set.ID()))
+			}
+			fmt.Println(schema.Describe())
+			return 0
+		}
+
+		schema, err := nomdl.ParseType(args[0])
+		d.CheckError(err)
+		_, err = db.SetDatasetSchema(set, schema)
+		d.CheckError(err)
+		fmt.Printf("Set schema of %v to %v\n", schemaDs, schema.Describe())
+	} else if rmSchemaDs != "" {
+		db, set, err := cfg.GetDataset(rmSchemaDs)
+		d.CheckError(err)
+		defer db.Close()
+
+		_, err = db.SetDatasetSchema(set, nil)
+		d.CheckError(err)
+		fmt.Printf("Removed schema of %v\n", rmSchemaDs)
 	} else {
 		dbSpec := ""
 		if len(args) >= 1 {
@@ -83,3 +83,49 @@ func (s *nomsDsTestSuite) TestNomsDs() {
 	rtnVal, _ = s.MustRun(main, []string{"ds", dbSpec})
 	s.Equal("", rtnVal)
 }
+
+func (s *nomsDsTestSuite) TestNomsDsSchema() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "people"))
+	s.NoError(err)
+	defer sp.Close()
+	_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), types.NewStruct("Person", types.StructData{"name": types.String("alice")}))
+	s.NoError(err)
+
+	dsName := sp.String()
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+
+	_, _ = s.MustRun(main, []string{"ds", "--schema", dsName, "Struct Person { name: String }"})
+	stdout, _ := s.MustRun(main, []string{"ds", "--schema", dsName})
+	s.Equal("Struct Person {\n  name: String,\n}\n", stdout)
+
+	// The schemas dataset isn't listed.
+	stdout, _ = s.MustRun(main, []string{"ds", dbSpec})
+	s.Equal("people\n", stdout)
+
+	// The current head doesn't conform to this schema.
+	_, _, recoveredErr := s.Run(main, []string{"ds", "--schema", dsName, "Number"})
+	s.NotNil(recoveredErr)
+
+	stdout, _ = s.MustRun(main, []string{"ds", "--rm-schema", dsName})
+	s.Equal("Removed schema of "+dsName+"\n", stdout)
+	_, _, recoveredErr = s.Run(main, []string{"ds", "--schema", dsName})
+	s.NotNil(recoveredErr)
+}
+
+func (s *nomsDsTestSuite) TestNomsDsDeleteExpect() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "ds"))
+	s.NoError(err)
+	defer sp.Close()
+	ds, err := sp.GetDatabase().CommitValue(sp.GetDataset(), types.String("a"))
+	s.NoError(err)
+	first := ds.HeadRef().TargetHash().String()
+	ds, err = sp.GetDatabase().CommitValue(ds, types.String("b"))
+	s.NoError(err)
+	second := ds.HeadRef().TargetHash().String()
+
+	_, _, recoveredErr := s.Run(main, []string{"ds", "-d", sp.String(), "--expect", first})
+	s.NotNil(recoveredErr)
+
+	stdout, _ := s.MustRun(main, []string{"ds", "-d", sp.String(), "--expect", "#" + second})
+	s.Equal("Deleted "+sp.String()+" (was #"+second+")\n", stdout)
+}
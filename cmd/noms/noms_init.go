@@ -0,0 +1,48 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	humanize "github.com/dustin/go-humanize"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+func nomsInit(noms *kingpin.Application) (*kingpin.CmdClause, util.KingpinHandler) {
+	initCmd := noms.Command("init", `Records the chunking parameters of a database
+Every writer of a database must chunk values the same way. This records the target chunk size, and rolling hash window, that all writers of the database will use. It must be run before anything is committed to a database that should use non-default chunking.
+`)
+	chunkSize := initCmd.Flag("chunk-size", "average size in bytes of the chunks values are split into; must be a power of two").Default(strconv.Itoa(int(types.DefaultChunkConfig.TargetSize))).Uint32()
+	chunkWindow := initCmd.Flag("chunk-window", "number of bytes in the rolling hash window used to find chunk boundaries").Default(strconv.Itoa(int(types.DefaultChunkConfig.Window))).Uint32()
+	database := addDatabaseArg(initCmd)
+
+	return initCmd, func(input string) int {
+		return runInit(*database, types.ChunkConfig{TargetSize: *chunkSize, Window: *chunkWindow})
+	}
+}
+
+func runInit(dbSpec string, chunkConfig types.ChunkConfig) int {
+	cfg := config.NewResolver()
+	db, err := cfg.GetDatabase(dbSpec)
+	d.CheckErrorNoUsage(err)
+	defer db.Close()
+
+	err = db.InitChunkConfig(chunkConfig)
+	if err == datas.ErrChunkConfigMismatch {
+		current := db.ChunkConfig()
+		err = fmt.Errorf("%s already uses %s chunks with a %d byte window", dbSpec, humanize.IBytes(uint64(current.TargetSize)), current.Window)
+	}
+	d.CheckErrorNoUsage(err)
+
+	fmt.Printf("Initialized %s with %s chunks and a %d byte window\n", dbSpec, humanize.IBytes(uint64(chunkConfig.TargetSize)), chunkConfig.Window)
+	return 0
+}
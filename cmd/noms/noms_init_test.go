@@ -0,0 +1,41 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestNomsInit(t *testing.T) {
+	suite.Run(t, &nomsInitTestSuite{})
+}
+
+type nomsInitTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsInitTestSuite) TestInit() {
+	dbSpec := spec.CreateDatabaseSpecString("nbs", s.DBDir)
+	stdout, _ := s.MustRun(main, []string{"init", "--chunk-size", "65536", dbSpec})
+	s.Equal("Initialized "+dbSpec+" with 64 KiB chunks and a 64 byte window\n", stdout)
+
+	sp, err := spec.ForDatabase(dbSpec)
+	s.NoError(err)
+	defer sp.Close()
+	s.Equal(types.ChunkConfig{TargetSize: 1 << 16, Window: 64}, sp.GetDatabase().ChunkConfig())
+
+	// Reserved datasets aren't listed.
+	stdout, _ = s.MustRun(main, []string{"ds", dbSpec})
+	s.Equal("", stdout)
+
+	_, stderr, recoveredErr := s.Run(main, []string{"init", dbSpec})
+	s.Equal(clienttest.ExitError{Code: 1}, recoveredErr)
+	s.Equal("error: "+dbSpec+" already uses 64 KiB chunks with a 64 byte window\n", stderr)
+}
@@ -24,6 +24,7 @@ import (
 	"github.com/attic-labs/noms/go/util/datetime"
 	"github.com/attic-labs/noms/go/util/functions"
 	"github.com/attic-labs/noms/go/util/outputpager"
+	"github.com/attic-labs/noms/go/util/signing"
 	"github.com/attic-labs/noms/go/util/verbose"
 	"github.com/attic-labs/noms/go/util/writers"
 	flag "github.com/juju/gnuflag"
@@ -38,6 +39,7 @@ var (
 	oneline    bool
 	showGraph  bool
 	showValue  bool
+	verifySigs bool
 )
 
 const parallelism = 16
@@ -46,7 +48,7 @@ var nomsLog = &util.Command{
 	Run:       runLog,
 	UsageLine: "log [options] <path-spec>",
 	Short:     "Displays the history of a path",
-	Long:      "Displays the history of a path. See Spelling Values at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the <path-spec> parameter.",
+	Long:      "Displays the history of a path. With --include or --exclude, only the commits that changed matching paths under it are shown, e.g. --exclude .meta --include '.rows[*].price'. See Spelling Values at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the <path-spec> parameter.",
 	Flags:     setupLogFlags,
 	Nargs:     1,
 }
@@ -59,7 +61,9 @@ func setupLogFlags() *flag.FlagSet {
 	logFlagSet.BoolVar(&oneline, "oneline", false, "show a summary of each commit on a single line")
 	logFlagSet.BoolVar(&showGraph, "graph", false, "show ascii-based commit hierarchy on left side of output")
 	logFlagSet.BoolVar(&showValue, "show-value", false, "show commit value rather than diff information")
+	logFlagSet.BoolVar(&verifySigs, "verify", false, "show whether each commit is signed by a key in the [trusted] section of .nomsconfig")
 	logFlagSet.StringVar(&tzName, "tz", "local", "display formatted date comments in specified timezone, must be: local or utc")
+	registerDiffFilterFlags(logFlagSet)
 	outputpager.RegisterOutputpagerFlags(logFlagSet)
 	verbose.RegisterVerboseFlags(logFlagSet)
 	return logFlagSet
@@ -69,6 +73,11 @@ func runLog(args []string) int {
 	useColor = shouldUseColor()
 	cfg := config.NewResolver()
 
+	filter, err := diffFilterOptions()
+	d.CheckError(err)
+	filter.LeftRight = true
+	filtered := len(filter.Include) > 0 || len(filter.Exclude) > 0
+
 	tz, _ := locationFromTimezoneArg(tzName, nil)
 	datetime.RegisterHRSCommenter(tz)
 
@@ -96,6 +105,12 @@ func runLog(args []string) int {
 		d.CheckError(fmt.Errorf("%s does not reference a Commit object", args[0]))
 	}
 
+	var trusted signing.KeySet
+	if verifySigs {
+		trusted, err = cfg.TrustedKeys()
+		d.CheckErrorNoUsage(err)
+	}
+
 	iter := NewCommitIterator(database, origCommit)
 	displayed := 0
 	if maxCommits <= 0 {
@@ -108,12 +123,15 @@ func runLog(args []string) int {
 
 	go func() {
 		for ln, ok := iter.Next(); !done && ok && displayed < maxCommits; ln, ok = iter.Next() {
+			if filtered && !commitChanged(ln.commit, path, database, filter) {
+				continue
+			}
 			ch := make(chan []byte)
 			bytesChan <- ch
 
 			go func(ch chan []byte, node LogNode) {
 				buff := &bytes.Buffer{}
-				printCommit(node, path, buff, database, tz)
+				printCommit(node, path, buff, database, tz, trusted, filter)
 				ch <- buff.Bytes()
 			}(ch, ln)
 
@@ -140,8 +158,8 @@ func runLog(args []string) int {
 }
 
 // Prints the information for one commit in the log, including ascii graph on left side of commits if
-// -graph arg is true.
-func printCommit(node LogNode, path types.Path, w io.Writer, db datas.Database, tz *time.Location) (err error) {
+// -graph arg is true. If trusted isn't nil, the commit's signature is verified against it.
+func printCommit(node LogNode, path types.Path, w io.Writer, db datas.Database, tz *time.Location, trusted signing.KeySet, filter diff.Options) (err error) {
 	maxMetaFieldNameLength := func(commit types.Struct) int {
 		maxLen := 0
 		if m, ok := commit.MaybeGet(datas.MetaField); ok {
@@ -176,15 +194,25 @@ func printCommit(node LogNode, path types.Path, w io.Writer, db datas.Database,
 
 	if oneline {
 		parentStr := fmt.Sprintf("%s %s", parentLabel+":", parentValue)
+		if trusted != nil {
+			parentStr += fmt.Sprintf(", %s %s", signatureLabel+":", signatureStatus(node.commit, trusted))
+		}
 		fmt.Fprintf(w, "%s (%s)\n", hashStr, parentStr)
 		return
 	}
 
 	maxFieldNameLen = max(maxFieldNameLen, len(parentLabel))
+	if trusted != nil {
+		maxFieldNameLen = max(maxFieldNameLen, len(signatureLabel))
+	}
 	parentStr := fmt.Sprintf("%-*s %s", maxFieldNameLen+1, parentLabel+":", parentValue)
 	fmt.Fprintf(w, "%s%s\n", genGraph(node, 0), hashStr)
 	fmt.Fprintf(w, "%s%s\n", genGraph(node, 1), parentStr)
 	lineno := 1
+	if trusted != nil {
+		fmt.Fprintf(w, "%s%-*s %s\n", genGraph(node, 2), maxFieldNameLen+1, signatureLabel+":", signatureStatus(node.commit, trusted))
+		lineno++
+	}
 
 	if maxLines != 0 {
 		lineno, err = writeMetaLines(node, maxLines, lineno, maxFieldNameLen, w, tz)
@@ -196,7 +224,7 @@ func printCommit(node LogNode, path types.Path, w io.Writer, db datas.Database,
 		if showValue {
 			_, err = writeCommitLines(node, path, maxLines, lineno, w, db)
 		} else {
-			_, err = writeDiffLines(node, path, db, maxLines, lineno, w)
+			_, err = writeDiffLines(node, path, db, maxLines, lineno, w, filter)
 		}
 	}
 	return
@@ -313,7 +341,7 @@ func writeCommitLines(node LogNode, path types.Path, maxLines, lineno int, w io.
 	return int(pw.NumLines), err
 }
 
-func writeDiffLines(node LogNode, path types.Path, db datas.Database, maxLines, lineno int, w io.Writer) (lineCnt int, err error) {
+func writeDiffLines(node LogNode, path types.Path, db datas.Database, maxLines, lineno int, w io.Writer, filter diff.Options) (lineCnt int, err error) {
 	genPrefix := func(w *writers.PrefixWriter) []byte {
 		return []byte(genGraph(node, int(w.NumLines)+1))
 	}
@@ -346,7 +374,7 @@ func writeDiffLines(node LogNode, path types.Path, db datas.Database, maxLines,
 	}
 
 	if old != nil && neu != nil {
-		err = diff.PrintDiff(pw, old, neu, true)
+		err = diff.PrintDiffWithOptions(pw, old, neu, filter)
 		mlw.MaxLines = 0
 		if err != nil {
 			d.PanicIfNotType(err, writers.MaxLinesErr)
@@ -362,6 +390,58 @@ func writeDiffLines(node LogNode, path types.Path, db datas.Database, maxLines,
 	return int(pw.NumLines), err
 }
 
+// commitChanged returns whether the value at path in commit has a Difference
+// that passes filter from the value at path in each of its parents, so that,
+// like git log, merges which take the value from one of their parents don't
+// count. Initial commits always count as changes.
+func commitChanged(commit types.Struct, path types.Path, db datas.Database, filter diff.Options) bool {
+	neu := path.Resolve(commit, db)
+	changed := true
+	commit.Get(datas.ParentsField).(types.Set).Iter(func(v types.Value) bool {
+		parentCommit := v.(types.Ref).TargetValue(db).(types.Struct)
+		changed = valueChanged(path.Resolve(parentCommit, db), neu, filter)
+		return !changed
+	})
+	return changed
+}
+
+// valueChanged returns whether there's a Difference from old to neu that
+// passes filter.
+func valueChanged(old, neu types.Value, filter diff.Options) bool {
+	if old == nil || neu == nil {
+		return old != nil || neu != nil
+	}
+
+	dChan := make(chan diff.Difference)
+	stopChan := make(chan struct{})
+	go func() {
+		diff.DiffWithOptions(old, neu, dChan, stopChan, filter)
+		close(dChan)
+	}()
+	_, changed := <-dChan
+	close(stopChan)
+	for range dChan {
+	}
+	return changed
+}
+
+const signatureLabel = "Verified"
+
+// signatureStatus describes whether commit is signed by a key in trusted.
+func signatureStatus(commit types.Struct, trusted signing.KeySet) string {
+	key, err := datas.VerifyCommit(commit)
+	switch {
+	case err == datas.ErrUnsigned:
+		return "no (unsigned)"
+	case err != nil:
+		return "no (bad signature)"
+	}
+	if name, ok := trusted.Name(key); ok {
+		return fmt.Sprintf("yes (signed by %s)", name)
+	}
+	return fmt.Sprintf("no (signed by untrusted key %s)", signing.FormatKey(key))
+}
+
 func shouldUseColor() bool {
 	if color != 1 && color != 0 {
 		return outputpager.IsStdoutTty()
@@ -5,6 +5,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/attic-labs/noms/go/datas"
@@ -73,6 +74,47 @@ func (s *nomsLogTestSuite) TestNomsLogPath() {
 	test.EqualsIgnoreHashes(s.T(), pathDiff, stdout)
 }
 
+func (s *nomsLogTestSuite) TestNomsLogFilters() {
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "dsFilterTest"))
+	s.NoError(err)
+	defer sp.Close()
+
+	db := sp.GetDatabase()
+	ds := sp.GetDataset()
+	for _, v := range [][2]int{{1, 1}, {2, 1}, {2, 2}, {3, 3}} {
+		ds, err = db.CommitValue(ds, types.NewStruct("", types.StructData{
+			"date":  types.Number(v[0]),
+			"price": types.Number(v[1]),
+		}))
+		s.NoError(err)
+	}
+
+	countCommits := func(args ...string) int {
+		stdout, stderr := s.MustRun(main, append(append([]string{"log", "--oneline"}, args...), sp.String()))
+		s.Empty(stderr)
+		return strings.Count(stdout, "\n")
+	}
+	s.Equal(4, countCommits())
+	s.Equal(3, countCommits("--include", ".price"))
+	s.Equal(3, countCommits("--exclude", ".date"))
+	s.Equal(3, countCommits("--include", ".*", "--exclude", ".price"))
+
+	stdout, _ := s.MustRun(main, []string{"log", "--exclude", ".date", sp.String()})
+	s.NotContains(stdout, "date")
+	s.Contains(stdout, "price")
+
+	// A merge that takes the price from one of its parents doesn't change it.
+	branch, err := db.Commit(db.GetDataset("dsFilterBranch"), types.NewStruct("", types.StructData{
+		"date":  types.Number(3),
+		"price": types.Number(9),
+	}), datas.CommitOptions{Parents: types.NewSet(db, ds.HeadRef())})
+	s.NoError(err)
+	ds, err = db.Commit(ds, branch.HeadValue(), datas.CommitOptions{Parents: types.NewSet(db, ds.HeadRef(), branch.HeadRef())})
+	s.NoError(err)
+	s.Equal(6, countCommits())
+	s.Equal(4, countCommits("--include", ".price"))
+}
+
 func addCommit(ds datas.Dataset, v string) (datas.Dataset, error) {
 	return ds.Database().CommitValue(ds, types.String(v))
 }
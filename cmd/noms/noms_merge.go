@@ -5,10 +5,15 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"os/signal"
 	"regexp"
+	"strings"
+	"time"
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
@@ -16,19 +21,29 @@ import (
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/merge"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/jsontonoms"
 	"github.com/attic-labs/noms/go/util/status"
 	"github.com/attic-labs/noms/go/util/verbose"
 	flag "github.com/juju/gnuflag"
 )
 
 var (
-	resolver string
+	resolver      string
+	allConflicts  bool
+	mergeStatus   bool
+	mergeContinue bool
+	mergeAbort    bool
+	resolveOurs   bool
+	resolveTheirs bool
+	resolveValue  string
+	policyFile    string
+	mergeInto     string
 
 	nomsMerge = &util.Command{
 		Run:       runMerge,
-		UsageLine: "merge [options] <database> <left-dataset-name> <right-dataset-name> <output-dataset-name>",
+		UsageLine: "merge [options] <database> <left-dataset-name> <right-dataset-name> <output-dataset-name>\n       merge [options] <database> <dataset-name>... --into <output-dataset-name>\n       merge --status|--continue|--abort <database>\n       merge resolve --ours|--theirs|--value <json> <database> <path>",
 		Short:     "Merges and commits the head values of two named datasets",
-		Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.\nYu must provide a working database and the names of two Datasets you want to merge. The values at the heads of these Datasets will be merged, put into a new Commit object, and set as the Head of the third provided Dataset name.",
+		Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.\nYu must provide a working database and the names of two Datasets you want to merge. The values at the heads of these Datasets will be merged, put into a new Commit object, and set as the Head of the third provided Dataset name.\nWith --into, any number of Datasets are merged into the Dataset it names, in a single Commit with all of their heads as parents.\nWith --all-conflicts or --policy p, a merge that has conflicts is kept in the database until it's finished. Resolve each conflict with 'merge resolve', then commit the merge with --continue, or drop it with --abort. --status shows the conflicts that remain.",
 		Flags:     setupMergeFlags,
 		Nargs:     1, // if absolute-path not present we read it from stdin
 	}
@@ -37,7 +52,16 @@ var (
 
 func setupMergeFlags() *flag.FlagSet {
 	commitFlagSet := flag.NewFlagSet("merge", flag.ExitOnError)
-	commitFlagSet.StringVar(&resolver, "policy", "n", "conflict resolution policy for merging. Defaults to 'n', which means no resolution strategy will be applied. Supported values are 'l' (left), 'r' (right) and 'p' (prompt). 'prompt' will bring up a simple command-line prompt allowing you to resolve conflicts by choosing between 'l' or 'r' on a case-by-case basis.")
+	commitFlagSet.StringVar(&resolver, "policy", "n", "conflict resolution policy for merging. Defaults to 'n', which means no resolution strategy will be applied. Supported values are 'l' (left), 'r' (right) and 'p' (prompt). 'prompt' will bring up a simple command-line prompt allowing you to resolve conflicts by choosing between 'l' or 'r' on a case-by-case basis. Conflicts that aren't resolved at the prompt, e.g. because it's interrupted, are kept as with --all-conflicts.")
+	commitFlagSet.StringVar(&policyFile, "policy-file", "", "JSON file listing {\"path\": <pattern>, \"resolve\": <resolver>} rules, tried in order before --policy on conflicts at matching paths. Patterns are paths where .* and [*] match any field or index. Resolvers are counter, max, min, concat, lww (last writer wins, by commit date), left and right.")
+	commitFlagSet.StringVar(&mergeInto, "into", "", "the dataset to commit the merge to, when every other argument after the database is a dataset to merge")
+	commitFlagSet.BoolVar(&allConflicts, "all-conflicts", false, "keep merging after a conflict the policy can't resolve, and report all of them instead of only the first")
+	commitFlagSet.BoolVar(&mergeStatus, "status", false, "show the conflicts that remain in the merge in progress")
+	commitFlagSet.BoolVar(&mergeContinue, "continue", false, "commit the merge in progress, once all its conflicts are resolved")
+	commitFlagSet.BoolVar(&mergeAbort, "abort", false, "drop the merge in progress")
+	commitFlagSet.BoolVar(&resolveOurs, "ours", false, "with resolve, take the left value")
+	commitFlagSet.BoolVar(&resolveTheirs, "theirs", false, "with resolve, take the right value")
+	commitFlagSet.StringVar(&resolveValue, "value", "", "with resolve, take this JSON value; objects become maps")
 	verbose.RegisterVerboseFlags(commitFlagSet)
 	return commitFlagSet
 }
@@ -51,6 +75,19 @@ func checkIfTrue(b bool, format string, args ...interface{}) {
 func runMerge(args []string) int {
 	cfg := config.NewResolver()
 
+	if len(args) > 0 && args[0] == "resolve" {
+		return runMergeResolve(cfg, args[1:])
+	}
+	if mergeStatus || mergeContinue || mergeAbort {
+		return runMergeInProgress(cfg, args)
+	}
+	if mergeInto != "" {
+		checkIfTrue(len(args) < 3, "Incorrect number of arguments")
+		if len(args) > 3 {
+			return runOctopusMerge(cfg, args[0], args[1:], mergeInto)
+		}
+		args = append(args[:3:3], mergeInto)
+	}
 	if len(args) != 4 {
 		d.CheckErrorNoUsage(fmt.Errorf("Incorrect number of arguments"))
 	}
@@ -58,37 +95,188 @@ func runMerge(args []string) int {
 	d.CheckError(err)
 	defer db.Close()
 
+	checkNoMergeInProgress(db)
 	leftDS, rightDS, outDS := resolveDatasets(db, args[1], args[2], args[3])
-	left, right, ancestor := getMergeCandidates(db, leftDS, rightDS)
-	policy := decidePolicy(resolver)
+	left, right, ancestor, ancestorRef := getMergeCandidates(db, leftDS, rightDS)
+	leftDate, _ := datas.CommitDate(leftDS.Head())
+	rightDate, _ := datas.CommitDate(rightDS.Head())
+	policy := decidePolicy(resolver, policyFileResolver(leftDate, rightDate), allConflicts || isPromptPolicy(resolver))
 	pc := newMergeProgressChan()
 	merged, err := policy(left, right, ancestor, db, pc)
+	close(pc)
+	if mc, ok := err.(*merge.ErrMergeConflicts); ok {
+		writeConflictReport(os.Stdout, mc.Conflicts)
+		st := datas.MergeState{
+			Output:    outDS.ID(),
+			Left:      leftDS.HeadRef(),
+			Right:     rightDS.HeadRef(),
+			Base:      ancestorRef,
+			Merged:    merged,
+			Conflicts: mc.Conflicts,
+		}
+		if r, ok := outDS.MaybeHeadRef(); ok {
+			st.OutputHead = r.TargetHash()
+		}
+		d.CheckErrorNoUsage(datas.SaveMergeState(db, st))
+		fmt.Println("Resolve them with 'noms merge resolve', then run 'noms merge --continue'")
+		return 1
+	}
 	d.CheckErrorNoUsage(err)
+
+	commitMerge(db, outDS, merged, leftDS.HeadRef(), rightDS.HeadRef())
+	return 0
+}
+
+// runOctopusMerge merges the heads of all of dsNames, and commits the result
+// to outName with all of them as parents.
+func runOctopusMerge(cfg *config.Resolver, dbSpec string, dsNames []string, outName string) int {
+	checkIfTrue(allConflicts, "--all-conflicts can only be used to merge two datasets")
+	db, err := cfg.GetDatabase(dbSpec)
+	d.CheckError(err)
+	defer db.Close()
+
+	checkNoMergeInProgress(db)
+	outDS := resolveDataset(db, outName)
+	heads := make([]types.Ref, len(dsNames))
+	parents := make([]types.Value, len(dsNames))
+	values := make([]types.Value, len(dsNames))
+	for i, name := range dsNames {
+		ds := resolveDataset(db, name)
+		r, ok := ds.MaybeHeadRef()
+		checkIfTrue(!ok, "Dataset %s has no data", name)
+		heads[i], parents[i], values[i] = r, r, ds.HeadValue()
+	}
+	ancestorRef, ok := datas.FindCommonAncestorN(heads, db)
+	checkIfTrue(!ok, "Datasets %s have no common ancestor", strings.Join(dsNames, ", "))
+	ancestor := ancestorRef.TargetValue(db).(types.Struct).Get(datas.ValueField)
+
+	// Which head was committed last isn't meaningful for the intermediate
+	// merges, so "lww" doesn't resolve anything here.
+	policy := decidePolicy(resolver, policyFileResolver(time.Time{}, time.Time{}), false)
+	pc := newMergeProgressChan()
+	merged, err := merge.NWay(values, ancestor, db, policy, pc)
 	close(pc)
+	d.CheckErrorNoUsage(err)
+
+	commitMerge(db, outDS, merged, parents...)
+	return 0
+}
 
-	_, err = db.SetHead(outDS, db.WriteValue(datas.NewCommit(merged, types.NewSet(db, leftDS.HeadRef(), rightDS.HeadRef()), types.EmptyStruct)))
+func checkNoMergeInProgress(db datas.Database) {
+	if _, ok, _ := datas.LoadMergeState(db); ok {
+		d.CheckErrorNoUsage(fmt.Errorf("A merge is already in progress, finish it with --continue or --abort"))
+	}
+}
+
+func commitMerge(db datas.Database, outDS datas.Dataset, merged types.Value, parents ...types.Value) {
+	_, err := db.SetHead(outDS, db.WriteValue(datas.NewCommit(merged, types.NewSet(db, parents...), types.EmptyStruct)))
 	d.PanicIfError(err)
+	printMergeDone()
+}
+
+func printMergeDone() {
 	if !verbose.Quiet() {
 		status.Printf("Done")
 		status.Done()
 	}
+}
+
+// runMergeInProgress handles --status, --continue and --abort of the merge
+// saved in the database by a merge with conflicts.
+func runMergeInProgress(cfg *config.Resolver, args []string) int {
+	flags := 0
+	for _, f := range []bool{mergeStatus, mergeContinue, mergeAbort} {
+		if f {
+			flags++
+		}
+	}
+	checkIfTrue(flags > 1, "Only one of --status, --continue and --abort may be given")
+	checkIfTrue(len(args) != 1, "Incorrect number of arguments")
+	db, err := cfg.GetDatabase(args[0])
+	d.CheckError(err)
+	defer db.Close()
+
+	st, ok, err := datas.LoadMergeState(db)
+	checkIfTrue(!ok, "No merge in progress")
+	switch {
+	case mergeAbort:
+		d.CheckErrorNoUsage(datas.ClearMergeState(db))
+		fmt.Printf("Aborted merge into %s\n", st.Output)
+		return 0
+	case mergeStatus:
+		d.CheckErrorNoUsage(err)
+		fmt.Printf("Merging into %s\nLeft:  %s\nRight: %s\nBase:  %s\n\n", st.Output, st.Left.TargetHash(), st.Right.TargetHash(), st.Base.TargetHash())
+		writeConflictReport(os.Stdout, st.Conflicts)
+		return 0
+	}
+	d.CheckErrorNoUsage(err)
+	checkIfTrue(len(st.Conflicts) > 0, "%d conflicts remain unresolved", len(st.Conflicts))
+	_, err = datas.CommitMerge(db, st)
+	if e, ok := err.(*datas.ErrHeadMoved); ok {
+		d.CheckErrorNoUsage(fmt.Errorf("%s was committed to during the merge (%s), run 'noms merge --abort' and merge again", st.Output, e))
+	}
+	d.CheckErrorNoUsage(err)
+	printMergeDone()
 	return 0
 }
 
-func resolveDatasets(db datas.Database, leftName, rightName, outName string) (leftDS, rightDS, outDS datas.Dataset) {
-	makeDS := func(dsName string) datas.Dataset {
-		if !datasetRe.MatchString(dsName) {
-			d.CheckErrorNoUsage(fmt.Errorf("Invalid dataset %s, must match %s", dsName, datas.DatasetRe.String()))
+// runMergeResolve resolves one conflict of the merge in progress.
+func runMergeResolve(cfg *config.Resolver, args []string) int {
+	checkIfTrue(len(args) != 2, "Incorrect number of arguments")
+	choices := 0
+	for _, f := range []bool{resolveOurs, resolveTheirs, resolveValue != ""} {
+		if f {
+			choices++
 		}
-		return db.GetDataset(dsName)
 	}
-	leftDS = makeDS(leftName)
-	rightDS = makeDS(rightName)
-	outDS = makeDS(outName)
+	checkIfTrue(choices != 1, "Exactly one of --ours, --theirs and --value must be given")
+	db, err := cfg.GetDatabase(args[0])
+	d.CheckError(err)
+	defer db.Close()
+
+	st, ok, err := datas.LoadMergeState(db)
+	checkIfTrue(!ok, "No merge in progress")
+	d.CheckErrorNoUsage(err)
+
+	var path types.Path
+	if args[1] != "<root>" {
+		path, err = types.ParsePath(args[1])
+		d.CheckErrorNoUsage(err)
+	}
+	c, ok := st.Conflict(path)
+	checkIfTrue(!ok, "No conflict at %s", args[1])
+	var v types.Value
+	switch {
+	case resolveOurs:
+		v = c.Ours
+	case resolveTheirs:
+		v = c.Theirs
+	default:
+		var o interface{}
+		d.CheckErrorNoUsage(json.Unmarshal([]byte(resolveValue), &o))
+		v = jsontonoms.NomsValueFromDecodedJSON(db, o, false)
+	}
+	d.CheckErrorNoUsage(st.Resolve(db, path, v))
+	d.CheckErrorNoUsage(datas.SaveMergeState(db, st))
+	fmt.Printf("Resolved %s, %d conflicts remain\n", args[1], len(st.Conflicts))
+	return 0
+}
+
+func resolveDatasets(db datas.Database, leftName, rightName, outName string) (leftDS, rightDS, outDS datas.Dataset) {
+	leftDS = resolveDataset(db, leftName)
+	rightDS = resolveDataset(db, rightName)
+	outDS = resolveDataset(db, outName)
 	return
 }
 
-func getMergeCandidates(db datas.Database, leftDS, rightDS datas.Dataset) (left, right, ancestor types.Value) {
+func resolveDataset(db datas.Database, dsName string) datas.Dataset {
+	if !datasetRe.MatchString(dsName) {
+		d.CheckErrorNoUsage(fmt.Errorf("Invalid dataset %s, must match %s", dsName, datas.DatasetRe.String()))
+	}
+	return db.GetDataset(dsName)
+}
+
+func getMergeCandidates(db datas.Database, leftDS, rightDS datas.Dataset) (left, right, ancestor types.Value, ancestorRef types.Ref) {
 	leftRef, ok := leftDS.MaybeHeadRef()
 	checkIfTrue(!ok, "Dataset %s has no data", leftDS.ID())
 	rightRef, ok := rightDS.MaybeHeadRef()
@@ -96,7 +284,7 @@ func getMergeCandidates(db datas.Database, leftDS, rightDS datas.Dataset) (left,
 	ancestorCommit, ok := getCommonAncestor(leftRef, rightRef, db)
 	checkIfTrue(!ok, "Datasets %s and %s have no common ancestor", leftDS.ID(), rightDS.ID())
 
-	return leftDS.HeadValue(), rightDS.HeadValue(), ancestorCommit.Get(datas.ValueField)
+	return leftDS.HeadValue(), rightDS.HeadValue(), ancestorCommit.Get(datas.ValueField), types.NewRef(ancestorCommit)
 }
 
 func getCommonAncestor(r1, r2 types.Ref, vr types.ValueReader) (a types.Struct, found bool) {
@@ -128,7 +316,10 @@ func newMergeProgressChan() chan struct{} {
 	return pc
 }
 
-func decidePolicy(policy string) merge.Policy {
+// decidePolicy returns the merge.Policy for the --policy flag and the
+// resolvers in the --policy-file. If collect is true, it collects all the
+// conflicts that they don't resolve, rather than stopping at the first.
+func decidePolicy(policy string, semantic merge.SemanticResolveFunc, collect bool) merge.Policy {
 	var resolve merge.ResolveFunc
 	switch policy {
 	case "n", "N":
@@ -138,13 +329,115 @@ func decidePolicy(policy string) merge.Policy {
 	case "r", "R":
 		resolve = merge.Theirs
 	case "p", "P":
+		in := promptInput()
 		resolve = func(aType, bType types.DiffChangeType, a, b types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
-			return cliResolve(os.Stdin, os.Stdout, aType, bType, a, b, path)
+			return cliResolve(in, os.Stdout, aType, bType, a, b, path)
 		}
 	default:
 		d.CheckErrorNoUsage(fmt.Errorf("Unsupported merge policy: %s. Choices are n, l, r and a.", policy))
 	}
-	return merge.NewThreeWay(resolve)
+	if collect {
+		return merge.NewSemanticThreeWayCollect(semantic, resolve)
+	}
+	return merge.NewSemanticThreeWay(semantic, resolve)
+}
+
+func isPromptPolicy(policy string) bool {
+	return policy == "p" || policy == "P"
+}
+
+// promptInput returns a reader of stdin for --policy p, which ends if the
+// merge is interrupted. The conflicts that are left are then collected, so
+// the merge is kept with the choices made so far instead of being lost. A
+// second interrupt exits as usual.
+func promptInput() io.Reader {
+	r, w := io.Pipe()
+	go func() {
+		_, err := io.Copy(w, os.Stdin)
+		w.CloseWithError(err)
+	}()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+		w.Close()
+	}()
+	return r
+}
+
+// policyFileResolver returns the resolver for the rules in --policy-file, if
+// there is one. See readPolicyFile().
+func policyFileResolver(leftDate, rightDate time.Time) merge.SemanticResolveFunc {
+	if policyFile == "" {
+		return nil
+	}
+	resolvers, err := readPolicyFile(policyFile, leftDate, rightDate)
+	d.CheckErrorNoUsage(err)
+	return resolvers.Resolve
+}
+
+// policyRule is an entry of a merge policy file: conflicts at paths that
+// match Path are resolved with the resolver named by Resolve.
+type policyRule struct {
+	Path    string `json:"path"`
+	Resolve string `json:"resolve"`
+}
+
+// readPolicyFile reads a merge policy file: a JSON list of policyRules, e.g.
+// [{"path": ".counts[*]", "resolve": "counter"}]. Rules are tried in order. leftDate and rightDate are when the left and
+// right heads were committed, for "lww".
+func readPolicyFile(path string, leftDate, rightDate time.Time) (merge.PathResolvers, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rules []policyRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("Invalid policy file %s: %s", path, err)
+	}
+	resolvers := map[string]merge.SemanticResolveFunc{
+		"counter": merge.Counter,
+		"max":     merge.Max,
+		"min":     merge.Min,
+		"concat":  merge.Concat,
+		"lww":     merge.LastWriterWins(leftDate, rightDate),
+		"left":    merge.Semantic(merge.Ours),
+		"right":   merge.Semantic(merge.Theirs),
+	}
+	prs := make(merge.PathResolvers, len(rules))
+	for i, rule := range rules {
+		if prs[i].Pattern, err = types.ParsePathPattern(rule.Path); err != nil {
+			return nil, err
+		}
+		var ok bool
+		if prs[i].Resolve, ok = resolvers[rule.Resolve]; !ok {
+			return nil, fmt.Errorf("Unsupported resolver %s at %s. Choices are counter, max, min, concat, lww, left and right.", rule.Resolve, rule.Path)
+		}
+	}
+	return prs, nil
+}
+
+// writeConflictReport describes each of conflicts: its path, and the base,
+// left and right values there.
+func writeConflictReport(out io.Writer, conflicts []merge.Conflict) {
+	describe := func(v types.Value) string {
+		if v == nil {
+			return "<none>"
+		}
+		return types.EncodedValueMaxLines(v, 10)
+	}
+	for _, c := range conflicts {
+		path := c.Path.String()
+		if path == "" {
+			path = "<root>"
+		}
+		fmt.Fprintf(out, "Conflict at: %s\n", path)
+		fmt.Fprintf(out, "Base:  %s\n", describe(c.Base))
+		fmt.Fprintf(out, "Left:  %s %s\n", merge.DescribeChangeType(c.OursChange), describe(c.Ours))
+		fmt.Fprintf(out, "Right: %s %s\n\n", merge.DescribeChangeType(c.TheirsChange), describe(c.Theirs))
+	}
+	fmt.Fprintf(out, "%d conflicts\n", len(conflicts))
 }
 
 func cliResolve(in io.Reader, out io.Writer, aType, bType types.DiffChangeType, a, b types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
@@ -167,8 +460,10 @@ func cliResolve(in io.Reader, out io.Writer, aType, bType types.DiffChangeType,
 	var choice rune
 	for {
 		fmt.Fprintln(out, "Enter 'l' to accept the left value, 'r' to accept the right value")
-		_, err := fmt.Fscanf(in, "%c\n", &choice)
-		d.PanicIfError(err)
+		if _, err := fmt.Fscanf(in, "%c\n", &choice); err != nil {
+			// The input ended, so the conflict is left unresolved.
+			return change, merged, false
+		}
 		switch choice {
 		case 'l', 'L':
 			return aType, a, true
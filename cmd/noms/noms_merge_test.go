@@ -180,6 +180,240 @@ func (s *nomsMergeTestSuite) TestNomsMerge_Conflict() {
 	s.Panics(func() { s.MustRun(main, []string{"merge", s.DBDir, left, right, "output"}) })
 }
 
+func (s *nomsMergeTestSuite) TestNomsMerge_AllConflicts() {
+	left, right := "left", "right"
+	parentSpec := s.spec("parent")
+	defer parentSpec.Close()
+	leftSpec := s.spec(left)
+	defer leftSpec.Close()
+	rightSpec := s.spec(right)
+	defer rightSpec.Close()
+	p := s.setupMergeDataset(parentSpec, types.StructData{"num": types.Number(42), "str": types.String("a"), "ok": types.Bool(false)}, types.NewSet(parentSpec.GetDatabase()))
+	l := s.setupMergeDataset(leftSpec, types.StructData{"num": types.Number(43), "str": types.String("b"), "ok": types.Bool(true)}, types.NewSet(leftSpec.GetDatabase(), p))
+	r := s.setupMergeDataset(rightSpec, types.StructData{"num": types.Number(44), "str": types.String("c"), "ok": types.Bool(false)}, types.NewSet(rightSpec.GetDatabase(), p))
+
+	stdout, _, err := s.Run(main, []string{"merge", "--all-conflicts", s.DBDir, left, right, "output"})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	s.Contains(stdout, "Conflict at: .num\nBase:  42\nLeft:  modified 43\nRight: modified 44\n")
+	s.Contains(stdout, "Conflict at: .str\n")
+	s.Contains(stdout, "2 conflicts\n")
+
+	sp := s.spec("output")
+	defer sp.Close()
+	st, ok, stErr := datas.LoadMergeState(sp.GetDatabase())
+	s.True(ok)
+	s.NoError(stErr)
+	s.True(types.NewStruct("", types.StructData{"num": types.Number(42), "str": types.String("a"), "ok": types.Bool(true)}).Equals(st.Merged))
+	s.True(l.Equals(st.Left))
+	s.True(r.Equals(st.Right))
+	s.Len(st.Conflicts, 2)
+
+	outSpec := s.spec("output")
+	defer outSpec.Close()
+	s.False(outSpec.GetDataset().HasHead())
+}
+
+func (s *nomsMergeTestSuite) TestNomsMerge_PromptKeepsUnresolvedConflicts() {
+	left, right := "left", "right"
+	parentSpec := s.spec("parent")
+	defer parentSpec.Close()
+	leftSpec := s.spec(left)
+	defer leftSpec.Close()
+	rightSpec := s.spec(right)
+	defer rightSpec.Close()
+	p := s.setupMergeDataset(parentSpec, types.StructData{"num": types.Number(42), "str": types.String("a")}, types.NewSet(parentSpec.GetDatabase()))
+	s.setupMergeDataset(leftSpec, types.StructData{"num": types.Number(43), "str": types.String("b")}, types.NewSet(leftSpec.GetDatabase(), p))
+	s.setupMergeDataset(rightSpec, types.StructData{"num": types.Number(44), "str": types.String("c")}, types.NewSet(rightSpec.GetDatabase(), p))
+
+	oldStdin := os.Stdin
+	newStdin, stdinWriter, err := os.Pipe()
+	s.NoError(err)
+	os.Stdin = newStdin
+	defer func() {
+		os.Stdin = oldStdin
+	}()
+
+	// The input ends after the first choice, as it would if the prompt were interrupted.
+	go func() {
+		stdinWriter.Write([]byte("r\n"))
+		stdinWriter.Close()
+	}()
+	stdout, _, runErr := s.Run(main, []string{"merge", "--policy=p", s.DBDir, left, right, "output"})
+	s.Equal(clienttest.ExitError{Code: 1}, runErr)
+	s.Contains(stdout, "1 conflicts\n")
+
+	sp := s.spec("output")
+	defer sp.Close()
+	st, ok, stErr := datas.LoadMergeState(sp.GetDatabase())
+	s.True(ok)
+	s.NoError(stErr)
+	s.Len(st.Conflicts, 1)
+	s.True(types.Number(44).Equals(st.Merged.(types.Struct).Get("num")))
+	s.False(sp.GetDataset().HasHead())
+}
+
+func (s *nomsMergeTestSuite) TestNomsMerge_Resume() {
+	left, right := "left", "right"
+	parentSpec := s.spec("parent")
+	defer parentSpec.Close()
+	leftSpec := s.spec(left)
+	defer leftSpec.Close()
+	rightSpec := s.spec(right)
+	defer rightSpec.Close()
+	p := s.setupMergeDataset(parentSpec, types.StructData{"num": types.Number(42), "str": types.String("a"), "lst": types.String("x")}, types.NewSet(parentSpec.GetDatabase()))
+	l := s.setupMergeDataset(leftSpec, types.StructData{"num": types.Number(43), "str": types.String("b"), "lst": types.String("y")}, types.NewSet(leftSpec.GetDatabase(), p))
+	r := s.setupMergeDataset(rightSpec, types.StructData{"num": types.Number(44), "str": types.String("c"), "lst": types.String("z")}, types.NewSet(rightSpec.GetDatabase(), p))
+
+	_, _, err := s.Run(main, []string{"merge", "--all-conflicts", s.DBDir, left, right, "output"})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+
+	_, stderr, err := s.Run(main, []string{"merge", "--all-conflicts", s.DBDir, left, right, "output"})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	s.Contains(stderr, "A merge is already in progress")
+
+	stdout, _ := s.MustRun(main, []string{"merge", "--status", s.DBDir})
+	s.Contains(stdout, "Merging into output\n")
+	s.Contains(stdout, "3 conflicts\n")
+
+	stdout, _ = s.MustRun(main, []string{"merge", "resolve", "--ours", s.DBDir, ".num"})
+	s.Equal("Resolved .num, 2 conflicts remain\n", stdout)
+	s.MustRun(main, []string{"merge", "resolve", "--theirs", s.DBDir, ".str"})
+
+	_, stderr, err = s.Run(main, []string{"merge", "--continue", s.DBDir})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	s.Contains(stderr, "1 conflicts remain unresolved")
+	_, stderr, err = s.Run(main, []string{"merge", "resolve", "--ours", s.DBDir, ".num"})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	s.Contains(stderr, "No conflict at .num")
+
+	s.MustRun(main, []string{"merge", "resolve", "--value", `[1, 2]`, s.DBDir, ".lst"})
+	s.MustRun(main, []string{"merge", "--continue", s.DBDir})
+
+	outSpec := s.spec("output")
+	defer outSpec.Close()
+	db := outSpec.GetDatabase()
+	s.validateDataset("output", types.NewStruct("", types.StructData{"num": types.Number(43), "str": types.String("c"), "lst": types.NewList(db, types.Number(1), types.Number(2))}), l, r)
+	_, ok, _ := datas.LoadMergeState(db)
+	s.False(ok)
+
+	_, stderr, err = s.Run(main, []string{"merge", "--status", s.DBDir})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	s.Contains(stderr, "No merge in progress")
+
+	_, _, err = s.Run(main, []string{"merge", "--all-conflicts", s.DBDir, left, right, "output2"})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	stdout, _ = s.MustRun(main, []string{"merge", "--abort", s.DBDir})
+	s.Equal("Aborted merge into output2\n", stdout)
+}
+
+func (s *nomsMergeTestSuite) TestNomsMerge_ResumeAfterOutputMoved() {
+	left, right := "left", "right"
+	parentSpec := s.spec("parent")
+	defer parentSpec.Close()
+	leftSpec := s.spec(left)
+	defer leftSpec.Close()
+	rightSpec := s.spec(right)
+	defer rightSpec.Close()
+	p := s.setupMergeDataset(parentSpec, types.StructData{"num": types.Number(42)}, types.NewSet(parentSpec.GetDatabase()))
+	l := s.setupMergeDataset(leftSpec, types.StructData{"num": types.Number(43)}, types.NewSet(leftSpec.GetDatabase(), p))
+	s.setupMergeDataset(rightSpec, types.StructData{"num": types.Number(44)}, types.NewSet(rightSpec.GetDatabase(), p))
+
+	_, _, err := s.Run(main, []string{"merge", "--all-conflicts", s.DBDir, left, right, left})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	s.MustRun(main, []string{"merge", "resolve", "--ours", s.DBDir, ".num"})
+
+	movedSpec := s.spec(left)
+	defer movedSpec.Close()
+	moved := s.setupMergeDataset(movedSpec, types.StructData{"num": types.Number(45)}, types.NewSet(movedSpec.GetDatabase(), l))
+
+	_, stderr, err := s.Run(main, []string{"merge", "--continue", s.DBDir})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	s.Contains(stderr, "left was committed to during the merge")
+
+	sp := s.spec(left)
+	defer sp.Close()
+	s.True(moved.Equals(sp.GetDataset().HeadRef()))
+	_, ok, _ := datas.LoadMergeState(sp.GetDatabase())
+	s.True(ok)
+}
+
+func (s *nomsMergeTestSuite) TestNomsMerge_PolicyFile() {
+	left, right := "left", "right"
+	parentSpec := s.spec("parent")
+	defer parentSpec.Close()
+	leftSpec := s.spec(left)
+	defer leftSpec.Close()
+	rightSpec := s.spec(right)
+	defer rightSpec.Close()
+	db := parentSpec.GetDatabase()
+	p := s.setupMergeDataset(parentSpec, types.StructData{"count": types.Number(10), "title": types.String("a"), "tags": types.NewList(db, types.String("x"))}, types.NewSet(db))
+
+	commit := func(sp spec.Spec, date string, data types.StructData) types.Ref {
+		ds, err := sp.GetDatabase().Commit(sp.GetDataset(), types.NewStruct("", data), datas.CommitOptions{
+			Parents: types.NewSet(sp.GetDatabase(), p),
+			Meta:    types.NewStruct("Meta", types.StructData{datas.CommitDateField: types.String(date)}),
+		})
+		s.NoError(err)
+		return ds.HeadRef()
+	}
+	l := commit(leftSpec, "2017-01-02T00:00:00Z", types.StructData{"count": types.Number(12), "title": types.String("b"), "tags": types.NewList(db, types.String("x"), types.String("y"))})
+	r := commit(rightSpec, "2017-01-01T00:00:00Z", types.StructData{"count": types.Number(15), "title": types.String("c"), "tags": types.NewList(db, types.String("x"), types.String("z"))})
+
+	policy, err := ioutil.TempFile("", "policy")
+	s.NoError(err)
+	defer os.Remove(policy.Name())
+	_, err = policy.WriteString(`[{"path": ".count", "resolve": "counter"}, {"path": ".title", "resolve": "lww"}, {"path": ".*", "resolve": "concat"}]`)
+	s.NoError(err)
+	s.NoError(policy.Close())
+
+	s.MustRun(main, []string{"merge", "--policy-file", policy.Name(), s.DBDir, left, right, "output"})
+	s.validateDataset("output", types.NewStruct("", types.StructData{
+		"count": types.Number(17),
+		"title": types.String("b"),
+		"tags":  types.NewList(db, types.String("x"), types.String("y"), types.String("z")),
+	}), l, r)
+
+	s.NoError(ioutil.WriteFile(policy.Name(), []byte(`[{"path": ".count", "resolve": "sum"}]`), 0644))
+	_, stderr, runErr := s.Run(main, []string{"merge", "--policy-file", policy.Name(), s.DBDir, left, right, "output2"})
+	s.Equal(clienttest.ExitError{Code: 1}, runErr)
+	s.Contains(stderr, "Unsupported resolver sum at .count")
+}
+
+func (s *nomsMergeTestSuite) TestNomsMerge_Octopus() {
+	parentSpec := s.spec("parent")
+	defer parentSpec.Close()
+	db := parentSpec.GetDatabase()
+	p := s.setupMergeDataset(parentSpec, types.StructData{"a": types.Number(0), "b": types.Number(0), "c": types.Number(0)}, types.NewSet(db))
+
+	heads := []types.Value{}
+	for _, name := range []string{"a", "b", "c"} {
+		sp := s.spec("shard-" + name)
+		defer sp.Close()
+		data := types.StructData{"a": types.Number(0), "b": types.Number(0), "c": types.Number(0)}
+		data[name] = types.Number(1)
+		heads = append(heads, s.setupMergeDataset(sp, data, types.NewSet(sp.GetDatabase(), p)))
+	}
+
+	s.MustRun(main, []string{"merge", s.DBDir, "shard-a", "shard-b", "shard-c", "--into", "output"})
+	s.validateDataset("output", types.NewStruct("", types.StructData{"a": types.Number(1), "b": types.Number(1), "c": types.Number(1)}), heads...)
+
+	// Two datasets with --into is a regular merge.
+	s.MustRun(main, []string{"merge", "--into=output2", s.DBDir, "shard-a", "shard-b"})
+	s.validateDataset("output2", types.NewStruct("", types.StructData{"a": types.Number(1), "b": types.Number(1), "c": types.Number(0)}), heads[:2]...)
+
+	conflictSpec := s.spec("conflict")
+	defer conflictSpec.Close()
+	s.setupMergeDataset(conflictSpec, types.StructData{"a": types.Number(2), "b": types.Number(0), "c": types.Number(0)}, types.NewSet(db, p))
+	_, _, err := s.Run(main, []string{"merge", s.DBDir, "shard-a", "shard-b", "conflict", "--into", "output3"})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	s.MustRun(main, []string{"merge", "--policy=r", s.DBDir, "shard-a", "shard-b", "conflict", "--into", "output3"})
+	s.validateDataset("output3", types.NewStruct("", types.StructData{"a": types.Number(2), "b": types.Number(1), "c": types.Number(0)}), heads[0], heads[1], conflictSpec.GetDataset().HeadRef())
+
+	_, stderr, err := s.Run(main, []string{"merge", "--all-conflicts", s.DBDir, "shard-a", "shard-b", "shard-c", "--into", "output4"})
+	s.Equal(clienttest.ExitError{Code: 1}, err)
+	s.Contains(stderr, "--all-conflicts can only be used to merge two datasets")
+}
+
 func (s *nomsMergeTestSuite) TestBadInput() {
 	sp, err := spec.ForDatabase(spec.CreateDatabaseSpecString("nbs", s.DBDir))
 	s.NoError(err)
@@ -236,6 +470,7 @@ func TestNomsMergeCliResolve(t *testing.T) {
 		{"r\n", types.DiffChangeAdded, types.DiffChangeAdded, types.String("foo"), types.String("bar"), types.DiffChangeAdded, types.String("bar"), true},
 		{"l\n", types.DiffChangeAdded, types.DiffChangeAdded, types.Number(7), types.String("bar"), types.DiffChangeAdded, types.Number(7), true},
 		{"r\n", types.DiffChangeModified, types.DiffChangeModified, types.Number(7), types.String("bar"), types.DiffChangeModified, types.String("bar"), true},
+		{"", types.DiffChangeModified, types.DiffChangeModified, types.Number(7), types.String("bar"), types.DiffChangeModified, nil, false},
 	}
 
 	for _, c := range cases {
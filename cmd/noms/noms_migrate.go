@@ -0,0 +1,67 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/migrate"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var migrateAll bool
+
+var nomsMigrate = &util.Command{
+	Run:       runMigrate,
+	UsageLine: "migrate [options] <migration-file> <dataset>",
+	Short:     "Rewrites the structs in a dataset from one type to another",
+	Long:      "The migration file is JSON declaring the nomdl types to migrate from and to, and optionally the paths from which fields of the new type take their values, e.g. {\"from\": \"Struct Person {name: String}\", \"to\": \"Struct Person {fullName: String}\", \"fields\": {\"fullName\": \".name\"}}. By default a new commit with the migrated head value is created; with --all every commit in the dataset's history is rewritten. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.",
+	Flags:     setupMigrateFlags,
+	Nargs:     2,
+}
+
+func setupMigrateFlags() *flag.FlagSet {
+	migrateFlagSet := flag.NewFlagSet("migrate", flag.ExitOnError)
+	migrateFlagSet.BoolVar(&migrateAll, "all", false, "rewrite every commit in the dataset's history, rather than committing a migrated head")
+	spec.RegisterCommitMetaFlags(migrateFlagSet)
+	verbose.RegisterVerboseFlags(migrateFlagSet)
+	return migrateFlagSet
+}
+
+func runMigrate(args []string) int {
+	f, err := os.Open(args[0])
+	d.CheckErrorNoUsage(err)
+	m, err := migrate.Parse(f)
+	f.Close()
+	d.CheckErrorNoUsage(err)
+
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(args[1])
+	d.CheckError(err)
+	defer db.Close()
+
+	oldCommitRef, ok := ds.MaybeHeadRef()
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("Dataset %s has no head", ds.ID()))
+	}
+
+	if migrateAll {
+		ds, err = migrate.MigrateHistory(ds, m)
+	} else {
+		meta, metaErr := spec.CreateCommitMetaStruct(db, "", "", nil, nil)
+		d.CheckErrorNoUsage(metaErr)
+		ds, err = migrate.MigrateHead(ds, m, meta)
+	}
+	d.CheckErrorNoUsage(err)
+
+	fmt.Fprintf(os.Stdout, "New head #%v (was #%v)\n", ds.HeadRef().TargetHash().String(), oldCommitRef.TargetHash().String())
+	return 0
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestNomsMigrate(t *testing.T) {
+	suite.Run(t, &nomsMigrateTestSuite{})
+}
+
+type nomsMigrateTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsMigrateTestSuite) TestMigrate() {
+	migration := filepath.Join(s.TempDir, "migration.json")
+	s.NoError(ioutil.WriteFile(migration, []byte(`{
+		"from": "Struct Row { id: String }",
+		"to": "Struct Row { key: Number }",
+		"fields": { "key": ".id" }
+	}`), 0644))
+
+	sp, err := spec.ForDataset(spec.CreateValueSpecString("nbs", s.DBDir, "rows"))
+	s.NoError(err)
+	defer sp.Close()
+
+	db := sp.GetDatabase()
+	row := func(id string) types.Value {
+		return types.NewStruct("Row", types.StructData{"id": types.String(id)})
+	}
+	_, err = db.CommitValue(sp.GetDataset(), types.NewList(db, row("1"), row("2")))
+	s.NoError(err)
+
+	stdout, _ := s.MustRun(main, []string{"migrate", "--message", "migrated", migration, sp.String()})
+	s.Contains(stdout, "New head #")
+
+	stdout, _ = s.MustRun(main, []string{"show", sp.String() + ".value[1]"})
+	s.Equal("struct Row {\n  key: 2,\n}\n", stdout)
+	stdout, _ = s.MustRun(main, []string{"show", sp.String() + ".meta.message"})
+	s.Equal("\"migrated\"\n", stdout)
+}
@@ -0,0 +1,150 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/config"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/diff"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	flag "github.com/juju/gnuflag"
+)
+
+var nomsPatch = &util.Command{
+	Run:       runPatch,
+	UsageLine: "patch create|apply [options] <args>",
+	Short:     "Creates patches, and applies them to datasets",
+	Long:      "'patch create <object1> <object2> <dataset>' commits the diff from object1 to object2 to the dataset, as a List of Struct Difference {path: String, type: String, old?: Value, new?: Value}, where it can be shown, synced and later applied. 'patch apply <dataset> <patch>' applies a patch to the head value of the dataset, and commits the result. The patch is either a file written by 'noms diff --format=json-patch' or 'noms diff --format=ndjson', or an object created by 'patch create'. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.",
+	Flags:     setupPatchFlags,
+	Nargs:     3,
+}
+
+func setupPatchFlags() *flag.FlagSet {
+	patchFlagSet := flag.NewFlagSet("patch", flag.ExitOnError)
+	spec.RegisterCommitMetaFlags(patchFlagSet)
+	verbose.RegisterVerboseFlags(patchFlagSet)
+	return patchFlagSet
+}
+
+func runPatch(args []string) int {
+	switch args[0] {
+	case "create":
+		if len(args) != 4 {
+			d.CheckError(fmt.Errorf("patch create takes <object1> <object2> <dataset>"))
+		}
+		return runPatchCreate(args[1], args[2], args[3])
+	case "apply":
+		if len(args) != 3 {
+			d.CheckError(fmt.Errorf("patch apply takes <dataset> <patch>"))
+		}
+		return runPatchApply(args[1], args[2])
+	}
+	d.CheckError(fmt.Errorf("Unknown patch command %s", args[0]))
+	return 1
+}
+
+func runPatchCreate(obj1, obj2, dsPath string) int {
+	cfg := config.NewResolver()
+	db1, value1, err := cfg.GetPath(obj1)
+	d.CheckErrorNoUsage(err)
+	if value1 == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", obj1))
+	}
+	defer db1.Close()
+
+	db2, value2, err := cfg.GetPath(obj2)
+	d.CheckErrorNoUsage(err)
+	if value2 == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", obj2))
+	}
+	defer db2.Close()
+
+	db, ds, err := cfg.GetDataset(dsPath)
+	d.CheckError(err)
+	defer db.Close()
+
+	dChan := make(chan diff.Difference, 16)
+	go func() {
+		diff.DiffWithOptions(value1, value2, dChan, make(chan struct{}), diff.Options{DetectMoves: true})
+		close(dChan)
+	}()
+	patch := diff.Patch{}
+	for dif := range dChan {
+		patch = append(patch, dif)
+	}
+	pullPatchValues(db1, db2, db, patch)
+
+	meta, err := spec.CreateCommitMetaStruct(db, "", "", nil, nil)
+	d.CheckErrorNoUsage(err)
+	ds, err = db.Commit(ds, diff.PatchToValue(db, patch), datas.CommitOptions{Meta: meta})
+	d.CheckErrorNoUsage(err)
+
+	fmt.Fprintf(os.Stdout, "Committed patch of %d differences to #%v\n", len(patch), ds.HeadRef().TargetHash().String())
+	return 0
+}
+
+func runPatchApply(dsPath, patchPath string) int {
+	cfg := config.NewResolver()
+	db, ds, err := cfg.GetDataset(dsPath)
+	d.CheckError(err)
+	defer db.Close()
+
+	oldCommitRef, ok := ds.MaybeHeadRef()
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("Dataset %s has no head", ds.ID()))
+	}
+
+	var patch diff.Patch
+	if f, err := os.Open(patchPath); err == nil {
+		patch, err = diff.ReadJSON(f, db)
+		f.Close()
+		d.CheckErrorNoUsage(err)
+	} else {
+		patchDB, patchValue, err := cfg.GetPath(patchPath)
+		d.CheckErrorNoUsage(err)
+		if patchValue == nil {
+			d.CheckErrorNoUsage(fmt.Errorf("Patch not found: %s", patchPath))
+		}
+		defer patchDB.Close()
+		patch, err = diff.PatchFromValue(patchValue)
+		d.CheckErrorNoUsage(err)
+		pullPatchValues(nil, patchDB, db, patch)
+	}
+
+	patched, err := diff.ApplyChecked(ds.HeadValue(), patch)
+	d.CheckErrorNoUsage(err)
+	meta, err := spec.CreateCommitMetaStruct(db, "", "", nil, nil)
+	d.CheckErrorNoUsage(err)
+	ds, err = db.Commit(ds, patched, datas.CommitOptions{Meta: meta})
+	d.CheckErrorNoUsage(err)
+
+	fmt.Fprintf(os.Stdout, "New head #%v (was #%v)\n", ds.HeadRef().TargetHash().String(), oldCommitRef.TargetHash().String())
+	return 0
+}
+
+// pullPatchValues copies the chunks which the old values of patch reference
+// from oldDB, and those which its new values reference from newDB, to sinkDB,
+// so that values holding them can be committed to sinkDB. If oldDB is nil,
+// the old values are left out.
+func pullPatchValues(oldDB, newDB, sinkDB datas.Database, patch diff.Patch) {
+	pull := func(srcDB datas.Database, v types.Value) {
+		if srcDB != nil && v != nil {
+			v.WalkRefs(func(r types.Ref) {
+				datas.Pull(srcDB, sinkDB, r, nil)
+			})
+		}
+	}
+	for _, dif := range patch {
+		pull(oldDB, dif.OldValue)
+		pull(newDB, dif.NewValue)
+	}
+}
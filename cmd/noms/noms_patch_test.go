@@ -0,0 +1,155 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/stretchr/testify/suite"
+)
+
+func TestNomsPatch(t *testing.T) {
+	suite.Run(t, &nomsPatchTestSuite{})
+}
+
+type nomsPatchTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsPatchTestSuite) TestPatchApply() {
+	sp, err := spec.ForDatabase(spec.CreateDatabaseSpecString("nbs", s.DBDir))
+	s.NoError(err)
+	defer sp.Close()
+
+	db := sp.GetDatabase()
+	row := func(id string, price float64) types.Value {
+		return types.NewStruct("Row", types.StructData{"id": types.String(id), "price": types.Number(price)})
+	}
+	v1 := types.NewList(db, row("a", 1), row("b", 2), row("c", 3))
+	v2 := types.NewList(db, row("c", 3), row("a", 1.5), row("b", 2))
+	_, err = db.CommitValue(db.GetDataset("old"), v1)
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("new"), v2)
+	s.NoError(err)
+	formats := []string{"json-patch", "ndjson"}
+	for _, format := range formats {
+		_, err = db.CommitValue(db.GetDataset(format), v1)
+		s.NoError(err)
+	}
+
+	dsSpec := func(name string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, name)
+	}
+	for _, format := range formats {
+		patch, _ := s.MustRun(main, []string{"diff", "--format", format, dsSpec("old") + ".value", dsSpec("new") + ".value"})
+		s.Contains(patch, "move")
+		patchFile := filepath.Join(s.TempDir, format+".json")
+		s.NoError(ioutil.WriteFile(patchFile, []byte(patch), 0644))
+
+		stdout, _ := s.MustRun(main, []string{"patch", "apply", "--message", "patched", dsSpec(format), patchFile})
+		s.Contains(stdout, "New head #")
+
+		stdout, _ = s.MustRun(main, []string{"show", dsSpec(format) + ".meta.message"})
+		s.Equal("\"patched\"\n", stdout)
+		sp, err := spec.ForDataset(dsSpec(format))
+		s.NoError(err)
+		s.True(v2.Equals(sp.GetDataset().HeadValue()))
+		sp.Close()
+
+		// The patch was made from v1, so it doesn't apply to v2.
+		_, stderr, runErr := s.Run(main, []string{"patch", "apply", dsSpec(format), patchFile})
+		s.Equal(clienttest.ExitError{Code: 1}, runErr)
+		s.Contains(stderr, "Patch doesn't apply at ")
+	}
+}
+
+func (s *nomsPatchTestSuite) TestPatchCreateApply() {
+	sp, err := spec.ForDatabase(spec.CreateDatabaseSpecString("nbs", s.DBDir))
+	s.NoError(err)
+	defer sp.Close()
+
+	db := sp.GetDatabase()
+	l := func(vals ...string) types.Value {
+		elems := make([]types.Value, len(vals))
+		for i, v := range vals {
+			elems[i] = types.String(v)
+		}
+		return types.NewList(db, elems...)
+	}
+	_, err = db.CommitValue(db.GetDataset("old"), l("a", "b", "c", "d"))
+	s.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("new"), l("d", "a", "x", "c"))
+	s.NoError(err)
+
+	dbDir2 := filepath.Join(s.TempDir, "other")
+	sp2, err := spec.ForDataset(spec.CreateValueSpecString("nbs", dbDir2, "target"))
+	s.NoError(err)
+	defer sp2.Close()
+	_, err = sp2.GetDatabase().CommitValue(sp2.GetDataset(), l("a", "b", "c", "d"))
+	s.NoError(err)
+
+	dsSpec := func(name string) string {
+		return spec.CreateValueSpecString("nbs", s.DBDir, name)
+	}
+	stdout, _ := s.MustRun(main, []string{"patch", "create", dsSpec("old") + ".value", dsSpec("new") + ".value", dsSpec("patches")})
+	s.Contains(stdout, "Committed patch of 2 differences")
+	stdout, _ = s.MustRun(main, []string{"show", dsSpec("patches") + ".value[0]"})
+	s.Equal("struct Difference {\n  from: \"[3]\",\n  new: \"d\",\n  old: \"d\",\n  path: \"[0]\",\n  type: \"moved\",\n}\n", stdout)
+
+	stdout, _ = s.MustRun(main, []string{"patch", "apply", sp2.String(), dsSpec("patches") + ".value"})
+	s.Contains(stdout, "New head #")
+	sp3, err := spec.ForDataset(sp2.String())
+	s.NoError(err)
+	defer sp3.Close()
+	s.True(l("d", "a", "x", "c").Equals(sp3.GetDataset().HeadValue()))
+}
+
+func (s *nomsPatchTestSuite) TestPatchAcrossDatabases() {
+	dbSpec := func(name string) string {
+		return spec.CreateDatabaseSpecString("nbs", filepath.Join(s.TempDir, name))
+	}
+	commit := func(dbName, dsName string, v func(vrw types.ValueReadWriter) types.Value) types.Value {
+		sp, err := spec.ForDataset(dbSpec(dbName) + "::" + dsName)
+		s.NoError(err)
+		defer sp.Close()
+		val := v(sp.GetDatabase())
+		_, err = sp.GetDatabase().CommitValue(sp.GetDataset(), val)
+		s.NoError(err)
+		return val
+	}
+	old := func(vrw types.ValueReadWriter) types.Value {
+		return types.NewStruct("Table", types.StructData{"name": types.String("t")})
+	}
+	// The new value holds a List which is chunked, so the patch references chunks in the database it was made from.
+	rows := make([]types.Value, 20000)
+	for i := range rows {
+		rows[i] = types.Number(i)
+	}
+	newValue := commit("new", "table", func(vrw types.ValueReadWriter) types.Value {
+		return types.NewStruct("Table", types.StructData{"name": types.String("t"), "rows": types.NewList(vrw, rows...)})
+	})
+	chunked := false
+	newValue.(types.Struct).Get("rows").WalkRefs(func(r types.Ref) { chunked = true })
+	s.True(chunked)
+	commit("old", "table", old)
+	commit("target", "table", old)
+
+	stdout, _ := s.MustRun(main, []string{"patch", "create", dbSpec("old") + "::table.value", dbSpec("new") + "::table.value", dbSpec("patches") + "::patch"})
+	s.Contains(stdout, "Committed patch of 1 differences")
+
+	stdout, _ = s.MustRun(main, []string{"patch", "apply", dbSpec("target") + "::table", dbSpec("patches") + "::patch.value"})
+	s.Contains(stdout, "New head #")
+	sp, err := spec.ForDataset(dbSpec("target") + "::table")
+	s.NoError(err)
+	defer sp.Close()
+	head := sp.GetDataset().HeadValue()
+	s.True(newValue.Equals(head))
+	s.Equal(uint64(20000), head.(types.Struct).Get("rows").(types.List).Len())
+}
@@ -5,23 +5,44 @@
 package main
 
 import (
+	"fmt"
 	"os"
 	"os/signal"
+	"plugin"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/profile"
 	"github.com/attic-labs/noms/go/util/verbose"
 	flag "github.com/juju/gnuflag"
 )
 
 var (
-	port int
+	port            int
+	replicaOf       string
+	replicaInterval time.Duration
+	hooks           stringList
+	hookPlugins     stringList
 )
 
+// stringList is a flag which can be given more than once.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(s string) error {
+	*l = append(*l, s)
+	return nil
+}
+
 var nomsServe = &util.Command{
 	Run:       runServe,
 	UsageLine: "serve [options] <database>",
@@ -34,6 +55,10 @@ var nomsServe = &util.Command{
 func setupServeFlags() *flag.FlagSet {
 	serveFlagSet := flag.NewFlagSet("serve", flag.ExitOnError)
 	serveFlagSet.IntVar(&port, "port", 8000, "port to listen on for HTTP requests")
+	serveFlagSet.StringVar(&replicaOf, "replica-of", "", "serve a read-only copy of this database, which is kept up to date by pulling from it")
+	serveFlagSet.DurationVar(&replicaInterval, "replica-interval", 10*time.Second, "how often to pull from the database given to -replica-of")
+	serveFlagSet.Var(&hooks, "hook", "an executable to run before each update of a dataset, with the dataset, its old head and its new head as arguments; if it fails, the update is rejected with its output (may be repeated)")
+	serveFlagSet.Var(&hookPlugins, "hook-plugin", "a Go plugin exporting a datas.CommitHook named CheckUpdate to call before each update of a dataset (may be repeated)")
 	verbose.RegisterVerboseFlags(serveFlagSet)
 	profile.RegisterProfileFlags(serveFlagSet)
 	return serveFlagSet
@@ -48,6 +73,15 @@ func runServe(args []string) int {
 	cs, err := cfg.GetChunkStore(db)
 	d.CheckError(err)
 	server := datas.NewRemoteDatabaseServer(cs, port)
+	server.CommitHooks, err = commitHooks()
+	d.CheckErrorNoUsage(err)
+
+	if replicaOf != "" {
+		primary, err := cfg.GetDatabase(replicaOf)
+		d.CheckErrorNoUsage(err)
+		server.Replica = datas.NewReplica(replicaOf, primary, cs)
+		go server.Replica.Run(replicaInterval)
+	}
 
 	// Shutdown server gracefully so that profile may be written
 	c := make(chan os.Signal, 1)
@@ -55,6 +89,9 @@ func runServe(args []string) int {
 	signal.Notify(c, syscall.SIGTERM)
 	go func() {
 		<-c
+		if server.Replica != nil {
+			server.Replica.Stop()
+		}
 		server.Stop()
 	}()
 
@@ -64,3 +101,42 @@ func runServe(args []string) int {
 	})
 	return 0
 }
+
+// commitHooks returns the CommitHooks given by the -hook and -hook-plugin
+// flags.
+func commitHooks() ([]datas.CommitHook, error) {
+	result := []datas.CommitHook{}
+	for _, path := range hooks {
+		result = append(result, datas.ExecCommitHook(path))
+	}
+	for _, path := range hookPlugins {
+		hook, err := loadPluginCommitHook(path)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, hook)
+	}
+	return result, nil
+}
+
+// loadPluginCommitHook loads a CommitHook from the Go plugin at path, which
+// must export a function
+//
+//	func CheckUpdate(update datas.DatasetUpdate, vr types.ValueReader) error
+//
+// The plugin must be built against the same version of Noms as the server.
+func loadPluginCommitHook(path string) (datas.CommitHook, error) {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup("CheckUpdate")
+	if err != nil {
+		return nil, err
+	}
+	check, ok := sym.(func(datas.DatasetUpdate, types.ValueReader) error)
+	if !ok {
+		return nil, fmt.Errorf("CheckUpdate in %s is a %T, not a func(datas.DatasetUpdate, types.ValueReader) error", path, sym)
+	}
+	return datas.CommitHook(check), nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPluginCommitHookMissing(t *testing.T) {
+	_, err := loadPluginCommitHook(filepath.Join(os.TempDir(), "no-such-plugin.so"))
+	assert.Error(t, err)
+}
+
+func TestServeExecCommitHook(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "noms-serve-test")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	hook := filepath.Join(dir, "hook.sh")
+	assert.NoError(ioutil.WriteFile(hook, []byte("#!/bin/sh\nif [ \"$1\" = protected ]; then\n  echo \"$1 is read-only\"\n  exit 1\nfi\n"), 0755))
+	hooks = stringList{hook}
+	defer func() { hooks = nil }()
+
+	dbDir := filepath.Join(dir, "db")
+	assert.NoError(os.Mkdir(dbDir, 0755))
+	server := datas.NewRemoteDatabaseServer(nbs.NewLocalStore(dbDir, clienttest.DefaultMemTableSize), 0)
+	server.CommitHooks, err = commitHooks()
+	assert.NoError(err)
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	defer server.Stop()
+	<-ready
+
+	db := datas.NewDatabase(datas.NewHTTPChunkStore(fmt.Sprintf("http://localhost:%d", server.Port()), ""))
+	defer db.Close()
+	_, err = db.CommitValue(db.GetDataset("open"), types.String("ok"))
+	assert.NoError(err)
+	_, err = db.CommitValue(db.GetDataset("protected"), types.String("no"))
+	assert.Equal(&datas.ErrCommitRejected{Message: "Update to protected rejected: hook.sh: protected is read-only"}, err)
+	_, ok := db.GetDataset("protected").MaybeHeadRef()
+	assert.False(ok)
+}
@@ -13,9 +13,11 @@ import (
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/datetime"
 	"github.com/attic-labs/noms/go/util/outputpager"
+	"github.com/attic-labs/noms/go/util/signing"
 	"github.com/attic-labs/noms/go/util/verbose"
 	flag "github.com/juju/gnuflag"
 )
@@ -30,9 +32,10 @@ var nomsShow = &util.Command{
 }
 
 var (
-	showRaw   = false
-	showStats = false
-	tzName    string
+	showRaw    = false
+	showStats  = false
+	showVerify = false
+	tzName     string
 )
 
 func setupShowFlags() *flag.FlagSet {
@@ -41,6 +44,7 @@ func setupShowFlags() *flag.FlagSet {
 	verbose.RegisterVerboseFlags(showFlagSet)
 	showFlagSet.BoolVar(&showRaw, "raw", false, "If true, dumps the raw binary version of the data")
 	showFlagSet.BoolVar(&showStats, "stats", false, "If true, reports statistics related to the value")
+	showFlagSet.BoolVar(&showVerify, "verify", false, "If true and the object is a commit, reports whether it's signed by a key in the [trusted] section of .nomsconfig")
 	showFlagSet.StringVar(&tzName, "tz", "local", "display formatted date comments in specified timezone, must be: local or utc")
 	return showFlagSet
 }
@@ -77,9 +81,21 @@ func runShow(args []string) int {
 	tz, _ := locationFromTimezoneArg(tzName, nil)
 	datetime.RegisterHRSCommenter(tz)
 
+	var trusted signing.KeySet
+	commit, isCommit := value.(types.Struct)
+	isCommit = isCommit && datas.IsCommit(commit)
+	if showVerify && isCommit {
+		trusted, err = cfg.TrustedKeys()
+		d.CheckErrorNoUsage(err)
+	}
+
 	pgr := outputpager.Start()
 	defer pgr.Stop()
 
+	if trusted != nil {
+		fmt.Fprintf(pgr.Writer, "// %s: %s\n", signatureLabel, signatureStatus(commit, trusted))
+	}
+
 	types.WriteEncodedValue(pgr.Writer, value)
 	fmt.Fprintln(pgr.Writer)
 	return 0
@@ -5,14 +5,19 @@
 package main
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/config"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/profile"
 	"github.com/attic-labs/noms/go/util/status"
@@ -22,7 +27,9 @@ import (
 )
 
 var (
-	p int
+	p             int
+	resume        bool
+	checkpointDir string
 )
 
 var nomsSync = &util.Command{
@@ -37,6 +44,8 @@ var nomsSync = &util.Command{
 func setupSyncFlags() *flag.FlagSet {
 	syncFlagSet := flag.NewFlagSet("sync", flag.ExitOnError)
 	syncFlagSet.IntVar(&p, "p", 512, "parallelism")
+	syncFlagSet.BoolVar(&resume, "resume", false, "checkpoint the sync to a local database, so that if it's interrupted, running it again with --resume continues from the last checkpoint")
+	syncFlagSet.StringVar(&checkpointDir, "checkpoint-dir", filepath.Join(os.TempDir(), "noms-sync"), "directory in which to keep sync checkpoints")
 	verbose.RegisterVerboseFlags(syncFlagSet)
 	profile.RegisterProfileFlags(syncFlagSet)
 	return syncFlagSet
@@ -56,14 +65,33 @@ func runSync(args []string) int {
 	d.CheckError(err)
 	defer sinkDB.Close()
 
+	sourceRef := types.NewRef(sourceObj)
+	checkpoint := syncCheckpointPath(checkpointDir, cfg.ResolvePathSpec(args[0]), cfg.ResolvePathSpec(args[1]), sourceRef.TargetHash())
+	if _, err := os.Stat(checkpoint); err == nil {
+		// The sink has some of what's being synced, but not all of it.
+		if !resume {
+			d.CheckErrorNoUsage(fmt.Errorf("A sync of %s to %s was interrupted; run it again with --resume to finish it", args[0], args[1]))
+		}
+		if datas.HasPullCheckpoint(checkpoint, sourceRef) {
+			fmt.Println("Resuming sync from checkpoint")
+		}
+	} else if resume {
+		d.CheckError(os.MkdirAll(checkpointDir, 0755))
+	}
+
 	start := time.Now()
 	progressCh := make(chan datas.PullProgress)
 	lastProgressCh := make(chan datas.PullProgress)
 
 	go func() {
-		var last datas.PullProgress
+		var first, last datas.PullProgress
+		started := false
 
 		for info := range progressCh {
+			if !started {
+				// When resuming, the first progress includes what was synced before.
+				first, started = info, true
+			}
 			last = info
 			if info.KnownCount == 1 {
 				// It's better to print "up to date" than "0% (0/1); 100% (1/1)".
@@ -72,18 +100,21 @@ func runSync(args []string) int {
 
 			if status.WillPrint() {
 				pct := 100.0 * float64(info.DoneCount) / float64(info.KnownCount)
-				status.Printf("Syncing - %.2f%% (%s/s)", pct, bytesPerSec(info.ApproxWrittenBytes, start))
+				status.Printf("Syncing - %.2f%% (%s/s)%s", pct, bytesPerSec(info.ApproxWrittenBytes-first.ApproxWrittenBytes, start), eta(first, info, start))
 			}
 		}
 		lastProgressCh <- last
 	}()
 
-	sourceRef := types.NewRef(sourceObj)
 	sinkRef, sinkExists := sinkDataset.MaybeHeadRef()
 	nonFF := false
 	err = d.Try(func() {
 		defer profile.MaybeStartProfile().Stop()
-		datas.Pull(sourceStore, sinkDB, sourceRef, progressCh)
+		if resume {
+			datas.PullWithCheckpoint(sourceStore, sinkDB, sourceRef, progressCh, checkpoint)
+		} else {
+			datas.Pull(sourceStore, sinkDB, sourceRef, progressCh)
+		}
 
 		var err error
 		sinkDataset, err = sinkDB.FastForward(sinkDataset, sourceRef)
@@ -94,12 +125,11 @@ func runSync(args []string) int {
 		d.PanicIfError(err)
 	})
 
-	if err != nil {
-		log.Fatal(err)
-	}
-
 	close(progressCh)
-	if last := <-lastProgressCh; last.DoneCount > 0 {
+	last := <-lastProgressCh
+	d.CheckErrorNoUsage(err)
+
+	if last.DoneCount > 0 {
 		status.Printf("Done - Synced %s in %s (%s/s)",
 			humanize.Bytes(last.ApproxWrittenBytes), since(start), bytesPerSec(last.ApproxWrittenBytes, start))
 		status.Done()
@@ -114,6 +144,25 @@ func runSync(args []string) int {
 	return 0
 }
 
+// syncCheckpointPath returns where to checkpoint a sync of sourceRef from
+// source to sink.
+func syncCheckpointPath(dir, source, sink string, sourceRef hash.Hash) string {
+	h := sha1.Sum([]byte(strings.Join([]string{source, sink, sourceRef.String()}, "\x00")))
+	return filepath.Join(dir, hex.EncodeToString(h[:]))
+}
+
+// eta estimates the time remaining from the rate at which chunks have been
+// synced since start. KnownCount grows as the sync descends into the source,
+// so it's optimistic until the sync nears completion.
+func eta(first, info datas.PullProgress, start time.Time) string {
+	done := info.DoneCount - first.DoneCount
+	if done == 0 || info.KnownCount <= info.DoneCount {
+		return ""
+	}
+	remaining := time.Duration(float64(time.Since(start)) * float64(info.KnownCount-info.DoneCount) / float64(done))
+	return fmt.Sprintf(", ETA %s", remaining.Round(time.Second))
+}
+
 func bytesPerSec(bytes uint64, start time.Time) string {
 	bps := float64(bytes) / float64(time.Since(start).Seconds())
 	return humanize.Bytes(uint64(bps))
@@ -5,9 +5,18 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
+	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/nbs"
 	"github.com/attic-labs/noms/go/spec"
@@ -153,3 +162,167 @@ func (s *nomsSyncTestSuite) TestRewind() {
 	s.True(types.Number(42).Equals(dest.HeadValue()))
 	db.Close()
 }
+
+func (s *nomsSyncTestSuite) TestSyncResume() {
+	defer s.NoError(os.RemoveAll(s.DBDir2))
+
+	sourceDB := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	source1 := sourceDB.GetDataset("src")
+	source1, err := sourceDB.CommitValue(source1, types.NewList(sourceDB, types.Number(1), types.Number(2)))
+	s.NoError(err)
+	sourceDB.Close()
+
+	checkpointDir, err := ioutil.TempDir("", "noms-sync-test")
+	s.NoError(err)
+	defer os.RemoveAll(checkpointDir)
+
+	// With nothing to resume from, --resume syncs from scratch.
+	sourceDataset := spec.CreateValueSpecString("nbs", s.DBDir, "src")
+	sinkDatasetSpec := spec.CreateValueSpecString("nbs", s.DBDir2, "dest")
+	sout, _ := s.MustRun(main, []string{"sync", "--resume", "--checkpoint-dir", checkpointDir, sourceDataset, sinkDatasetSpec})
+	s.Regexp("Synced", sout)
+
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	dest := db.GetDataset("dest")
+	s.True(source1.HeadValue().Equals(dest.HeadValue()))
+	db.Close()
+
+	// The checkpoint is removed once the sync completes.
+	files, err := ioutil.ReadDir(checkpointDir)
+	s.NoError(err)
+	s.Empty(files)
+
+	// Without --resume, nothing is checkpointed...
+	sourceDB = datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	defer sourceDB.Close()
+	source1, err = sourceDB.CommitValue(sourceDB.GetDataset("src"), types.NewList(sourceDB, types.Number(3)))
+	s.NoError(err)
+	unused := filepath.Join(checkpointDir, "unused")
+	sout, _ = s.MustRun(main, []string{"sync", "--checkpoint-dir", unused, sourceDataset, sinkDatasetSpec})
+	s.Regexp("Synced", sout)
+	_, err = os.Stat(unused)
+	s.True(os.IsNotExist(err))
+
+	// ...but an interrupted sync can only be finished with --resume. A checkpoint that can't be resumed from starts the sync over.
+	source1, err = sourceDB.CommitValue(source1, types.NewList(sourceDB, types.Number(4)))
+	s.NoError(err)
+	checkpoint := syncCheckpointPath(checkpointDir, sourceDataset, sinkDatasetSpec, source1.HeadRef().TargetHash())
+	s.NoError(ioutil.WriteFile(checkpoint, nil, 0644))
+	_, _, recoveredErr := s.Run(main, []string{"sync", "--checkpoint-dir", checkpointDir, sourceDataset, sinkDatasetSpec})
+	s.NotNil(recoveredErr)
+	sout, _ = s.MustRun(main, []string{"sync", "--resume", "--checkpoint-dir", checkpointDir, sourceDataset, sinkDatasetSpec})
+	s.NotContains(sout, "Resuming")
+	s.Regexp("Synced", sout)
+}
+
+func (s *nomsSyncTestSuite) TestSyncResumeAfterFailure() {
+	defer s.NoError(os.RemoveAll(s.DBDir2))
+
+	sourceDB := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	nums := make([]types.Value, 10000)
+	for i := range nums {
+		nums[i] = types.Number(i)
+	}
+	source1, err := sourceDB.CommitValue(sourceDB.GetDataset("src"), types.NewList(sourceDB, nums...))
+	s.NoError(err)
+	sourceDB.Close()
+
+	server := datas.NewRemoteDatabaseServer(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize), 0)
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	defer server.Stop()
+	<-ready
+
+	// Once failing is set, the source fails after a few more requests for chunks.
+	getRefs, failing := 0, false
+	mu := sync.Mutex{}
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", server.Port())})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		fail := false
+		if req.URL.Path == constants.GetRefsPath {
+			getRefs++
+			fail = failing && getRefs > 3
+		}
+		mu.Unlock()
+		if fail {
+			http.Error(w, "unavailable", http.StatusBadRequest)
+			return
+		}
+		proxy.ServeHTTP(w, req)
+	}))
+	defer ts.Close()
+
+	checkpointDir, err := ioutil.TempDir("", "noms-sync-test")
+	s.NoError(err)
+	defer os.RemoveAll(checkpointDir)
+
+	sourceDataset := ts.URL + "::src"
+	sinkDatasetSpec := spec.CreateValueSpecString("nbs", s.DBDir2, "dest")
+	mu.Lock()
+	failing = true
+	mu.Unlock()
+	_, _, recoveredErr := s.Run(main, []string{"sync", "--resume", "--checkpoint-dir", checkpointDir, sourceDataset, sinkDatasetSpec})
+	s.NotNil(recoveredErr)
+	files, err := ioutil.ReadDir(checkpointDir)
+	s.NoError(err)
+	s.Len(files, 1)
+
+	// The sink has some of the source, but not the dataset.
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	_, ok := db.GetDataset("dest").MaybeHeadRef()
+	s.False(ok)
+	db.Close()
+
+	mu.Lock()
+	getRefs, failing = 0, false
+	mu.Unlock()
+	sout, _ := s.MustRun(main, []string{"sync", "--resume", "--checkpoint-dir", checkpointDir, sourceDataset, sinkDatasetSpec})
+	s.Regexp("Resuming sync from checkpoint", sout)
+	s.Regexp("Synced", sout)
+
+	db = datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	defer db.Close()
+	s.True(source1.HeadValue().Equals(db.GetDataset("dest").HeadValue()))
+	files, err = ioutil.ReadDir(checkpointDir)
+	s.NoError(err)
+	s.Empty(files)
+}
+
+func (s *nomsSyncTestSuite) TestSyncFromRemoteGetsOnePack() {
+	defer s.NoError(os.RemoveAll(s.DBDir2))
+
+	sourceDB := datas.NewDatabase(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize))
+	source1, err := sourceDB.CommitValue(sourceDB.GetDataset("src"), types.NewList(sourceDB, types.Number(1), types.Number(2)))
+	s.NoError(err)
+	sourceDB.Close()
+
+	server := datas.NewRemoteDatabaseServer(nbs.NewLocalStore(s.DBDir, clienttest.DefaultMemTableSize), 0)
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	defer server.Stop()
+	<-ready
+
+	// Count the requests the sync makes to the server.
+	requests := map[string]int{}
+	mu := sync.Mutex{}
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: fmt.Sprintf("localhost:%d", server.Port())})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		mu.Lock()
+		requests[req.URL.Path]++
+		mu.Unlock()
+		proxy.ServeHTTP(w, req)
+	}))
+	defer ts.Close()
+
+	sinkDatasetSpec := spec.CreateValueSpecString("nbs", s.DBDir2, "dest")
+	sout, _ := s.MustRun(main, []string{"sync", ts.URL + "::src", sinkDatasetSpec})
+	s.Regexp("Synced", sout)
+	s.Equal(1, requests[constants.GetPackPath])
+
+	db := datas.NewDatabase(nbs.NewLocalStore(s.DBDir2, clienttest.DefaultMemTableSize))
+	defer db.Close()
+	s.True(source1.HeadValue().Equals(db.GetDataset("dest").HeadValue()))
+}
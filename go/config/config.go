@@ -11,20 +11,34 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/BurntSushi/toml"
 	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/signing"
+	"golang.org/x/crypto/ed25519"
 )
 
 type Config struct {
-	File string
-	Db   map[string]DbConfig
+	File    string
+	Db      map[string]DbConfig
+	Signing SigningConfig
+	// Trusted maps the names of signers whose commits are trusted to their base64-encoded ed25519 public keys.
+	Trusted map[string]string
 }
 
 type DbConfig struct {
 	Url string
 }
 
+// SigningConfig is the key `noms commit --sign` signs commits with.
+type SigningConfig struct {
+	// Key is a base64-encoded ed25519 private key.
+	Key string
+	// KeyFile is a file holding Key, relative to the config file. It's used if Key is empty.
+	KeyFile string `toml:"key_file"`
+}
+
 const (
 	NomsConfigFile = ".nomsconfig"
 	DefaultDbAlias = "default"
@@ -119,9 +133,41 @@ func qualifyPaths(configPath string, c *Config) (*Config, error) {
 	for k, r := range c.Db {
 		qc.Db[k] = DbConfig{absDbSpec(dir, r.Url)}
 	}
+	if qc.Signing.KeyFile != "" && !filepath.IsAbs(qc.Signing.KeyFile) {
+		qc.Signing.KeyFile = filepath.Join(dir, qc.Signing.KeyFile)
+	}
 	return &qc, nil
 }
 
+// SigningKey returns the key configured in the [signing] section.
+func (c *Config) SigningKey() (ed25519.PrivateKey, error) {
+	key := c.Signing.Key
+	if key == "" && c.Signing.KeyFile != "" {
+		data, err := ioutil.ReadFile(c.Signing.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		key = strings.TrimSpace(string(data))
+	}
+	if key == "" {
+		return nil, fmt.Errorf("no signing key in %s", NomsConfigFile)
+	}
+	return signing.ParsePrivateKey(key)
+}
+
+// TrustedKeys returns the keys configured in the [trusted] section.
+func (c *Config) TrustedKeys() (signing.KeySet, error) {
+	ks := signing.KeySet{}
+	for name, s := range c.Trusted {
+		key, err := signing.ParsePublicKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("trusted key %s: %s", name, err)
+		}
+		ks[name] = key
+	}
+	return ks, nil
+}
+
 func (c *Config) String() string {
 	var buffer bytes.Buffer
 	if c.File != "" {
@@ -137,5 +183,20 @@ func (c *Config) writeableString() string {
 		buffer.WriteString(fmt.Sprintf("[db.%s]\n", k))
 		buffer.WriteString(fmt.Sprintf("\t"+`url = "%s"`+"\n", r.Url))
 	}
+	if c.Signing != (SigningConfig{}) {
+		buffer.WriteString("[signing]\n")
+		if c.Signing.Key != "" {
+			buffer.WriteString(fmt.Sprintf("\t"+`key = "%s"`+"\n", c.Signing.Key))
+		}
+		if c.Signing.KeyFile != "" {
+			buffer.WriteString(fmt.Sprintf("\t"+`key_file = "%s"`+"\n", c.Signing.KeyFile))
+		}
+	}
+	if len(c.Trusted) > 0 {
+		buffer.WriteString("[trusted]\n")
+		for name, key := range c.Trusted {
+			buffer.WriteString(fmt.Sprintf("\t"+`%s = "%s"`+"\n", name, key))
+		}
+	}
 	return buffer.String()
 }
@@ -5,6 +5,7 @@
 package config
 
 import (
+	"crypto/rand"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -12,7 +13,9 @@ import (
 	"testing"
 
 	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/signing"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ed25519"
 )
 
 const (
@@ -32,6 +35,8 @@ var (
 			DefaultDbAlias: {nbsSpec},
 			remoteAlias:    {httpSpec},
 		},
+		SigningConfig{},
+		nil,
 	}
 
 	httpConfig = &Config{
@@ -40,6 +45,8 @@ var (
 			DefaultDbAlias: {httpSpec},
 			remoteAlias:    {nbsSpec},
 		},
+		SigningConfig{},
+		nil,
 	}
 
 	memConfig = &Config{
@@ -48,6 +55,8 @@ var (
 			DefaultDbAlias: {memSpec},
 			remoteAlias:    {httpSpec},
 		},
+		SigningConfig{},
+		nil,
 	}
 
 	ldbAbsConfig = &Config{
@@ -56,6 +65,8 @@ var (
 			DefaultDbAlias: {nbsAbsSpec},
 			remoteAlias:    {httpSpec},
 		},
+		SigningConfig{},
+		nil,
 	}
 )
 
@@ -199,3 +210,44 @@ func TestCwd(t *testing.T) {
 
 	assert.Equal(cwd, abs)
 }
+
+func TestSigningKeys(t *testing.T) {
+	assert := assert.New(t)
+	path := getPaths(assert, "signing")
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(err)
+
+	c := &Config{
+		Db:      map[string]DbConfig{DefaultDbAlias: {memSpec}},
+		Signing: SigningConfig{Key: signing.FormatKey(priv)},
+		Trusted: map[string]string{"me": signing.FormatKey(pub)},
+	}
+	writeConfig(assert, c, path.home)
+	ac, err := ReadConfig(path.config)
+	assert.NoError(err)
+	key, err := ac.SigningKey()
+	assert.NoError(err)
+	assert.Equal(priv, key)
+	trusted, err := ac.TrustedKeys()
+	assert.NoError(err)
+	assert.Equal(signing.KeySet{"me": pub}, trusted)
+
+	// A relative key_file is relative to the config file.
+	assert.NoError(ioutil.WriteFile(filepath.Join(path.home, "key"), []byte(signing.FormatKey(priv)+"\n"), 0600))
+	c.Signing = SigningConfig{KeyFile: "key"}
+	writeConfig(assert, c, path.home)
+	ac, err = ReadConfig(path.config)
+	assert.NoError(err)
+	key, err = ac.SigningKey()
+	assert.NoError(err)
+	assert.Equal(priv, key)
+
+	c.Signing, c.Trusted = SigningConfig{}, map[string]string{"me": "not a key"}
+	writeConfig(assert, c, path.home)
+	ac, err = ReadConfig(path.config)
+	assert.NoError(err)
+	_, err = ac.SigningKey()
+	assert.Error(err)
+	_, err = ac.TrustedKeys()
+	assert.Error(err)
+}
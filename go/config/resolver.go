@@ -12,7 +12,9 @@ import (
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/signing"
 	"github.com/attic-labs/noms/go/util/verbose"
+	"golang.org/x/crypto/ed25519"
 )
 
 type Resolver struct {
@@ -35,6 +37,23 @@ func NewResolver() *Resolver {
 	return &Resolver{c, ""}
 }
 
+// SigningKey returns the key configured in .nomsconfig for signing commits.
+func (r *Resolver) SigningKey() (ed25519.PrivateKey, error) {
+	if r.config == nil {
+		return nil, fmt.Errorf("no signing key: %s", NoConfig)
+	}
+	return r.config.SigningKey()
+}
+
+// TrustedKeys returns the keys of trusted commit signers configured in
+// .nomsconfig, if there are any.
+func (r *Resolver) TrustedKeys() (signing.KeySet, error) {
+	if r.config == nil {
+		return signing.KeySet{}, nil
+	}
+	return r.config.TrustedKeys()
+}
+
 // Print replacement if one occurred
 func (r *Resolver) verbose(orig string, replacement string) string {
 	if orig != replacement {
@@ -92,7 +111,9 @@ func (r *Resolver) GetDatabase(str string) (datas.Database, error) {
 	if err != nil {
 		return nil, err
 	}
-	return sp.GetDatabase(), nil
+	var db datas.Database
+	err = datas.CatchRemoteErrors(func() { db = sp.GetDatabase() })
+	return db, err
 }
 
 // Resolve string to a chunkstore. Like ResolveDatabase, but returns the underlying ChunkStore
@@ -112,7 +133,10 @@ func (r *Resolver) GetDataset(str string) (datas.Database, datas.Dataset, error)
 	if err != nil {
 		return nil, datas.Dataset{}, err
 	}
-	return sp.GetDatabase(), sp.GetDataset(), nil
+	var db datas.Database
+	var ds datas.Dataset
+	err = datas.CatchRemoteErrors(func() { db, ds = sp.GetDatabase(), sp.GetDataset() })
+	return db, ds, err
 }
 
 // Resolve string to a value path. If a config is present,
@@ -123,5 +147,8 @@ func (r *Resolver) GetPath(str string) (datas.Database, types.Value, error) {
 	if err != nil {
 		return nil, nil, err
 	}
-	return sp.GetDatabase(), sp.GetValue(), nil
+	var db datas.Database
+	var val types.Value
+	err = datas.CatchRemoteErrors(func() { db, val = sp.GetDatabase(), sp.GetValue() })
+	return db, val, err
 }
@@ -35,6 +35,8 @@ var (
 			DefaultDbAlias: {localSpec},
 			remoteAlias:    {remoteSpec},
 		},
+		SigningConfig{},
+		nil,
 	}
 
 	dbTestsNoAliases = []testData{
@@ -8,8 +8,10 @@ const (
 	RootPath       = "/root/"
 	GetRefsPath    = "/getRefs/"
 	GetBlobPath    = "/getBlob/"
+	GetPackPath    = "/getPack/"
 	HasRefsPath    = "/hasRefs/"
 	WriteValuePath = "/writeValue/"
+	ReplicaPath    = "/replica/"
 	BasePath       = "/"
 
 	GraphQLPath = "/graphql/"
@@ -0,0 +1,134 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/datetime"
+)
+
+// CommitDateField is the field of a Commit's meta holding the date it was
+// made, as written by spec.CreateCommitMetaStruct().
+const CommitDateField = "date"
+
+// AsOfRe is a regexp that matches the timestamp of an as-of Dataset, e.g.
+// the '2017-01-01T00:00Z' of 'ds@2017-01-01T00:00Z', anywhere within the
+// target string. See ParseAsOf().
+var AsOfRe = regexp.MustCompile(`\d{4}-\d{2}-\d{2}(T\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:\d{2})?)?`)
+
+var asOfLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// ParseAsOf parses the timestamp of an as-of Dataset. It's RFC 3339, but
+// seconds, or the whole time of day, can be left out. If there's no time
+// zone, it's UTC.
+func ParseAsOf(s string) (time.Time, error) {
+	if AsOfRe.FindString(s) == s {
+		for _, layout := range asOfLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("Invalid timestamp: %s", s)
+}
+
+// SplitAsOf splits a Dataset name of the form 'ds@timestamp' into the name
+// of the Dataset and the time it's as of. If s has no '@', the time is zero.
+func SplitAsOf(s string) (string, time.Time, error) {
+	idx := strings.LastIndex(s, "@")
+	if idx == -1 {
+		return s, time.Time{}, nil
+	}
+	t, err := ParseAsOf(s[idx+1:])
+	return s[:idx], t, err
+}
+
+// CommitDate returns the date in the meta of commit, if it has one.
+func CommitDate(commit types.Struct) (time.Time, bool) {
+	meta, ok := commit.Get(MetaField).(types.Struct)
+	if !ok {
+		return time.Time{}, false
+	}
+	v, ok := meta.MaybeGet(CommitDateField)
+	if !ok {
+		return time.Time{}, false
+	}
+	if s, ok := v.(types.String); ok {
+		t, err := time.Parse(time.RFC3339Nano, string(s))
+		return t, err == nil
+	}
+	if types.TypeOf(v).Equals(datetime.DateTimeType) {
+		var dt datetime.DateTime
+		err := dt.UnmarshalNoms(v)
+		return dt.Time, err == nil
+	}
+	return time.Time{}, false
+}
+
+// AsOf returns ds as it was at t: with the newest Commit at or before t as
+// its Head. Only the Head and its first parents are considered, where the
+// first parent of a merge Commit is its tallest parent, i.e. the one with the
+// longest history. Commits without a date are skipped. If there's no such
+// Commit, the returned Dataset has no Head.
+//
+// Committing to the returned Dataset is like committing to an out of date
+// one: it's merged with, or conflicts with, the current Head.
+func (ds Dataset) AsOf(t time.Time) Dataset {
+	commit, ok := ds.MaybeHead()
+	for ok {
+		if date, hasDate := CommitDate(commit); hasDate && !date.After(t) {
+			return newDataset(ds.db, ds.id, commit)
+		}
+		var parent types.Ref
+		commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+			if r := v.(types.Ref); r.Height() > parent.Height() {
+				parent = r
+			}
+		})
+		if ok = parent.Height() > 0; ok {
+			commit = parent.TargetValue(ds.db).(types.Struct)
+		}
+	}
+	return newDataset(ds.db, ds.id, nil)
+}
+
+// AsOfAnyParent is like AsOf(), but considers every ancestor of the Head,
+// not only its first parents. Of the Commits at or before t, the tallest one
+// is returned.
+func (ds Dataset) AsOfAnyParent(t time.Time) Dataset {
+	r, ok := ds.MaybeHeadRef()
+	if !ok {
+		return ds
+	}
+	queue, seen := &types.RefByHeight{r}, hash.HashSet{}
+	seen.Insert(r.TargetHash())
+	for !queue.Empty() {
+		commit := queue.PopBack().TargetValue(ds.db).(types.Struct)
+		if date, ok := CommitDate(commit); ok && !date.After(t) {
+			return newDataset(ds.db, ds.id, commit)
+		}
+		commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+			if p := v.(types.Ref); !seen.Has(p.TargetHash()) {
+				seen.Insert(p.TargetHash())
+				queue.PushBack(p)
+			}
+		})
+		sort.Sort(queue)
+	}
+	return newDataset(ds.db, ds.id, nil)
+}
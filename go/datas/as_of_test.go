@@ -0,0 +1,129 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/datetime"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAsOf(t *testing.T) {
+	assert := assert.New(t)
+
+	test := func(s string, exp time.Time) {
+		act, err := ParseAsOf(s)
+		assert.NoError(err)
+		assert.True(exp.Equal(act), "%s: expected %s, got %s", s, exp, act)
+	}
+	test("2017-01-02", time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC))
+	test("2017-01-02T03:04", time.Date(2017, 1, 2, 3, 4, 0, 0, time.UTC))
+	test("2017-01-02T03:04Z", time.Date(2017, 1, 2, 3, 4, 0, 0, time.UTC))
+	test("2017-01-02T03:04-08:00", time.Date(2017, 1, 2, 11, 4, 0, 0, time.UTC))
+	test("2017-01-02T03:04:05.5Z", time.Date(2017, 1, 2, 3, 4, 5, 5e8, time.UTC))
+
+	for _, s := range []string{"", "yesterday", "2017-1-2", "2017-01-02T03", "2017-13-01", "2017-01-02 "} {
+		_, err := ParseAsOf(s)
+		assert.Error(err, s)
+	}
+
+	id, asOf, err := SplitAsOf("ds@2017-01-02")
+	assert.NoError(err)
+	assert.Equal("ds", id)
+	assert.True(asOf.Equal(time.Date(2017, 1, 2, 0, 0, 0, 0, time.UTC)))
+	id, asOf, err = SplitAsOf("ds")
+	assert.NoError(err)
+	assert.Equal("ds", id)
+	assert.True(asOf.IsZero())
+}
+
+func TestDatasetAsOf(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	dated := func(date string) types.Struct {
+		return types.NewStruct("Meta", types.StructData{CommitDateField: types.String(date)})
+	}
+	commit := func(ds Dataset, v string, meta types.Struct, parents ...types.Value) Dataset {
+		opts := CommitOptions{Meta: meta}
+		if len(parents) > 0 {
+			opts.Parents = types.NewSet(db, parents...)
+		}
+		ds, err := db.Commit(ds, types.String(v), opts)
+		assert.NoError(err)
+		return ds
+	}
+
+	// a <- b <- c <- e <- m
+	//  \                 /
+	//   x <--------------
+	ds := commit(db.GetDataset("ds"), "a", dated("2017-01-01T00:00:00Z"))
+	a := ds.HeadRef()
+	ds = commit(ds, "b", types.EmptyStruct)
+	ds = commit(ds, "c", dated("2017-03-01T00:00:00Z"))
+	ds = commit(ds, "e", dated("2017-03-05T00:00:00-08:00"))
+	x := commit(db.GetDataset("side"), "x", dated("2017-02-01T00:00:00Z"), a)
+	ds = commit(ds, "m", dated("2017-04-01T00:00:00Z"), ds.HeadRef(), x.HeadRef())
+
+	asOf := func(s string, anyParent bool) types.Value {
+		ts, err := ParseAsOf(s)
+		assert.NoError(err)
+		var r Dataset
+		if anyParent {
+			r = ds.AsOfAnyParent(ts)
+		} else {
+			r = ds.AsOf(ts)
+		}
+		assert.Equal(ds.ID(), r.ID())
+		if v, ok := r.MaybeHeadValue(); ok {
+			return v
+		}
+		return nil
+	}
+
+	assert.Equal(types.String("m"), asOf("2017-04-01", false))
+	assert.Equal(types.String("e"), asOf("2017-03-05T08:00Z", false))
+	assert.Equal(types.String("c"), asOf("2017-03-05T07:59Z", false))
+	assert.Equal(types.String("a"), asOf("2017-02-15", false))
+	assert.Nil(asOf("2016-12-31", false))
+
+	assert.Equal(types.String("c"), asOf("2017-03-01", true))
+	assert.Equal(types.String("x"), asOf("2017-02-15", true))
+	assert.Equal(types.String("a"), asOf("2017-01-15", true))
+	assert.Nil(asOf("2016-12-31", true))
+
+	empty := db.GetDataset("empty")
+	assert.False(empty.AsOf(time.Now()).HasHead())
+	assert.False(empty.AsOfAnyParent(time.Now()).HasHead())
+}
+
+func TestCommitDate(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	now := time.Date(2017, 1, 2, 3, 4, 5, 0, time.UTC)
+	test := func(meta types.Struct, ok bool) {
+		date, hasDate := CommitDate(NewCommit(types.Bool(true), types.NewSet(db), meta))
+		assert.Equal(ok, hasDate)
+		if ok {
+			assert.True(now.Equal(date))
+		}
+	}
+	dt, err := datetime.DateTime{Time: now}.MarshalNoms(db)
+	assert.NoError(err)
+	test(types.NewStruct("", types.StructData{CommitDateField: types.String(now.Format(time.RFC3339))}), true)
+	test(types.NewStruct("", types.StructData{CommitDateField: dt}), true)
+	test(types.NewStruct("", types.StructData{CommitDateField: types.String("last tuesday")}), false)
+	test(types.NewStruct("", types.StructData{CommitDateField: types.Number(42)}), false)
+	test(types.EmptyStruct, false)
+}
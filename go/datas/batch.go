@@ -0,0 +1,187 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/types"
+	"golang.org/x/crypto/ed25519"
+)
+
+// Batch collects updates to several Datasets of a Database, which Apply()
+// makes atomically: readers of the Database see either all of them or none
+// of them. Create one with Database.Batch().
+type Batch struct {
+	db  *database
+	ops []batchOp
+	err error
+}
+
+type batchOp struct {
+	datasetID string
+
+	expectedHead hash.Hash
+
+	// For commits.
+	commit      types.Struct
+	mergePolicy merge.Policy
+	signingKey  ed25519.PrivateKey
+
+	// For deletes.
+	isDelete bool
+	head     types.Ref // the head when Delete() was called
+	hasHead  bool
+
+	// For setHead(), whose expectedHead is empty if the Dataset is expected
+	// to have no head.
+	isSetHead bool
+}
+
+func (db *database) Batch() *Batch {
+	return &Batch{db: db}
+}
+
+// Commit adds a commit of v to ds to the batch, just as Database.Commit()
+// would make it. If Apply() finds that the head of ds has moved, v is merged
+// with the new head according to opts.Policy, unless opts.ExpectedHead is
+// set, in which case Apply() returns an '*ErrHeadMoved'.
+func (b *Batch) Commit(ds Dataset, v types.Value, opts CommitOptions) *Batch {
+	return b.add(batchOp{datasetID: ds.ID(), commit: buildNewCommit(ds, v, opts), mergePolicy: opts.Policy, expectedHead: opts.ExpectedHead, signingKey: opts.SigningKey})
+}
+
+// CommitValue adds a commit of v to ds to the batch, using the current head
+// of ds as its lone parent.
+func (b *Batch) CommitValue(ds Dataset, v types.Value) *Batch {
+	return b.Commit(ds, v, CommitOptions{})
+}
+
+// Delete adds the removal of ds to the batch. If the head of ds has moved
+// by the time Apply() is called, including if ds had no head and has since
+// been committed to, Apply() returns 'ErrMergeNeeded'.
+func (b *Batch) Delete(ds Dataset) *Batch {
+	head, hasHead := ds.MaybeHeadRef()
+	return b.add(batchOp{datasetID: ds.ID(), isDelete: true, head: head, hasHead: hasHead})
+}
+
+// DeleteExpecting adds the removal of ds to the batch, provided that its head
+// is the Commit whose hash is expectedHead when Apply() is called. If it
+// isn't, Apply() returns an '*ErrHeadMoved'.
+func (b *Batch) DeleteExpecting(ds Dataset, expectedHead hash.Hash) *Batch {
+	return b.add(batchOp{datasetID: ds.ID(), isDelete: true, expectedHead: expectedHead})
+}
+
+// setHead adds setting the head of the Dataset datasetID to commit to the
+// batch, whether or not commit descends from it, like Database.SetHead(). If
+// the head by the time Apply() is called isn't the Commit whose hash is
+// expectedHead, or if expectedHead is empty and the Dataset has a head,
+// Apply() returns an '*ErrHeadMoved'. Unlike Commit(), it may update a
+// reserved Dataset.
+func (b *Batch) setHead(datasetID string, commit types.Struct, expectedHead hash.Hash) *Batch {
+	b.ops = append(b.ops, batchOp{datasetID: datasetID, commit: commit, expectedHead: expectedHead, isSetHead: true})
+	return b
+}
+
+// deleteReserved adds the removal of the reserved Dataset ds to the batch,
+// like Delete().
+func (b *Batch) deleteReserved(ds Dataset) *Batch {
+	head, hasHead := ds.MaybeHeadRef()
+	b.ops = append(b.ops, batchOp{datasetID: ds.ID(), isDelete: true, head: head, hasHead: hasHead})
+	return b
+}
+
+func (b *Batch) add(op batchOp) *Batch {
+	if IsReservedDatasetID(op.datasetID) && b.err == nil {
+		b.err = ErrReservedDataset
+	}
+	for _, o := range b.ops {
+		if o.datasetID == op.datasetID && b.err == nil {
+			b.err = fmt.Errorf("Dataset %s is updated more than once in a batch", op.datasetID)
+		}
+	}
+	b.ops = append(b.ops, op)
+	return b
+}
+
+// Apply makes every update in the batch with a single commit to the
+// underlying ChunkStore. If any of the updates can't be made, e.g. because
+// the head of a Dataset moved and couldn't be merged, none of them are, and
+// Apply returns the error that the corresponding Database method would
+// have. Either way, Datasets() is updated to match backing storage upon
+// return.
+func (b *Batch) Apply() (err error) {
+	if b.err != nil {
+		return b.err
+	}
+	if cerr := CatchRemoteErrors(func() { err = b.apply() }); cerr != nil {
+		return cerr
+	}
+	return
+}
+
+func (b *Batch) apply() error {
+	db := b.db
+	var err error
+	for err = ErrOptimisticLockFailed; err == ErrOptimisticLockFailed; {
+		currentRootHash, currentDatasets := db.rt.Root(), db.root()
+		if err := db.checkChunkConfig(currentDatasets); err != nil {
+			return err
+		}
+		for _, op := range b.ops {
+			if op.isDelete {
+				currentDatasets, err = b.deleteFromDatasets(currentDatasets, op)
+			} else if op.isSetHead {
+				currentDatasets, err = b.setHeadInDatasets(currentDatasets, op)
+			} else if err = checkExpectedHead(currentDatasets, op.datasetID, op.expectedHead); err == nil {
+				currentDatasets, err = db.commitToDatasets(currentDatasets, op.datasetID, op.commit, op.mergePolicy, op.signingKey)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		err = db.tryCommitChunks(currentDatasets, currentRootHash)
+	}
+	return err
+}
+
+func (b *Batch) deleteFromDatasets(datasets types.Map, op batchOp) (types.Map, error) {
+	if !op.expectedHead.IsEmpty() {
+		if err := checkExpectedHead(datasets, op.datasetID, op.expectedHead); err != nil {
+			return datasets, err
+		}
+		return datasets.Edit().Remove(types.String(op.datasetID)).Map(), nil
+	}
+	datasetID := types.String(op.datasetID)
+	r, hasHead := datasets.MaybeGet(datasetID)
+	if hasHead != op.hasHead || (hasHead && op.head.TargetHash() != r.(types.Ref).TargetHash()) {
+		return datasets, ErrMergeNeeded
+	}
+	if !hasHead {
+		return datasets, nil
+	}
+	return datasets.Edit().Remove(datasetID).Map(), nil
+}
+
+func (b *Batch) setHeadInDatasets(datasets types.Map, op batchOp) (types.Map, error) {
+	datasetID := types.String(op.datasetID)
+	if op.expectedHead.IsEmpty() {
+		if r, hasHead := datasets.MaybeGet(datasetID); hasHead {
+			return datasets, &ErrHeadMoved{DatasetID: op.datasetID, Current: r.(types.Ref).TargetHash()}
+		}
+	} else if err := checkExpectedHead(datasets, op.datasetID, op.expectedHead); err != nil {
+		return datasets, err
+	}
+	schemas, err := readSchemas(datasets, b.db)
+	if err != nil {
+		return datasets, err
+	}
+	if err := checkSchema(schemas, op.datasetID, op.commit.Get(ValueField)); err != nil {
+		return datasets, err
+	}
+	commitRef := b.db.WriteValue(op.commit) // will be orphaned if the new datasets are never committed
+	return datasets.Edit().Set(datasetID, types.ToRefOfValue(commitRef)).Map(), nil
+}
@@ -0,0 +1,356 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+/*
+  Bundle Serialization:
+    Magic     // "NOMSBNDL"
+    Version   // 4-byte length, followed by the NomsVersion of the chunks
+    Head      // 20-byte hash
+    NumSince  // 4-byte int
+    Since 0   // 20-byte hash
+     ..
+    Since N
+    Chunks    // serialized by chunks.Serialize(), until EOF
+*/
+
+const bundleMagic = "NOMSBNDL"
+
+// ErrNotBundle is returned by ReadBundle() if its input doesn't start with a
+// bundle header.
+var ErrNotBundle = errors.New("Not a Noms bundle")
+
+// BundleHeader describes the chunks in a bundle: every chunk reachable from
+// Head which isn't reachable from any of Since.
+type BundleHeader struct {
+	Head  hash.Hash
+	Since hash.HashSlice
+}
+
+// WriteBundle writes every chunk in db which is reachable from head, but not
+// from any of since, to w, preceded by a header naming head and since.
+// ReadBundle() loads them into a Database which already has the chunks
+// reachable from since.
+func WriteBundle(db Database, head types.Ref, since []types.Ref, w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	header := BundleHeader{Head: head.TargetHash()}
+	for _, r := range since {
+		header.Since = append(header.Since, r.TargetHash())
+	}
+	writeBundleHeader(bw, header)
+
+	walkMissingChunks(db.chunkStore(), db, head, since, func(c chunks.Chunk) {
+		chunks.Serialize(c, bw)
+	})
+	return bw.Flush()
+}
+
+func writeBundleHeader(w *bufio.Writer, header BundleHeader) {
+	w.WriteString(bundleMagic)
+	binary.Write(w, binary.BigEndian, uint32(len(constants.NomsVersion)))
+	w.WriteString(constants.NomsVersion)
+	w.Write(header.Head[:])
+	binary.Write(w, binary.BigEndian, uint32(len(header.Since)))
+	for _, h := range header.Since {
+		w.Write(h[:])
+	}
+}
+
+// ReadBundle loads the chunks in a bundle written by WriteBundle() into db,
+// and returns its header. It returns an error, without loading anything, if
+// db doesn't have the chunks the bundle was written since, or if the chunks
+// don't complete the graph reachable from the head, e.g. because the bundle
+// was truncated. To check that, the chunks are staged in a temporary file
+// before any are put into db. The chunks are persisted, but nothing refers to
+// them until the caller commits the head, e.g. with FastForward().
+func ReadBundle(db Database, r io.Reader) (BundleHeader, error) {
+	br := bufio.NewReader(r)
+	header, err := readBundleHeader(br)
+	if err != nil {
+		return BundleHeader{}, err
+	}
+
+	cs := db.chunkStore()
+	if len(header.Since) > 0 {
+		if absent := cs.HasMany(header.Since.HashSet()); len(absent) > 0 {
+			missing := hash.HashSlice{}
+			for h := range absent {
+				missing = append(missing, h)
+			}
+			sort.Sort(missing)
+			return BundleHeader{}, fmt.Errorf("Bundle was created since #%s, which the database doesn't have", missing[0])
+		}
+	}
+
+	staged, err := ioutil.TempFile("", "noms-bundle")
+	if err != nil {
+		return BundleHeader{}, err
+	}
+	defer os.Remove(staged.Name())
+	defer staged.Close()
+	sw := bufio.NewWriter(staged)
+
+	// Every chunk the bundle refers to, including its head, must either be in it, or already be in db.
+	loaded, refs := hash.HashSet{}, hash.NewHashSet(header.Head)
+	err = readBundleChunks(io.TeeReader(br, sw), func(c *chunks.Chunk) {
+		loaded.Insert(c.Hash())
+		types.DecodeValue(*c, db).WalkRefs(func(r types.Ref) {
+			refs.Insert(r.TargetHash())
+		})
+	})
+	if err != nil {
+		return BundleHeader{}, err
+	}
+	for h := range loaded {
+		delete(refs, h)
+	}
+	if absent := cs.HasMany(refs); len(absent) > 0 {
+		if absent.Has(header.Head) {
+			return BundleHeader{}, fmt.Errorf("Bundle is missing its head #%s", header.Head)
+		}
+		return BundleHeader{}, fmt.Errorf("Bundle is incomplete: %d chunks reachable from its head are missing", len(absent))
+	}
+
+	if err := sw.Flush(); err != nil {
+		return BundleHeader{}, err
+	}
+	if _, err := staged.Seek(0, io.SeekStart); err != nil {
+		return BundleHeader{}, err
+	}
+	if err := readBundleChunks(bufio.NewReader(staged), func(c *chunks.Chunk) { cs.Put(*c) }); err != nil {
+		return BundleHeader{}, err
+	}
+	persistChunks(cs)
+	return header, nil
+}
+
+// readBundleChunks calls f with each chunk serialized in r, in order.
+func readBundleChunks(r io.Reader, f func(c *chunks.Chunk)) error {
+	chunkChan := make(chan *chunks.Chunk, 16)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(chunkChan)
+		errChan <- chunks.Deserialize(r, chunkChan)
+	}()
+	for c := range chunkChan {
+		f(c)
+	}
+	return <-errChan
+}
+
+func readBundleHeader(r io.Reader) (header BundleHeader, err error) {
+	magic := make([]byte, len(bundleMagic))
+	if _, err = io.ReadFull(r, magic); err != nil || string(magic) != bundleMagic {
+		return BundleHeader{}, ErrNotBundle
+	}
+
+	var l uint32
+	if err = binary.Read(r, binary.BigEndian, &l); err != nil {
+		return
+	}
+	version := make([]byte, l)
+	if _, err = io.ReadFull(r, version); err != nil {
+		return
+	}
+	if string(version) != constants.NomsVersion {
+		return BundleHeader{}, fmt.Errorf("Bundle has Noms version %s, but this is version %s", version, constants.NomsVersion)
+	}
+
+	if _, err = io.ReadFull(r, header.Head[:]); err != nil {
+		return
+	}
+	if err = binary.Read(r, binary.BigEndian, &l); err != nil {
+		return
+	}
+	header.Since = make(hash.HashSlice, l)
+	for i := range header.Since {
+		if _, err = io.ReadFull(r, header.Since[i][:]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// walkMissingChunks calls f with every chunk in cs which is reachable from
+// want, but not from any of haves, tallest first. Since refs are always
+// taller than their targets, once everything reachable from haves down to a
+// given height has been visited, any chunk of that height which is reachable
+// from both want and haves has been found. Haves which cs doesn't have are
+// ignored.
+//
+// If want is a Commit, haves are the heads of the caller's Datasets, and
+// walking everything reachable from them would mean walking most of the
+// database. Instead, the commits missing from haves are found first, by
+// commitBoundary(), and the chunks of those commits which can be in common
+// with haves are looked for only in the values of the commits where the
+// histories meet. Chunks which are only in common with the rest of haves,
+// e.g. another Dataset, are then walked as if they were missing.
+//
+// A had chunk which is also reachable from want isn't walked any further:
+// want doesn't need anything below it, and neither side of the walk
+// descends into it. So when want's value is a small change to a had value,
+// only the chunks of the had value which the change replaced are read,
+// rather than the whole value. If a chunk below one which isn't walked is
+// also reachable from want some other way, it's walked as missing.
+func walkMissingChunks(cs chunks.ChunkStore, vrw types.ValueReadWriter, want types.Ref, haves []types.Ref, f func(c chunks.Chunk)) {
+	wantQueue, haveQueue := types.RefByHeight{want}, types.RefByHeight{}
+	wantSeen, haveSeen := hash.HashSet{}, hash.HashSet{}
+	wantSeen.Insert(want.TargetHash())
+	if boundary, ok := commitBoundary(vrw, want, haves); ok {
+		haves = nil
+		for _, r := range boundary {
+			if r.TargetHash() == want.TargetHash() {
+				return
+			}
+			// The commits themselves are had, but walking them would walk their histories.
+			wantSeen.Insert(r.TargetHash())
+			r.TargetValue(vrw).(types.Struct).Get(ValueField).WalkRefs(func(r types.Ref) {
+				haves = append(haves, r)
+			})
+		}
+	}
+	for _, r := range haves {
+		if !haveSeen.Has(r.TargetHash()) {
+			haveQueue.PushBack(r)
+			haveSeen.Insert(r.TargetHash())
+		}
+	}
+	sort.Sort(haveQueue)
+
+	for !wantQueue.Empty() {
+		height := wantQueue.MaxHeight()
+		if haveQueue.MaxHeight() > height {
+			height = haveQueue.MaxHeight()
+		}
+
+		wanted := hash.HashSet{}
+		for _, r := range wantQueue.PopRefsOfHeight(height) {
+			wanted.Insert(r.TargetHash())
+		}
+		had := hash.HashSet{}
+		for _, r := range haveQueue.PopRefsOfHeight(height) {
+			had.Insert(r.TargetHash())
+			delete(wanted, r.TargetHash())
+		}
+
+		toGet := hash.HashSet{}
+		for h := range wanted {
+			toGet.Insert(h)
+		}
+		for h := range had {
+			if wantSeen.Has(h) {
+				delete(had, h)
+			} else {
+				toGet.Insert(h)
+			}
+		}
+		found := map[hash.Hash]*chunks.Chunk{}
+		foundChan := make(chan *chunks.Chunk)
+		go func() { defer close(foundChan); cs.GetMany(toGet, foundChan) }()
+		for c := range foundChan {
+			found[c.Hash()] = c
+		}
+
+		ordered := hash.HashSlice{}
+		for h := range wanted {
+			ordered = append(ordered, h)
+		}
+		sort.Sort(ordered)
+		for _, h := range ordered {
+			c := found[h]
+			if c == nil {
+				d.Panic("Missing chunk #%s", h)
+			}
+			f(*c)
+			types.DecodeValue(*c, vrw).WalkRefs(func(r types.Ref) {
+				if !wantSeen.Has(r.TargetHash()) {
+					wantQueue.PushBack(r)
+					wantSeen.Insert(r.TargetHash())
+				}
+			})
+		}
+		for h := range had {
+			if c := found[h]; c != nil {
+				types.DecodeValue(*c, vrw).WalkRefs(func(r types.Ref) {
+					if !haveSeen.Has(r.TargetHash()) {
+						haveQueue.PushBack(r)
+						haveSeen.Insert(r.TargetHash())
+					}
+				})
+			}
+		}
+		sort.Sort(wantQueue)
+		sort.Sort(haveQueue)
+	}
+}
+
+// commitBoundary walks the history of want, tallest first, alongside the
+// histories of those of haves which are Commits, until each commit reachable
+// from want is found to be reachable from haves, or not. It returns the
+// commits of the first kind where the walk stopped, which may include want
+// itself. The histories of haves are only walked as far down as the lowest
+// commit that may be missing from them. It returns false if want isn't a
+// Commit.
+func commitBoundary(vr types.ValueReader, want types.Ref, haves []types.Ref) (boundary types.RefSlice, ok bool) {
+	if !IsCommit(want.TargetValue(vr)) {
+		return nil, false
+	}
+
+	wantQueue, haveQueue := &types.RefByHeight{want}, &types.RefByHeight{}
+	for _, r := range haves {
+		if v := r.TargetValue(vr); v != nil && IsCommit(v) {
+			haveQueue.PushBack(r)
+		}
+	}
+	sort.Sort(haveQueue)
+
+	wantSeen, haveSeen := hash.HashSet{}, hash.HashSet{}
+	for !wantQueue.Empty() {
+		height := wantQueue.MaxHeight()
+		if haveQueue.MaxHeight() > height {
+			height = haveQueue.MaxHeight()
+		}
+
+		had := types.RefSlice{}
+		for _, r := range haveQueue.PopRefsOfHeight(height) {
+			if !haveSeen.Has(r.TargetHash()) {
+				haveSeen.Insert(r.TargetHash())
+				had = append(had, r)
+			}
+		}
+		wanted := types.RefSlice{}
+		for _, r := range wantQueue.PopRefsOfHeight(height) {
+			if wantSeen.Has(r.TargetHash()) {
+				continue
+			}
+			wantSeen.Insert(r.TargetHash())
+			if haveSeen.Has(r.TargetHash()) {
+				boundary = append(boundary, r)
+			} else {
+				wanted = append(wanted, r)
+			}
+		}
+		parentsToQueue(had, haveQueue, vr)
+		parentsToQueue(wanted, wantQueue, vr)
+	}
+	return boundary, true
+}
@@ -0,0 +1,200 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func (suite *PullSuite) TestBundle() {
+	l1 := buildListOfHeight(3, suite.source)
+	ref1 := suite.commitToSource(l1, types.NewSet(suite.source))
+	l2 := buildListOfHeight(5, suite.source)
+	ref2 := suite.commitToSource(l2, types.NewSet(suite.source, ref1))
+
+	full, incremental := &bytes.Buffer{}, &bytes.Buffer{}
+	suite.NoError(WriteBundle(suite.source, ref1, nil, full))
+	suite.NoError(WriteBundle(suite.source, ref2, []types.Ref{ref1}, incremental))
+
+	// The sink doesn't have ref1 yet, so the incremental bundle can't be loaded.
+	_, err := ReadBundle(suite.sink, bytes.NewReader(incremental.Bytes()))
+	suite.Error(err)
+
+	// Nor can a bundle that's been truncated at a chunk boundary.
+	truncated := &bytes.Buffer{}
+	bw := bufio.NewWriter(truncated)
+	writeBundleHeader(bw, BundleHeader{Head: ref1.TargetHash()})
+	n := 0
+	walkMissingChunks(suite.source.chunkStore(), suite.source, ref1, nil, func(c chunks.Chunk) {
+		if n++; n <= 3 {
+			chunks.Serialize(c, bw)
+		}
+	})
+	suite.NoError(bw.Flush())
+	suite.True(n > 3)
+	_, err = ReadBundle(suite.sink, truncated)
+	if suite.Error(err) {
+		suite.Contains(err.Error(), "incomplete")
+	}
+	suite.False(suite.sink.chunkStore().Has(ref1.TargetHash()))
+
+	header, err := ReadBundle(suite.sink, full)
+	suite.NoError(err)
+	suite.Equal(ref1.TargetHash(), header.Head)
+	suite.Empty(header.Since)
+	sinkDS, err := suite.sink.FastForward(suite.sink.GetDataset(datasetID), ref1)
+	suite.NoError(err)
+
+	header, err = ReadBundle(suite.sink, incremental)
+	suite.NoError(err)
+	suite.Equal(ref2.TargetHash(), header.Head)
+	suite.Equal(hash.HashSlice{ref1.TargetHash()}, header.Since)
+	sinkDS, err = suite.sink.FastForward(sinkDS, ref2)
+	suite.NoError(err)
+	suite.True(l2.Equals(sinkDS.HeadValue()))
+}
+
+func TestWalkMissingChunks(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	l1 := buildListOfHeight(3, db)
+	ds, err := db.CommitValue(db.GetDataset(datasetID), l1)
+	assert.NoError(err)
+	ref1 := ds.HeadRef()
+	ds, err = db.CommitValue(ds, buildListOfHeight(4, db))
+	assert.NoError(err)
+	ref2 := ds.HeadRef()
+
+	haveChunks := hash.HashSet{}
+	walkMissingChunks(db.chunkStore(), db, ref1, nil, func(c chunks.Chunk) {
+		haveChunks.Insert(c.Hash())
+	})
+
+	missing := hash.HashSet{}
+	lastHeight := ^uint64(0)
+	walkMissingChunks(db.chunkStore(), db, ref2, []types.Ref{ref1}, func(c chunks.Chunk) {
+		assert.False(haveChunks.Has(c.Hash()))
+		assert.False(missing.Has(c.Hash()))
+		missing.Insert(c.Hash())
+
+		// Chunks are walked tallest first.
+		height := uint64(1)
+		types.DecodeValue(c, db).WalkRefs(func(r types.Ref) {
+			if r.Height()+1 > height {
+				height = r.Height() + 1
+			}
+		})
+		assert.True(height <= lastHeight)
+		lastHeight = height
+	})
+	assert.True(missing.Has(ref2.TargetHash()))
+	assert.False(missing.Has(ref1.TargetHash()))
+
+	// Everything reachable from ref2 is either missing or had.
+	all := hash.HashSet{}
+	walkMissingChunks(db.chunkStore(), db, ref2, nil, func(c chunks.Chunk) {
+		all.Insert(c.Hash())
+		assert.True(missing.Has(c.Hash()) || haveChunks.Has(c.Hash()))
+	})
+	assert.True(len(all) > len(missing))
+}
+
+// readCountingStore records the chunks read from it.
+type readCountingStore struct {
+	chunks.ChunkStore
+	read hash.HashSet
+}
+
+func (s *readCountingStore) Get(h hash.Hash) chunks.Chunk {
+	s.read.Insert(h)
+	return s.ChunkStore.Get(h)
+}
+
+func (s *readCountingStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
+	for h := range hashes {
+		s.read.Insert(h)
+	}
+	s.ChunkStore.GetMany(hashes, foundChunks)
+}
+
+func TestWalkMissingChunksOnlyReadsHistoryInCommon(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	listOf := func(start int) types.List {
+		nums := make([]types.Value, 5000)
+		for i := range nums {
+			nums[i] = types.Number(start + i)
+		}
+		return types.NewList(db, nums...)
+	}
+	other, err := db.CommitValue(db.GetDataset("other"), listOf(1e6))
+	assert.NoError(err)
+	ds, err := db.CommitValue(db.GetDataset(datasetID), listOf(0))
+	assert.NoError(err)
+	ref1 := ds.HeadRef()
+	ds, err = db.CommitValue(ds, ds.HeadValue().(types.List).Edit().Set(0, types.Number(-1)).List())
+	assert.NoError(err)
+	ref2 := ds.HeadRef()
+
+	had, otherChunks := hash.HashSet{}, hash.HashSet{}
+	walkMissingChunks(db.chunkStore(), db, ref1, nil, func(c chunks.Chunk) {
+		had.Insert(c.Hash())
+	})
+	walkMissingChunks(db.chunkStore(), db, other.HeadRef(), nil, func(c chunks.Chunk) {
+		otherChunks.Insert(c.Hash())
+	})
+	assert.True(len(otherChunks) > 10)
+
+	cs := &readCountingStore{db.chunkStore(), hash.HashSet{}}
+	missing := hash.HashSet{}
+	walkMissingChunks(cs, types.NewValueStore(cs), ref2, []types.Ref{other.HeadRef(), ref1}, func(c chunks.Chunk) {
+		assert.False(had.Has(c.Hash()))
+		missing.Insert(c.Hash())
+	})
+	assert.True(missing.Has(ref2.TargetHash()))
+	assert.True(len(missing) < len(had))
+
+	// Of ref1's value, only the chunks which the change replaced are read.
+	readHad := 0
+	for h := range had {
+		if cs.read.Has(h) {
+			readHad++
+		}
+	}
+	assert.True(readHad <= len(missing), "read %d of %d had chunks", readHad, len(had))
+
+	// Of the other Dataset, only its head commit is read.
+	for h := range otherChunks {
+		assert.Equal(h == other.HeadRef().TargetHash(), cs.read.Has(h))
+	}
+
+	// Nothing is missing from a have.
+	walkMissingChunks(db.chunkStore(), db, ref1, []types.Ref{ref2}, func(c chunks.Chunk) {
+		assert.Fail("Nothing should be missing", "#%s", c.Hash())
+	})
+}
+
+func TestReadBundleNotBundle(t *testing.T) {
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	_, err := ReadBundle(db, strings.NewReader("not a bundle at all"))
+	assert.Equal(t, ErrNotBundle, err)
+}
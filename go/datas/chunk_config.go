@@ -0,0 +1,256 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// reservedDatasetPrefix prefixes the IDs of Datasets in which a Database
+// records state about itself, rather than user data.
+const reservedDatasetPrefix = "__noms/"
+
+// chunkConfigDatasetID is the reserved Dataset whose head records the
+// types.ChunkConfig that all writers of a Database must use.
+const chunkConfigDatasetID = reservedDatasetPrefix + "chunk-config"
+
+const (
+	chunkConfigName      = "ChunkConfig"
+	chunkTargetSizeField = "targetSize"
+	chunkWindowField     = "window"
+)
+
+var (
+	ErrChunkConfigMismatch = errors.New("Chunk config does not match the one recorded in the database")
+	ErrReservedDataset     = errors.New("Dataset is reserved for use by Noms")
+)
+
+// IsReservedDatasetID returns true if id names a Dataset used by Noms to
+// store state about the Database itself.
+func IsReservedDatasetID(id string) bool {
+	return strings.HasPrefix(id, reservedDatasetPrefix)
+}
+
+// hasUserDatasets returns true if datasets has any Dataset that isn't reserved.
+func hasUserDatasets(datasets types.Map) bool {
+	return datasets.Any(func(k, v types.Value) bool {
+		return !IsReservedDatasetID(string(k.(types.String)))
+	})
+}
+
+// splitReservedDatasets returns the Datasets in datasets that aren't
+// reserved, and those that are.
+func (db *database) splitReservedDatasets(datasets types.Map) (user, reserved types.Map) {
+	user = datasets
+	re := types.NewMap(db).Edit()
+	var ue *types.MapEditor
+	it := datasets.IteratorFrom(types.String(reservedDatasetPrefix))
+	for k, v := it.Next(); k != nil && IsReservedDatasetID(string(k.(types.String))); k, v = it.Next() {
+		if ue == nil {
+			ue = datasets.Edit()
+		}
+		ue.Remove(k)
+		re.Set(k, v)
+	}
+	if ue != nil {
+		user = ue.Map()
+	}
+	return user, re.Map()
+}
+
+// setReservedHead returns datasets with the head of the reserved Dataset id
+// set to a new Commit of v, whose parent is the previous head, if any.
+func (db *database) setReservedHead(datasets types.Map, id string, v types.Value) types.Map {
+	d.PanicIfFalse(IsReservedDatasetID(id))
+	parents := types.NewSet(db)
+	if r, ok := datasets.MaybeGet(types.String(id)); ok {
+		parents = parents.Edit().Insert(types.NewRef(db.validateRefAsCommit(r.(types.Ref)))).Set()
+	}
+	commitRef := db.WriteValue(NewCommit(v, parents, types.EmptyStruct))
+	return datasets.Edit().Set(types.String(id), types.ToRefOfValue(commitRef)).Map()
+}
+
+func (db *database) commitReserved(id string, v types.Value) (err error) {
+	d.PanicIfFalse(IsReservedDatasetID(id))
+	if cerr := CatchRemoteErrors(func() { err = db.doCommitReserved(id, v) }); cerr != nil {
+		return cerr
+	}
+	return
+}
+
+func (db *database) doCommitReserved(id string, v types.Value) error {
+	for {
+		currentRootHash, currentDatasets := db.rt.Root(), db.root()
+		if err := db.checkChunkConfig(currentDatasets); err != nil {
+			return err
+		}
+		if v == nil {
+			currentDatasets = currentDatasets.Edit().Remove(types.String(id)).Map()
+		} else {
+			currentDatasets = db.setReservedHead(currentDatasets, id, v)
+		}
+		if err := db.tryCommitChunks(currentDatasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return err
+		}
+	}
+}
+
+// assertReservedUpdatesAllowed panics if proposed changes a reserved Dataset
+// of last in a way that the Database methods which maintain it never would:
+// the chunk config may only be recorded once, by InitChunkConfig(), the
+// schemas only replaced by SetDatasetSchema(), and neither may be deleted.
+// The merge state may be saved or cleared, but must be a MergeState.
+func assertReservedUpdatesAllowed(proposed, last types.Map, vrw types.ValueReadWriter) {
+	diffMaps(proposed, last, func(change types.ValueChanged) {
+		id := string(change.Key.(types.String))
+		if !IsReservedDatasetID(id) {
+			return
+		}
+		if id == mergeStateDatasetID {
+			if change.ChangeType != types.DiffChangeRemoved {
+				commit := change.NewValue.(types.Ref).TargetValue(vrw).(types.Struct)
+				_, err := mergeStateFromValue(commit.Get(ValueField))
+				d.PanicIfError(err)
+			}
+			return
+		}
+		if change.ChangeType == types.DiffChangeRemoved {
+			d.Panic("Reserved dataset %s can't be deleted", id)
+		}
+		switch id {
+		case schemaDatasetID:
+			_, err := readSchemas(proposed, vrw)
+			d.PanicIfError(err)
+		case chunkConfigDatasetID:
+			if change.ChangeType != types.DiffChangeAdded {
+				d.Panic("%s", ErrChunkConfigMismatch)
+			}
+			commit := change.NewValue.(types.Ref).TargetValue(vrw).(types.Struct)
+			cfg, err := structToChunkConfig(commit.Get(ValueField))
+			d.PanicIfError(err)
+			if hasUserDatasets(last) && cfg != types.DefaultChunkConfig {
+				d.Panic("%s", ErrChunkConfigMismatch)
+			}
+		default:
+			d.Panic("Reserved dataset %s can't be updated", id)
+		}
+	})
+}
+
+func chunkConfigToStruct(cfg types.ChunkConfig) types.Struct {
+	return types.NewStruct(chunkConfigName, types.StructData{
+		chunkTargetSizeField: types.Number(cfg.TargetSize),
+		chunkWindowField:     types.Number(cfg.Window),
+	})
+}
+
+func structToChunkConfig(v types.Value) (types.ChunkConfig, error) {
+	s, ok := v.(types.Struct)
+	if !ok || s.Name() != chunkConfigName {
+		return types.ChunkConfig{}, fmt.Errorf("Malformed chunk config: %s", types.EncodedValueMaxLines(v, 10))
+	}
+	field := func(name string) (uint32, error) {
+		f, ok := s.MaybeGet(name)
+		if n, isNum := f.(types.Number); ok && isNum && float64(n) == float64(uint32(n)) {
+			return uint32(n), nil
+		}
+		return 0, fmt.Errorf("Malformed chunk config: field %s missing or not a uint32", name)
+	}
+
+	targetSize, err := field(chunkTargetSizeField)
+	if err != nil {
+		return types.ChunkConfig{}, err
+	}
+	window, err := field(chunkWindowField)
+	if err != nil {
+		return types.ChunkConfig{}, err
+	}
+	cfg := types.ChunkConfig{TargetSize: targetSize, Window: window}
+	return cfg, cfg.Validate()
+}
+
+// readChunkConfig returns the ChunkConfig recorded in datasets, if any.
+func (db *database) readChunkConfig(datasets types.Map) (cfg types.ChunkConfig, ok bool, err error) {
+	r, ok := datasets.MaybeGet(types.String(chunkConfigDatasetID))
+	if !ok {
+		return types.ChunkConfig{}, false, nil
+	}
+	commit := db.validateRefAsCommit(r.(types.Ref))
+	cfg, err = structToChunkConfig(commit.Get(ValueField))
+	return cfg, true, err
+}
+
+// loadChunkConfig makes db's ValueStore chunk values according to the
+// ChunkConfig recorded in the database, if any. It panics if the recorded
+// ChunkConfig is invalid, since writing through db would then risk building
+// trees that other writers can't reproduce.
+func (db *database) loadChunkConfig() {
+	cfg, ok, err := db.readChunkConfig(db.root())
+	if err != nil {
+		d.Panic("Database has an invalid chunk config: %s", err)
+	}
+	if ok {
+		db.ValueStore.SetChunkConfig(cfg)
+	}
+}
+
+// checkChunkConfig returns ErrChunkConfigMismatch if the ChunkConfig recorded
+// in datasets isn't the one db's ValueStore is using, which happens when
+// another writer recorded it after db was opened. In that case db switches to
+// the recorded ChunkConfig, so that values written from then on are chunked
+// the way other writers would chunk them.
+func (db *database) checkChunkConfig(datasets types.Map) error {
+	cfg, ok, err := db.readChunkConfig(datasets)
+	if err != nil || !ok || cfg == db.ValueStore.ChunkConfig() {
+		return err
+	}
+	db.ValueStore.SetChunkConfig(cfg)
+	return ErrChunkConfigMismatch
+}
+
+func (db *database) InitChunkConfig(cfg types.ChunkConfig) (err error) {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cerr := CatchRemoteErrors(func() { err = db.initChunkConfig(cfg) }); cerr != nil {
+		return cerr
+	}
+	return
+}
+
+func (db *database) initChunkConfig(cfg types.ChunkConfig) error {
+	for {
+		currentRootHash, currentDatasets := db.rt.Root(), db.root()
+		stored, ok, err := db.readChunkConfig(currentDatasets)
+		if err != nil {
+			return err
+		}
+		if ok {
+			if stored != cfg {
+				return ErrChunkConfigMismatch
+			}
+			return nil
+		}
+
+		// Data written before any chunk config was recorded was chunked using the default.
+		if hasUserDatasets(currentDatasets) && cfg != types.DefaultChunkConfig {
+			return ErrChunkConfigMismatch
+		}
+
+		currentDatasets = db.setReservedHead(currentDatasets, chunkConfigDatasetID, chunkConfigToStruct(cfg))
+		err = db.tryCommitChunks(currentDatasets, currentRootHash)
+		if err != ErrOptimisticLockFailed {
+			if err == nil {
+				db.ValueStore.SetChunkConfig(cfg)
+			}
+			return err
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package datas
 
 import (
+	"math"
 	"sort"
 
 	"github.com/attic-labs/noms/go/d"
@@ -48,30 +49,57 @@ func NewCommit(value types.Value, parents types.Set, meta types.Struct) types.St
 // one exists, setting ok to true. If there is no common ancestor, ok is set
 // to false.
 func FindCommonAncestor(c1, c2 types.Ref, vr types.ValueReader) (a types.Ref, ok bool) {
-	if !IsRefOfCommitType(types.TypeOf(c1)) {
-		d.Panic("FindCommonAncestor() called on %s", types.TypeOf(c1).Describe())
+	return FindCommonAncestorN([]types.Ref{c1, c2}, vr)
+}
+
+// FindCommonAncestorN is like FindCommonAncestor, but returns the most recent
+// common ancestor of all of commits.
+func FindCommonAncestorN(commits []types.Ref, vr types.ValueReader) (a types.Ref, ok bool) {
+	if len(commits) == 0 {
+		return
 	}
-	if !IsRefOfCommitType(types.TypeOf(c2)) {
-		d.Panic("FindCommonAncestor() called on %s", types.TypeOf(c2).Describe())
+	queues := make([]*types.RefByHeight, len(commits))
+	for i, c := range commits {
+		if !IsRefOfCommitType(types.TypeOf(c)) {
+			d.Panic("FindCommonAncestor() called on %s", types.TypeOf(c).Describe())
+		}
+		queues[i] = &types.RefByHeight{c}
 	}
 
-	c1Q, c2Q := &types.RefByHeight{c1}, &types.RefByHeight{c2}
-	for !c1Q.Empty() && !c2Q.Empty() {
-		c1Ht, c2Ht := c1Q.MaxHeight(), c2Q.MaxHeight()
-		if c1Ht == c2Ht {
-			c1Parents, c2Parents := c1Q.PopRefsOfHeight(c1Ht), c2Q.PopRefsOfHeight(c2Ht)
-			if common, ok := findCommonRef(c1Parents, c2Parents); ok {
+	for {
+		minHt, maxHt := uint64(math.MaxUint64), uint64(0)
+		for _, q := range queues {
+			if q.Empty() {
+				return
+			}
+			ht := q.MaxHeight()
+			if ht < minHt {
+				minHt = ht
+			}
+			if ht > maxHt {
+				maxHt = ht
+			}
+		}
+		if minHt == maxHt {
+			popped := make([]types.RefSlice, len(queues))
+			for i, q := range queues {
+				popped[i] = q.PopRefsOfHeight(maxHt)
+			}
+			if common, ok := findCommonRef(popped); ok {
 				return common, true
 			}
-			parentsToQueue(c1Parents, c1Q, vr)
-			parentsToQueue(c2Parents, c2Q, vr)
-		} else if c1Ht > c2Ht {
-			parentsToQueue(c1Q.PopRefsOfHeight(c1Ht), c1Q, vr)
-		} else {
-			parentsToQueue(c2Q.PopRefsOfHeight(c2Ht), c2Q, vr)
+			for i, q := range queues {
+				parentsToQueue(popped[i], q, vr)
+			}
+			continue
+		}
+		// Nothing as tall as maxHt can be common to all the queues.
+		for _, q := range queues {
+			if q.MaxHeight() == maxHt {
+				parentsToQueue(q.PopRefsOfHeight(maxHt), q, vr)
+			}
 		}
 	}
-	return
 }
 
 func parentsToQueue(refs types.RefSlice, q *types.RefByHeight, vr types.ValueReader) {
@@ -85,18 +113,20 @@ func parentsToQueue(refs types.RefSlice, q *types.RefByHeight, vr types.ValueRea
 	sort.Sort(q)
 }
 
-func findCommonRef(a, b types.RefSlice) (types.Ref, bool) {
-	toRefSet := func(s types.RefSlice) map[hash.Hash]types.Ref {
-		out := map[hash.Hash]types.Ref{}
+// findCommonRef returns a Ref that's in every one of slices, if there is one.
+func findCommonRef(slices []types.RefSlice) (types.Ref, bool) {
+	counts := map[hash.Hash]int{}
+	for _, s := range slices {
+		seen := hash.HashSet{}
 		for _, r := range s {
-			out[r.TargetHash()] = r
+			if !seen.Has(r.TargetHash()) {
+				seen.Insert(r.TargetHash())
+				counts[r.TargetHash()]++
+			}
 		}
-		return out
 	}
-
-	aSet, bSet := toRefSet(a), toRefSet(b)
-	for s, r := range aSet {
-		if _, present := bSet[s]; present {
+	for _, r := range slices[0] {
+		if counts[r.TargetHash()] == len(slices) {
 			return r, true
 		}
 	}
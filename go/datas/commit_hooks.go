@@ -0,0 +1,108 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// DatasetUpdate is a change to the head of a Dataset which a client proposes
+// by updating the root of a Database. OldHead is empty if the Dataset is
+// being created, and NewHead is empty if it's being deleted.
+type DatasetUpdate struct {
+	ID               string
+	OldHead, NewHead hash.Hash
+}
+
+// CommitHook is called by a RemoteDatabaseServer for each Dataset changed
+// by a proposed root update, before the root is updated. The chunks of the
+// update have been written, so vr can read NewHead. If the update has to be
+// merged with one made since the client read the root, it's called again
+// with the OldHead that the merged root replaces. If the hook returns an
+// error, the whole update is rejected, and the error's message is sent to
+// the client as an ErrCommitRejected.
+type CommitHook func(update DatasetUpdate, vr types.ValueReader) error
+
+// ErrCommitRejected is returned when committing to a remote Database is
+// refused by the server, e.g. by one of its CommitHooks.
+type ErrCommitRejected struct {
+	Message string
+}
+
+func (e *ErrCommitRejected) Error() string {
+	return e.Message
+}
+
+// ExecCommitHook returns a CommitHook which runs the executable at path with
+// args, followed by the ID, old head and new head of the update. The heads
+// are hashes, or empty strings if there are none. The head's chunks can be
+// read from the server by hash, e.g. with `noms show`. If the executable
+// exits with an error, the update is rejected with what it wrote to stdout
+// and stderr.
+func ExecCommitHook(path string, args ...string) CommitHook {
+	name := filepath.Base(path)
+	return func(update DatasetUpdate, vr types.ValueReader) error {
+		hashArg := func(h hash.Hash) string {
+			if h.IsEmpty() {
+				return ""
+			}
+			return h.String()
+		}
+		cmd := exec.Command(path, append(args, update.ID, hashArg(update.OldHead), hashArg(update.NewHead))...)
+		out := &bytes.Buffer{}
+		cmd.Stdout, cmd.Stderr = out, out
+		if err := cmd.Run(); err != nil {
+			msg := strings.TrimSpace(out.String())
+			if msg == "" {
+				msg = err.Error()
+			}
+			return fmt.Errorf("%s: %s", name, msg)
+		}
+		return nil
+	}
+}
+
+// datasetUpdates returns the updates to the Datasets in last made by
+// proposed.
+func datasetUpdates(proposed, last types.Map) []DatasetUpdate {
+	headHash := func(v types.Value) hash.Hash {
+		if v == nil {
+			return hash.Hash{}
+		}
+		return v.(types.Ref).TargetHash()
+	}
+	updates := []DatasetUpdate{}
+	diffMaps(proposed, last, func(change types.ValueChanged) {
+		updates = append(updates, DatasetUpdate{
+			ID:      string(change.Key.(types.String)),
+			OldHead: headHash(change.OldValue),
+			NewHead: headHash(change.NewValue),
+		})
+	})
+	return updates
+}
+
+// runCommitHooks calls each of hooks with each update from last to proposed,
+// and returns an *ErrCommitRejected for the first which fails.
+func runCommitHooks(hooks []CommitHook, proposed, last types.Map, vr types.ValueReader) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+	for _, update := range datasetUpdates(proposed, last) {
+		for _, hook := range hooks {
+			if err := hook(update, vr); err != nil {
+				return &ErrCommitRejected{fmt.Sprintf("Update to %s rejected: %s", update.ID, err)}
+			}
+		}
+	}
+	return nil
+}
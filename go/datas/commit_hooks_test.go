@@ -0,0 +1,110 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatasetUpdates(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	a, err := db.CommitValue(db.GetDataset("a"), types.String("a"))
+	assert.NoError(err)
+	b, err := db.CommitValue(db.GetDataset("b"), types.String("b"))
+	assert.NoError(err)
+	last := db.Datasets()
+
+	_, err = db.CommitValue(a, types.String("a2"))
+	assert.NoError(err)
+	_, err = db.Delete(b)
+	assert.NoError(err)
+	c, err := db.CommitValue(db.GetDataset("c"), types.String("c"))
+	assert.NoError(err)
+
+	assert.Equal([]DatasetUpdate{
+		{"a", a.HeadRef().TargetHash(), db.GetDataset("a").HeadRef().TargetHash()},
+		{"b", b.HeadRef().TargetHash(), hash.Hash{}},
+		{"c", hash.Hash{}, c.HeadRef().TargetHash()},
+	}, datasetUpdates(db.Datasets(), last))
+}
+
+func TestCommitHooks(t *testing.T) {
+	assert := assert.New(t)
+
+	requireMessage := func(update DatasetUpdate, vr types.ValueReader) error {
+		if update.NewHead.IsEmpty() {
+			return nil
+		}
+		meta := vr.ReadValue(update.NewHead).(types.Struct).Get(MetaField).(types.Struct)
+		if _, ok := meta.MaybeGet("message"); !ok {
+			return errors.New("commits must have a message")
+		}
+		return nil
+	}
+	protect := func(update DatasetUpdate, vr types.ValueReader) error {
+		if update.ID == "protected" && update.NewHead.IsEmpty() {
+			return errors.New("protected can't be deleted")
+		}
+		return nil
+	}
+
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(newHookedHTTPChunkStoreForTest(storage.NewView(), []CommitHook{requireMessage, protect}))
+	defer db.Close()
+
+	ds := db.GetDataset("protected")
+	_, err := db.CommitValue(ds, types.String("no message"))
+	assert.Equal(&ErrCommitRejected{"Update to protected rejected: commits must have a message"}, err)
+	assert.False(db.GetDataset("protected").HasHead())
+
+	meta := types.NewStruct("", types.StructData{"message": types.String("hi")})
+	ds, err = db.Commit(ds, types.String("message"), CommitOptions{Meta: meta})
+	assert.NoError(err)
+	assert.True(types.String("message").Equals(ds.HeadValue()))
+
+	_, err = db.Delete(ds)
+	assert.Equal(&ErrCommitRejected{"Update to protected rejected: protected can't be deleted"}, err)
+	assert.True(db.GetDataset("protected").HasHead())
+}
+
+func TestExecCommitHook(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "hook")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	out := filepath.Join(dir, "out")
+	script := filepath.Join(dir, "hook.sh")
+	assert.NoError(ioutil.WriteFile(script, []byte(`#!/bin/sh
+echo "$1 $2 $3 $4" > `+out+`
+if [ "$2" = protected ]; then
+  echo "$2 is read-only"
+  exit 1
+fi
+`), 0755))
+
+	h := hash.Of([]byte("head"))
+	hook := ExecCommitHook(script, "arg")
+	assert.NoError(hook(DatasetUpdate{"ds", hash.Hash{}, h}, nil))
+	data, err := ioutil.ReadFile(out)
+	assert.NoError(err)
+	assert.Equal("arg ds  "+h.String()+"\n", string(data))
+
+	err = hook(DatasetUpdate{"protected", h, hash.Hash{}}, nil)
+	assert.EqualError(err, "hook.sh: protected is read-only")
+}
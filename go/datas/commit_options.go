@@ -5,8 +5,10 @@
 package datas
 
 import (
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/merge"
 	"github.com/attic-labs/noms/go/types"
+	"golang.org/x/crypto/ed25519"
 )
 
 // CommitOptions is used to pass options into Commit.
@@ -24,4 +26,14 @@ type CommitOptions struct {
 	// be attempted. Note that because Commit() retries in some cases, Policy
 	// might also be called multiple times with different values.
 	Policy merge.Policy
+
+	// ExpectedHead, if provided, is the hash of the Commit that must be the
+	// Head of the Dataset for the commit to be made. If the Head is anything
+	// else, or the Dataset has no Head, Commit() returns an '*ErrHeadMoved'
+	// instead of merging.
+	ExpectedHead hash.Hash
+
+	// SigningKey, if provided, is used to sign the Commit, and any Commit
+	// made to merge it with the current Head. See SignCommit().
+	SigningKey ed25519.PrivateKey
 }
@@ -0,0 +1,155 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/signing"
+	"golang.org/x/crypto/ed25519"
+)
+
+// SignatureField is the field of a Commit's meta which holds its signature:
+// a struct Signature {key: String, sig: String} of the base64-encoded public
+// key and ed25519 signature. What's signed is the hashes of the Commit's
+// value, its parents and its meta without the signature, so a signature can
+// be added to any meta.
+const SignatureField = "signature"
+
+const (
+	signatureStructName = "Signature"
+	signatureKeyField   = "key"
+	signatureSigField   = "sig"
+	signaturePrefix     = "noms commit signature v1\x00"
+)
+
+var (
+	// ErrUnsigned is returned by VerifyCommit() if a Commit has no signature.
+	ErrUnsigned = errors.New("Commit is not signed")
+	// ErrBadSignature is returned by VerifyCommit() if a Commit's signature
+	// doesn't match it.
+	ErrBadSignature = errors.New("Commit signature is invalid")
+	// ErrUntrustedKey is the cause of an '*ErrUnverifiedCommit' which is
+	// correctly signed, but not by a trusted key.
+	ErrUntrustedKey = errors.New("Commit is signed by an untrusted key")
+)
+
+// ErrUnverifiedCommit is returned by VerifyCommitChain() if a Commit isn't
+// signed by a trusted key.
+type ErrUnverifiedCommit struct {
+	Commit hash.Hash
+	Cause  error
+}
+
+func (e *ErrUnverifiedCommit) Error() string {
+	return fmt.Sprintf("#%s: %s", e.Commit, e.Cause)
+}
+
+// SignCommit returns commit with a signature by key in its meta, replacing
+// any it already had.
+func SignCommit(commit types.Struct, key ed25519.PrivateKey) types.Struct {
+	if !IsCommit(commit) {
+		d.Panic("Can't sign a non-Commit struct")
+	}
+	meta := commit.Get(MetaField).(types.Struct)
+	sig := ed25519.Sign(key, commitSigningMessage(commit))
+	meta = meta.Set(SignatureField, types.NewStruct(signatureStructName, types.StructData{
+		signatureKeyField: types.String(signing.FormatKey(key.Public().(ed25519.PublicKey))),
+		signatureSigField: types.String(signing.FormatSignature(sig)),
+	}))
+	return NewCommit(commit.Get(ValueField), commit.Get(ParentsField).(types.Set), meta)
+}
+
+// VerifyCommit returns the public key commit is signed with. It returns
+// ErrUnsigned if commit isn't signed, and ErrBadSignature if the signature
+// doesn't match it.
+func VerifyCommit(commit types.Struct) (ed25519.PublicKey, error) {
+	meta, ok := commit.Get(MetaField).(types.Struct)
+	if !ok {
+		return nil, ErrUnsigned
+	}
+	sigVal, ok := meta.MaybeGet(SignatureField)
+	if !ok {
+		return nil, ErrUnsigned
+	}
+
+	sigStruct, ok := sigVal.(types.Struct)
+	if !ok {
+		return nil, ErrBadSignature
+	}
+	keyStr, ok := sigStruct.MaybeGet(signatureKeyField)
+	if !ok {
+		return nil, ErrBadSignature
+	}
+	sigStr, ok := sigStruct.MaybeGet(signatureSigField)
+	if !ok {
+		return nil, ErrBadSignature
+	}
+	keyS, ok1 := keyStr.(types.String)
+	sigS, ok2 := sigStr.(types.String)
+	if !ok1 || !ok2 {
+		return nil, ErrBadSignature
+	}
+	key, err := signing.ParsePublicKey(string(keyS))
+	if err != nil {
+		return nil, ErrBadSignature
+	}
+	sig, err := signing.ParseSignature(string(sigS))
+	if err != nil || !ed25519.Verify(key, commitSigningMessage(commit), sig) {
+		return nil, ErrBadSignature
+	}
+	return key, nil
+}
+
+// VerifyCommitChain checks that head, and every Commit it descends from, is
+// signed by one of trusted. It returns an '*ErrUnverifiedCommit' for the
+// first, tallest, Commit that isn't.
+func VerifyCommitChain(vr types.ValueReader, head types.Ref, trusted signing.KeySet) error {
+	queue, seen := types.RefByHeight{head}, hash.HashSet{}
+	seen.Insert(head.TargetHash())
+	for !queue.Empty() {
+		r := queue.PopBack()
+		commit, ok := r.TargetValue(vr).(types.Struct)
+		if !ok || !IsCommit(commit) {
+			return &ErrUnverifiedCommit{r.TargetHash(), errors.New("Not a commit")}
+		}
+		key, err := VerifyCommit(commit)
+		if err != nil {
+			return &ErrUnverifiedCommit{r.TargetHash(), err}
+		}
+		if _, ok := trusted.Name(key); !ok {
+			return &ErrUnverifiedCommit{r.TargetHash(), ErrUntrustedKey}
+		}
+		commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+			if p := v.(types.Ref); !seen.Has(p.TargetHash()) {
+				seen.Insert(p.TargetHash())
+				queue.PushBack(p)
+			}
+		})
+		sort.Sort(queue)
+	}
+	return nil
+}
+
+// commitSigningMessage returns what the signature of commit signs: the
+// hashes of its value, its parents and its meta without its signature.
+func commitSigningMessage(commit types.Struct) []byte {
+	meta := commit.Get(MetaField).(types.Struct)
+	if _, ok := meta.MaybeGet(SignatureField); ok {
+		meta = meta.Delete(SignatureField)
+	}
+	buf := &bytes.Buffer{}
+	buf.WriteString(signaturePrefix)
+	for _, h := range []hash.Hash{commit.Get(ValueField).Hash(), commit.Get(ParentsField).Hash(), meta.Hash()} {
+		buf.Write(h[:])
+	}
+	return buf.Bytes()
+}
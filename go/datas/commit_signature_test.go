@@ -0,0 +1,100 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/signing"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ed25519"
+)
+
+func newTestKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	return pub, priv
+}
+
+func TestSignCommit(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+	pub, priv := newTestKey(t)
+
+	meta := types.NewStruct("Meta", types.StructData{"message": types.String("hi")})
+	commit := NewCommit(types.String("value"), types.NewSet(db), meta)
+	_, err := VerifyCommit(commit)
+	assert.Equal(ErrUnsigned, err)
+
+	signed := SignCommit(commit, priv)
+	key, err := VerifyCommit(signed)
+	assert.NoError(err)
+	assert.Equal(pub, key)
+	signedMeta := signed.Get(MetaField).(types.Struct)
+	assert.True(types.String("hi").Equals(signedMeta.Get("message")))
+	assert.True(meta.Equals(signedMeta.Delete(SignatureField)))
+
+	// Re-signing replaces the signature.
+	pub2, priv2 := newTestKey(t)
+	key, err = VerifyCommit(SignCommit(signed, priv2))
+	assert.NoError(err)
+	assert.Equal(pub2, key)
+
+	// Changing anything signed invalidates the signature.
+	tampered := []types.Struct{
+		NewCommit(types.String("other"), types.NewSet(db), signedMeta),
+		NewCommit(types.String("value"), types.NewSet(db, types.NewRef(commit)), signedMeta),
+		NewCommit(types.String("value"), types.NewSet(db), signedMeta.Set("message", types.String("bye"))),
+	}
+	for _, c := range tampered {
+		_, err = VerifyCommit(c)
+		assert.Equal(ErrBadSignature, err)
+	}
+}
+
+func TestVerifyCommitChain(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+	alicePub, alice := newTestKey(t)
+	bobPub, bob := newTestKey(t)
+	trusted := signing.KeySet{"alice": alicePub, "bob": bobPub}
+
+	ds, err := db.Commit(db.GetDataset("ds"), types.String("a"), CommitOptions{SigningKey: alice})
+	assert.NoError(err)
+	first := ds.HeadRef()
+	ds, err = db.Commit(ds, types.NewMap(db), CommitOptions{SigningKey: bob})
+	assert.NoError(err)
+	assert.NoError(VerifyCommitChain(db, ds.HeadRef(), trusted))
+
+	name, ok := trusted.Name(alicePub)
+	assert.True(ok)
+	assert.Equal("alice", name)
+	assert.Equal(&ErrUnverifiedCommit{ds.HeadRef().TargetHash(), ErrUntrustedKey}, VerifyCommitChain(db, ds.HeadRef(), signing.KeySet{"alice": alicePub}))
+
+	// The commit made to merge concurrent commits is signed too.
+	base := ds
+	_, err = db.Commit(base, types.NewMap(db, types.String("bob"), types.Bool(true)), CommitOptions{SigningKey: bob})
+	assert.NoError(err)
+	ds, err = db.Commit(base, types.NewMap(db, types.String("alice"), types.Bool(true)), CommitOptions{SigningKey: alice, Policy: merge.NewThreeWay(merge.None)})
+	assert.NoError(err)
+	assert.Equal(uint64(2), ds.HeadValue().(types.Map).Len())
+	key, err := VerifyCommit(ds.Head())
+	assert.NoError(err)
+	assert.Equal(alicePub, key)
+	assert.NoError(VerifyCommitChain(db, ds.HeadRef(), trusted))
+
+	ds, err = db.CommitValue(ds, types.String("unsigned"))
+	assert.NoError(err)
+	assert.Equal(&ErrUnverifiedCommit{ds.HeadRef().TargetHash(), ErrUnsigned}, VerifyCommitChain(db, ds.HeadRef(), trusted))
+	assert.NoError(VerifyCommitChain(db, first, trusted))
+}
@@ -192,6 +192,23 @@ func TestFindCommonAncestor(t *testing.T) {
 	assertCommonAncestor(a2, a4, b4) // Common grandparent
 	assertCommonAncestor(a1, a6, c3) // Traversing multiple parents on both sides
 
+	// More than two commits
+	findN := func(commits ...types.Struct) types.Value {
+		refs := make([]types.Ref, len(commits))
+		for i, c := range commits {
+			refs[i] = types.NewRef(c)
+		}
+		if found, ok := FindCommonAncestorN(refs, db); ok {
+			return found.TargetValue(db).(types.Struct).Get(ValueField)
+		}
+		return nil
+	}
+	assert.Equal(types.String("a2"), findN(a4, b4, a3))
+	assert.Equal(types.String("a1"), findN(a6, b5, c3))
+	assert.Equal(types.String("a1"), findN(a1))
+	assert.Nil(findN(a6, b5, d2))
+	assert.Nil(findN())
+
 	// No common ancestor
 	if found, ok := FindCommonAncestor(types.NewRef(d2), types.NewRef(a6), db); !assert.False(ok) {
 		assert.Fail(
@@ -21,6 +21,13 @@ import (
 // The Database API is stateful, meaning that calls to GetDataset() or
 // Datasets() occurring after a call to Commit() (et al) will represent the
 // result of the Commit().
+// Datasets whose IDs are reserved, see IsReservedDatasetID(), hold state
+// about the Database itself. Commit, Delete, SetHead and FastForward return
+// 'ErrReservedDataset' for them.
+// If the Database is backed by a Noms server, the methods that return an
+// error return the one with which a request to the server failed, see
+// IsRemoteError(). The methods that can't return an error panic with it
+// instead, see CatchRemoteErrors().
 type Database interface {
 	// To implement types.ValueWriter, Database implementations provide
 	// WriteValue(). WriteValue() writes v to this Database, though v is not
@@ -33,11 +40,16 @@ type Database interface {
 	io.Closer
 
 	// Datasets returns the root of the database which is a
-	// Map<String, Ref<Commit>> where string is a datasetID.
+	// Map<String, Ref<Commit>> where string is a datasetID. The reserved
+	// Datasets are left out.
 	Datasets() types.Map
 
+	// ReservedDatasets returns the Map<String, Ref<Commit>> of the reserved
+	// Datasets, which Datasets() leaves out.
+	ReservedDatasets() types.Map
+
 	// GetDataset returns a Dataset struct containing the current mapping of
-	// datasetID in the above Datasets Map.
+	// datasetID in the root of the database, which may be a reserved Dataset.
 	GetDataset(datasetID string) Dataset
 
 	// Rebase brings this Database's view of the world inline with upstream.
@@ -99,6 +111,40 @@ type Database interface {
 	// Regardless, Datasets() is updated to match backing storage upon return.
 	FastForward(ds Dataset, newHeadRef types.Ref) (Dataset, error)
 
+	// Batch returns an empty Batch of updates to Datasets in this Database,
+	// which are made atomically by Batch.Apply().
+	Batch() *Batch
+
+	// ChunkConfig returns the types.ChunkConfig used to chunk every
+	// collection written to this Database. This is the ChunkConfig recorded
+	// in the Database by InitChunkConfig(), or types.DefaultChunkConfig if
+	// there is none.
+	ChunkConfig() types.ChunkConfig
+
+	// InitChunkConfig records cfg as the types.ChunkConfig that all writers
+	// of this Database must use. It may only be called on a Database that
+	// doesn't yet record a ChunkConfig, and which is either empty or was
+	// written using types.DefaultChunkConfig. Calling it again with the
+	// recorded ChunkConfig is a no-op. If cfg differs from the one already
+	// in effect, InitChunkConfig returns 'ErrChunkConfigMismatch'.
+	// Writers that were opened before InitChunkConfig() was called switch to
+	// the recorded ChunkConfig the next time they commit. That commit fails
+	// with 'ErrChunkConfigMismatch', since its values were chunked
+	// differently, and should be rebuilt and retried.
+	InitChunkConfig(cfg types.ChunkConfig) error
+
+	// DatasetSchema returns the Type that values committed to the Dataset
+	// datasetID must be a subtype of, if it has one.
+	DatasetSchema(datasetID string) (*types.Type, bool)
+
+	// SetDatasetSchema records schema as the Type that all values committed
+	// to ds must be a subtype of, from now on. Commit(), SetHead() and
+	// FastForward() fail with an '*ErrSchemaViolation' for values that don't
+	// conform, and so does SetDatasetSchema() itself if the current head of
+	// ds doesn't. A nil schema removes any schema ds has. The returned
+	// Dataset is always the newest snapshot of ds.
+	SetDatasetSchema(ds Dataset, schema *types.Type) (Dataset, error)
+
 	// Stats may return some kind of struct that reports statistics about the
 	// ChunkStore that backs this Database instance. The type is
 	// implementation-dependent, and impls may return nil
@@ -109,6 +155,11 @@ type Database interface {
 	// level detail of the database that should infrequently be needed by
 	// clients.
 	chunkStore() chunks.ChunkStore
+
+	// commitReserved sets the head of the reserved Dataset id to a new Commit
+	// of v, or removes it if v is nil. Unlike Commit() and Delete(), it's how
+	// the Database maintains its own state.
+	commitReserved(id string, v types.Value) error
 }
 
 func NewDatabase(cs chunks.ChunkStore) Database {
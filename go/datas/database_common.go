@@ -6,12 +6,14 @@ package datas
 
 import (
 	"errors"
+	"fmt"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/merge"
 	"github.com/attic-labs/noms/go/types"
+	"golang.org/x/crypto/ed25519"
 )
 
 type database struct {
@@ -24,6 +26,25 @@ var (
 	ErrMergeNeeded          = errors.New("Dataset head is not ancestor of commit")
 )
 
+// ErrHeadMoved is returned when a commit is made with CommitOptions.ExpectedHead, but the Head of the Dataset is something else.
+type ErrHeadMoved struct {
+	DatasetID string
+	// Expected is the hash of the Head the Dataset was expected to have, which is empty if it was expected to have none.
+	Expected hash.Hash
+	// Current is the hash of the current Head of the Dataset, which is empty if it has none.
+	Current hash.Hash
+}
+
+func (e *ErrHeadMoved) Error() string {
+	if e.Expected.IsEmpty() {
+		return fmt.Sprintf("Expected %s to have no head, but it is #%s", e.DatasetID, e.Current)
+	}
+	if e.Current.IsEmpty() {
+		return fmt.Sprintf("Expected head of %s to be #%s, but it has no head", e.DatasetID, e.Expected)
+	}
+	return fmt.Sprintf("Expected head of %s to be #%s, but it is #%s", e.DatasetID, e.Expected, e.Current)
+}
+
 // rootTracker is a narrowing of the ChunkStore interface, to keep Database disciplined about working directly with Chunks
 type rootTracker interface {
 	Rebase()
@@ -37,10 +58,12 @@ func newDatabase(cs chunks.ChunkStore) *database {
 		vs.SetEnforceCompleteness(false)
 	}
 
-	return &database{
+	db := &database{
 		ValueStore: vs, // ValueStore is responsible for closing |cs|
 		rt:         vs,
 	}
+	db.loadChunkConfig()
+	return db
 }
 
 func (db *database) chunkStore() chunks.ChunkStore {
@@ -52,6 +75,18 @@ func (db *database) Stats() interface{} {
 }
 
 func (db *database) Datasets() types.Map {
+	user, _ := db.splitReservedDatasets(db.root())
+	return user
+}
+
+func (db *database) ReservedDatasets() types.Map {
+	_, reserved := db.splitReservedDatasets(db.root())
+	return reserved
+}
+
+// root returns the Map at the root of the database, of all of its Datasets
+// including the reserved ones.
+func (db *database) root() types.Map {
 	rootHash := db.rt.Root()
 	if rootHash.IsEmpty() {
 		return types.NewMap(db)
@@ -65,7 +100,7 @@ func (db *database) GetDataset(datasetID string) Dataset {
 		d.Panic("Invalid dataset ID: %s", datasetID)
 	}
 	var head types.Value
-	if r, ok := db.Datasets().MaybeGet(types.String(datasetID)); ok {
+	if r, ok := db.root().MaybeGet(types.String(datasetID)); ok {
 		head = r.(types.Ref).TargetValue(db)
 	}
 
@@ -74,6 +109,7 @@ func (db *database) GetDataset(datasetID string) Dataset {
 
 func (db *database) Rebase() {
 	db.rt.Rebase()
+	db.loadChunkConfig()
 }
 
 func (db *database) Close() error {
@@ -81,6 +117,9 @@ func (db *database) Close() error {
 }
 
 func (db *database) SetHead(ds Dataset, newHeadRef types.Ref) (Dataset, error) {
+	if IsReservedDatasetID(ds.ID()) {
+		return ds, ErrReservedDataset
+	}
 	return db.doHeadUpdate(ds, func(ds Dataset) error { return db.doSetHead(ds, newHeadRef) })
 }
 
@@ -90,7 +129,17 @@ func (db *database) doSetHead(ds Dataset, newHeadRef types.Ref) error {
 	}
 	commit := db.validateRefAsCommit(newHeadRef)
 
-	currentRootHash, currentDatasets := db.rt.Root(), db.Datasets()
+	currentRootHash, currentDatasets := db.rt.Root(), db.root()
+	if err := db.checkChunkConfig(currentDatasets); err != nil {
+		return err
+	}
+	schemas, err := readSchemas(currentDatasets, db)
+	if err != nil {
+		return err
+	}
+	if err := checkSchema(schemas, ds.ID(), commit.Get(ValueField)); err != nil {
+		return err
+	}
 	commitRef := db.WriteValue(commit) // will be orphaned if the tryCommitChunks() below fails
 
 	currentDatasets = currentDatasets.Edit().Set(types.String(ds.ID()), types.ToRefOfValue(commitRef)).Map()
@@ -98,6 +147,9 @@ func (db *database) doSetHead(ds Dataset, newHeadRef types.Ref) error {
 }
 
 func (db *database) FastForward(ds Dataset, newHeadRef types.Ref) (Dataset, error) {
+	if IsReservedDatasetID(ds.ID()) {
+		return ds, ErrReservedDataset
+	}
 	return db.doHeadUpdate(ds, func(ds Dataset) error { return db.doFastForward(ds, newHeadRef) })
 }
 
@@ -112,13 +164,18 @@ func (db *database) doFastForward(ds Dataset, newHeadRef types.Ref) error {
 	}
 
 	commit := db.validateRefAsCommit(newHeadRef)
-	return db.doCommit(ds.ID(), commit, nil)
+	return db.doCommit(ds.ID(), commit, nil, hash.Hash{}, nil)
 }
 
 func (db *database) Commit(ds Dataset, v types.Value, opts CommitOptions) (Dataset, error) {
+	if IsReservedDatasetID(ds.ID()) {
+		return ds, ErrReservedDataset
+	}
 	return db.doHeadUpdate(
 		ds,
-		func(ds Dataset) error { return db.doCommit(ds.ID(), buildNewCommit(ds, v, opts), opts.Policy) },
+		func(ds Dataset) error {
+			return db.doCommit(ds.ID(), buildNewCommit(ds, v, opts), opts.Policy, opts.ExpectedHead, opts.SigningKey)
+		},
 	)
 }
 
@@ -126,8 +183,8 @@ func (db *database) CommitValue(ds Dataset, v types.Value) (Dataset, error) {
 	return db.Commit(ds, v, CommitOptions{})
 }
 
-// doCommit manages concurrent access the single logical piece of mutable state: the current Root. doCommit is optimistic in that it is attempting to update head making the assumption that currentRootHash is the hash of the current head. The call to Commit below will return an 'ErrOptimisticLockFailed' error if that assumption fails (e.g. because of a race with another writer) and the entire algorithm must be tried again. This method will also fail and return an 'ErrMergeNeeded' error if the |commit| is not a descendent of the current dataset head
-func (db *database) doCommit(datasetID string, commit types.Struct, mergePolicy merge.Policy) error {
+// doCommit manages concurrent access the single logical piece of mutable state: the current Root. doCommit is optimistic in that it is attempting to update head making the assumption that currentRootHash is the hash of the current head. The call to Commit below will return an 'ErrOptimisticLockFailed' error if that assumption fails (e.g. because of a race with another writer) and the entire algorithm must be tried again. This method will also fail and return an 'ErrMergeNeeded' error if the |commit| is not a descendent of the current dataset head, or an '*ErrSchemaViolation' if the value it would commit isn't accepted by the schema of the dataset, or 'ErrChunkConfigMismatch' if another writer has since recorded a chunk config that the commit's values weren't chunked with
+func (db *database) doCommit(datasetID string, commit types.Struct, mergePolicy merge.Policy, expectedHead hash.Hash, signingKey ed25519.PrivateKey) error {
 	if !IsCommit(commit) {
 		d.Panic("Can't commit a non-Commit struct to dataset %s", datasetID)
 	}
@@ -135,53 +192,94 @@ func (db *database) doCommit(datasetID string, commit types.Struct, mergePolicy
 	// This could loop forever, given enough simultaneous committers. BUG 2565
 	var err error
 	for err = ErrOptimisticLockFailed; err == ErrOptimisticLockFailed; {
-		currentRootHash, currentDatasets := db.rt.Root(), db.Datasets()
-		commitRef := db.WriteValue(commit) // will be orphaned if the tryCommitChunks() below fails
-
-		// If there's nothing in the DB yet, skip all this logic.
-		if !currentRootHash.IsEmpty() {
-			r, hasHead := currentDatasets.MaybeGet(types.String(datasetID))
-
-			// First commit in dataset is always fast-forward, so go through all this iff there's already a Head for datasetID.
-			if hasHead {
-				head := r.(types.Ref).TargetValue(db)
-				currentHeadRef := types.NewRef(head)
-				ancestorRef, found := FindCommonAncestor(commitRef, currentHeadRef, db)
-				if !found {
-					return ErrMergeNeeded
-				}
-
-				// This covers all cases where currentHeadRef is not an ancestor of commit, including the following edge cases:
-				//   - commit is a duplicate of currentHead.
-				//   - we hit an ErrOptimisticLockFailed and looped back around because some other process changed the Head out from under us.
-				if currentHeadRef.TargetHash() != ancestorRef.TargetHash() || currentHeadRef.TargetHash() == commitRef.TargetHash() {
-					if mergePolicy == nil {
-						return ErrMergeNeeded
-					}
-
-					ancestor, currentHead := db.validateRefAsCommit(ancestorRef), db.validateRefAsCommit(currentHeadRef)
-					merged, err := mergePolicy(commit.Get(ValueField), currentHead.Get(ValueField), ancestor.Get(ValueField), db, nil)
-					if err != nil {
-						return err
-					}
-					commitRef = db.WriteValue(NewCommit(merged, types.NewSet(db, commitRef, currentHeadRef), types.EmptyStruct))
-				}
-			}
+		currentRootHash, currentDatasets := db.rt.Root(), db.root()
+		if err := db.checkChunkConfig(currentDatasets); err != nil {
+			return err
+		}
+		if err := checkExpectedHead(currentDatasets, datasetID, expectedHead); err != nil {
+			return err
+		}
+		currentDatasets, err = db.commitToDatasets(currentDatasets, datasetID, commit, mergePolicy, signingKey)
+		if err != nil {
+			return err
 		}
-		currentDatasets = currentDatasets.Edit().Set(types.String(datasetID), types.ToRefOfValue(commitRef)).Map()
 		err = db.tryCommitChunks(currentDatasets, currentRootHash)
 	}
 	return err
 }
 
+// commitToDatasets returns datasets with the head of datasetID set to commit, or to the merge of commit with the current head, if mergePolicy allows it. A commit made to merge them is signed with signingKey, if it's provided. It returns 'ErrMergeNeeded' if commit is not a descendent of the current head and can't be merged with it, and an '*ErrSchemaViolation' if the resulting value isn't accepted by the schema of datasetID.
+func (db *database) commitToDatasets(datasets types.Map, datasetID string, commit types.Struct, mergePolicy merge.Policy, signingKey ed25519.PrivateKey) (types.Map, error) {
+	commitRef := db.WriteValue(commit) // will be orphaned if the new datasets are never committed
+	value := commit.Get(ValueField)
+
+	// First commit in dataset is always fast-forward, so go through all this iff there's already a Head for datasetID.
+	if r, hasHead := datasets.MaybeGet(types.String(datasetID)); hasHead {
+		head := r.(types.Ref).TargetValue(db)
+		currentHeadRef := types.NewRef(head)
+		ancestorRef, found := FindCommonAncestor(commitRef, currentHeadRef, db)
+		if !found {
+			return datasets, ErrMergeNeeded
+		}
+
+		// This covers all cases where currentHeadRef is not an ancestor of commit, including the following edge cases:
+		//   - commit is a duplicate of currentHead.
+		//   - we hit an ErrOptimisticLockFailed and looped back around because some other process changed the Head out from under us.
+		if currentHeadRef.TargetHash() != ancestorRef.TargetHash() || currentHeadRef.TargetHash() == commitRef.TargetHash() {
+			if mergePolicy == nil {
+				return datasets, ErrMergeNeeded
+			}
+
+			ancestor, currentHead := db.validateRefAsCommit(ancestorRef), db.validateRefAsCommit(currentHeadRef)
+			merged, err := mergePolicy(value, currentHead.Get(ValueField), ancestor.Get(ValueField), db, nil)
+			if err != nil {
+				return datasets, err
+			}
+			mergeCommit := NewCommit(merged, types.NewSet(db, commitRef, currentHeadRef), types.EmptyStruct)
+			if len(signingKey) > 0 {
+				mergeCommit = SignCommit(mergeCommit, signingKey)
+			}
+			commitRef = db.WriteValue(mergeCommit)
+			value = merged
+		}
+	}
+	schemas, err := readSchemas(datasets, db)
+	if err != nil {
+		return datasets, err
+	}
+	if err := checkSchema(schemas, datasetID, value); err != nil {
+		return datasets, err
+	}
+	return datasets.Edit().Set(types.String(datasetID), types.ToRefOfValue(commitRef)).Map(), nil
+}
+
+// checkExpectedHead returns an '*ErrHeadMoved' if expected isn't empty and isn't the hash of the head of datasetID in datasets.
+func checkExpectedHead(datasets types.Map, datasetID string, expected hash.Hash) error {
+	if expected.IsEmpty() {
+		return nil
+	}
+	r, hasHead := datasets.MaybeGet(types.String(datasetID))
+	if hasHead && r.(types.Ref).TargetHash() == expected {
+		return nil
+	}
+	e := &ErrHeadMoved{DatasetID: datasetID, Expected: expected}
+	if hasHead {
+		e.Current = r.(types.Ref).TargetHash()
+	}
+	return e
+}
+
 func (db *database) Delete(ds Dataset) (Dataset, error) {
+	if IsReservedDatasetID(ds.ID()) {
+		return ds, ErrReservedDataset
+	}
 	return db.doHeadUpdate(ds, func(ds Dataset) error { return db.doDelete(ds.ID()) })
 }
 
 // doDelete manages concurrent access the single logical piece of mutable state: the current Root. doDelete is optimistic in that it is attempting to update head making the assumption that currentRootHash is the hash of the current head. The call to Commit below will return an 'ErrOptimisticLockFailed' error if that assumption fails (e.g. because of a race with another writer) and the entire algorithm must be tried again.
 func (db *database) doDelete(datasetIDstr string) error {
 	datasetID := types.String(datasetIDstr)
-	currentRootHash, currentDatasets := db.rt.Root(), db.Datasets()
+	currentRootHash, currentDatasets := db.rt.Root(), db.root()
 	var initialHead types.Ref
 	if r, hasHead := currentDatasets.MaybeGet(datasetID); !hasHead {
 		return nil
@@ -197,7 +295,7 @@ func (db *database) doDelete(datasetIDstr string) error {
 			break
 		}
 		// If the optimistic lock failed because someone changed the Head of datasetID, then return ErrMergeNeeded. If it failed because someone changed a different Dataset, we should try again.
-		currentRootHash, currentDatasets = db.rt.Root(), db.Datasets()
+		currentRootHash, currentDatasets = db.rt.Root(), db.root()
 		if r, hasHead := currentDatasets.MaybeGet(datasetID); !hasHead || (hasHead && !initialHead.Equals(r)) {
 			err = ErrMergeNeeded
 			break
@@ -206,10 +304,12 @@ func (db *database) doDelete(datasetIDstr string) error {
 	return err
 }
 
+// tryCommitChunks makes currentDatasets the root, if the root is still currentRootHash. It returns ErrOptimisticLockFailed if it isn't, and an *ErrCommitRejected if the ChunkStore refuses the update.
 func (db *database) tryCommitChunks(currentDatasets types.Map, currentRootHash hash.Hash) (err error) {
 	newRootHash := db.WriteValue(currentDatasets).TargetHash()
 
-	if !db.rt.Commit(newRootHash, currentRootHash) {
+	var committed bool
+	if err = d.Try(func() { committed = db.rt.Commit(newRootHash, currentRootHash) }, &ErrCommitRejected{}); err == nil && !committed {
 		err = ErrOptimisticLockFailed
 	}
 	return
@@ -240,10 +340,19 @@ func buildNewCommit(ds Dataset, v types.Value, opts CommitOptions) types.Struct
 	if meta.IsZeroValue() {
 		meta = types.EmptyStruct
 	}
-	return NewCommit(v, parents, meta)
+	commit := NewCommit(v, parents, meta)
+	if len(opts.SigningKey) > 0 {
+		commit = SignCommit(commit, opts.SigningKey)
+	}
+	return commit
 }
 
-func (db *database) doHeadUpdate(ds Dataset, updateFunc func(ds Dataset) error) (Dataset, error) {
-	err := updateFunc(ds)
-	return db.GetDataset(ds.ID()), err
+func (db *database) doHeadUpdate(ds Dataset, updateFunc func(ds Dataset) error) (updated Dataset, err error) {
+	if cerr := CatchRemoteErrors(func() {
+		err = updateFunc(ds)
+		updated = db.GetDataset(ds.ID())
+	}); cerr != nil {
+		return ds, cerr
+	}
+	return
 }
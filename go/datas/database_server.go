@@ -30,6 +30,12 @@ type RemoteDatabaseServer struct {
 	closing bool
 	// Called just before the server is started.
 	Ready func()
+	// Called with each Dataset changed by an update of the root, before it's
+	// made. Any of them can reject the update.
+	CommitHooks []CommitHook
+	// If set, the server is a read-only copy of Replica's primary. Writes are
+	// rejected, and the replica/ endpoint reports how far behind it is.
+	Replica *Replica
 }
 
 func NewRemoteDatabaseServer(cs chunks.ChunkStore, port int) *RemoteDatabaseServer {
@@ -38,7 +44,7 @@ func NewRemoteDatabaseServer(cs chunks.ChunkStore, port int) *RemoteDatabaseServ
 		d.Panic("SDK version %s is incompatible with data of version %s", constants.NomsVersion, dataVersion)
 	}
 	return &RemoteDatabaseServer{
-		cs, port, nil, make(chan *connectionState, 16), false, func() {},
+		cs, port, nil, make(chan *connectionState, 16), false, func() {}, nil, nil,
 	}
 }
 
@@ -64,13 +70,21 @@ func (s *RemoteDatabaseServer) Run() {
 	router.POST(constants.GetRefsPath, s.corsHandle(s.makeHandle(HandleGetRefs)))
 	router.GET(constants.GetBlobPath, s.corsHandle(s.makeHandle(HandleGetBlob)))
 	router.OPTIONS(constants.GetRefsPath, s.corsHandle(noopHandle))
+	router.POST(constants.GetPackPath, s.corsHandle(s.makeHandle(HandleGetPack)))
+	router.OPTIONS(constants.GetPackPath, s.corsHandle(noopHandle))
 	router.POST(constants.HasRefsPath, s.corsHandle(s.makeHandle(HandleHasRefs)))
 	router.OPTIONS(constants.HasRefsPath, s.corsHandle(noopHandle))
 	router.GET(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootGet)))
-	router.POST(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootPost)))
 	router.OPTIONS(constants.RootPath, s.corsHandle(noopHandle))
-	router.POST(constants.WriteValuePath, s.corsHandle(s.makeHandle(HandleWriteValue)))
 	router.OPTIONS(constants.WriteValuePath, s.corsHandle(noopHandle))
+	if s.Replica != nil {
+		router.POST(constants.RootPath, s.corsHandle(s.Replica.rejectWrite))
+		router.POST(constants.WriteValuePath, s.corsHandle(s.Replica.rejectWrite))
+		router.GET(constants.ReplicaPath, s.corsHandle(s.Replica.handleStatus))
+	} else {
+		router.POST(constants.RootPath, s.corsHandle(s.makeHandle(NewHandleRootPost(s.CommitHooks))))
+		router.POST(constants.WriteValuePath, s.corsHandle(s.makeHandle(HandleWriteValue)))
+	}
 	router.GET(constants.BasePath, s.corsHandle(s.makeHandle(HandleBaseGet)))
 
 	router.GET(constants.GraphQLPath, s.corsHandle(s.makeHandle(HandleGraphQL)))
@@ -5,6 +5,9 @@
 package datas
 
 import (
+	"bytes"
+	"math/rand"
+	"net/http"
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -70,14 +73,19 @@ func (suite *DatabaseSuite) TearDownTest() {
 func (suite *RemoteDatabaseSuite) TestWriteRefToNonexistentValue() {
 	ds := suite.db.GetDataset("foo")
 	r := types.NewRef(types.Bool(true))
-	suite.Panics(func() { suite.db.CommitValue(ds, r) })
+	_, err := suite.db.CommitValue(ds, r)
+	if resErr, ok := err.(*ErrHTTPResponse); suite.True(ok) {
+		suite.Equal(http.StatusBadRequest, resErr.StatusCode)
+	}
 }
 
 func (suite *DatabaseSuite) TestTolerateUngettableRefs() {
 	suite.Nil(suite.db.ReadValue(hash.Hash{}))
 }
 
-func (suite *DatabaseSuite) TestCompletenessCheck() {
+// setUpCompletenessCheck commits a Set of Refs to ds1, and returns ds1 along
+// with a Set that adds a dangling Ref to it.
+func (suite *DatabaseSuite) setUpCompletenessCheck() (Dataset, types.Set) {
 	datasetID := "ds1"
 	ds1 := suite.db.GetDataset(datasetID)
 
@@ -92,11 +100,24 @@ func (suite *DatabaseSuite) TestCompletenessCheck() {
 
 	s = ds1.HeadValue().(types.Set)
 	s = s.Edit().Insert(types.NewRef(types.Number(1000))).Set() // danging ref
+	return ds1, s
+}
+
+func (suite *LocalDatabaseSuite) TestCompletenessCheck() {
+	ds1, s := suite.setUpCompletenessCheck()
 	suite.Panics(func() {
-		ds1, err = suite.db.CommitValue(ds1, s)
+		suite.db.CommitValue(ds1, s)
 	})
 }
 
+func (suite *RemoteDatabaseSuite) TestCompletenessCheck() {
+	ds1, s := suite.setUpCompletenessCheck()
+	_, err := suite.db.CommitValue(ds1, s)
+	if resErr, ok := err.(*ErrHTTPResponse); suite.True(ok) {
+		suite.Equal(http.StatusBadRequest, resErr.StatusCode)
+	}
+}
+
 func (suite *DatabaseSuite) TestRebase() {
 	datasetID := "ds1"
 	ds1 := suite.db.GetDataset(datasetID)
@@ -333,6 +354,159 @@ func (suite *DatabaseSuite) TestDatabaseDelete() {
 	suite.True(present, "Dataset %s should be present", datasetID2)
 }
 
+func (suite *DatabaseSuite) TestCommitExpectedHead() {
+	ds, err := suite.db.CommitValue(suite.db.GetDataset("ds1"), types.String("a"))
+	suite.NoError(err)
+	a := ds.HeadRef().TargetHash()
+
+	ds, err = suite.db.Commit(ds, types.String("b"), CommitOptions{ExpectedHead: a})
+	suite.NoError(err)
+	b := ds.HeadRef().TargetHash()
+
+	// Another writer moves the head, even though the new commit would fast-forward from it.
+	interloper := suite.makeDb(suite.storage.NewView())
+	defer interloper.Close()
+	_, err = interloper.CommitValue(interloper.GetDataset("ds1"), types.String("c"))
+	suite.NoError(err)
+	c := interloper.GetDataset("ds1").HeadRef().TargetHash()
+
+	ds, err = suite.db.Commit(ds, types.String("d"), CommitOptions{ExpectedHead: b, Policy: merge.NewThreeWay(merge.Ours)})
+	if suite.IsType(&ErrHeadMoved{}, err) {
+		suite.Equal(ErrHeadMoved{"ds1", b, c}, *err.(*ErrHeadMoved))
+	}
+	suite.Equal(c, ds.HeadRef().TargetHash())
+
+	ds, err = suite.db.Commit(ds, types.String("d"), CommitOptions{ExpectedHead: c})
+	suite.NoError(err)
+
+	// A dataset without a head never matches.
+	_, err = suite.db.Commit(suite.db.GetDataset("ds2"), types.String("x"), CommitOptions{ExpectedHead: c})
+	if suite.IsType(&ErrHeadMoved{}, err) {
+		suite.True(err.(*ErrHeadMoved).Current.IsEmpty())
+	}
+
+	// Batches check it too.
+	err = suite.db.Batch().
+		CommitValue(suite.db.GetDataset("ds2"), types.String("x")).
+		Commit(ds, types.String("e"), CommitOptions{ExpectedHead: c}).
+		Apply()
+	suite.IsType(&ErrHeadMoved{}, err)
+	suite.False(suite.db.GetDataset("ds2").HasHead())
+}
+
+func (suite *DatabaseSuite) TestBatch() {
+	cs := &countRootUpdatesChunkStore{ChunkStore: suite.storage.NewView()}
+	db := suite.makeDb(cs)
+	defer db.Close()
+
+	ds3, err := db.CommitValue(db.GetDataset("ds3"), types.String("doomed"))
+	suite.NoError(err)
+	cs.updates = 0
+
+	err = db.Batch().
+		CommitValue(db.GetDataset("ds1"), types.String("a")).
+		CommitValue(db.GetDataset("ds2"), types.String("b")).
+		Delete(ds3).
+		Apply()
+	suite.NoError(err)
+	suite.Equal(1, cs.updates)
+
+	// Other readers see every update.
+	newDB := suite.makeDb(suite.storage.NewView())
+	defer newDB.Close()
+	suite.True(types.String("a").Equals(newDB.GetDataset("ds1").HeadValue()))
+	suite.True(types.String("b").Equals(newDB.GetDataset("ds2").HeadValue()))
+	suite.False(newDB.GetDataset("ds3").HasHead())
+
+	// Updating the same dataset twice isn't allowed.
+	ds1 := db.GetDataset("ds1")
+	err = db.Batch().CommitValue(ds1, types.String("c")).CommitValue(ds1, types.String("d")).Apply()
+	suite.Error(err)
+	suite.True(types.String("a").Equals(db.GetDataset("ds1").HeadValue()))
+}
+
+func (suite *DatabaseSuite) TestBatchIsAtomic() {
+	ds1, err := suite.db.CommitValue(suite.db.GetDataset("ds1"), types.String("a"))
+	suite.NoError(err)
+	ds2, err := suite.db.CommitValue(suite.db.GetDataset("ds2"), types.String("b"))
+	suite.NoError(err)
+	root := suite.db.Datasets().Hash()
+
+	// Move ds2 behind suite.db's back.
+	interloper := suite.makeDb(suite.storage.NewView())
+	defer interloper.Close()
+	_, err = interloper.CommitValue(interloper.GetDataset("ds2"), types.String("e"))
+	suite.NoError(err)
+
+	// Committing to ds2 can't fast-forward, so neither update is made.
+	err = suite.db.Batch().
+		CommitValue(ds1, types.String("c")).
+		CommitValue(ds2, types.String("d")).
+		Apply()
+	suite.Equal(ErrMergeNeeded, err)
+	suite.True(types.String("a").Equals(suite.db.GetDataset("ds1").HeadValue()))
+	suite.True(types.String("e").Equals(suite.db.GetDataset("ds2").HeadValue()))
+
+	// Nor can a moved dataset be deleted.
+	err = suite.db.Batch().CommitValue(ds1, types.String("c")).Delete(ds2).Apply()
+	suite.Equal(ErrMergeNeeded, err)
+	suite.True(types.String("a").Equals(suite.db.GetDataset("ds1").HeadValue()))
+	suite.NotEqual(root, suite.db.Datasets().Hash())
+
+	// Deleting with an expected head reports where the head moved to.
+	err = suite.db.Batch().DeleteExpecting(ds2, ds2.HeadRef().TargetHash()).Apply()
+	if suite.IsType(&ErrHeadMoved{}, err) {
+		suite.Equal(suite.db.GetDataset("ds2").HeadRef().TargetHash(), err.(*ErrHeadMoved).Current)
+	}
+	suite.NoError(suite.db.Batch().DeleteExpecting(ds2, suite.db.GetDataset("ds2").HeadRef().TargetHash()).Apply())
+	suite.False(suite.db.GetDataset("ds2").HasHead())
+
+	// Nor can a dataset which had no head be deleted once it has one.
+	ds3 := suite.db.GetDataset("ds3")
+	_, err = interloper.CommitValue(interloper.GetDataset("ds3"), types.String("f"))
+	suite.NoError(err)
+	// A remote server merges updates to different datasets, and a delete of a dataset with no head changes nothing, so make sure Apply() starts from the root the interloper made.
+	suite.db.Rebase()
+	err = suite.db.Batch().CommitValue(ds1, types.String("c")).Delete(ds3).Apply()
+	suite.Equal(ErrMergeNeeded, err)
+	suite.True(types.String("a").Equals(suite.db.GetDataset("ds1").HeadValue()))
+	suite.True(types.String("f").Equals(suite.db.GetDataset("ds3").HeadValue()))
+}
+
+func (suite *DatabaseSuite) TestBatchMerge() {
+	m := func(kv ...types.Value) types.Map { return types.NewMap(suite.db, kv...) }
+	ds1, err := suite.db.CommitValue(suite.db.GetDataset("ds1"), m(types.String("a"), types.Number(1)))
+	suite.NoError(err)
+	ds2, err := suite.db.CommitValue(suite.db.GetDataset("ds2"), m(types.String("a"), types.Number(1)))
+	suite.NoError(err)
+
+	interloper := suite.makeDb(suite.storage.NewView())
+	defer interloper.Close()
+	_, err = interloper.CommitValue(interloper.GetDataset("ds1"), m(types.String("a"), types.Number(1), types.String("b"), types.Number(2)))
+	suite.NoError(err)
+
+	// Only ds1 needs merging, using its own policy.
+	err = suite.db.Batch().
+		Commit(ds1, m(types.String("a"), types.Number(1), types.String("c"), types.Number(3)), CommitOptions{Policy: merge.NewThreeWay(merge.None)}).
+		Commit(ds2, m(types.String("z"), types.Number(26)), CommitOptions{}).
+		Apply()
+	suite.NoError(err)
+	suite.True(m(types.String("a"), types.Number(1), types.String("b"), types.Number(2), types.String("c"), types.Number(3)).Equals(suite.db.GetDataset("ds1").HeadValue()))
+	suite.True(m(types.String("z"), types.Number(26)).Equals(suite.db.GetDataset("ds2").HeadValue()))
+}
+
+type countRootUpdatesChunkStore struct {
+	chunks.ChunkStore
+	updates int
+}
+
+func (c *countRootUpdatesChunkStore) Commit(current, last hash.Hash) bool {
+	if current != last {
+		c.updates++
+	}
+	return c.ChunkStore.Commit(current, last)
+}
+
 type waitDuringUpdateRootChunkStore struct {
 	chunks.ChunkStore
 	preUpdateRootHook func()
@@ -567,3 +741,179 @@ func (suite *DatabaseSuite) TestMetaOption() {
 	c := ds.Head()
 	suite.Equal(types.String("arv"), c.Get("meta").(types.Struct).Get("author"))
 }
+
+func (suite *DatabaseSuite) TestInitChunkConfig() {
+	cfg := types.ChunkConfig{TargetSize: 1 << 16, Window: 64}
+	suite.Equal(types.DefaultChunkConfig, suite.db.ChunkConfig())
+	suite.Error(suite.db.InitChunkConfig(types.ChunkConfig{TargetSize: 1000, Window: 64}))
+
+	suite.NoError(suite.db.InitChunkConfig(cfg))
+	suite.Equal(cfg, suite.db.ChunkConfig())
+	suite.NoError(suite.db.InitChunkConfig(cfg))
+	suite.Equal(ErrChunkConfigMismatch, suite.db.InitChunkConfig(types.DefaultChunkConfig))
+
+	suite.False(suite.db.Datasets().Has(types.String(chunkConfigDatasetID)))
+	suite.True(suite.db.ReservedDatasets().Has(types.String(chunkConfigDatasetID)))
+	suite.True(IsReservedDatasetID(chunkConfigDatasetID))
+
+	// Other writers pick up the recorded config.
+	newDB := suite.makeDb(suite.storage.NewView())
+	defer newDB.Close()
+	suite.Equal(cfg, newDB.ChunkConfig())
+
+	// Differently chunked trees of the same data have different hashes.
+	data := make([]byte, 1<<17)
+	rand.New(rand.NewSource(42)).Read(data)
+	otherDB := suite.makeDb((&chunks.TestStorage{}).NewView())
+	defer otherDB.Close()
+	suite.NotEqual(
+		types.NewBlob(newDB, bytes.NewReader(data)).Hash(),
+		types.NewBlob(otherDB, bytes.NewReader(data)).Hash(),
+	)
+}
+
+func (suite *DatabaseSuite) TestInitChunkConfigExistingData() {
+	ds, err := suite.db.CommitValue(suite.db.GetDataset("ds1"), types.String("a"))
+	suite.NoError(err)
+
+	suite.Equal(ErrChunkConfigMismatch, suite.db.InitChunkConfig(types.ChunkConfig{TargetSize: 1 << 16, Window: 64}))
+	suite.NoError(suite.db.InitChunkConfig(types.DefaultChunkConfig))
+	suite.True(ds.HeadValue().Equals(suite.db.GetDataset("ds1").HeadValue()))
+}
+
+func (suite *DatabaseSuite) TestInitChunkConfigIgnoresReservedDatasets() {
+	_, err := suite.db.SetDatasetSchema(suite.db.GetDataset("ds1"), types.StringType)
+	suite.NoError(err)
+
+	cfg := types.ChunkConfig{TargetSize: 1 << 16, Window: 64}
+	suite.NoError(suite.db.InitChunkConfig(cfg))
+	suite.Equal(cfg, suite.db.ChunkConfig())
+}
+
+func (suite *DatabaseSuite) TestChunkConfigRecordedByOtherWriter() {
+	stale := suite.makeDb(suite.storage.NewView())
+	defer stale.Close()
+
+	cfg := types.ChunkConfig{TargetSize: 1 << 16, Window: 64}
+	suite.NoError(suite.db.InitChunkConfig(cfg))
+	suite.Equal(types.DefaultChunkConfig, stale.ChunkConfig())
+
+	ds := stale.GetDataset("ds1")
+	_, err := stale.CommitValue(ds, types.String("a"))
+	suite.Equal(ErrChunkConfigMismatch, err)
+	suite.Equal(cfg, stale.ChunkConfig())
+
+	_, err = stale.CommitValue(ds, types.String("a"))
+	suite.NoError(err)
+}
+
+func (suite *DatabaseSuite) TestReservedDatasets() {
+	suite.NoError(suite.db.InitChunkConfig(types.DefaultChunkConfig))
+	ds := suite.db.GetDataset(chunkConfigDatasetID)
+	head := ds.HeadRef()
+
+	_, err := suite.db.CommitValue(ds, types.String("a"))
+	suite.Equal(ErrReservedDataset, err)
+	_, err = suite.db.Delete(ds)
+	suite.Equal(ErrReservedDataset, err)
+	_, err = suite.db.SetHead(ds, head)
+	suite.Equal(ErrReservedDataset, err)
+	_, err = suite.db.FastForward(ds, head)
+	suite.Equal(ErrReservedDataset, err)
+	suite.Equal(ErrReservedDataset, suite.db.Batch().Delete(ds).Apply())
+
+	suite.True(head.Equals(suite.db.GetDataset(chunkConfigDatasetID).HeadRef()))
+}
+
+func (suite *DatabaseSuite) TestDatasetsLeavesOutReservedDatasets() {
+	ds, err := suite.db.CommitValue(suite.db.GetDataset("ds1"), types.String("a"))
+	suite.NoError(err)
+	_, err = suite.db.SetDatasetSchema(ds, types.StringType)
+	suite.NoError(err)
+	suite.NoError(suite.db.InitChunkConfig(types.DefaultChunkConfig))
+
+	suite.Equal(uint64(1), suite.db.Datasets().Len())
+	suite.True(suite.db.Datasets().Has(types.String("ds1")))
+	reserved := suite.db.ReservedDatasets()
+	suite.Equal(uint64(2), reserved.Len())
+	suite.True(reserved.Has(types.String(chunkConfigDatasetID)))
+	suite.True(reserved.Has(types.String(schemaDatasetID)))
+	suite.True(suite.db.GetDataset(schemaDatasetID).HasHead())
+}
+
+func (suite *DatabaseSuite) TestDatasetSchema() {
+	schema := types.MakeStructType("Person", types.StructField{Name: "name", Type: types.StringType})
+	person := func(name string) types.Value {
+		return types.NewStruct("Person", types.StructData{"name": types.String(name)})
+	}
+
+	ds := suite.db.GetDataset("people")
+	_, ok := suite.db.DatasetSchema(ds.ID())
+	suite.False(ok)
+
+	ds, err := suite.db.CommitValue(ds, types.Number(42))
+	suite.NoError(err)
+
+	// The current head doesn't conform.
+	_, err = suite.db.SetDatasetSchema(ds, schema)
+	suite.IsType(&ErrSchemaViolation{}, err)
+	_, ok = suite.db.DatasetSchema(ds.ID())
+	suite.False(ok)
+
+	ds, err = suite.db.CommitValue(ds, person("alice"))
+	suite.NoError(err)
+	ds, err = suite.db.SetDatasetSchema(ds, schema)
+	suite.NoError(err)
+	t, ok := suite.db.DatasetSchema(ds.ID())
+	suite.True(ok)
+	suite.True(schema.Equals(t))
+
+	// Reserved datasets aren't listed, so schemas don't count as data.
+	suite.False(suite.db.Datasets().Has(types.String(schemaDatasetID)))
+	suite.True(suite.db.ReservedDatasets().Has(types.String(schemaDatasetID)))
+
+	// Values with extra fields are subtypes of the schema.
+	ds, err = suite.db.CommitValue(ds, types.NewStruct("Person", types.StructData{"name": types.String("bob"), "age": types.Number(30)}))
+	suite.NoError(err)
+
+	head := ds.HeadRef()
+	_, err = suite.db.CommitValue(ds, types.String("bob"))
+	if suite.IsType(&ErrSchemaViolation{}, err) {
+		suite.Equal(ds.ID(), err.(*ErrSchemaViolation).DatasetID)
+		suite.True(types.StringType.Equals(err.(*ErrSchemaViolation).Actual))
+	}
+	suite.True(head.Equals(suite.db.GetDataset(ds.ID()).HeadRef()))
+
+	// SetHead is checked too.
+	other, err := suite.db.CommitValue(suite.db.GetDataset("other"), types.Number(1))
+	suite.NoError(err)
+	_, err = suite.db.SetHead(ds, other.HeadRef())
+	suite.IsType(&ErrSchemaViolation{}, err)
+
+	// Other datasets are unaffected.
+	_, err = suite.db.CommitValue(other, types.String("anything"))
+	suite.NoError(err)
+
+	// A nil schema removes it.
+	ds, err = suite.db.SetDatasetSchema(ds, nil)
+	suite.NoError(err)
+	_, ok = suite.db.DatasetSchema(ds.ID())
+	suite.False(ok)
+	_, err = suite.db.CommitValue(ds, types.String("bob"))
+	suite.NoError(err)
+}
+
+func (suite *DatabaseSuite) TestDatasetSchemaMerge() {
+	schema := types.MakeMapType(types.StringType, types.NumberType)
+	ds, err := suite.db.CommitValue(suite.db.GetDataset("ds"), types.NewMap(suite.db, types.String("a"), types.Number(1)))
+	suite.NoError(err)
+	ds, err = suite.db.SetDatasetSchema(ds, schema)
+	suite.NoError(err)
+
+	// Values that need merging are checked after the merge.
+	ds2, err := suite.db.CommitValue(ds, types.NewMap(suite.db, types.String("a"), types.Number(1), types.String("b"), types.Number(2)))
+	suite.NoError(err)
+	_, err = suite.db.Commit(ds, types.NewMap(suite.db, types.String("a"), types.Number(1), types.String("c"), types.Bool(true)), CommitOptions{Policy: merge.NewThreeWay(merge.Ours)})
+	suite.IsType(&ErrSchemaViolation{}, err)
+	suite.True(ds2.HeadRef().Equals(suite.db.GetDataset("ds").HeadRef()))
+}
@@ -8,13 +8,18 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -22,6 +27,7 @@ import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/nbs"
+	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/verbose"
 	"github.com/golang/snappy"
 	"github.com/julienschmidt/httprouter"
@@ -39,10 +45,65 @@ var customHTTPTransport = http.Transport{
 	ResponseHeaderTimeout: time.Duration(4) * time.Minute,
 }
 
+// HTTPChunkStoreOptions configures how a ChunkStore backed by a remote Noms
+// server copes with an unreliable network.
+type HTTPChunkStoreOptions struct {
+	// Retries is how many times a request which fails with a network error,
+	// or a 5xx or 429 response, is sent again before giving up. Only
+	// requests which can safely be repeated are retried; updating the root
+	// isn't.
+	Retries int
+
+	// Backoff is how long to wait before the first retry. Each later retry
+	// waits twice as long as the one before, up to MaxBackoff. Up to half
+	// as long again is added at random, so that clients which failed
+	// together don't retry together.
+	Backoff, MaxBackoff time.Duration
+
+	// Timeout limits how long each request may wait for the server: first
+	// for the response to begin, then between reads of its body. A response
+	// that keeps arriving, like a large getPack/, isn't cut off however long
+	// it takes in all. Zero means no limit.
+	Timeout time.Duration
+
+	// WriteBatchSize is roughly the most bytes of chunk data sent in one
+	// writeValue/ request. Chunks are sent children first, so every request
+	// stands on its own. If a request fails, the next Commit() resumes the
+	// upload from it, rather than starting over.
+	WriteBatchSize int
+}
+
+// DefaultHTTPChunkStoreOptions are the options of NewHTTPChunkStore().
+var DefaultHTTPChunkStoreOptions = HTTPChunkStoreOptions{
+	Retries:        5,
+	Backoff:        500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+	Timeout:        4 * time.Minute,
+	WriteBatchSize: 1 << 25, // 32MB
+}
+
+// ErrHTTPResponse is the error when a Noms server responds to a request
+// with an error.
+type ErrHTTPResponse struct {
+	Method, Path string
+	StatusCode   int
+	Body         string
+}
+
+func (e *ErrHTTPResponse) Error() string {
+	return fmt.Sprintf("%s %s: %s: %s", e.Method, e.Path, http.StatusText(e.StatusCode), e.Body)
+}
+
+func newErrHTTPResponse(method string, u url.URL, res *http.Response, body io.Reader) *ErrHTTPResponse {
+	data, _ := ioutil.ReadAll(body)
+	return &ErrHTTPResponse{method, u.Path, res.StatusCode, strings.TrimSpace(string(data))}
+}
+
 type httpChunkStore struct {
 	host         *url.URL
 	httpClient   httpDoer
 	auth         string
+	opts         HTTPChunkStoreOptions
 	getQueue     chan chunks.ReadRequest
 	hasQueue     chan chunks.ReadRequest
 	finishedChan chan struct{}
@@ -51,27 +112,54 @@ type httpChunkStore struct {
 
 	cacheMu       *sync.RWMutex
 	unwrittenPuts *nbs.NomsBlockCache
+	// The hashes of unwrittenPuts which an interrupted Commit() sent to the server.
+	written hash.HashSet
 
 	rootMu  *sync.RWMutex
 	root    hash.Hash
 	version string
 }
 
+// NewHTTPChunkStore returns a ChunkStore backed by the Noms server at baseURL,
+// with DefaultHTTPChunkStoreOptions. It panics if the server can't be reached.
+//
+// The methods of the ChunkStore that can't return an error panic with a
+// d.WrappedError when a request to the server fails. The methods of a
+// Database backed by it that can return an error recover from these panics,
+// and return the error instead; elsewhere, use CatchRemoteErrors.
 func NewHTTPChunkStore(baseURL, auth string) chunks.ChunkStore {
+	hcs, err := NewHTTPChunkStoreWithOptions(baseURL, auth, DefaultHTTPChunkStoreOptions)
+	d.PanicIfError(err)
+	return hcs
+}
+
+// NewHTTPChunkStoreWithOptions returns a ChunkStore backed by the Noms server
+// at baseURL, which retries and times out requests according to opts. It
+// returns an error if the server can't be reached.
+func NewHTTPChunkStoreWithOptions(baseURL, auth string, opts HTTPChunkStoreOptions) (chunks.ChunkStore, error) {
 	// Custom http.Client to give control of idle connections and timeouts
-	return newHTTPChunkStoreWithClient(baseURL, auth, &http.Client{Transport: &customHTTPTransport})
+	return newHTTPChunkStore(baseURL, auth, &http.Client{Transport: &customHTTPTransport}, opts)
 }
 
 func newHTTPChunkStoreWithClient(baseURL, auth string, client httpDoer) *httpChunkStore {
-	u, err := url.Parse(baseURL)
+	hcs, err := newHTTPChunkStore(baseURL, auth, client, DefaultHTTPChunkStoreOptions)
 	d.PanicIfError(err)
+	return hcs
+}
+
+func newHTTPChunkStore(baseURL, auth string, client httpDoer, opts HTTPChunkStoreOptions) (*httpChunkStore, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
 	if u.Scheme != "http" && u.Scheme != "https" {
-		d.Panic("Unrecognized scheme: %s", u.Scheme)
+		return nil, fmt.Errorf("Unrecognized scheme: %s", u.Scheme)
 	}
 	hcs := &httpChunkStore{
 		host:          u,
 		httpClient:    client,
 		auth:          auth,
+		opts:          opts,
 		getQueue:      make(chan chunks.ReadRequest),
 		hasQueue:      make(chan chunks.ReadRequest),
 		finishedChan:  make(chan struct{}),
@@ -79,18 +167,159 @@ func newHTTPChunkStoreWithClient(baseURL, auth string, client httpDoer) *httpChu
 		workerWg:      &sync.WaitGroup{},
 		cacheMu:       &sync.RWMutex{},
 		unwrittenPuts: nbs.NewCache(),
+		written:       hash.HashSet{},
 		rootMu:        &sync.RWMutex{},
 	}
-	hcs.root, hcs.version = hcs.getRoot(false)
+	if hcs.root, hcs.version, err = hcs.getRoot(false); err != nil {
+		hcs.unwrittenPuts.Destroy()
+		return nil, err
+	}
 	hcs.batchGetRequests()
 	hcs.batchHasRequests()
-	return hcs
+	return hcs, nil
 }
 
 type httpDoer interface {
 	Do(req *http.Request) (resp *http.Response, err error)
 }
 
+// do sends a request, and calls handle with the response and its decoded
+// body, unless the response is a 5xx or 429. If sending the request fails,
+// or reading the response does, or the response is a 5xx or 429, and retry
+// is true, the request is sent again according to hcs.opts. Since body is
+// called for each attempt, it can leave out whatever a failed attempt got.
+func (hcs *httpChunkStore) do(method string, u url.URL, body func() io.Reader, header http.Header, retry bool, handle func(res *http.Response, reader io.Reader) error) (err error) {
+	backoff := hcs.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		err = hcs.doOnce(method, u, body, header, handle)
+		if err == nil || !retry || attempt >= hcs.opts.Retries || !isRetryable(err) {
+			return
+		}
+
+		wait := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+		verbose.Log("%s %s failed, retrying in %s: %s", method, u.Path, wait, err)
+		time.Sleep(wait)
+		if backoff *= 2; backoff > hcs.opts.MaxBackoff {
+			backoff = hcs.opts.MaxBackoff
+		}
+	}
+}
+
+func (hcs *httpChunkStore) doOnce(method string, u url.URL, body func() io.Reader, header http.Header, handle func(res *http.Response, reader io.Reader) error) error {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = body()
+	}
+	req := newRequest(method, hcs.auth, u.String(), bodyReader, header)
+	var idle *idleTimer
+	if hcs.opts.Timeout > 0 {
+		var ctx context.Context
+		ctx, idle = newIdleTimer(hcs.opts.Timeout)
+		defer idle.stop()
+		req = req.WithContext(ctx)
+	}
+
+	err := func() error {
+		res, err := hcs.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		if idle != nil {
+			res.Body = idle.wrap(res.Body)
+		}
+		reader, err := resBodyReader(res)
+		if err != nil {
+			closeResponse(res.Body)
+			return err
+		}
+		defer closeResponse(reader)
+
+		if res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests {
+			return newErrHTTPResponse(method, u, res, reader)
+		}
+		return handle(res, reader)
+	}()
+	if err != nil && idle != nil && idle.expired() {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// idleTimer cancels a request once it has waited too long for the server,
+// either for the response to begin or for more of its body. Every read of
+// the body that makes progress restarts the wait.
+type idleTimer struct {
+	timeout time.Duration
+	timer   *time.Timer
+	fired   int32
+}
+
+func newIdleTimer(timeout time.Duration) (context.Context, *idleTimer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	it := &idleTimer{timeout: timeout}
+	it.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&it.fired, 1)
+		cancel()
+	})
+	return ctx, it
+}
+
+func (it *idleTimer) expired() bool {
+	return atomic.LoadInt32(&it.fired) == 1
+}
+
+func (it *idleTimer) stop() {
+	it.timer.Stop()
+}
+
+func (it *idleTimer) wrap(body io.ReadCloser) io.ReadCloser {
+	return idleTimerBody{body, it}
+}
+
+type idleTimerBody struct {
+	io.ReadCloser
+	it *idleTimer
+}
+
+func (b idleTimerBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	if n > 0 && !b.it.expired() {
+		b.it.timer.Reset(b.it.timeout)
+	}
+	return
+}
+
+// isRetryable returns true if err might not happen if the request that
+// caused it were sent again.
+func isRetryable(err error) bool {
+	switch err := err.(type) {
+	case *ErrHTTPResponse:
+		return err.StatusCode >= http.StatusInternalServerError || err.StatusCode == http.StatusTooManyRequests
+	case net.Error:
+		return true
+	}
+	return err == io.ErrUnexpectedEOF || err == context.DeadlineExceeded
+}
+
+// IsRemoteError returns true if err is how a request to a Noms server failed:
+// an error response, or a network error.
+func IsRemoteError(err error) bool {
+	_, ok := err.(*ErrHTTPResponse)
+	return ok || isRetryable(err)
+}
+
+// CatchRemoteErrors calls f. If f panics because a request to a Noms server
+// failed, it recovers and returns the error with which the request failed.
+// Other panics are passed on.
+func CatchRemoteErrors(f func()) error {
+	return d.TryCatch(f, func(err error) error {
+		if cause := d.Unwrap(err); IsRemoteError(cause) {
+			return cause
+		}
+		panic(err)
+	})
+}
+
 func (hcs *httpChunkStore) Version() string {
 	return hcs.version
 }
@@ -116,6 +345,22 @@ func (hcs *httpChunkStore) Stats() interface{} {
 	return nil
 }
 
+// failableRequest is a chunks.ReadRequest whose caller can find out why it
+// failed, if it did: the batch it's sent in sets *err before failing it.
+type failableRequest struct {
+	chunks.ReadRequest
+	err *error
+}
+
+func (r failableRequest) Outstanding() chunks.OutstandingRequest {
+	return failableOutstanding{r.ReadRequest.Outstanding(), r.err}
+}
+
+type failableOutstanding struct {
+	chunks.OutstandingRequest
+	err *error
+}
+
 func (hcs *httpChunkStore) Get(h hash.Hash) chunks.Chunk {
 	checkCache := func(h hash.Hash) chunks.Chunk {
 		hcs.cacheMu.RLock()
@@ -129,13 +374,16 @@ func (hcs *httpChunkStore) Get(h hash.Hash) chunks.Chunk {
 	ch := make(chan *chunks.Chunk)
 	defer close(ch)
 
+	var err error
 	select {
 	case <-hcs.finishedChan:
 		d.Panic("Tried to Get %s from closed ChunkStore", h)
-	case hcs.getQueue <- chunks.NewGetRequest(h, ch):
+	case hcs.getQueue <- failableRequest{chunks.NewGetRequest(h, ch), &err}:
 	}
 
-	return *(<-ch)
+	c := <-ch
+	d.PanicIfError(err)
+	return *c
 }
 
 func (hcs *httpChunkStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
@@ -160,12 +408,14 @@ func (hcs *httpChunkStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks
 	}
 	wg := &sync.WaitGroup{}
 	wg.Add(len(remaining))
+	var err error
 	select {
 	case <-hcs.finishedChan:
 		d.Panic("Tried to GetMany from closed ChunkStore")
-	case hcs.getQueue <- chunks.NewGetManyRequest(remaining, wg, foundChunks):
+	case hcs.getQueue <- failableRequest{chunks.NewGetManyRequest(remaining, wg, foundChunks), &err}:
 	}
 	wg.Wait()
+	d.PanicIfError(err)
 }
 
 func (hcs *httpChunkStore) batchGetRequests() {
@@ -184,13 +434,16 @@ func (hcs *httpChunkStore) Has(h hash.Hash) bool {
 
 	ch := make(chan bool)
 	defer close(ch)
+	var err error
 	select {
 	case <-hcs.finishedChan:
 		d.Panic("Tried to Has %s on closed ChunkStore", h)
-	case hcs.hasQueue <- chunks.NewAbsentRequest(h, ch):
+	case hcs.hasQueue <- failableRequest{chunks.NewAbsentRequest(h, ch), &err}:
 	}
 
-	return <-ch
+	has := <-ch
+	d.PanicIfError(err)
+	return has
 }
 
 func (hcs *httpChunkStore) HasMany(hashes hash.HashSet) (absent hash.HashSet) {
@@ -207,10 +460,11 @@ func (hcs *httpChunkStore) HasMany(hashes hash.HashSet) (absent hash.HashSet) {
 	notFoundChunks := make(chan hash.Hash)
 	wg := &sync.WaitGroup{}
 	wg.Add(len(remaining))
+	var err error
 	select {
 	case <-hcs.finishedChan:
 		d.Panic("Tried to HasMany on closed ChunkStore")
-	case hcs.hasQueue <- chunks.NewAbsentManyRequest(remaining, wg, notFoundChunks):
+	case hcs.hasQueue <- failableRequest{chunks.NewAbsentManyRequest(remaining, wg, notFoundChunks), &err}:
 	}
 	go func() { defer close(notFoundChunks); wg.Wait() }()
 
@@ -218,6 +472,7 @@ func (hcs *httpChunkStore) HasMany(hashes hash.HashSet) (absent hash.HashSet) {
 	for notFound := range notFoundChunks {
 		absent.Insert(notFound)
 	}
+	d.PanicIfError(err)
 	return absent
 }
 
@@ -225,7 +480,7 @@ func (hcs *httpChunkStore) batchHasRequests() {
 	hcs.batchReadRequests(hcs.hasQueue, hcs.hasRefs)
 }
 
-type batchGetter func(batch chunks.ReadBatch)
+type batchGetter func(batch chunks.ReadBatch) error
 
 func (hcs *httpChunkStore) batchReadRequests(queue <-chan chunks.ReadRequest, getter batchGetter) {
 	hcs.workerWg.Add(1)
@@ -266,11 +521,20 @@ func (hcs *httpChunkStore) sendReadRequests(req chunks.ReadRequest, queue <-chan
 		defer batch.Close()
 		defer func() { <-hcs.rateLimit }()
 
-		getter(batch)
+		// The requests left in batch are failed by batch.Close(). Panicking here would crash, so let their callers know why.
+		if err := getter(batch); err != nil {
+			for _, reqs := range batch {
+				for _, req := range reqs {
+					if fr, ok := req.(failableOutstanding); ok {
+						*fr.err = err
+					}
+				}
+			}
+		}
 	}()
 }
 
-func (hcs *httpChunkStore) getRefs(batch chunks.ReadBatch) {
+func (hcs *httpChunkStore) getRefs(batch chunks.ReadBatch) error {
 	// POST http://<host>/getRefs/. Post body: ref=hash0&ref=hash1& Response will be chunk data if present, 404 if absent.
 	u := *hcs.host
 	u.Path = httprouter.CleanPath(hcs.host.Path + constants.GetRefsPath)
@@ -282,70 +546,145 @@ func (hcs *httpChunkStore) getRefs(batch chunks.ReadBatch) {
 	}
 	u.RawQuery = q
 
-	req := newRequest("POST", hcs.auth, u.String(), buildHashesRequest(batch), http.Header{
+	header := http.Header{
 		"Accept-Encoding": {"x-snappy-framed"},
 		"Content-Type":    {"application/octet-stream"},
-	})
+	}
+	// Chunks found by an attempt are removed from batch, so a retry only asks for the rest.
+	return hcs.do("POST", u, func() io.Reader { return hashesBody(batch) }, header, true, func(res *http.Response, reader io.Reader) error {
+		if err := expectVersion(hcs.version, res); err != nil {
+			return err
+		}
+		if http.StatusOK != res.StatusCode {
+			return newErrHTTPResponse("POST", u, res, reader)
+		}
 
-	res, err := hcs.httpClient.Do(req)
-	d.Chk.NoError(err)
-	expectVersion(hcs.version, res)
-	reader := resBodyReader(res)
-	defer closeResponse(reader)
+		return deserializeChunks(reader, func(c *chunks.Chunk) {
+			h := c.Hash()
+			for _, or := range batch[h] {
+				go or.Satisfy(h, c)
+			}
+			delete(batch, h)
+		})
+	})
+}
 
-	if http.StatusOK != res.StatusCode {
-		data, _ := ioutil.ReadAll(reader)
-		d.Panic("Unexpected response: %s\n%s", http.StatusText(res.StatusCode), string(data))
+// getPack calls found with every chunk the server has which is reachable
+// from want, but not from any of haves, tallest first. It does so with a
+// single request, rather than the round trip per level of the graph that
+// Get() and Has() take. It returns false, having found nothing, if the
+// server doesn't support getPack/ or doesn't have want, or if there are Puts
+// the server doesn't have yet. If the request is retried, chunks found by
+// the failed attempt may be found again.
+func (hcs *httpChunkStore) getPack(want hash.Hash, haves hash.HashSlice, found func(c *chunks.Chunk)) (ok bool) {
+	hcs.cacheMu.RLock()
+	pending := hcs.unwrittenPuts.Count()
+	hcs.cacheMu.RUnlock()
+	if pending > 0 {
+		return false
 	}
 
-	chunkChan := make(chan *chunks.Chunk, 16)
-	go func() { defer close(chunkChan); chunks.Deserialize(reader, chunkChan) }()
+	// POST http://<host>/getPack/?want=<hash>. Post body: the hashes of haves. Response will be chunk data, or 404 if want is absent.
+	u := *hcs.host
+	u.Path = httprouter.CleanPath(hcs.host.Path + constants.GetPackPath)
+	q := "want=" + want.String()
+	if u.RawQuery != "" {
+		q = u.RawQuery + "&" + q
+	}
+	u.RawQuery = q
 
-	for c := range chunkChan {
-		h := c.Hash()
-		for _, or := range batch[h] {
-			go or.Satisfy(h, c)
-		}
-		delete(batch, c.Hash())
+	batch := chunks.ReadBatch{}
+	for _, h := range haves {
+		batch[h] = nil
 	}
+	header := http.Header{
+		"Accept-Encoding": {"x-snappy-framed"},
+		"Content-Type":    {"application/octet-stream"},
+	}
+	err := hcs.do("POST", u, func() io.Reader { return hashesBody(batch) }, header, true, func(res *http.Response, reader io.Reader) error {
+		// Servers which predate getPack/ respond 404 without a version header.
+		if res.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		if err := expectVersion(hcs.version, res); err != nil {
+			return err
+		}
+		if http.StatusOK != res.StatusCode {
+			return newErrHTTPResponse("POST", u, res, reader)
+		}
+		ok = true
+		return deserializeChunks(reader, found)
+	})
+	d.PanicIfError(err)
+	return
 }
 
-func (hcs *httpChunkStore) hasRefs(batch chunks.ReadBatch) {
+func (hcs *httpChunkStore) hasRefs(batch chunks.ReadBatch) error {
 	// POST http://<host>/hasRefs/. Post body: ref=sha1---&ref=sha1---& Response will be text of lines containing "|ref| |bool|".
 	u := *hcs.host
 	u.Path = httprouter.CleanPath(hcs.host.Path + constants.HasRefsPath)
 
-	req := newRequest("POST", hcs.auth, u.String(), buildHashesRequest(batch), http.Header{
+	header := http.Header{
 		"Accept-Encoding": {"x-snappy-framed"},
 		"Content-Type":    {"application/octet-stream"},
-	})
+	}
+	// Absent hashes found by an attempt are removed from batch, so a retry only asks about the rest.
+	return hcs.do("POST", u, func() io.Reader { return hashesBody(batch) }, header, true, func(res *http.Response, reader io.Reader) error {
+		if err := expectVersion(hcs.version, res); err != nil {
+			return err
+		}
+		if http.StatusOK != res.StatusCode {
+			return newErrHTTPResponse("POST", u, res, reader)
+		}
 
-	res, err := hcs.httpClient.Do(req)
-	d.Chk.NoError(err)
-	expectVersion(hcs.version, res)
-	reader := resBodyReader(res)
-	defer closeResponse(reader)
+		scanner := bufio.NewScanner(reader)
+		scanner.Split(bufio.ScanWords)
+		for scanner.Scan() {
+			h, ok := hash.MaybeParse(scanner.Text())
+			if !ok {
+				return fmt.Errorf("Invalid hash in hasRefs/ response: %s", scanner.Text())
+			}
+			for _, outstanding := range batch[h] {
+				outstanding.Satisfy(h, &chunks.EmptyChunk)
+			}
+			delete(batch, h)
+		}
+		return scanner.Err()
+	})
+}
 
-	if http.StatusOK != res.StatusCode {
-		d.Panic("Unexpected response: %s", http.StatusText(res.StatusCode))
-	}
+func hashesBody(batch chunks.ReadBatch) io.Reader {
+	buf := &bytes.Buffer{}
+	serializeHashes(buf, batch)
+	return buf
+}
 
-	scanner := bufio.NewScanner(reader)
-	scanner.Split(bufio.ScanWords)
-	for scanner.Scan() {
-		h := hash.Parse(scanner.Text())
-		for _, outstanding := range batch[h] {
-			outstanding.Satisfy(h, &chunks.EmptyChunk)
+// deserializeChunks calls found with each chunk serialized in reader, and
+// returns an error if reader fails or holds an invalid chunk.
+func deserializeChunks(reader io.Reader, found func(c *chunks.Chunk)) error {
+	chunkChan := make(chan *chunks.Chunk, 16)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(chunkChan)
+		var err error
+		if tryErr := d.Try(func() { err = chunks.Deserialize(reader, chunkChan) }); tryErr != nil {
+			err = d.Unwrap(tryErr)
 		}
-		delete(batch, h)
+		errChan <- err
+	}()
+	for c := range chunkChan {
+		found(c)
 	}
+	return <-errChan
 }
 
-func resBodyReader(res *http.Response) (reader io.ReadCloser) {
+func resBodyReader(res *http.Response) (reader io.ReadCloser, err error) {
 	reader = res.Body
 	if strings.Contains(res.Header.Get("Content-Encoding"), "gzip") {
-		gr, err := gzip.NewReader(reader)
-		d.Chk.NoError(err)
+		var gr *gzip.Reader
+		if gr, err = gzip.NewReader(reader); err != nil {
+			return nil, err
+		}
 		reader = gr
 	} else if strings.Contains(res.Header.Get("Content-Encoding"), "x-snappy-framed") {
 		sr := snappy.NewReader(reader)
@@ -365,27 +704,72 @@ func (hcs *httpChunkStore) Put(c chunks.Chunk) {
 	hcs.unwrittenPuts.Insert(c)
 }
 
-func sendWriteRequest(u url.URL, auth, vers string, p *nbs.NomsBlockCache, cli httpDoer) {
+// sendWriteRequests sends the unwrittenPuts which haven't been written yet
+// to the server, in writeValue/ requests of about opts.WriteBatchSize. The
+// server rejects chunks which refer to chunks it doesn't have, so they're
+// sent in order of height, children first.
+func (hcs *httpChunkStore) sendWriteRequests() error {
+	type chunkHeight struct {
+		h      hash.Hash
+		height uint64
+	}
+	ordered := []chunkHeight{}
 	chunkChan := make(chan *chunks.Chunk, 1024)
 	go func() {
-		p.ExtractChunks(chunkChan)
+		hcs.unwrittenPuts.ExtractChunks(chunkChan)
 		close(chunkChan)
 	}()
+	for c := range chunkChan {
+		if hcs.written.Has(c.Hash()) {
+			continue
+		}
+		height := uint64(1)
+		types.DecodeValue(*c, nil).WalkRefs(func(r types.Ref) {
+			if r.Height() >= height {
+				height = r.Height() + 1
+			}
+		})
+		ordered = append(ordered, chunkHeight{c.Hash(), height})
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].height < ordered[j].height })
 
-	body := buildWriteValueRequest(chunkChan)
-	req := newRequest("POST", auth, u.String(), body, http.Header{
+	u := *hcs.host
+	u.Path = httprouter.CleanPath(hcs.host.Path + constants.WriteValuePath)
+	header := http.Header{
 		"Content-Encoding": {"x-snappy-framed"},
 		"Content-Type":     {"application/octet-stream"},
-	})
-
-	res, err := cli.Do(req)
-	d.PanicIfError(err)
-	expectVersion(vers, res)
-	defer closeResponse(res.Body)
+	}
+	for len(ordered) > 0 {
+		buf := &bytes.Buffer{}
+		sw := snappy.NewBufferedWriter(buf)
+		sent := hash.HashSlice{}
+		for size := 0; len(ordered) > 0 && size < hcs.opts.WriteBatchSize; ordered = ordered[1:] {
+			c := hcs.unwrittenPuts.Get(ordered[0].h)
+			chunks.Serialize(c, sw)
+			size += len(c.Data())
+			sent = append(sent, c.Hash())
+		}
+		d.PanicIfError(sw.Close())
 
-	if http.StatusCreated != res.StatusCode {
-		d.Panic("Unexpected response: %s", formatErrorResponse(res))
+		body := buf.Bytes()
+		verbose.Log("Sending %d chunks", len(sent))
+		err := hcs.do("POST", u, func() io.Reader { return bytes.NewReader(body) }, header, true, func(res *http.Response, reader io.Reader) error {
+			if err := expectVersion(hcs.version, res); err != nil {
+				return err
+			}
+			if http.StatusCreated != res.StatusCode {
+				return newErrHTTPResponse("POST", u, res, reader)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, h := range sent {
+			hcs.written.Insert(h)
+		}
 	}
+	return nil
 }
 
 func (hcs *httpChunkStore) Root() hash.Hash {
@@ -395,27 +779,34 @@ func (hcs *httpChunkStore) Root() hash.Hash {
 }
 
 func (hcs *httpChunkStore) Rebase() {
-	root, _ := hcs.getRoot(true)
+	root, _, err := hcs.getRoot(true)
+	d.PanicIfError(err)
 	hcs.rootMu.Lock()
 	defer hcs.rootMu.Unlock()
 	hcs.root = root
 }
 
-func (hcs *httpChunkStore) getRoot(checkVers bool) (root hash.Hash, vers string) {
+func (hcs *httpChunkStore) getRoot(checkVers bool) (root hash.Hash, vers string, err error) {
 	// GET http://<host>/root. Response will be ref of root.
-	res := hcs.requestRoot("GET", hash.Hash{}, hash.Hash{})
-	if checkVers {
-		expectVersion(hcs.version, res)
-	}
-	defer closeResponse(res.Body)
-
-	if http.StatusOK != res.StatusCode {
-		d.Panic("Unexpected response: %s", http.StatusText(res.StatusCode))
-	}
-	data, err := ioutil.ReadAll(res.Body)
-	d.PanicIfError(err)
-
-	return hash.Parse(string(data)), res.Header.Get(NomsVersionHeader)
+	u := *hcs.host
+	u.Path = httprouter.CleanPath(hcs.host.Path + constants.RootPath)
+	err = hcs.do("GET", u, nil, nil, true, func(res *http.Response, reader io.Reader) error {
+		if checkVers {
+			if err := expectVersion(hcs.version, res); err != nil {
+				return err
+			}
+		}
+		if http.StatusOK != res.StatusCode {
+			return newErrHTTPResponse("GET", u, res, reader)
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		root, vers = hash.Parse(string(data)), res.Header.Get(NomsVersionHeader)
+		return nil
+	})
+	return
 }
 
 func (hcs *httpChunkStore) Commit(current, last hash.Hash) bool {
@@ -432,58 +823,48 @@ func (hcs *httpChunkStore) Commit(current, last hash.Hash) bool {
 	}
 
 	if count := hcs.unwrittenPuts.Count(); count > 0 {
-		url := *hcs.host
-		url.Path = httprouter.CleanPath(hcs.host.Path + constants.WriteValuePath)
 		verbose.Log("Sending %d chunks", count)
-		sendWriteRequest(url, hcs.auth, hcs.version, hcs.unwrittenPuts, hcs.httpClient)
+		d.PanicIfError(hcs.sendWriteRequests())
 		verbose.Log("Finished sending %d hashes", count)
 		hcs.unwrittenPuts.Destroy()
 		hcs.unwrittenPuts = nbs.NewCache()
+		hcs.written = hash.HashSet{}
 	}
 
 	// POST http://<host>/root?current=<ref>&last=<ref>. Response will be 200 on success, 409 if current is outdated. Regardless, the server returns its current root for this store
-	res := hcs.requestRoot("POST", current, last)
-	expectVersion(hcs.version, res)
-	defer closeResponse(res.Body)
-
-	var success bool
-	switch res.StatusCode {
-	case http.StatusOK:
-		success = true
-	case http.StatusConflict:
-		success = false
-	default:
-		buf := bytes.Buffer{}
-		buf.ReadFrom(res.Body)
-		body := buf.String()
-		d.Chk.Fail(
-			fmt.Sprintf("Unexpected response: %s: %s",
-				http.StatusText(res.StatusCode),
-				body))
-		return false
-	}
-	data, err := ioutil.ReadAll(res.Body)
-	d.PanicIfError(err)
-	hcs.root = hash.Parse(string(data))
-	return success
-}
-
-func (hcs *httpChunkStore) requestRoot(method string, current, last hash.Hash) *http.Response {
 	u := *hcs.host
 	u.Path = httprouter.CleanPath(hcs.host.Path + constants.RootPath)
-	if method == "POST" {
-		params := u.Query()
-		params.Add("last", last.String())
-		params.Add("current", current.String())
-		u.RawQuery = params.Encode()
-	}
+	params := u.Query()
+	params.Add("last", last.String())
+	params.Add("current", current.String())
+	u.RawQuery = params.Encode()
 
-	req := newRequest(method, hcs.auth, u.String(), nil, nil)
-
-	res, err := hcs.httpClient.Do(req)
+	// If the response to an update of the root is lost, the update may or may not have happened, so it isn't retried.
+	var success bool
+	err := hcs.do("POST", u, nil, nil, false, func(res *http.Response, reader io.Reader) error {
+		if err := expectVersion(hcs.version, res); err != nil {
+			return err
+		}
+		switch res.StatusCode {
+		case http.StatusOK:
+			success = true
+		case http.StatusConflict:
+			success = false
+		case http.StatusForbidden:
+			data, _ := ioutil.ReadAll(reader)
+			return &ErrCommitRejected{strings.TrimSpace(string(data))}
+		default:
+			return newErrHTTPResponse("POST", u, res, reader)
+		}
+		data, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		hcs.root = hash.Parse(string(data))
+		return nil
+	})
 	d.PanicIfError(err)
-
-	return res
+	return success
 }
 
 func newRequest(method, auth, url string, body io.Reader, header http.Header) *http.Request {
@@ -501,24 +882,19 @@ func newRequest(method, auth, url string, body io.Reader, header http.Header) *h
 	return req
 }
 
-func formatErrorResponse(res *http.Response) string {
-	data, err := ioutil.ReadAll(res.Body)
-	d.Chk.NoError(err)
-	return fmt.Sprintf("%s:\n%s\n", res.Status, data)
-}
-
-func expectVersion(expected string, res *http.Response) {
+func expectVersion(expected string, res *http.Response) error {
 	dataVersion := res.Header.Get(NomsVersionHeader)
 	if expected != dataVersion {
 		b, _ := ioutil.ReadAll(res.Body)
 		res.Body.Close()
-		d.Panic(
+		return fmt.Errorf(
 			"Version skew\n\r"+
 				"\tServer data version changed from '%s' to '%s'\n\r"+
 				"\tHTTP Response: %d (%s): %s\n",
 			expected, dataVersion,
 			res.StatusCode, res.Status, string(b))
 	}
+	return nil
 }
 
 // In order for keep alive to work we must read to EOF on every response. We may want to add a timeout so that a server that left its connection open can't cause all of ports to be eaten up.
@@ -5,15 +5,20 @@
 package datas
 
 import (
+	"context"
 	"encoding/binary"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/julienschmidt/httprouter"
@@ -56,6 +61,10 @@ func (suite *HTTPChunkStoreSuite) SetupTest() {
 }
 
 func newHTTPChunkStoreForTest(cs chunks.ChunkStore) *httpChunkStore {
+	return newHookedHTTPChunkStoreForTest(cs, nil)
+}
+
+func newHookedHTTPChunkStoreForTest(cs chunks.ChunkStore, hooks []CommitHook) *httpChunkStore {
 	// Ideally, this function (and its bretheren below) would take a *TestStorage and mint a fresh TestStoreView in each handler call below. That'd break a bunch of tests in pull_test.go that want to pass in a single TestStoreView and then inspect it after doing a bunch of work. The cs.Rebase() calls here are a good compromise for now, but BUG 3415 tracks Making This Right.
 	serv := inlineServer{httprouter.New()}
 	serv.POST(
@@ -79,11 +88,18 @@ func newHTTPChunkStoreForTest(cs chunks.ChunkStore) *httpChunkStore {
 			HandleHasRefs(w, req, ps, cs)
 		},
 	)
+	serv.POST(
+		constants.GetPackPath,
+		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+			cs.Rebase()
+			HandleGetPack(w, req, ps, cs)
+		},
+	)
 	serv.POST(
 		constants.RootPath,
 		func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 			cs.Rebase()
-			HandleRootPost(w, req, ps, cs)
+			NewHandleRootPost(hooks)(w, req, ps, cs)
 		},
 	)
 	serv.GET(
@@ -454,3 +470,201 @@ func (suite *HTTPChunkStoreSuite) TestHasManySomeCached() {
 	}
 	suite.False(absent.Has(cached.Hash()), "%s present in %v", cached.Hash(), absent)
 }
+
+// flakyHTTPDoer responds 503 to requests for which fail returns true,
+// instead of passing them on, and counts every request by path.
+type flakyHTTPDoer struct {
+	httpDoer
+	fail     func(req *http.Request) bool
+	requests map[string]int
+}
+
+func (f flakyHTTPDoer) Do(req *http.Request) (*http.Response, error) {
+	f.requests[req.URL.Path]++
+	if f.fail(req) {
+		return &http.Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Status:     http.StatusText(http.StatusServiceUnavailable),
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader("try again later")),
+		}, nil
+	}
+	return f.httpDoer.Do(req)
+}
+
+func (suite *HTTPChunkStoreSuite) makeFlaky(retries int, fail func(req *http.Request) bool) flakyHTTPDoer {
+	suite.http.opts.Retries = retries
+	suite.http.opts.Backoff = time.Millisecond
+	suite.http.opts.MaxBackoff = time.Millisecond
+	flaky := flakyHTTPDoer{suite.http.httpClient, fail, map[string]int{}}
+	suite.http.httpClient = flaky
+	return flaky
+}
+
+func (suite *HTTPChunkStoreSuite) TestRetry() {
+	attempts := map[string]int{}
+	flaky := suite.makeFlaky(2, func(req *http.Request) bool {
+		if req.URL.Path == constants.RootPath {
+			return false
+		}
+		attempts[req.URL.Path]++
+		return attempts[req.URL.Path] <= 2
+	})
+
+	c := types.EncodeValue(types.String("abc"))
+	suite.http.Put(c)
+	suite.True(suite.http.Commit(hash.Hash{}, hash.Hash{}))
+	suite.True(suite.serverCS.Has(c.Hash()))
+
+	other := chunks.NewChunk([]byte("def"))
+	suite.serverCS.Put(other)
+	persistChunks(suite.serverCS)
+	suite.Equal(other.Hash(), suite.http.Get(other.Hash()).Hash())
+	suite.True(suite.http.Has(other.Hash()))
+
+	for _, path := range []string{constants.WriteValuePath, constants.GetRefsPath, constants.HasRefsPath} {
+		suite.Equal(3, flaky.requests[path], path)
+	}
+}
+
+func (suite *HTTPChunkStoreSuite) TestCommitIsNotRetried() {
+	flaky := suite.makeFlaky(2, func(req *http.Request) bool {
+		return req.Method == "POST" && req.URL.Path == constants.RootPath
+	})
+	err := d.Try(func() { suite.http.Commit(hash.Hash{}, hash.Hash{}) })
+	suite.IsType(&ErrHTTPResponse{}, d.Unwrap(err))
+	suite.Equal(1, flaky.requests[constants.RootPath])
+}
+
+func (suite *HTTPChunkStoreSuite) TestDatabaseReturnsRemoteErrors() {
+	suite.makeFlaky(2, func(req *http.Request) bool {
+		return req.Method == "POST" && req.URL.Path == constants.RootPath
+	})
+	db := NewDatabase(suite.http)
+	ds := db.GetDataset("ds")
+	_, err := db.CommitValue(ds, types.String("abc"))
+	suite.IsType(&ErrHTTPResponse{}, err)
+	suite.True(IsRemoteError(err))
+	suite.IsType(&ErrHTTPResponse{}, db.Batch().CommitValue(ds, types.String("abc")).Apply())
+}
+
+func (suite *HTTPChunkStoreSuite) TestGetFailure() {
+	flaky := suite.makeFlaky(1, func(req *http.Request) bool { return true })
+
+	c := chunks.NewChunk([]byte("abc"))
+	suite.serverCS.Put(c)
+	persistChunks(suite.serverCS)
+	err := d.Try(func() { suite.http.Get(c.Hash()) })
+	suite.Error(err)
+	if resErr, ok := d.Unwrap(err).(*ErrHTTPResponse); suite.True(ok) {
+		suite.Equal(http.StatusServiceUnavailable, resErr.StatusCode)
+		suite.Equal("try again later", resErr.Body)
+	}
+	suite.Equal(2, flaky.requests[constants.GetRefsPath])
+
+	err = d.Try(func() { suite.http.HasMany(hash.NewHashSet(c.Hash())) })
+	suite.IsType(&ErrHTTPResponse{}, d.Unwrap(err))
+}
+
+func (suite *HTTPChunkStoreSuite) TestResumeWrites() {
+	vals := []types.Value{
+		types.String("abc"),
+		types.String("def"),
+	}
+	vs := types.NewValueStore(suite.serverCS)
+	defer vs.Close()
+	le := types.NewList(vs).Edit()
+	for _, val := range vals {
+		suite.http.Put(types.EncodeValue(val))
+		le.Append(types.NewRef(val))
+	}
+	l := le.List()
+	suite.http.Put(types.EncodeValue(l))
+
+	// Each chunk is written in its own request, and the second one fails until healed.
+	healed := false
+	writes := 0
+	flaky := suite.makeFlaky(0, func(req *http.Request) bool {
+		if req.URL.Path != constants.WriteValuePath {
+			return false
+		}
+		writes++
+		return writes == 2 && !healed
+	})
+	suite.http.opts.WriteBatchSize = 1
+
+	suite.Panics(func() { suite.http.Commit(hash.Hash{}, hash.Hash{}) })
+	suite.Equal(2, flaky.requests[constants.WriteValuePath])
+	suite.Equal(1, suite.serverCS.Writes)
+
+	healed = true
+	suite.True(suite.http.Commit(hash.Hash{}, hash.Hash{}))
+	suite.Equal(4, flaky.requests[constants.WriteValuePath])
+	suite.Equal(3, suite.serverCS.Writes)
+	suite.True(suite.serverCS.Has(l.Hash()))
+}
+
+// slowHTTPDoer passes requests on, but delivers the bodies of responses to
+// requests for which slow returns true a few bytes every delay, giving up if
+// the request is cancelled.
+type slowHTTPDoer struct {
+	httpDoer
+	delay time.Duration
+	slow  func(req *http.Request) bool
+}
+
+func (s slowHTTPDoer) Do(req *http.Request) (*http.Response, error) {
+	res, err := s.httpDoer.Do(req)
+	if err == nil && s.slow(req) {
+		res.Body = slowBody{res.Body, req, s.delay}
+	}
+	return res, err
+}
+
+type slowBody struct {
+	io.ReadCloser
+	req   *http.Request
+	delay time.Duration
+}
+
+func (b slowBody) Read(p []byte) (int, error) {
+	select {
+	case <-b.req.Context().Done():
+		return 0, b.req.Context().Err()
+	case <-time.After(b.delay):
+	}
+	if len(p) > 16 {
+		p = p[:16]
+	}
+	return b.ReadCloser.Read(p)
+}
+
+func (suite *HTTPChunkStoreSuite) TestSlowGetPack() {
+	vs := types.NewValueStore(suite.serverCS)
+	le := types.NewList(vs).Edit()
+	for i := 0; i < 20; i++ {
+		le.Append(vs.WriteValue(types.String(strings.Repeat("x", i))))
+	}
+	want := vs.WriteValue(le.List())
+	vs.Commit(vs.Root(), vs.Root())
+	vs.Close()
+
+	isGetPack := func(req *http.Request) bool { return req.URL.Path == constants.GetPackPath }
+	flaky := suite.makeFlaky(0, func(req *http.Request) bool { return false })
+	suite.http.httpClient = slowHTTPDoer{flaky, time.Millisecond, isGetPack}
+	suite.http.opts.Timeout = 20 * time.Millisecond
+
+	// The whole response takes far longer than Timeout, but it never stalls for that long.
+	found := 0
+	start := time.Now()
+	suite.True(suite.http.getPack(want.TargetHash(), nil, func(c *chunks.Chunk) { found++ }))
+	suite.True(time.Since(start) > suite.http.opts.Timeout)
+	suite.Equal(21, found)
+	suite.Equal(1, flaky.requests[constants.GetPackPath])
+
+	// A response which stalls is given up on.
+	suite.http.httpClient = slowHTTPDoer{flaky, time.Second, isGetPack}
+	err := d.Try(func() { suite.http.getPack(want.TargetHash(), nil, func(c *chunks.Chunk) {}) })
+	suite.Equal(context.DeadlineExceeded, d.Unwrap(err))
+	suite.Equal(2, flaky.requests[constants.GetPackPath])
+}
@@ -0,0 +1,175 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// mergeStateDatasetID is the reserved Dataset holding the MergeState of an
+// unfinished merge. A Database has at most one merge in progress.
+const mergeStateDatasetID = reservedDatasetPrefix + "merge-state"
+
+const (
+	mergeStateStructName     = "MergeState"
+	mergeStateOutputField    = "output"
+	mergeStateOutputHead     = "outputHead"
+	mergeStateLeftField      = "left"
+	mergeStateRightField     = "right"
+	mergeStateBaseField      = "base"
+	mergeStateMergedField    = "merged"
+	mergeStateConflictsField = "conflicts"
+)
+
+// MergeState is a merge whose Conflicts remain to be resolved. It's kept in
+// the Database, so the merge can be continued by another process, or by
+// someone else.
+type MergeState struct {
+	// Output is the name of the Dataset the merge is to be committed to.
+	Output string
+	// OutputHead is the hash of the head Output had when the merge was
+	// started, which is empty if it had none. The merge is only committed
+	// if Output's head hasn't moved since.
+	OutputHead hash.Hash
+	// Left and Right are Refs to the Commits being merged, and Base is a Ref
+	// to their common ancestor.
+	Left, Right, Base types.Ref
+	// Merged is the Value merged so far. At the Path of each Conflict it
+	// has the Value from Base.
+	Merged    types.Value
+	Conflicts []merge.Conflict
+}
+
+// Conflict returns the unresolved Conflict at path, if there is one.
+func (st MergeState) Conflict(path types.Path) (merge.Conflict, bool) {
+	if i := st.conflictIndex(path); i >= 0 {
+		return st.Conflicts[i], true
+	}
+	return merge.Conflict{}, false
+}
+
+func (st MergeState) conflictIndex(path types.Path) int {
+	for i, c := range st.Conflicts {
+		if c.Path.String() == path.String() {
+			return i
+		}
+	}
+	return -1
+}
+
+// Resolve sets the Value at path in st.Merged to v, or removes it if v is
+// nil, and drops the Conflict at path. It's an error if there's no Conflict
+// at path.
+func (st *MergeState) Resolve(vrw types.ValueReadWriter, path types.Path, v types.Value) error {
+	i := st.conflictIndex(path)
+	if i < 0 {
+		return fmt.Errorf("No conflict at %s", describePath(path))
+	}
+	merged, err := merge.SetPath(vrw, st.Merged, path, v)
+	if err != nil {
+		return err
+	}
+	st.Merged = merged
+	st.Conflicts = append(st.Conflicts[:i:i], st.Conflicts[i+1:]...)
+	return nil
+}
+
+// SaveMergeState saves st in db, replacing any MergeState saved before.
+func SaveMergeState(db Database, st MergeState) error {
+	data := types.StructData{
+		mergeStateOutputField:    types.String(st.Output),
+		mergeStateLeftField:      st.Left,
+		mergeStateRightField:     st.Right,
+		mergeStateBaseField:      st.Base,
+		mergeStateMergedField:    st.Merged,
+		mergeStateConflictsField: merge.ConflictsToValue(db, st.Conflicts),
+	}
+	if !st.OutputHead.IsEmpty() {
+		data[mergeStateOutputHead] = types.String(st.OutputHead.String())
+	}
+	return db.commitReserved(mergeStateDatasetID, types.NewStruct(mergeStateStructName, data))
+}
+
+// LoadMergeState returns the MergeState saved in db, if there is one.
+func LoadMergeState(db Database) (st MergeState, ok bool, err error) {
+	v, ok := db.GetDataset(mergeStateDatasetID).MaybeHeadValue()
+	if !ok {
+		return
+	}
+	st, err = mergeStateFromValue(v)
+	return st, true, err
+}
+
+func mergeStateFromValue(v types.Value) (st MergeState, err error) {
+	s, isStruct := v.(types.Struct)
+	if !isStruct || s.Name() != mergeStateStructName {
+		return st, fmt.Errorf("%s isn't a %s", mergeStateDatasetID, mergeStateStructName)
+	}
+	output, _ := s.MaybeGet(mergeStateOutputField)
+	str, isStr := output.(types.String)
+	if !isStr {
+		return st, fmt.Errorf("%s has no %s", mergeStateStructName, mergeStateOutputField)
+	}
+	st.Output = string(str)
+	if v, ok := s.MaybeGet(mergeStateOutputHead); ok {
+		str, isStr := v.(types.String)
+		if st.OutputHead, ok = hash.MaybeParse(string(str)); !isStr || !ok {
+			return st, fmt.Errorf("%s has an invalid %s", mergeStateStructName, mergeStateOutputHead)
+		}
+	}
+	for name, r := range map[string]*types.Ref{mergeStateLeftField: &st.Left, mergeStateRightField: &st.Right, mergeStateBaseField: &st.Base} {
+		v, _ := s.MaybeGet(name)
+		var isRef bool
+		if *r, isRef = v.(types.Ref); !isRef {
+			return st, fmt.Errorf("%s has no %s", mergeStateStructName, name)
+		}
+	}
+	st.Merged, _ = s.MaybeGet(mergeStateMergedField)
+	conflicts, _ := s.MaybeGet(mergeStateConflictsField)
+	if conflicts == nil {
+		return st, fmt.Errorf("%s has no %s", mergeStateStructName, mergeStateConflictsField)
+	}
+	st.Conflicts, err = merge.ConflictsFromValue(conflicts)
+	return st, err
+}
+
+// CommitMerge makes a Commit of st.Merged, with st.Left and st.Right as its
+// parents, the head of st.Output, and removes the MergeState saved in db, in a
+// single update of db's root. Like SetHead(), the Commit replaces the head of
+// st.Output whether or not it descends from it. But if st.Output has been
+// committed to since the merge was started, so that its head isn't
+// st.OutputHead, CommitMerge returns an '*ErrHeadMoved' instead of dropping
+// those commits from its history. It returns 'ErrMergeNeeded' if the saved
+// MergeState changes before the update is made.
+func CommitMerge(db Database, st MergeState) (Dataset, error) {
+	if IsReservedDatasetID(st.Output) {
+		return Dataset{}, ErrReservedDataset
+	}
+	commit := NewCommit(st.Merged, types.NewSet(db, st.Left, st.Right), types.EmptyStruct)
+	err := db.Batch().
+		setHead(st.Output, commit, st.OutputHead).
+		deleteReserved(db.GetDataset(mergeStateDatasetID)).
+		Apply()
+	return db.GetDataset(st.Output), err
+}
+
+// ClearMergeState removes the MergeState saved in db, if there is one.
+func ClearMergeState(db Database) error {
+	if !db.GetDataset(mergeStateDatasetID).HasHead() {
+		return nil
+	}
+	return db.commitReserved(mergeStateDatasetID, nil)
+}
+
+func describePath(p types.Path) string {
+	if len(p) == 0 {
+		return "<root>"
+	}
+	return p.String()
+}
@@ -0,0 +1,146 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMergeState(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	_, ok, err := LoadMergeState(db)
+	assert.False(ok)
+	assert.NoError(err)
+
+	s := func(num, str int) types.Struct {
+		return types.NewStruct("", types.StructData{"num": types.Number(num), "str": types.Number(str)})
+	}
+	base, err := db.CommitValue(db.GetDataset("base"), s(1, 1))
+	assert.NoError(err)
+	left, err := db.Commit(db.GetDataset("left"), s(2, 2), CommitOptions{Parents: types.NewSet(db, base.HeadRef())})
+	assert.NoError(err)
+	right, err := db.Commit(db.GetDataset("right"), s(3, 3), CommitOptions{Parents: types.NewSet(db, base.HeadRef())})
+	assert.NoError(err)
+
+	merged, err := merge.ThreeWayCollect(left.HeadValue(), right.HeadValue(), base.HeadValue(), db, nil, nil)
+	assert.IsType(&merge.ErrMergeConflicts{}, err)
+	st := MergeState{
+		Output:    "out",
+		Left:      left.HeadRef(),
+		Right:     right.HeadRef(),
+		Base:      base.HeadRef(),
+		Merged:    merged,
+		Conflicts: err.(*merge.ErrMergeConflicts).Conflicts,
+	}
+	assert.NoError(SaveMergeState(db, st))
+
+	loaded, ok, err := LoadMergeState(db)
+	assert.True(ok)
+	assert.NoError(err)
+	assert.Equal("out", loaded.Output)
+	assert.True(st.Left.Equals(loaded.Left))
+	assert.True(st.Right.Equals(loaded.Right))
+	assert.True(st.Base.Equals(loaded.Base))
+	assert.True(merged.Equals(loaded.Merged))
+	assert.Len(loaded.Conflicts, 2)
+
+	num := types.MustParsePath(".num")
+	c, ok := loaded.Conflict(num)
+	assert.True(ok)
+	assert.True(types.Number(2).Equals(c.Ours))
+	assert.NoError(loaded.Resolve(db, num, c.Theirs))
+	assert.Error(loaded.Resolve(db, num, c.Ours))
+	assert.NoError(loaded.Resolve(db, types.MustParsePath(".str"), types.Number(4)))
+	assert.Empty(loaded.Conflicts)
+	assert.True(s(3, 4).Equals(loaded.Merged))
+	assert.Len(st.Conflicts, 2)
+
+	assert.NoError(SaveMergeState(db, loaded))
+	loaded, ok, err = LoadMergeState(db)
+	assert.True(ok)
+	assert.NoError(err)
+	assert.Empty(loaded.Conflicts)
+
+	assert.True(IsReservedDatasetID(mergeStateDatasetID))
+	_, err = db.CommitValue(db.GetDataset(mergeStateDatasetID), types.String("not a merge state"))
+	assert.Equal(ErrReservedDataset, err)
+
+	assert.NoError(ClearMergeState(db))
+	_, ok, _ = LoadMergeState(db)
+	assert.False(ok)
+	assert.NoError(ClearMergeState(db))
+}
+
+func TestRemoteMergeState(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(newHTTPChunkStoreForTest(storage.NewView()))
+	defer db.Close()
+
+	ds, err := db.CommitValue(db.GetDataset("ds"), types.Number(1))
+	assert.NoError(err)
+	assert.NoError(SaveMergeState(db, MergeState{Output: "ds", OutputHead: ds.HeadRef().TargetHash(), Left: ds.HeadRef(), Right: ds.HeadRef(), Base: ds.HeadRef(), Merged: types.Number(2)}))
+	st, ok, err := LoadMergeState(db)
+	assert.True(ok)
+	assert.NoError(err)
+	assert.Equal("ds", st.Output)
+	assert.Equal(ds.HeadRef().TargetHash(), st.OutputHead)
+
+	ds, err = CommitMerge(db, st)
+	assert.NoError(err)
+	assert.True(types.Number(2).Equals(ds.HeadValue()))
+	_, ok, _ = LoadMergeState(db)
+	assert.False(ok)
+	assert.NoError(ClearMergeState(db))
+}
+
+func TestCommitMerge(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	db := NewDatabase(storage.NewView())
+	defer db.Close()
+
+	left, err := db.CommitValue(db.GetDataset("left"), types.Number(1))
+	assert.NoError(err)
+	right, err := db.CommitValue(db.GetDataset("right"), types.Number(2))
+	assert.NoError(err)
+	st := MergeState{Output: "out", Left: left.HeadRef(), Right: right.HeadRef(), Base: left.HeadRef(), Merged: types.Number(3)}
+	assert.NoError(SaveMergeState(db, st))
+
+	// out was committed to while the merge was in progress.
+	moved, err := db.CommitValue(db.GetDataset("out"), types.Number(4))
+	assert.NoError(err)
+	_, err = CommitMerge(db, st)
+	assert.Equal(&ErrHeadMoved{DatasetID: "out", Current: moved.HeadRef().TargetHash()}, err)
+	assert.True(moved.HeadRef().Equals(db.GetDataset("out").HeadRef()))
+	_, ok, _ := LoadMergeState(db)
+	assert.True(ok)
+
+	st.OutputHead = moved.HeadRef().TargetHash()
+	assert.NoError(SaveMergeState(db, st))
+	out, err := CommitMerge(db, st)
+	assert.NoError(err)
+	assert.True(types.Number(3).Equals(out.HeadValue()))
+	assert.True(types.NewSet(db, left.HeadRef(), right.HeadRef()).Equals(out.Head().Get(ParentsField)))
+	_, ok, _ = LoadMergeState(db)
+	assert.False(ok)
+
+	// The merge can't be committed twice.
+	_, err = CommitMerge(db, st)
+	assert.IsType(&ErrHeadMoved{}, err)
+	assert.True(out.HeadRef().Equals(db.GetDataset("out").HeadRef()))
+
+	_, err = CommitMerge(db, MergeState{Output: mergeStateDatasetID})
+	assert.Equal(ErrReservedDataset, err)
+}
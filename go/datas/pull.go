@@ -21,67 +21,170 @@ type PullProgress struct {
 
 const bytesWrittenSampleRate = .10
 
-// Pull objects that descend from sourceRef from srcDB to sinkDB.
+// pullBatchSize is the most chunks Pull gets from the source at once. It's a variable so tests can make it small.
+var pullBatchSize = 1 << 12
+
+// packGetter is implemented by ChunkStores which can find every chunk
+// reachable from one hash, but not from others, in one go. See
+// httpChunkStore.getPack().
+type packGetter interface {
+	getPack(want hash.Hash, haves hash.HashSlice, found func(c *chunks.Chunk)) bool
+}
+
+// Pull objects that descend from sourceRef from srcDB to sinkDB. If srcDB is
+// remote, Pull gets every chunk sinkDB is missing in a single request, by
+// telling the server the heads of the Datasets in sinkDB.
 func Pull(srcDB, sinkDB Database, sourceRef types.Ref, progressCh chan PullProgress) {
+	pull(srcDB, sinkDB, sourceRef, progressCh, nil)
+}
+
+// PullWithCheckpoint is like Pull, but periodically persists the chunks
+// pulled so far in sinkDB, and records where the Pull got to in a checkpoint
+// at checkpointPath, which is a local file path. If a Pull of the same
+// sourceRef to checkpointPath was interrupted, it continues from the last
+// checkpoint. The checkpoint is removed once the Pull completes.
+// Until then, sinkDB has sourceRef but not everything it references, so an
+// interrupted PullWithCheckpoint must be finished by calling it again, rather
+// than Pull, which would take sinkDB to be up to date. Callers should choose
+// checkpointPath according to srcDB and sinkDB, since they aren't recorded in
+// the checkpoint. A remote sinkDB can't hold chunks whose descendants it
+// doesn't have, so PullWithCheckpoint panics if sinkDB is remote.
+func PullWithCheckpoint(srcDB, sinkDB Database, sourceRef types.Ref, progressCh chan PullProgress, checkpointPath string) {
+	if _, ok := sinkDB.chunkStore().(*httpChunkStore); ok {
+		d.Panic("Can't checkpoint a pull to a remote database")
+	}
+	cp := openPullCheckpoint(checkpointPath, sourceRef.TargetHash(), sinkDB.chunkStore())
+	pull(srcDB, sinkDB, sourceRef, progressCh, cp)
+	cp.remove()
+}
+
+func pull(srcDB, sinkDB Database, sourceRef types.Ref, progressCh chan PullProgress, cp *pullCheckpoint) {
 	// Sanity Check
 	d.PanicIfFalse(srcDB.chunkStore().Has(sourceRef.TargetHash()))
 
-	if sinkDB.chunkStore().Has(sourceRef.TargetHash()) {
+	// An interrupted Pull may have persisted the sourceRef chunk, but not all of its descendants.
+	resuming := cp != nil && cp.resumed
+	if !resuming && sinkDB.chunkStore().Has(sourceRef.TargetHash()) {
 		return // already up to date
 	}
 
-	var doneCount, knownCount, approxBytesWritten uint64
+	var progress PullProgress
 	updateProgress := func(moreDone, moreKnown, moreApproxBytesWritten uint64) {
-		if progressCh == nil {
-			return
+		progress.DoneCount += moreDone
+		progress.KnownCount += moreKnown
+		progress.ApproxWrittenBytes += moreApproxBytesWritten
+		if progressCh != nil {
+			progressCh <- progress
 		}
-		doneCount, knownCount, approxBytesWritten = doneCount+moreDone, knownCount+moreKnown, approxBytesWritten+moreApproxBytesWritten
-		progressCh <- PullProgress{doneCount, knownCount, approxBytesWritten}
 	}
 	var sampleSize, sampleCount uint64
+	approxBytesWritten := func(c *chunks.Chunk) uint64 {
+		// Randomly sample amount of data written
+		if rand.Float64() < bytesWrittenSampleRate {
+			sampleSize += uint64(len(snappy.Encode(nil, c.Data())))
+			sampleCount++
+		}
+		return sampleSize / uint64(math.Max(1, float64(sampleCount)))
+	}
 
+	// |absent| holds the hashes of the level being pulled which haven't been pulled yet. The children of the chunks pulled so far are gathered up in |uniqueOrdered|, an ordered, uniquified list of the next level.
 	absent := hash.HashSlice{sourceRef.TargetHash()}
-	for len(absent) != 0 {
+	nextLevel := hash.HashSet{}
+	uniqueOrdered := hash.HashSlice{}
+	if resuming {
+		absent, uniqueOrdered = cp.remaining, cp.next
+		nextLevel = uniqueOrdered.HashSet()
+		updateProgress(cp.progress.DoneCount, cp.progress.KnownCount, cp.progress.ApproxWrittenBytes)
+	} else {
 		updateProgress(0, uint64(len(absent)), 0)
 
-		// Concurrently pull all the chunks the sink is missing out of the source
-		neededChunks := map[hash.Hash]*chunks.Chunk{}
-		found := make(chan *chunks.Chunk)
-		go func() { defer close(found); srcDB.chunkStore().GetMany(absent.HashSet(), found) }()
-		for c := range found {
-			neededChunks[c.Hash()] = c
-
-			// Randomly sample amount of data written
-			if rand.Float64() < bytesWrittenSampleRate {
-				sampleSize += uint64(len(snappy.Encode(nil, c.Data())))
-				sampleCount++
+		// A checkpoint can't record the progress of a pack, which is fetched in one go.
+		if pg, ok := srcDB.chunkStore().(packGetter); ok && cp == nil {
+			haves := hash.HashSlice{}
+			sinkDB.Datasets().IterAll(func(k, v types.Value) {
+				haves = append(haves, v.(types.Ref).TargetHash())
+			})
+			// Until the pack is done, how many chunks are in it is unknown; there's at least one more than have been found.
+			first := true
+			if pg.getPack(sourceRef.TargetHash(), haves, func(c *chunks.Chunk) {
+				sinkDB.chunkStore().Put(*c)
+				moreKnown := uint64(1)
+				if first {
+					moreKnown, first = 0, false
+				}
+				updateProgress(1, moreKnown, approxBytesWritten(c))
+			}) {
+				persistChunks(sinkDB.chunkStore())
+				return
 			}
-			updateProgress(1, 0, sampleSize/uint64(math.Max(1, float64(sampleCount))))
 		}
+	}
 
-		// Now, put the absent chunks into the sink IN ORDER, meanwhile decoding each into a value so we can iterate all its refs.
-		// Descend to the next level of the tree by gathering up an ordered, uniquified list of all the children of the chunks in |absent|.
-		nextLevel := hash.HashSet{}
-		uniqueOrdered := hash.HashSlice{}
-		for _, h := range absent {
-			c := neededChunks[h]
-			sinkDB.chunkStore().Put(*c)
-			types.DecodeValue(*c, srcDB).WalkRefs(func(r types.Ref) {
-				if !nextLevel.Has(r.TargetHash()) {
-					uniqueOrdered = append(uniqueOrdered, r.TargetHash())
-					nextLevel.Insert(r.TargetHash())
-				}
-			})
+	// If the Pull fails, e.g. because the source does, checkpoint the start of the batch it got to, so that resuming pulls that batch again.
+	failedProgress, failedRemaining, failedNext := progress, absent, uniqueOrdered
+	if cp != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				cp.save(failedProgress, failedRemaining, failedNext)
+				panic(r)
+			}
+		}()
+	}
+
+	for {
+		for len(absent) != 0 {
+			failedProgress, failedRemaining, failedNext = progress, absent, uniqueOrdered
+			batch := absent
+			if len(batch) > pullBatchSize {
+				batch = batch[:pullBatchSize]
+			}
+			absent = absent[len(batch):]
+
+			// Concurrently pull all the chunks the sink is missing out of the source
+			neededChunks := map[hash.Hash]*chunks.Chunk{}
+			found := make(chan *chunks.Chunk)
+			var getFailed interface{}
+			go func() {
+				defer close(found)
+				defer func() { getFailed = recover() }()
+				srcDB.chunkStore().GetMany(batch.HashSet(), found)
+			}()
+			for c := range found {
+				neededChunks[c.Hash()] = c
+				updateProgress(1, 0, approxBytesWritten(c))
+			}
+			if getFailed != nil {
+				panic(getFailed)
+			}
+
+			// Now, put the absent chunks into the sink IN ORDER, meanwhile decoding each into a value so we can iterate all its refs.
+			for _, h := range batch {
+				c := neededChunks[h]
+				sinkDB.chunkStore().Put(*c)
+				types.DecodeValue(*c, srcDB).WalkRefs(func(r types.Ref) {
+					if !nextLevel.Has(r.TargetHash()) {
+						uniqueOrdered = append(uniqueOrdered, r.TargetHash())
+						nextLevel.Insert(r.TargetHash())
+					}
+				})
+			}
+			cp.maybeSave(progress, absent, uniqueOrdered)
 		}
 
-		// Ask sinkDB which of the next level's hashes it doesn't have.
+		if len(uniqueOrdered) == 0 {
+			break
+		}
+
+		// Descend to the next level of the tree, asking sinkDB which of the next level's hashes it doesn't have.
 		absentSet := sinkDB.chunkStore().HasMany(nextLevel)
-		absent = absent[:0]
+		absent = hash.HashSlice{}
 		for _, h := range uniqueOrdered {
 			if absentSet.Has(h) {
 				absent = append(absent, h)
 			}
 		}
+		nextLevel, uniqueOrdered = hash.HashSet{}, hash.HashSlice{}
+		updateProgress(0, uint64(len(absent)), 0)
 	}
 
 	persistChunks(sinkDB.chunkStore())
@@ -0,0 +1,152 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	pullCheckpointMagic    = "NOMSPULL2"
+	pullCheckpointInterval = 10 * time.Second
+)
+
+// pullCheckpoint records the progress of a Pull in a file at path. Each time
+// it's saved, the chunks pulled so far are persisted in the sink by
+// committing its ChunkStore, and the file is rewritten atomically with the
+// sourceRef being pulled, the PullProgress, and the frontier: the hashes
+// remaining in the level being pulled, and the hashes of the next level found
+// so far. It's saved at most every pullCheckpointInterval.
+//
+// Methods may be called on a nil *pullCheckpoint, and do nothing.
+type pullCheckpoint struct {
+	path      string
+	sourceRef hash.Hash
+	sink      chunks.ChunkStore
+
+	interval  time.Duration
+	lastSaved time.Time
+
+	// Restored from the checkpoint file, if resumed is true.
+	resumed         bool
+	progress        PullProgress
+	remaining, next hash.HashSlice
+}
+
+func openPullCheckpoint(path string, sourceRef hash.Hash, sink chunks.ChunkStore) *pullCheckpoint {
+	cp := &pullCheckpoint{path: path, sourceRef: sourceRef, sink: sink, interval: pullCheckpointInterval, lastSaved: time.Now()}
+	cp.resumed = cp.load()
+	if !cp.resumed {
+		cp.progress, cp.remaining, cp.next = PullProgress{}, nil, nil
+	}
+	return cp
+}
+
+// HasPullCheckpoint returns true if there's a checkpoint at checkpointPath
+// from which PullWithCheckpoint can resume pulling sourceRef.
+func HasPullCheckpoint(checkpointPath string, sourceRef types.Ref) bool {
+	cp := &pullCheckpoint{path: checkpointPath, sourceRef: sourceRef.TargetHash()}
+	return cp.load()
+}
+
+// load reads the checkpoint at cp.path, and returns false if there isn't
+// one, or if it's for a different sourceRef.
+func (cp *pullCheckpoint) load() bool {
+	f, err := os.Open(cp.path)
+	if os.IsNotExist(err) {
+		return false
+	}
+	d.PanicIfError(err)
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	magic := make([]byte, len(pullCheckpointMagic))
+	var sourceRef hash.Hash
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != pullCheckpointMagic {
+		return false
+	}
+	if _, err := io.ReadFull(r, sourceRef[:]); err != nil || sourceRef != cp.sourceRef {
+		return false
+	}
+
+	readHashes := func() (hs hash.HashSlice, err error) {
+		var l uint64
+		if err = binary.Read(r, binary.BigEndian, &l); err != nil {
+			return
+		}
+		hs = make(hash.HashSlice, l)
+		for i := range hs {
+			if _, err = io.ReadFull(r, hs[i][:]); err != nil {
+				return
+			}
+		}
+		return
+	}
+	for _, n := range []*uint64{&cp.progress.DoneCount, &cp.progress.KnownCount, &cp.progress.ApproxWrittenBytes} {
+		if err := binary.Read(r, binary.BigEndian, n); err != nil {
+			return false
+		}
+	}
+	if cp.remaining, err = readHashes(); err != nil {
+		return false
+	}
+	if cp.next, err = readHashes(); err != nil {
+		return false
+	}
+	return true
+}
+
+// maybeSave saves a checkpoint if it's been long enough since the last one.
+func (cp *pullCheckpoint) maybeSave(progress PullProgress, remaining, next hash.HashSlice) {
+	if cp == nil || time.Since(cp.lastSaved) < cp.interval {
+		return
+	}
+	cp.save(progress, remaining, next)
+}
+
+func (cp *pullCheckpoint) save(progress PullProgress, remaining, next hash.HashSlice) {
+	// The frontier may only be recorded once everything above it is persisted.
+	persistChunks(cp.sink)
+
+	tmp := cp.path + ".tmp"
+	f, err := os.Create(tmp)
+	d.PanicIfError(err)
+	w := bufio.NewWriter(f)
+	w.WriteString(pullCheckpointMagic)
+	w.Write(cp.sourceRef[:])
+	for _, n := range []uint64{progress.DoneCount, progress.KnownCount, progress.ApproxWrittenBytes} {
+		binary.Write(w, binary.BigEndian, n)
+	}
+	for _, hs := range []hash.HashSlice{remaining, next} {
+		binary.Write(w, binary.BigEndian, uint64(len(hs)))
+		for _, h := range hs {
+			w.Write(h[:])
+		}
+	}
+	d.PanicIfError(w.Flush())
+	d.PanicIfError(f.Sync())
+	d.PanicIfError(f.Close())
+	d.PanicIfError(os.Rename(tmp, cp.path))
+	cp.lastSaved = time.Now()
+}
+
+// remove deletes the checkpoint, once the Pull it records is complete.
+func (cp *pullCheckpoint) remove() {
+	if cp == nil {
+		return
+	}
+	if err := os.Remove(cp.path); !os.IsNotExist(err) {
+		d.PanicIfError(err)
+	}
+}
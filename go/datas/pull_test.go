@@ -5,10 +5,17 @@
 package datas
 
 import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -318,3 +325,142 @@ func buildListOfHeight(height int, vrw types.ValueReadWriter) types.List {
 	}
 	return l
 }
+
+type countingHTTPDoer struct {
+	httpDoer
+	requests map[string]int
+}
+
+func (c countingHTTPDoer) Do(req *http.Request) (*http.Response, error) {
+	c.requests[req.URL.Path]++
+	return c.httpDoer.Do(req)
+}
+
+func TestPullFromRemoteGetsOnePack(t *testing.T) {
+	assert := assert.New(t)
+	sinkCS, sourceCS := makeTestStoreViews()
+	hcs := newHTTPChunkStoreForTest(sourceCS)
+	counter := countingHTTPDoer{hcs.httpClient, map[string]int{}}
+	hcs.httpClient = counter
+	source, sink := NewDatabase(hcs), NewDatabase(sinkCS)
+	defer source.Close()
+	defer sink.Close()
+
+	sourceDS := source.GetDataset(datasetID)
+	sourceDS, err := source.CommitValue(sourceDS, buildListOfHeight(2, source))
+	assert.NoError(err)
+	Pull(source, sink, sourceDS.HeadRef(), nil)
+	_, err = sink.FastForward(sink.GetDataset(datasetID), sourceDS.HeadRef())
+	assert.NoError(err)
+
+	for i := 3; i < 6; i++ {
+		sourceDS, err = source.CommitValue(sourceDS, buildListOfHeight(i, source))
+		assert.NoError(err)
+	}
+	counter.requests[constants.GetPackPath], counter.requests[constants.GetRefsPath] = 0, 0
+	writes := sinkCS.Writes
+	Pull(source, sink, sourceDS.HeadRef(), nil)
+
+	assert.Equal(1, counter.requests[constants.GetPackPath])
+	assert.Zero(counter.requests[constants.GetRefsPath])
+	v := sink.ReadValue(sourceDS.HeadRef().TargetHash()).(types.Struct)
+	assert.True(sourceDS.HeadValue().Equals(v.Get(ValueField)))
+
+	// Only the chunks of the new commits were pulled.
+	newChunks := 0
+	walkMissingChunks(sourceCS, source, sourceDS.HeadRef(), nil, func(c chunks.Chunk) { newChunks++ })
+	assert.True(sinkCS.Writes-writes < newChunks)
+}
+
+type failingGetManyChunkStore struct {
+	chunks.ChunkStore
+	remaining int
+}
+
+func (cs *failingGetManyChunkStore) GetMany(hashes hash.HashSet, foundChunks chan *chunks.Chunk) {
+	if cs.remaining == 0 {
+		return // as if the source went away
+	}
+	cs.remaining--
+	cs.ChunkStore.GetMany(hashes, foundChunks)
+}
+
+func (suite *PullSuite) TestPullWithCheckpoint() {
+	defer func(size int) { pullBatchSize = size }(pullBatchSize)
+	pullBatchSize = 1
+
+	dir, err := ioutil.TempDir("", "pull-checkpoint")
+	suite.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	l := buildListOfHeight(4, suite.source)
+	sourceRef := suite.commitToSource(l, types.NewSet(suite.source))
+
+	if _, ok := suite.sink.chunkStore().(*httpChunkStore); ok {
+		suite.Panics(func() { PullWithCheckpoint(suite.source, suite.sink, sourceRef, nil, path) })
+		return
+	}
+
+	// Interrupt a pull after a few batches, having checkpointed after each.
+	interrupted := NewDatabase(&failingGetManyChunkStore{suite.sourceCS, 5})
+	cp := openPullCheckpoint(path, sourceRef.TargetHash(), suite.sink.chunkStore())
+	cp.interval = 0
+	suite.Panics(func() { pull(interrupted, suite.sink, sourceRef, nil, cp) })
+
+	pt := startProgressTracker()
+	PullWithCheckpoint(suite.source, suite.sink, sourceRef, pt.Ch, path)
+	close(pt.Ch)
+	progress := <-pt.doneCh
+
+	// The pull resumed from the checkpoint.
+	suite.Equal(uint64(5), progress[0].DoneCount)
+	last := progress[len(progress)-1]
+	suite.Equal(last.KnownCount, last.DoneCount)
+
+	v := suite.sink.ReadValue(sourceRef.TargetHash()).(types.Struct)
+	suite.True(l.Equals(v.Get(ValueField)))
+
+	_, err = os.Stat(path)
+	suite.True(os.IsNotExist(err))
+}
+
+func TestPullCheckpointSave(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "pull-checkpoint")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	c1, c2 := chunks.NewChunk([]byte("abc")), chunks.NewChunk([]byte("def"))
+	sourceRef := c1.Hash()
+	remaining, next := hash.HashSlice{c2.Hash()}, hash.HashSlice{c1.Hash(), c2.Hash()}
+	progress := PullProgress{1, 2, 3}
+
+	storage := &chunks.TestStorage{}
+	sink := storage.NewView()
+	cp := openPullCheckpoint(path, sourceRef, sink)
+	assert.False(cp.resumed)
+	sink.Put(c1)
+	cp.save(progress, remaining, next)
+	sink.Put(c2) // not checkpointed
+
+	// Saving persisted the chunks put in the sink so far.
+	sink = storage.NewView()
+	assert.True(sink.Has(c1.Hash()))
+	assert.False(sink.Has(c2.Hash()))
+
+	cp = openPullCheckpoint(path, sourceRef, sink)
+	assert.True(cp.resumed)
+	assert.Equal(progress, cp.progress)
+	assert.Equal(remaining, cp.remaining)
+	assert.Equal(next, cp.next)
+	cp.remove()
+
+	// A checkpoint of a different ref is ignored.
+	cp = openPullCheckpoint(path, sourceRef, sink)
+	cp.save(progress, remaining, next)
+	cp = openPullCheckpoint(path, c2.Hash(), sink)
+	assert.False(cp.resumed)
+	cp.remove()
+}
@@ -55,6 +55,15 @@ var (
 	// expects/honors, payload format, and responses.
 	HandleGetRefs = createHandler(handleGetRefs, true)
 
+	// HandleGetPack is meant to handle HTTP POST requests to the getPack/
+	// server endpoint. Given the hash of a wanted Chunk in the `want` query
+	// param, and a sequence of hashes of Chunks the client already has,
+	// usually the heads of its Datasets, the server returns every Chunk
+	// reachable from the wanted one but not from any it has, tallest first,
+	// in a single response. It returns 404 if it doesn't have the wanted
+	// Chunk.
+	HandleGetPack = createHandler(handleGetPack, true)
+
 	// HandleGetBlob is a custom endpoint whose sole purpose is to directly
 	// fetch the *bytes* contained in a Blob value. It expects a single query
 	// param of `h` to be the ref of the Blob.
@@ -79,7 +88,7 @@ var (
 	// Chunk.
 	// TODO: Nice comment about what headers it expects/honors, payload
 	// format, and error responses.
-	HandleRootPost = createHandler(handleRootPost, true)
+	HandleRootPost = NewHandleRootPost(nil)
 
 	// HandleBaseGet is meant to handle HTTP GET requests to the / server
 	// endpoint. This is used to give a friendly message to users.
@@ -286,6 +295,40 @@ func handleGetRefs(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 	}
 }
 
+func handleGetPack(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	if req.Method != "POST" {
+		d.Panic("Expected post method.")
+	}
+
+	want, ok := hash.MaybeParse(req.URL.Query().Get("want"))
+	if !ok {
+		d.Panic("Expected want param")
+	}
+	haves := extractHashes(req)
+
+	vs := types.NewValueStore(cs)
+	wantVal := vs.ReadValue(want)
+	if wantVal == nil {
+		http.Error(w, fmt.Sprintf("Error: %s not found", want), http.StatusNotFound)
+		return
+	}
+	// Haves which cs doesn't have can't be in common with want.
+	haveRefs := []types.Ref{}
+	for _, v := range vs.ReadManyValues(haves) {
+		if v != nil {
+			haveRefs = append(haveRefs, types.NewRef(v))
+		}
+	}
+
+	w.Header().Add("Content-Type", "application/octet-stream")
+	writer := respWriter(req, w)
+	defer writer.Close()
+
+	walkMissingChunks(cs, vs, types.NewRef(wantVal), haveRefs, func(c chunks.Chunk) {
+		chunks.Serialize(c, writer)
+	})
+}
+
 func handleGetBlob(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
 	refStr := req.URL.Query().Get("h")
 	if refStr == "" {
@@ -360,7 +403,16 @@ func handleRootGet(w http.ResponseWriter, req *http.Request, ps URLParams, rt ch
 	w.Header().Add("content-type", "text/plain")
 }
 
-func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+// NewHandleRootPost returns a handler like HandleRootPost, which calls hooks
+// with the Datasets changed by each update of the Root. If any of them fails,
+// the update is rejected with a 403 response.
+func NewHandleRootPost(hooks []CommitHook) Handler {
+	return createHandler(func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		handleRootPost(w, req, ps, cs, hooks)
+	}, true)
+}
+
+func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore, hooks []CommitHook) {
 	if req.Method != "POST" {
 		d.Panic("Expected post method.")
 	}
@@ -386,6 +438,19 @@ func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs c
 	proposedMap := validateProposed(proposed, last, vs)
 	if !proposedMap.Empty() {
 		assertMapOfStringToRefOfCommit(proposedMap, lastMap, vs)
+		assertSchemasSatisfied(proposedMap, lastMap, vs)
+	}
+	assertReservedUpdatesAllowed(proposedMap, lastMap, vs)
+	rejected := func(proposed, last types.Map) bool {
+		if err := runCommitHooks(hooks, proposed, last, vs); err != nil {
+			verbose.Log("Rejected root update: %s", err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return true
+		}
+		return false
+	}
+	if rejected(proposedMap, lastMap) {
+		return
 	}
 
 	// If some other client has committed to |vs| since it had |from| at the
@@ -409,12 +474,23 @@ func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs c
 		// traverse the Ref<Commit>s stored in the maps, though, just
 		// basically merge the maps together as long the changes to rootMap
 		// and proposedMap were in different Datasets.
+		// Values in proposedMap were chunked using the chunk config in lastMap, so if another client has recorded one since, the update can't be merged.
 		merged, err := mergeDatasetMaps(proposedMap, rootMap, lastMap, vs)
+		if err == nil && !sameHead(rootMap, lastMap, chunkConfigDatasetID) {
+			err = ErrChunkConfigMismatch
+		}
 		if err != nil {
 			verbose.Log("Attempted root map auto-merge failed: %s", err)
 			w.WriteHeader(http.StatusConflict)
 			break
 		}
+		// The schemas in rootMap may not accept the changes in proposedMap.
+		assertReservedUpdatesAllowed(merged, rootMap, vs)
+		assertSchemasSatisfied(merged, rootMap, vs)
+		// The hooks are run again with the heads which the merged update actually replaces.
+		if rejected(merged, rootMap) {
+			return
+		}
 		to, from = vs.WriteValue(merged).TargetHash(), root
 	}
 
@@ -426,6 +502,13 @@ func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs c
 	fmt.Fprintf(w, "%v", vs.Root().String())
 }
 
+// sameHead returns true if datasetID has the same head, or none, in a and b.
+func sameHead(a, b types.Map, datasetID string) bool {
+	ra, okA := a.MaybeGet(types.String(datasetID))
+	rb, okB := b.MaybeGet(types.String(datasetID))
+	return okA == okB && (!okA || ra.Equals(rb))
+}
+
 func validateLast(last hash.Hash, vrw types.ValueReadWriter) types.Map {
 	if last.IsEmpty() {
 		return types.NewMap(vrw)
@@ -576,11 +659,20 @@ func handleGraphQL(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 	var rootValue types.Value
 	var err error
 	if ds != "" {
-		dataset := db.GetDataset(ds)
-		var ok bool
-		rootValue, ok = dataset.MaybeHead()
-		if !ok {
-			err = fmt.Errorf("Dataset %s not found", ds)
+		// ds can be as of a time, e.g. 'ds@2017-01-01'.
+		var id string
+		var asOf time.Time
+		id, asOf, err = SplitAsOf(ds)
+		if err == nil {
+			dataset := db.GetDataset(id)
+			if !asOf.IsZero() {
+				dataset = dataset.AsOf(asOf)
+			}
+			var ok bool
+			rootValue, ok = dataset.MaybeHead()
+			if !ok {
+				err = fmt.Errorf("Dataset %s not found", ds)
+			}
 		}
 	} else {
 		rootValue = db.ReadValue(hash.Parse(h))
@@ -195,6 +195,52 @@ func TestHandleGetRefs(t *testing.T) {
 	}
 }
 
+func TestHandleGetPack(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	db := NewDatabase(storage.NewView())
+	ds, err := db.CommitValue(db.GetDataset("ds1"), types.NewList(db, types.String("a")))
+	assert.NoError(err)
+	have := ds.HeadRef()
+	ds, err = db.CommitValue(ds, types.NewList(db, types.String("a"), types.String("b")))
+	assert.NoError(err)
+	want := ds.HeadRef()
+	db.Close()
+
+	getPack := func(want hash.Hash, haves ...hash.Hash) *httptest.ResponseRecorder {
+		batch := chunks.ReadBatch{}
+		for _, h := range haves {
+			batch[h] = nil
+		}
+		w := httptest.NewRecorder()
+		HandleGetPack(
+			w,
+			newRequest("POST", "", "/?want="+want.String(), buildHashesRequest(batch), http.Header{
+				"Content-Type": {"application/octet-stream"},
+			}),
+			params{},
+			storage.NewView(),
+		)
+		return w
+	}
+
+	w := getPack(want.TargetHash(), have.TargetHash())
+	if assert.Equal(http.StatusOK, w.Code, "Handler error:\n%s", string(w.Body.Bytes())) {
+		chunkChan := make(chan *chunks.Chunk, 16)
+		go func() { defer close(chunkChan); chunks.Deserialize(w.Body, chunkChan) }()
+		found := hash.HashSlice{}
+		for c := range chunkChan {
+			found = append(found, c.Hash())
+		}
+		// The wanted commit comes first, and nothing of the one the client has is sent.
+		assert.Equal(want.TargetHash(), found[0])
+		assert.NotContains(found, have.TargetHash())
+	}
+
+	w = getPack(hash.Of([]byte("nothing")))
+	assert.Equal(http.StatusNotFound, w.Code)
+}
+
 func TestHandleGetBlob(t *testing.T) {
 	assert := assert.New(t)
 
@@ -428,3 +474,88 @@ type params map[string]string
 func (p params) ByName(k string) string {
 	return p[k]
 }
+
+func TestRejectPostRootSchemaViolation(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	cs := storage.NewView()
+	vs := types.NewValueStore(cs)
+	defer vs.Close()
+
+	schemas := types.NewMap(vs, types.String("dataset1"), types.NumberType)
+	head := types.NewMap(vs,
+		types.String("dataset1"), types.ToRefOfValue(vs.WriteValue(buildTestCommit(vs, types.Number(1)))),
+		types.String(schemaDatasetID), types.ToRefOfValue(vs.WriteValue(buildTestCommit(vs, schemas))),
+	)
+	headRef := vs.WriteValue(head)
+	assert.True(vs.Commit(headRef.TargetHash(), vs.Root()))
+
+	post := func(newHead types.Map) int {
+		newHeadRef := vs.WriteValue(newHead)
+		vs.Commit(vs.Root(), vs.Root())
+		w := httptest.NewRecorder()
+		HandleRootPost(w, newRequest("POST", "", buildPostRootURL(newHeadRef.TargetHash(), headRef.TargetHash()), nil, nil), params{}, storage.NewView())
+		return w.Code
+	}
+
+	// Committing a String to dataset1 violates its schema.
+	bad := head.Edit().Set(types.String("dataset1"), types.ToRefOfValue(vs.WriteValue(buildTestCommit(vs, types.String("nope"))))).Map()
+	assert.Equal(http.StatusBadRequest, post(bad))
+
+	// So does changing the schema out from under the current head.
+	schemas = schemas.Edit().Set(types.String("dataset1"), types.StringType).Map()
+	bad = head.Edit().Set(types.String(schemaDatasetID), types.ToRefOfValue(vs.WriteValue(buildTestCommit(vs, schemas)))).Map()
+	assert.Equal(http.StatusBadRequest, post(bad))
+
+	// Schemas are read from the current root, so they can't be replaced or removed along with the violating commit.
+	violation := types.ToRefOfValue(vs.WriteValue(buildTestCommit(vs, types.String("nope"))))
+	bad = bad.Edit().Set(types.String("dataset1"), violation).Map()
+	assert.Equal(http.StatusBadRequest, post(bad))
+	bad = head.Edit().Remove(types.String(schemaDatasetID)).Set(types.String("dataset1"), violation).Map()
+	assert.Equal(http.StatusBadRequest, post(bad))
+
+	// Schemas that aren't a Map<String, Type> are rejected.
+	bad = head.Edit().Set(types.String(schemaDatasetID), types.ToRefOfValue(vs.WriteValue(buildTestCommit(vs, types.String("nope"))))).Map()
+	assert.Equal(http.StatusBadRequest, post(bad))
+
+	good := head.Edit().Set(types.String("dataset1"), types.ToRefOfValue(vs.WriteValue(buildTestCommit(vs, types.Number(2))))).Map()
+	assert.Equal(http.StatusOK, post(good))
+}
+
+func TestRejectPostRootReservedDatasetUpdate(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	cs := storage.NewView()
+	vs := types.NewValueStore(cs)
+	defer vs.Close()
+
+	cfg := types.ChunkConfig{TargetSize: 1 << 16, Window: 64}
+	head := types.NewMap(vs, types.String("dataset1"), types.ToRefOfValue(vs.WriteValue(buildTestCommit(vs, types.Number(1)))))
+	headRef := vs.WriteValue(head)
+	assert.True(vs.Commit(headRef.TargetHash(), vs.Root()))
+
+	post := func(newHead types.Map) int {
+		newHeadRef := vs.WriteValue(newHead)
+		vs.Commit(vs.Root(), vs.Root())
+		w := httptest.NewRecorder()
+		HandleRootPost(w, newRequest("POST", "", buildPostRootURL(newHeadRef.TargetHash(), headRef.TargetHash()), nil, nil), params{}, storage.NewView())
+		return w.Code
+	}
+	withConfig := func(m types.Map, cfg types.ChunkConfig) types.Map {
+		return m.Edit().Set(types.String(chunkConfigDatasetID), types.ToRefOfValue(vs.WriteValue(buildTestCommit(vs, chunkConfigToStruct(cfg))))).Map()
+	}
+
+	// The database already has data, which was chunked with the default config.
+	assert.Equal(http.StatusBadRequest, post(withConfig(head, cfg)))
+	assert.Equal(http.StatusBadRequest, post(head.Edit().Set(types.String(reservedDatasetPrefix+"other"), head.Get(types.String("dataset1"))).Map()))
+	assert.Equal(http.StatusBadRequest, post(head.Edit().Set(types.String(mergeStateDatasetID), head.Get(types.String("dataset1"))).Map()))
+
+	head = withConfig(head, types.DefaultChunkConfig)
+	assert.Equal(http.StatusOK, post(head))
+	headRef = types.NewRef(head)
+
+	// Once recorded, the config can't be changed or deleted.
+	assert.Equal(http.StatusBadRequest, post(withConfig(head, cfg)))
+	assert.Equal(http.StatusBadRequest, post(head.Edit().Remove(types.String(chunkConfigDatasetID)).Map()))
+	assert.Equal(http.StatusBadRequest, post(types.NewMap(vs)))
+}
@@ -0,0 +1,167 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/verbose"
+	"github.com/julienschmidt/httprouter"
+)
+
+// Replica keeps a local ChunkStore a copy of a primary Database, by
+// repeatedly pulling the primary's root into it. Nothing else should write
+// to the local ChunkStore; a RemoteDatabaseServer serving it with Replica set
+// rejects writes.
+type Replica struct {
+	primaryName string
+	primary     Database
+	local       *database
+
+	mu     *sync.Mutex
+	status ReplicaStatus
+
+	stop, done chan struct{}
+}
+
+// ReplicaStatus describes how far behind its primary a Replica is.
+type ReplicaStatus struct {
+	// Root is the root the Replica has, and PrimaryRoot is the root the
+	// primary had when it was last checked, at Checked.
+	Root, PrimaryRoot hash.Hash
+	Checked           time.Time
+
+	// Synced is when the primary was last known to have Root. If the primary
+	// has been written to since, the Replica is at most Lag() behind.
+	Synced time.Time
+
+	// Err is why the last check failed, if it did.
+	Err error
+}
+
+// Lag returns how long it's been since the Replica was last known to be up
+// to date with its primary.
+func (rs ReplicaStatus) Lag() time.Duration {
+	if rs.Synced.IsZero() {
+		return 0
+	}
+	return time.Since(rs.Synced)
+}
+
+// NewReplica returns a Replica which copies primary, whose name is used in
+// messages, e.g. its URL, into local. It doesn't copy anything until Sync()
+// or Run() is called. Stop() closes primary, but not local.
+func NewReplica(primaryName string, primary Database, local chunks.ChunkStore) *Replica {
+	return &Replica{
+		primaryName: primaryName,
+		primary:     primary,
+		local:       newDatabase(local),
+		mu:          &sync.Mutex{},
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Sync pulls the primary's current root into the local ChunkStore, if it
+// doesn't have it already, and makes it the local root.
+func (r *Replica) Sync() error {
+	checked := time.Now()
+	var primaryRoot, root hash.Hash
+	err := d.Try(func() {
+		r.primary.Rebase()
+		r.local.Rebase()
+		primaryRoot, root = r.primary.chunkStore().Root(), r.local.rt.Root()
+		if primaryRoot == root {
+			return
+		}
+
+		if !primaryRoot.IsEmpty() {
+			verbose.Log("Replicating #%s from %s", primaryRoot, r.primaryName)
+			Pull(r.primary, r.local, types.NewRef(r.primary.ReadValue(primaryRoot)), nil)
+		}
+		if !r.local.rt.Commit(primaryRoot, root) {
+			d.Panic("Root of replica changed from #%s to #%s during replication", root, r.local.rt.Root())
+		}
+		root = primaryRoot
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status.Checked, r.status.Err = checked, d.Unwrap(err)
+	if err == nil {
+		r.status.Root, r.status.PrimaryRoot, r.status.Synced = root, primaryRoot, checked
+	}
+	return r.status.Err
+}
+
+// Status returns the status of the Replica as of the last Sync().
+func (r *Replica) Status() ReplicaStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// Run calls Sync() every interval until Stop() is called. Failures are
+// logged, and retried at the next interval.
+func (r *Replica) Run(interval time.Duration) {
+	defer close(r.done)
+	for {
+		if err := r.Sync(); err != nil {
+			log.Printf("Replicating %s failed: %v", r.primaryName, err)
+		}
+		select {
+		case <-r.stop:
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Stop waits for a call to Run() to return, and closes the primary.
+func (r *Replica) Stop() {
+	close(r.stop)
+	<-r.done
+	r.primary.Close()
+}
+
+func (r *Replica) rejectWrite(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	w.Header().Set(NomsVersionHeader, constants.NomsVersion)
+	http.Error(w, fmt.Sprintf("Error: this database is a read-only replica of %s; write to it instead", r.primaryName), http.StatusForbidden)
+}
+
+func (r *Replica) handleStatus(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+	status := r.Status()
+	res := struct {
+		Primary     string    `json:"primary"`
+		Root        string    `json:"root"`
+		PrimaryRoot string    `json:"primaryRoot"`
+		Checked     time.Time `json:"checked"`
+		Synced      time.Time `json:"synced"`
+		LagSeconds  float64   `json:"lagSeconds"`
+		Error       string    `json:"error,omitempty"`
+	}{
+		Primary:     r.primaryName,
+		Root:        status.Root.String(),
+		PrimaryRoot: status.PrimaryRoot.String(),
+		Checked:     status.Checked,
+		Synced:      status.Synced,
+		LagSeconds:  status.Lag().Seconds(),
+	}
+	if status.Err != nil {
+		res.Error = status.Err.Error()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	d.PanicIfError(json.NewEncoder(w).Encode(res))
+}
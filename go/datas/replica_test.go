@@ -0,0 +1,107 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/julienschmidt/httprouter"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaSync(t *testing.T) {
+	assert := assert.New(t)
+	primaryStorage, localStorage := &chunks.TestStorage{}, &chunks.TestStorage{}
+	primary := NewDatabase(primaryStorage.NewView())
+	defer primary.Close()
+	r := NewReplica("primary", NewDatabase(primaryStorage.NewView()), localStorage.NewView())
+	defer r.primary.Close()
+
+	// Nothing to replicate yet.
+	assert.NoError(r.Sync())
+	assert.Equal(hash.Hash{}, r.Status().Root)
+	assert.False(r.Status().Synced.IsZero())
+
+	ds, err := primary.CommitValue(primary.GetDataset("ds"), buildListOfHeight(3, primary))
+	assert.NoError(err)
+	assert.NoError(r.Sync())
+	status := r.Status()
+	assert.NoError(status.Err)
+	assert.Equal(primary.Datasets().Hash(), status.Root)
+	assert.Equal(status.Root, status.PrimaryRoot)
+
+	local := NewDatabase(localStorage.NewView())
+	defer local.Close()
+	assert.True(ds.HeadRef().Equals(local.GetDataset("ds").HeadRef()))
+
+	ds, err = primary.CommitValue(ds, types.String("next"))
+	assert.NoError(err)
+	assert.NoError(r.Sync())
+	assert.True(r.Status().Synced.After(status.Synced))
+	local.Rebase()
+	assert.True(types.String("next").Equals(local.GetDataset("ds").HeadValue()))
+}
+
+func newReplicaRouterForTest(r *Replica, cs chunks.ChunkStore) *httprouter.Router {
+	router := httprouter.New()
+	router.GET(constants.RootPath, func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		HandleRootGet(w, req, ps, cs)
+	})
+	router.POST(constants.RootPath, r.rejectWrite)
+	router.POST(constants.WriteValuePath, r.rejectWrite)
+	router.GET(constants.ReplicaPath, r.handleStatus)
+	return router
+}
+
+func TestReplicaRejectsWrites(t *testing.T) {
+	assert := assert.New(t)
+	primaryStorage, localStorage := &chunks.TestStorage{}, &chunks.TestStorage{}
+	r := NewReplica("http://primary:8000", NewDatabase(primaryStorage.NewView()), localStorage.NewView())
+	defer r.primary.Close()
+
+	hcs := newHTTPChunkStoreWithClient("http://localhost:9000", "", inlineServer{newReplicaRouterForTest(r, localStorage.NewView())})
+	defer hcs.Close()
+	hcs.Put(types.EncodeValue(types.String("abc")))
+	err := d.Try(func() { hcs.Commit(hash.Hash{}, hash.Hash{}) })
+	if resErr, ok := d.Unwrap(err).(*ErrHTTPResponse); assert.True(ok) {
+		assert.Equal(http.StatusForbidden, resErr.StatusCode)
+		assert.Contains(resErr.Body, "read-only replica of http://primary:8000")
+	}
+}
+
+func TestReplicaStatus(t *testing.T) {
+	assert := assert.New(t)
+	primaryStorage, localStorage := &chunks.TestStorage{}, &chunks.TestStorage{}
+	primary := NewDatabase(primaryStorage.NewView())
+	defer primary.Close()
+	_, err := primary.CommitValue(primary.GetDataset("ds"), types.String("abc"))
+	assert.NoError(err)
+
+	r := NewReplica("http://primary:8000", NewDatabase(primaryStorage.NewView()), localStorage.NewView())
+	defer r.primary.Close()
+	assert.NoError(r.Sync())
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", constants.ReplicaPath, nil)
+	newReplicaRouterForTest(r, localStorage.NewView()).ServeHTTP(w, req)
+	assert.Equal(http.StatusOK, w.Code)
+
+	var status map[string]interface{}
+	assert.NoError(json.NewDecoder(w.Body).Decode(&status))
+	assert.Equal("http://primary:8000", status["primary"])
+	assert.Equal(primary.Datasets().Hash().String(), status["root"])
+	assert.Equal(status["root"], status["primaryRoot"])
+	assert.NotContains(status, "error")
+	_, ok := status["lagSeconds"].(float64)
+	assert.True(ok)
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// schemaDatasetID is the reserved Dataset whose head value is a
+// Map<String, Type> from datasetID to the Type that values committed to that
+// Dataset must be a subtype of.
+const schemaDatasetID = reservedDatasetPrefix + "schemas"
+
+// ErrSchemaViolation is returned when a value that isn't a subtype of a
+// Dataset's schema is committed to it.
+type ErrSchemaViolation struct {
+	DatasetID string
+	Schema    *types.Type
+	Actual    *types.Type
+}
+
+func (e *ErrSchemaViolation) Error() string {
+	return fmt.Sprintf("Dataset %s requires values of type %s, but got a %s", e.DatasetID, e.Schema.Describe(), e.Actual.Describe())
+}
+
+var schemasType = types.MakeMapType(types.StringType, types.TypeType)
+
+// readSchemas returns the Map<String, Type> of Dataset schemas recorded in
+// datasets, which is empty if no Dataset has a schema. It's an error if the
+// head of the schemas Dataset isn't a Commit of a Map<String, Type>.
+func readSchemas(datasets types.Map, vrw types.ValueReadWriter) (types.Map, error) {
+	r, ok := datasets.MaybeGet(types.String(schemaDatasetID))
+	if !ok {
+		return types.NewMap(vrw), nil
+	}
+	if commit := r.(types.Ref).TargetValue(vrw); IsCommit(commit) {
+		if schemas, ok := commit.(types.Struct).Get(ValueField).(types.Map); ok && types.IsValueSubtypeOf(schemas, schemasType) {
+			return schemas, nil
+		}
+	}
+	return types.Map{}, fmt.Errorf("Malformed schemas: head of %s isn't a Commit of a %s", schemaDatasetID, schemasType.Describe())
+}
+
+// checkSchema returns an *ErrSchemaViolation if the schema of datasetID in
+// schemas doesn't accept v.
+func checkSchema(schemas types.Map, datasetID string, v types.Value) error {
+	t, ok := schemas.MaybeGet(types.String(datasetID))
+	if !ok {
+		return nil
+	}
+	if schema := t.(*types.Type); !types.IsValueSubtypeOf(v, schema) {
+		return &ErrSchemaViolation{datasetID, schema, types.TypeOf(v)}
+	}
+	return nil
+}
+
+func (db *database) DatasetSchema(datasetID string) (*types.Type, bool) {
+	schemas, err := readSchemas(db.root(), db)
+	if err != nil {
+		return nil, false
+	}
+	if t, ok := schemas.MaybeGet(types.String(datasetID)); ok {
+		return t.(*types.Type), true
+	}
+	return nil, false
+}
+
+func (db *database) SetDatasetSchema(ds Dataset, schema *types.Type) (Dataset, error) {
+	return db.doHeadUpdate(ds, func(ds Dataset) error { return db.doSetDatasetSchema(ds.ID(), schema) })
+}
+
+func (db *database) doSetDatasetSchema(datasetID string, schema *types.Type) error {
+	for {
+		currentRootHash, currentDatasets := db.rt.Root(), db.root()
+		schemas, err := readSchemas(currentDatasets, db)
+		if err != nil {
+			return err
+		}
+		if schema == nil {
+			schemas = schemas.Edit().Remove(types.String(datasetID)).Map()
+		} else {
+			schemas = schemas.Edit().Set(types.String(datasetID), schema).Map()
+			if r, ok := currentDatasets.MaybeGet(types.String(datasetID)); ok {
+				head := r.(types.Ref).TargetValue(db).(types.Struct)
+				if err := checkSchema(schemas, datasetID, head.Get(ValueField)); err != nil {
+					return err
+				}
+			}
+		}
+
+		currentDatasets = db.setReservedHead(currentDatasets, schemaDatasetID, schemas)
+		if err = db.tryCommitChunks(currentDatasets, currentRootHash); err != ErrOptimisticLockFailed {
+			return err
+		}
+	}
+}
+
+// assertSchemasSatisfied panics if the head of any Dataset which changed
+// between last and proposed isn't accepted by the schema it has in last, or
+// if the head of any Dataset whose schema changed isn't accepted by its new
+// schema. Schemas are read from last, so that an update can't get around them
+// by replacing them along with the Datasets they apply to.
+func assertSchemasSatisfied(proposed, last types.Map, vrw types.ValueReadWriter) {
+	lastSchemas, err := readSchemas(last, vrw)
+	d.PanicIfError(err)
+	schemas, err := readSchemas(proposed, vrw)
+	d.PanicIfError(err)
+	if lastSchemas.Empty() && schemas.Empty() {
+		return
+	}
+
+	check := func(schemas types.Map, datasetID types.String) {
+		r, ok := proposed.MaybeGet(datasetID)
+		if !ok {
+			return
+		}
+		head := r.(types.Ref).TargetValue(vrw).(types.Struct)
+		if err := checkSchema(schemas, string(datasetID), head.Get(ValueField)); err != nil {
+			d.Panic("%s", err)
+		}
+	}
+
+	schemas.IterAll(func(k, t types.Value) {
+		if lt, ok := lastSchemas.MaybeGet(k); !ok || !lt.Equals(t) {
+			check(schemas, k.(types.String))
+		}
+	})
+	diffMaps(proposed, last, func(change types.ValueChanged) {
+		if change.ChangeType != types.DiffChangeRemoved {
+			check(lastSchemas, change.Key.(types.String))
+		}
+	})
+}
+
+func diffMaps(m, last types.Map, cb func(change types.ValueChanged)) {
+	stopChan := make(chan struct{})
+	defer close(stopChan)
+	changes := make(chan types.ValueChanged)
+	go func() {
+		defer close(changes)
+		m.Diff(last, changes, stopChan)
+	}()
+	for change := range changes {
+		cb(change)
+	}
+}
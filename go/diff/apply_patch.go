@@ -25,9 +25,26 @@ import (
 // one is applied in order. When done in combination with the stack, this enables
 // all Differences that change a particular node to be applied to that node
 // before it gets assigned back to it's parent.
+//
+// Moves are applied as a removal from the OldPath and an addition at the Path.
 func Apply(root types.Value, patch Patch) types.Value {
+	v, err := apply(root, patch, false)
+	d.PanicIfError(err)
+	return v
+}
+
+// ApplyChecked applies patch to root like Apply, but returns an error rather
+// than applying a patch that wasn't made from root: one with a Difference
+// whose OldValue isn't the Value at its Path, or which adds a Value where
+// there already is one.
+func ApplyChecked(root types.Value, patch Patch) (types.Value, error) {
+	return apply(root, patch, true)
+}
+
+func apply(root types.Value, patch Patch, check bool) (types.Value, error) {
 	var lastPath types.Path
 	stack := patchStack{}
+	patch = splitMoves(patch)
 	sort.Sort(patch)
 
 	// Push the element on the stack that corresponds to the root
@@ -55,6 +72,12 @@ func Apply(root types.Value, patch Patch) types.Value {
 		}
 		lastPath = p
 
+		if check && len(p) == 0 {
+			if err := checkDifference(dif, nil, stack.vals[0].newestValue()); err != nil {
+				return nil, err
+			}
+		}
+
 		// if the stack has elements on it leftover from the last iteration. Pop
 		// those elements until the stack only has values in it that are
 		// referenced by this p. Popping an element on the stack, folds that
@@ -70,16 +93,24 @@ func Apply(root types.Value, patch Patch) types.Value {
 		for i, pp := range tail {
 			top := stack.top()
 			parent := top.newestValue()
-			oldValue := pp.Resolve(parent, nil)
+			if check && !canResolve(parent, pp) {
+				return nil, fmt.Errorf("Patch doesn't apply at %s: there's no %s to change at %s", describePath(p), describePathPart(pp), describePath(p[:idx+i]))
+			}
+			oldValue := stack.resolve(p[:idx+i], pp, parent)
 			var newValue types.Value
 			if i == len(tail)-1 { // last pathPart in this path
+				if check {
+					if err := checkDifference(dif, parent, oldValue); err != nil {
+						return nil, err
+					}
+				}
 				newValue = oldValue
 				oldValue = dif.OldValue
 			}
 			// Any intermediate elements on the stack will have a changeType
 			// of modified.  Leaf elements will be updated below to reflect the
 			// actual changeType.
-			stack.push(p, pp, types.DiffChangeModified, oldValue, newValue, dif.NewKeyValue)
+			stack.push(p[:idx+i+1], pp, types.DiffChangeModified, oldValue, newValue, dif.NewKeyValue)
 		}
 
 		// Update the top element in the stack with changeType from the dif and
@@ -95,7 +126,73 @@ func Apply(root types.Value, patch Patch) types.Value {
 	for stack.Len() > 0 {
 		newRoot = stack.pop()
 	}
-	return newRoot.newValue
+	return newRoot.newValue, nil
+}
+
+// canResolve returns true if parent is a Value that pp can index into.
+func canResolve(parent types.Value, pp types.PathPart) bool {
+	if parent == nil {
+		return false
+	}
+	switch k := parent.Kind(); pp.(type) {
+	case types.FieldPath:
+		return k == types.StructKind
+	case types.IndexPath:
+		return k == types.ListKind || k == types.MapKind || k == types.SetKind
+	case types.HashIndexPath:
+		return k == types.MapKind || k == types.SetKind
+	}
+	return false
+}
+
+// checkDifference returns an error if dif can't be applied to current, the
+// Value at its Path in parent.
+func checkDifference(dif Difference, parent, current types.Value) error {
+	if dif.ChangeType == types.DiffChangeAdded {
+		// Adding to a List inserts before whatever is at the index.
+		if current != nil && parent.Kind() != types.ListKind {
+			return fmt.Errorf("Patch doesn't apply at %s: it adds a value, but there already is one", describePath(dif.Path))
+		}
+		return nil
+	}
+	if current == nil {
+		return fmt.Errorf("Patch doesn't apply at %s: it changes a value, but there isn't one", describePath(dif.Path))
+	}
+	if dif.OldValue != nil && !current.Equals(dif.OldValue) {
+		return fmt.Errorf("Patch doesn't apply at %s: expected %s, found %s", describePath(dif.Path), types.EncodedValueMaxLines(dif.OldValue, 1), types.EncodedValueMaxLines(current, 1))
+	}
+	return nil
+}
+
+func describePath(p types.Path) string {
+	if len(p) == 0 {
+		return "<root>"
+	}
+	return p.String()
+}
+
+func describePathPart(pp types.PathPart) string {
+	if _, ok := pp.(types.FieldPath); ok {
+		return "struct"
+	}
+	return "collection"
+}
+
+// splitMoves returns patch with each move replaced by the removal and the
+// addition it's made of.
+func splitMoves(patch Patch) Patch {
+	split := make(Patch, 0, len(patch))
+	for _, dif := range patch {
+		if dif.ChangeType != DiffChangeMoved {
+			split = append(split, dif)
+			continue
+		}
+		split = append(split,
+			Difference{Path: dif.OldPath, ChangeType: types.DiffChangeRemoved, OldValue: dif.OldValue},
+			Difference{Path: dif.Path, ChangeType: types.DiffChangeAdded, NewValue: dif.NewValue},
+		)
+	}
+	return split
 }
 
 // updateNode handles the actual update of a node. It uses 'pp' to get the
@@ -229,10 +326,25 @@ func (se stackElem) newestValue() types.Value {
 }
 
 type patchStack struct {
-	vals     []stackElem
-	lastPath types.Path
-	addCnt   int
-	rmCnt    int
+	vals []stackElem
+	// adds & removes applied so far to each list, by its path
+	listCnts map[string]*listCnts
+}
+
+type listCnts struct {
+	addCnt int
+	rmCnt  int
+}
+
+func (stack *patchStack) cnts(listPath types.Path) *listCnts {
+	if stack.listCnts == nil {
+		stack.listCnts = map[string]*listCnts{}
+	}
+	key := listPath.String()
+	if stack.listCnts[key] == nil {
+		stack.listCnts[key] = &listCnts{}
+	}
+	return stack.listCnts[key]
 }
 
 func (stack *patchStack) push(p types.Path, pp types.PathPart, changeType types.DiffChangeType, oldValue, newValue, newKeyValue types.Value) {
@@ -256,6 +368,29 @@ func (stack *patchStack) pop() stackElem {
 	return *top
 }
 
+// resolve returns the Value at pp in parent, which is at parentPath. Like
+// updateNode(), it offsets indexes into lists by the adds & removes that have
+// already been applied to them.
+func (stack *patchStack) resolve(parentPath types.Path, pp types.PathPart, parent types.Value) types.Value {
+	if ip, ok := pp.(types.IndexPath); ok {
+		switch parent := parent.(type) {
+		case types.List:
+			cnts := stack.cnts(parentPath)
+			idx := int(ip.Index.(types.Number)) + cnts.addCnt - cnts.rmCnt
+			if idx >= 0 && uint64(idx) < parent.Len() {
+				return parent.Get(uint64(idx))
+			}
+		case types.Set:
+			// Unlike Path, the Value at a Set element's index is the element.
+			if parent.Has(ip.Index) {
+				return ip.Index
+			}
+			return nil
+		}
+	}
+	return pp.Resolve(parent, nil)
+}
+
 func (stack *patchStack) Len() int {
 	return len(stack.vals)
 }
@@ -263,35 +398,28 @@ func (stack *patchStack) Len() int {
 // adjustIndexOffset returns an offset that needs to be added to list indexes
 // when applying diffs to lists. Diffs are applied to lists beginning at the 0th
 // element. Changes to the list mean that subsequent changes to the same list
-// have to be adjusted accordingly. The stack keeps state for each list as it's
-// processed so updateNode() can get the correct index.
+// have to be adjusted accordingly. The stack keeps state for each list, by its
+// path, so updateNode() can get the correct index.
 // Whenever a list is encountered, diffs consist of add & remove operations. The
 // offset is calculated by keeping a count of each add & remove. Due to the way
 // way diffs are calculated, no offset is ever needed for 'add' operations. The
-// offset for 'remove' operations are calculated as:
-//   stack.addCnt - stack.rmCnt
+// offset for 'remove' and 'modify' operations are calculated as:
+//   addCnt - rmCnt
 func (stack *patchStack) adjustIndexOffset(p types.Path, changeType types.DiffChangeType) (res int) {
-	parentPath := p[:len(p)-1]
-
-	// parentPath is different than the last parentPath so reset counters
-	if stack.lastPath == nil || !stack.lastPath.Equals(parentPath) {
-		stack.lastPath = parentPath
-		stack.addCnt = 0
-		stack.rmCnt = 0
-	}
+	cnts := stack.cnts(p[:len(p)-1])
 
-	// offset for 'Add' operations are always 0, 'Remove' ops offset are
-	// calculated here
-	if changeType == types.DiffChangeRemoved {
-		res = stack.addCnt - stack.rmCnt
+	// offset for 'Add' operations are always 0, 'Remove' and 'Modify' ops
+	// offset are calculated here
+	if changeType != types.DiffChangeAdded {
+		res = cnts.addCnt - cnts.rmCnt
 	}
 
 	// Bump up the appropriate cnt for this operation.
 	switch changeType {
 	case types.DiffChangeAdded:
-		stack.addCnt += 1
+		cnts.addCnt += 1
 	case types.DiffChangeRemoved:
-		stack.rmCnt += 1
+		cnts.rmCnt += 1
 	}
 	return
 }
@@ -136,7 +136,8 @@ func getPatch(g1, g2 types.Value) Patch {
 
 func checkApplyPatch(assert *assert.Assertions, g1, expectedG2 types.Value, k1, k2 string) {
 	patch := getPatch(g1, expectedG2)
-	g2 := Apply(g1, patch)
+	g2, err := ApplyChecked(g1, patch)
+	assert.NoError(err, "k1: %s, k2: %s", k1, k2)
 	assert.True(expectedG2.Equals(g2), "failed to apply diffs for k1: %s and k2: %s", k1, k2)
 }
 
@@ -157,6 +158,31 @@ func TestPatches(t *testing.T) {
 	}
 }
 
+func TestApplyCheckedMismatch(t *testing.T) {
+	assert := assert.New(t)
+	vs := newTestValueStore()
+	defer vs.Close()
+
+	s := func(name string, n float64) types.Value {
+		return types.NewStruct("", types.StructData{"name": types.String(name), "n": types.Number(n)})
+	}
+	patch := getPatch(s("a", 1), s("a", 2))
+	_, err := ApplyChecked(s("a", 3), patch)
+	assert.EqualError(err, "Patch doesn't apply at .n: expected 1, found 3")
+	_, err = ApplyChecked(types.NewStruct("", types.StructData{"name": types.String("a")}), patch)
+	assert.EqualError(err, "Patch doesn't apply at .n: it changes a value, but there isn't one")
+	_, err = ApplyChecked(types.Number(1), patch)
+	assert.EqualError(err, "Patch doesn't apply at .n: there's no struct to change at <root>")
+
+	patch = getPatch(types.NewMap(vs), types.NewMap(vs, types.String("k"), types.Number(1)))
+	_, err = ApplyChecked(types.NewMap(vs, types.String("k"), types.Number(2)), patch)
+	assert.EqualError(err, "Patch doesn't apply at [\"k\"]: it adds a value, but there already is one")
+
+	patch = getPatch(types.Number(1), types.Number(2))
+	_, err = ApplyChecked(types.Number(3), patch)
+	assert.EqualError(err, "Patch doesn't apply at <root>: expected 1, found 3")
+}
+
 func TestNestedLists(t *testing.T) {
 	assert := assert.New(t)
 
@@ -302,6 +328,16 @@ func TestUpdateList(t *testing.T) {
 	a1 = []interface{}{"five", "ten", "fifteen"}
 	a2 = []interface{}{}
 	tryApplyDiff(a, a1, a2)
+
+	// insert at beginning, replace in the middle, remove at end
+	a1 = []interface{}{"one", "two", "three", "four"}
+	a2 = []interface{}{"four", "one", "2", "three"}
+	tryApplyDiff(a, a1, a2)
+
+	// replace nested value after an insertion
+	a1 = []interface{}{[]interface{}{"one"}, []interface{}{"two"}}
+	a2 = []interface{}{"zero", []interface{}{"one"}, []interface{}{"2"}}
+	tryApplyDiff(a, a1, a2)
 }
 
 func TestUpdateMap(t *testing.T) {
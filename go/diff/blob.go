@@ -0,0 +1,307 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	// blobScanSize is how many bytes are read at a time looking for line ends.
+	blobScanSize = 4096
+	// maxLineDiffBytes is the largest hunk whose lines are diffed.
+	maxLineDiffBytes = 1 << 20
+	// maxLineDiffMatrixSize is the largest number of pairs of old and new
+	// lines that are compared, beyond which all the lines are shown as changed.
+	// Their table of common subsequence lengths takes 4 bytes per pair.
+	maxLineDiffMatrixSize = 1 << 20
+)
+
+// blobSplices returns the Splices of bytes from b1 to b2.
+func blobSplices(b1, b2 types.Blob) []types.Splice {
+	spliceChan := make(chan types.Splice)
+	go func() {
+		b2.Diff(b1, spliceChan, nil)
+		close(spliceChan)
+	}()
+	splices := []types.Splice{}
+	for splice := range spliceChan {
+		splices = append(splices, splice)
+	}
+	return splices
+}
+
+// blobHunk is a range of bytes in an old Blob that was replaced by a range of
+// bytes in a new Blob.
+type blobHunk struct {
+	oldStart, oldEnd, newStart, newEnd uint64
+}
+
+func (h blobHunk) write(w io.Writer) error {
+	return write(w, []byte(fmt.Sprintf("    @@ -%d,%d +%d,%d @@\n", h.oldStart, h.oldEnd-h.oldStart, h.newStart, h.newEnd-h.newStart)))
+}
+
+// lineHunk is a blobHunk widened to whole lines, along with the blobHunks it
+// covers.
+type lineHunk struct {
+	blobHunk
+	byteHunks []blobHunk
+}
+
+// writeBlobDiff writes the ranges of bytes that changed from b1 to b2, as
+// `@@ -<offset>,<length> +<offset>,<length> @@`. If lines is true, the ranges
+// that are UTF-8 are widened to whole lines, and followed by a diff of those
+// lines.
+func writeBlobDiff(w io.Writer, b1, b2 types.Blob, lines bool) error {
+	splices := blobSplices(b1, b2)
+	hunks := make([]blobHunk, len(splices))
+	for i, sp := range splices {
+		hunks[i] = blobHunk{sp.SpAt, sp.SpAt + sp.SpRemoved, sp.SpFrom, sp.SpFrom + sp.SpAdded}
+	}
+	if !lines {
+		for _, h := range hunks {
+			if err := h.write(w); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	lhs, err := lineHunks(b1, b2, hunks)
+	if err != nil {
+		return err
+	}
+	for _, lh := range lhs {
+		if lh.oldEnd-lh.oldStart+lh.newEnd-lh.newStart <= maxLineDiffBytes {
+			old, err := readBlobRange(b1, lh.oldStart, lh.oldEnd)
+			if err != nil {
+				return err
+			}
+			neu, err := readBlobRange(b2, lh.newStart, lh.newEnd)
+			if err != nil {
+				return err
+			}
+			if utf8.Valid(old) && utf8.Valid(neu) {
+				err := lh.write(w)
+				if err == nil {
+					err = writeLineDiff(w, old, neu)
+				}
+				if err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		for _, h := range lh.byteHunks {
+			if err := h.write(w); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// lineHunks widens hunks, which are in ascending order, to start and end at
+// line boundaries, merging the ones that end up on the same lines. The bytes
+// between two hunks are the same in both Blobs, so they're only read from the
+// old one.
+func lineHunks(b1, b2 types.Blob, hunks []blobHunk) ([]lineHunk, error) {
+	merged := []lineHunk{}
+	for i, h := range hunks {
+		if n := len(merged); n > 0 && merged[n-1].oldEnd == h.oldStart {
+			merged[n-1].oldEnd, merged[n-1].newEnd = h.oldEnd, h.newEnd
+			merged[n-1].byteHunks = append(merged[n-1].byteHunks, h)
+		} else {
+			bound := uint64(0)
+			if n > 0 {
+				bound = merged[n-1].oldEnd
+			}
+			start, err := lineStart(b1, h.oldStart, bound)
+			if err != nil {
+				return nil, err
+			}
+			lead := h.oldStart - start
+			merged = append(merged, lineHunk{blobHunk{h.oldStart - lead, h.oldEnd, h.newStart - lead, h.newEnd}, []blobHunk{h}})
+		}
+
+		last := &merged[len(merged)-1]
+		oldEnds, err := endsLine(b1, last.oldStart, last.oldEnd)
+		if err != nil {
+			return nil, err
+		}
+		newEnds, err := endsLine(b2, last.newStart, last.newEnd)
+		if err != nil {
+			return nil, err
+		}
+		if oldEnds && newEnds {
+			continue
+		}
+		bound := b1.Len()
+		if i+1 < len(hunks) {
+			bound = hunks[i+1].oldStart
+		}
+		end, err := lineEnd(b1, last.oldEnd, bound)
+		if err != nil {
+			return nil, err
+		}
+		trail := end - last.oldEnd
+		last.oldEnd += trail
+		last.newEnd += trail
+	}
+	return merged, nil
+}
+
+// endsLine returns whether the bytes of b from start to end are whole lines.
+func endsLine(b types.Blob, start, end uint64) (bool, error) {
+	if start == end {
+		return true, nil
+	}
+	last, err := readBlobRange(b, end-1, end)
+	if err != nil {
+		return false, err
+	}
+	return last[0] == '\n', nil
+}
+
+// lineStart returns the offset just after the last '\n' in b before off, or
+// bound if there's none after bound.
+func lineStart(b types.Blob, off, bound uint64) (uint64, error) {
+	buf := make([]byte, blobScanSize)
+	for end := off; end > bound; {
+		start := bound
+		if end-start > blobScanSize {
+			start = end - blobScanSize
+		}
+		if err := readBlobAt(b, buf[:end-start], start); err != nil {
+			return 0, err
+		}
+		if i := bytes.LastIndexByte(buf[:end-start], '\n'); i >= 0 {
+			return start + uint64(i) + 1, nil
+		}
+		end = start
+	}
+	return bound, nil
+}
+
+// lineEnd returns the offset just after the first '\n' in b at or after off,
+// or bound if there's none before bound.
+func lineEnd(b types.Blob, off, bound uint64) (uint64, error) {
+	buf := make([]byte, blobScanSize)
+	for start := off; start < bound; {
+		end := bound
+		if end-start > blobScanSize {
+			end = start + blobScanSize
+		}
+		if err := readBlobAt(b, buf[:end-start], start); err != nil {
+			return 0, err
+		}
+		if i := bytes.IndexByte(buf[:end-start], '\n'); i >= 0 {
+			return start + uint64(i) + 1, nil
+		}
+		start = end
+	}
+	return bound, nil
+}
+
+// readBlobRange returns the bytes of b from start to end.
+func readBlobRange(b types.Blob, start, end uint64) ([]byte, error) {
+	buf := make([]byte, end-start)
+	if err := readBlobAt(b, buf, start); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// readBlobAt fills buf with the bytes of b from off, failing if b ends
+// before buf is full.
+func readBlobAt(b types.Blob, buf []byte, off uint64) error {
+	n, err := b.ReadAt(buf, int64(off))
+	// ReadAt returns io.EOF along with the last bytes of b.
+	if n == len(buf) {
+		return nil
+	}
+	if err == nil || err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// writeLineDiff writes the lines of old and neu, prefixed by '-' if they were
+// removed, '+' if they were added or ' ' if they're in both.
+func writeLineDiff(w io.Writer, old, neu []byte) error {
+	oldLines, newLines := splitLines(old), splitLines(neu)
+	writeLine := func(op byte, l []byte) error {
+		return write(w, append(append([]byte{' ', ' ', ' ', ' ', op}, bytes.TrimSuffix(l, []byte{'\n'})...), '\n'))
+	}
+
+	if len(oldLines)*len(newLines) > maxLineDiffMatrixSize {
+		for _, l := range oldLines {
+			if err := writeLine('-', l); err != nil {
+				return err
+			}
+		}
+		for _, l := range newLines {
+			if err := writeLine('+', l); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// lcs[i][j] is the length of the longest common subsequence of
+	// oldLines[i:] and newLines[j:].
+	lcs := make([][]int32, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int32, len(newLines)+1)
+	}
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if bytes.Equal(oldLines[i], newLines[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var err error
+	i, j := 0, 0
+	for err == nil && (i < len(oldLines) || j < len(newLines)) {
+		switch {
+		case i < len(oldLines) && j < len(newLines) && bytes.Equal(oldLines[i], newLines[j]):
+			err = writeLine(' ', oldLines[i])
+			i++
+			j++
+		case j == len(newLines) || (i < len(oldLines) && lcs[i+1][j] >= lcs[i][j+1]):
+			err = writeLine('-', oldLines[i])
+			i++
+		default:
+			err = writeLine('+', newLines[j])
+			j++
+		}
+	}
+	return err
+}
+
+// splitLines splits data after each '\n'.
+func splitLines(data []byte) [][]byte {
+	lines := [][]byte{}
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n') + 1
+		if i == 0 {
+			i = len(data)
+		}
+		lines = append(lines, data[:i])
+		data = data[i:]
+	}
+	return lines
+}
@@ -5,6 +5,7 @@
 package diff
 
 import (
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
@@ -27,8 +28,16 @@ type Difference struct {
 	// NewKeyValue is used for when elements are added to diffs with a
 	// non-primitive key. The new key must available when the map gets updated.
 	NewKeyValue types.Value
+	// OldPath is where a moved List element used to be. Path is where it
+	// moved to. It's only set if ChangeType is DiffChangeMoved.
+	OldPath types.Path
 }
 
+// DiffChangeMoved is the ChangeType of a Difference for a List element that
+// moved to another index, which is only reported with Options.DetectMoves.
+// It's not a change that the diffs in package types report.
+const DiffChangeMoved = types.DiffChangeModified + 1
+
 func (dif Difference) IsEmpty() bool {
 	return dif.Path == nil && dif.OldValue == nil && dif.NewValue == nil
 }
@@ -41,6 +50,32 @@ type differ struct {
 	stopChan chan struct{}
 	// Use LeftRight diff as opposed to TopDown
 	leftRight bool
+	// Report List elements that moved as DiffChangeMoved
+	detectMoves bool
+	// Only report Differences at Paths that pass these filters
+	include, exclude []types.PathPattern
+}
+
+// Options configures DiffWithOptions.
+type Options struct {
+	// LeftRight uses the left-right diff for ordered sequences, see Diff.
+	LeftRight bool
+	// DetectMoves reports an element that was removed from a List and added
+	// back at another index as a single Difference with ChangeType
+	// DiffChangeMoved. Elements are matched by hash, so finding moves needs
+	// the whole diff of each List in memory.
+	DetectMoves bool
+	// Include, if not empty, limits the Differences to those at or under a
+	// Path matched by one of its patterns. Differences above such a Path, e.g.
+	// a removed row when including `.rows[*].price`, are reported too, since
+	// they change what's at it.
+	Include []types.PathPattern
+	// Exclude drops the Differences at or under a Path matched by one of its
+	// patterns. Excluded subtrees aren't descended into.
+	Exclude []types.PathPattern
+	// BlobLines makes PrintDiff show the changed lines of Blobs that are
+	// UTF-8, rather than only the ranges of bytes that changed.
+	BlobLines bool
 }
 
 // Diff traverses two graphs simultaneously looking for differences. It returns
@@ -75,7 +110,12 @@ type differ struct {
 //        <some code>
 //    }
 func Diff(v1, v2 types.Value, dChan chan<- Difference, stopChan chan struct{}, leftRight bool) {
-	d := differ{diffChan: dChan, stopChan: stopChan, leftRight: leftRight}
+	DiffWithOptions(v1, v2, dChan, stopChan, Options{LeftRight: leftRight})
+}
+
+// DiffWithOptions is like Diff, but configured by opts.
+func DiffWithOptions(v1, v2 types.Value, dChan chan<- Difference, stopChan chan struct{}, opts Options) {
+	d := differ{diffChan: dChan, stopChan: stopChan, leftRight: opts.LeftRight, detectMoves: opts.DetectMoves, include: opts.Include, exclude: opts.Exclude}
 	if !v1.Equals(v2) {
 		if !shouldDescend(v1, v2) {
 			d.sendDiff(Difference{Path: nil, ChangeType: types.DiffChangeModified, OldValue: v1, NewValue: v2})
@@ -86,6 +126,9 @@ func Diff(v1, v2 types.Value, dChan chan<- Difference, stopChan chan struct{}, l
 }
 
 func (d differ) diff(p types.Path, v1, v2 types.Value) bool {
+	if d.filtered(p) {
+		return false
+	}
 	switch v1.Kind() {
 	case types.ListKind:
 		return d.diffLists(p, v1.(types.List), v2.(types.List))
@@ -101,6 +144,10 @@ func (d differ) diff(p types.Path, v1, v2 types.Value) bool {
 }
 
 func (d differ) diffLists(p types.Path, v1, v2 types.List) (stop bool) {
+	if d.detectMoves {
+		return d.diffListMoves(p, v1, v2)
+	}
+
 	spliceChan := make(chan types.Splice)
 	stopChan := make(chan struct{}, 1) // buffer size of 1s, so this won't block if diff already finished
 
@@ -123,7 +170,7 @@ func (d differ) diffLists(p types.Path, v1, v2 types.List) (stop bool) {
 					stop = d.diff(append(p, types.NewIndexPath(idx)), lastEl, newEl)
 				} else {
 					p1 := p.Append(types.NewIndexPath(types.Number(splice.SpAt + i)))
-					dif := Difference{p1, types.DiffChangeModified, v1.Get(splice.SpAt + i), v2.Get(splice.SpFrom + i), nil}
+					dif := Difference{Path: p1, ChangeType: types.DiffChangeModified, OldValue: v1.Get(splice.SpAt + i), NewValue: v2.Get(splice.SpFrom + i)}
 					stop = !d.sendDiff(dif)
 				}
 			}
@@ -152,6 +199,86 @@ func (d differ) diffLists(p types.Path, v1, v2 types.List) (stop bool) {
 	return
 }
 
+// diffListMoves is like diffLists, but pairs up elements that were removed
+// with equal elements that were added, and reports each pair as a move.
+// Elements that are removed at the same index where another is added are
+// still reported as modified, unless either of them moved.
+func (d differ) diffListMoves(p types.Path, v1, v2 types.List) (stop bool) {
+	splices := []types.Splice{}
+	spliceChan := make(chan types.Splice)
+	go func() {
+		v2.Diff(v1, spliceChan, nil)
+		close(spliceChan)
+	}()
+	for splice := range spliceChan {
+		splices = append(splices, splice)
+	}
+
+	removed := map[hash.Hash][]uint64{}
+	for _, splice := range splices {
+		for i := uint64(0); i < splice.SpRemoved; i++ {
+			h := v1.Get(splice.SpAt + i).Hash()
+			removed[h] = append(removed[h], splice.SpAt+i)
+		}
+	}
+	movedFrom := map[uint64]uint64{} // new index -> old index
+	movedAway := map[uint64]bool{}   // old index
+	for _, splice := range splices {
+		for i := uint64(0); i < splice.SpAdded; i++ {
+			h := v2.Get(splice.SpFrom + i).Hash()
+			if from := removed[h]; len(from) > 0 {
+				movedFrom[splice.SpFrom+i] = from[0]
+				movedAway[from[0]] = true
+				removed[h] = from[1:]
+			}
+		}
+	}
+
+	index := func(i uint64) types.Path {
+		return p.Append(types.NewIndexPath(types.Number(i)))
+	}
+	remove := func(at uint64) bool {
+		if movedAway[at] {
+			return true
+		}
+		return d.sendDiff(Difference{Path: index(at), ChangeType: types.DiffChangeRemoved, OldValue: v1.Get(at)})
+	}
+	add := func(at uint64) bool {
+		v := v2.Get(at)
+		if from, ok := movedFrom[at]; ok {
+			return d.sendDiff(Difference{Path: index(at), ChangeType: DiffChangeMoved, OldValue: v, NewValue: v, OldPath: index(from)})
+		}
+		return d.sendDiff(Difference{Path: index(at), ChangeType: types.DiffChangeAdded, NewValue: v})
+	}
+
+	for _, splice := range splices {
+		if splice.SpRemoved == splice.SpAdded {
+			for i := uint64(0); i < splice.SpRemoved && !stop; i++ {
+				at, from := splice.SpAt+i, splice.SpFrom+i
+				if _, ok := movedFrom[from]; ok || movedAway[at] {
+					stop = !remove(at) || !add(from)
+					continue
+				}
+				// Heuristic: what isn't moved is only modified.
+				lastEl, newEl := v1.Get(at), v2.Get(from)
+				if shouldDescend(lastEl, newEl) {
+					stop = d.diff(index(at), lastEl, newEl)
+				} else {
+					stop = !d.sendDiff(Difference{Path: index(at), ChangeType: types.DiffChangeModified, OldValue: lastEl, NewValue: newEl})
+				}
+			}
+			continue
+		}
+		for i := uint64(0); i < splice.SpRemoved && !stop; i++ {
+			stop = !remove(splice.SpAt + i)
+		}
+		for i := uint64(0); i < splice.SpAdded && !stop; i++ {
+			stop = !add(splice.SpFrom + i)
+		}
+	}
+	return
+}
+
 func (d differ) diffMaps(p types.Path, v1, v2 types.Map) bool {
 	return d.diffOrdered(p,
 		func(v types.Value) types.PathPart {
@@ -262,8 +389,26 @@ func shouldDescend(v1, v2 types.Value) bool {
 	return !types.IsPrimitiveKind(kind) && kind == v2.Kind() && kind != types.RefKind
 }
 
+// filtered returns true if Differences at p, and under it, aren't reported.
+func (d differ) filtered(p types.Path) bool {
+	for _, pp := range d.exclude {
+		if pp.MatchPrefix(p) {
+			return true
+		}
+	}
+	for _, pp := range d.include {
+		if pp.MatchPrefix(p) || pp.MatchUnder(p) {
+			return false
+		}
+	}
+	return len(d.include) > 0
+}
+
 // stopSent returns true if a message has been sent to this StopChannel
 func (d differ) sendDiff(dif Difference) bool {
+	if d.filtered(dif.Path) {
+		return true
+	}
 	select {
 	case <-d.stopChan:
 		return false
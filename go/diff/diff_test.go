@@ -7,6 +7,7 @@ package diff
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"strings"
 	"testing"
 
@@ -414,13 +415,89 @@ func TestNomsDiffPrintList(t *testing.T) {
 	tf(false)
 }
 
+func TestDiffListMoves(t *testing.T) {
+	assert := assert.New(t)
+
+	diffs := func(v1, v2 types.Value) []string {
+		dChan := make(chan Difference)
+		go func() {
+			DiffWithOptions(v1, v2, dChan, make(chan struct{}), Options{DetectMoves: true})
+			close(dChan)
+		}()
+		var res []string
+		patch := Patch{}
+		for d := range dChan {
+			if d.ChangeType == DiffChangeMoved {
+				res = append(res, fmt.Sprintf("%s->%s", d.OldPath, d.Path))
+			} else {
+				res = append(res, d.Path.String())
+			}
+			patch = append(patch, d)
+		}
+		patched, err := ApplyChecked(v1, patch)
+		assert.NoError(err)
+		assert.True(v2.Equals(patched))
+		return res
+	}
+
+	l1 := createList("a", "b", "c", "d", "e")
+	assert.Equal([]string{"[0]->[3]"}, diffs(l1, createList("b", "c", "d", "a", "e")))
+	assert.Equal([]string{"[1]->[0]"}, diffs(l1, createList("b", "a", "c", "d", "e")))
+	assert.Equal([]string{"[4]->[1]", "[2]"}, diffs(l1, createList("a", "e", "b", "d")))
+	assert.Equal([]string{"[1]"}, diffs(l1, createList("a", "x", "c", "d", "e")))
+	assert.Equal([]string{"[0]", "[3]"}, pathsFromDiff(l1, createList("b", "c", "d", "a", "e"), false))
+
+	l2 := createList(mm1, mm2, mm3, mm4)
+	assert.Equal([]string{"[3]->[0]", "[2]", "[3]"}, diffs(l2, createList(mm4, mm1, mm2, mm3x)))
+	assert.Equal([]string{"[1]->[0]", "[2]", "[2]"}, diffs(l2, createList(mm2, mm1, mm3x, mm4)))
+}
+
+func TestDiffFilters(t *testing.T) {
+	assert := assert.New(t)
+
+	diffs := func(v1, v2 types.Value, include, exclude []string) []string {
+		opts := Options{}
+		for _, s := range include {
+			opts.Include = append(opts.Include, types.MustParsePathPattern(s))
+		}
+		for _, s := range exclude {
+			opts.Exclude = append(opts.Exclude, types.MustParsePathPattern(s))
+		}
+		dChan := make(chan Difference)
+		go func() {
+			DiffWithOptions(v1, v2, dChan, make(chan struct{}), opts)
+			close(dChan)
+		}()
+		res := []string{}
+		for d := range dChan {
+			res = append(res, d.Path.String())
+		}
+		return res
+	}
+
+	row := func(name string, price int) types.Struct {
+		return createStruct("Row", "name", name, "price", price)
+	}
+	s1 := createStruct("S", "meta", createStruct("M", "date", 1), "rows", createList(row("a", 1), row("b", 2), row("c", 3)))
+	s2 := createStruct("S", "meta", createStruct("M", "date", 2), "rows", createList(row("a", 1), row("bb", 2), row("c", 4)))
+	s3 := createStruct("S", "meta", createStruct("M", "date", 2), "rows", createList(row("a", 1), row("b", 2)))
+
+	assert.Equal([]string{".meta.date", ".rows[1].name", ".rows[2].price"}, diffs(s1, s2, nil, nil))
+	assert.Equal([]string{".rows[1].name", ".rows[2].price"}, diffs(s1, s2, nil, []string{".meta"}))
+	assert.Equal([]string{".rows[2].price"}, diffs(s1, s2, []string{".rows[*].price"}, nil))
+	assert.Equal([]string{".rows[2].price"}, diffs(s1, s2, []string{".rows"}, []string{".rows[*].name"}))
+	assert.Equal([]string{}, diffs(s1, s2, []string{".rows[*].price"}, []string{".rows[2]"}))
+	assert.Equal([]string{".rows[2]"}, diffs(s1, s3, []string{".rows[*].price"}, nil))
+	assert.Equal([]string{}, diffs(s1, s3, []string{".owner"}, nil))
+}
+
 func TestNomsDiffPrintBlob(t *testing.T) {
 	assert := assert.New(t)
 
 	vs := newTestValueStore()
 	defer vs.Close()
 
-	expected := "-   Blob (2.0 kB)\n+   Blob (11 B)\n"
+	expected := "-   Blob (2.0 kB)\n+   Blob (11 B)\n    @@ -0,2048 +0,11 @@\n"
 	expectedPaths1 := []string{``}
 	b1 := types.NewBlob(vs, strings.NewReader(strings.Repeat("x", 2*1024)))
 	b2 := types.NewBlob(vs, strings.NewReader("Hello World"))
@@ -438,6 +515,80 @@ func TestNomsDiffPrintBlob(t *testing.T) {
 	tf(false)
 }
 
+func TestNomsDiffPrintBlobRanges(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := newTestValueStore()
+	defer vs.Close()
+
+	text := func(s string) types.Blob {
+		return types.NewBlob(vs, strings.NewReader(s))
+	}
+	print := func(v1, v2 types.Value, lines bool) string {
+		buf := &bytes.Buffer{}
+		assert.NoError(PrintDiffWithOptions(buf, v1, v2, Options{BlobLines: lines}))
+		return buf.String()
+	}
+
+	s1 := createStruct("S", "doc", text("one\ntwo\nthree\nfour\n"))
+	s2 := createStruct("S", "doc", text("one\n2\nthree\nfour\nfive"))
+	assert.Equal(`(root) {
+-   doc: Blob (19 B)
++   doc: Blob (21 B)
+    @@ -4,3 +4,1 @@
+    @@ -19,0 +17,4 @@
+  }
+`, print(s1, s2, false))
+	assert.Equal(`(root) {
+-   doc: Blob (19 B)
++   doc: Blob (21 B)
+    @@ -4,4 +4,2 @@
+    -two
+    +2
+    @@ -19,0 +17,4 @@
+    +five
+  }
+`, print(s1, s2, true))
+
+	assert.Equal(`-   Blob (9 B)
++   Blob (10 B)
+    @@ -0,3 +0,4 @@
+    -ab
+    +aXb
+    @@ -3,3 +4,3 @@
+    -cd
+    +cY
+`, print(text("ab\ncd\nef\n"), text("aXb\ncY\nef\n"), true))
+
+	buf := &bytes.Buffer{}
+	assert.NoError(writeLineDiff(buf, []byte("a\nb\nc"), []byte("a\nx\nc\nd\n")))
+	assert.Equal("     a\n    -b\n    -c\n    +x\n    +c\n    +d\n", buf.String())
+
+	bin := func(bs ...byte) types.Blob {
+		return types.NewBlob(vs, bytes.NewReader(bs))
+	}
+	assert.Equal("-   Blob (3 B)\n+   Blob (3 B)\n    @@ -1,1 +1,1 @@\n", print(bin(0, 0xff, '\n'), bin(0, 0xfe, '\n'), true))
+
+	// Reads past the end of a Blob fail instead of coming up short.
+	_, err := readBlobRange(bin('a', 'b'), 1, 3)
+	assert.Equal(io.ErrUnexpectedEOF, err)
+	last, err := readBlobRange(bin('a', 'b'), 1, 2)
+	assert.NoError(err)
+	assert.Equal([]byte("b"), last)
+
+	// Only the chunks that differ are compared.
+	data := make([]byte, 1<<20)
+	for i := range data {
+		data[i] = byte('a' + i%26)
+		if i%80 == 79 {
+			data[i] = '\n'
+		}
+	}
+	b1 := types.NewBlob(vs, bytes.NewReader(data))
+	b2 := b1.Edit().Splice(500000, 0, []byte("inserted\n")).Blob()
+	assert.Equal([]types.Splice{{SpAt: 500000, SpRemoved: 0, SpAdded: 9, SpFrom: 500000}}, blobSplices(b1, b2))
+}
+
 func TestNomsDiffPrintType(t *testing.T) {
 	assert := assert.New(t)
 
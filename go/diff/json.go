@@ -0,0 +1,432 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package diff
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// JSONFormat is a machine-readable format that Differences can be written in
+// with PrintJSON, and read back from with ReadJSON.
+//
+// Values in either format are JSON values. Bools, Numbers, Strings and Lists
+// are written as such, and Maps whose keys are all Strings as objects. Structs
+// are objects with their name in a "_name" field. Other Values are objects with
+// a single field: {"_map": [[key, value], ...]}, {"_set": [...]},
+// {"_ref": "<hash>"} and {"_blob": "<base64>"}. Types can't be written.
+type JSONFormat int
+
+const (
+	// JSONPatch is a JSON array of operations shaped like RFC 6902's, whose
+	// "op" is add, remove, replace or move. Their "path" and "from" are Noms
+	// Paths rather than JSON Pointers, and what was removed or replaced is in
+	// "oldValue".
+	JSONPatch JSONFormat = iota
+	// NDJSON is a JSON object per line, with the "path", "type" (added,
+	// removed, modified or moved), and "old" and "new" values of a
+	// Difference, and "from" for moves.
+	NDJSON
+)
+
+var jsonPatchOps = map[types.DiffChangeType]string{
+	types.DiffChangeAdded:    "add",
+	types.DiffChangeRemoved:  "remove",
+	types.DiffChangeModified: "replace",
+	DiffChangeMoved:          "move",
+}
+
+type jsonPatchOp struct {
+	Op       string      `json:"op"`
+	Path     string      `json:"path"`
+	From     string      `json:"from,omitempty"`
+	Key      interface{} `json:"key,omitempty"`
+	Value    interface{} `json:"value,omitempty"`
+	OldValue interface{} `json:"oldValue,omitempty"`
+}
+
+type ndjsonDifference struct {
+	Path string      `json:"path"`
+	Type string      `json:"type"`
+	From string      `json:"from,omitempty"`
+	Key  interface{} `json:"key,omitempty"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// PrintJSON writes the diff from v1 to v2 to w in format.
+func PrintJSON(w io.Writer, v1, v2 types.Value, format JSONFormat, opts Options) (err error) {
+	dChan := make(chan Difference, 16)
+	stopChan := make(chan struct{})
+	go func() {
+		DiffWithOptions(v1, v2, dChan, stopChan, opts)
+		close(dChan)
+	}()
+
+	start, sep, end := "[\n", ",\n", "\n]\n"
+	if format == NDJSON {
+		start, sep, end = "", "\n", "\n"
+	}
+	for dif := range dChan {
+		var o interface{}
+		var data []byte
+		if o, err = encodeJSONDifference(dif, format); err == nil {
+			data, err = json.Marshal(o)
+		}
+		if err == nil {
+			err = write(w, append([]byte(start), data...))
+			start = sep
+		}
+		if err != nil {
+			close(stopChan)
+			for range dChan {
+			}
+			return err
+		}
+	}
+	if start != sep {
+		// There were no Differences.
+		if format == JSONPatch {
+			return write(w, []byte("[]\n"))
+		}
+		return nil
+	}
+	return write(w, []byte(end))
+}
+
+func encodeJSONDifference(dif Difference, format JSONFormat) (interface{}, error) {
+	var key, ov, nv interface{}
+	var err error
+	// Only Map entries whose keys aren't in their Path need the key to be
+	// added.
+	if _, ok := lastPathPart(dif.Path).(types.HashIndexPath); ok && dif.NewKeyValue != nil {
+		if key, err = encodeJSONValue(dif.NewKeyValue); err != nil {
+			return nil, err
+		}
+	}
+	if dif.OldValue != nil {
+		if ov, err = encodeJSONValue(dif.OldValue); err != nil {
+			return nil, err
+		}
+	}
+	if dif.NewValue != nil {
+		if nv, err = encodeJSONValue(dif.NewValue); err != nil {
+			return nil, err
+		}
+	}
+	if format == JSONPatch {
+		op := jsonPatchOp{Op: jsonPatchOps[dif.ChangeType], Path: dif.Path.String(), From: dif.OldPath.String(), Key: key, Value: nv, OldValue: ov}
+		if dif.ChangeType == DiffChangeMoved {
+			op.OldValue = nil
+		}
+		return op, nil
+	}
+	return ndjsonDifference{Path: dif.Path.String(), Type: changeTypeNames[dif.ChangeType], From: dif.OldPath.String(), Key: key, Old: ov, New: nv}, nil
+}
+
+// ReadJSON reads a Patch written by PrintJSON in either format, creating its
+// Values in vrw.
+func ReadJSON(r io.Reader, vrw types.ValueReadWriter) (Patch, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		return readJSONPatch(bytes.NewReader(data), vrw)
+	}
+
+	patch := Patch{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var nd ndjsonDifference
+		if err := dec.Decode(&nd); err == io.EOF {
+			return patch, nil
+		} else if err != nil {
+			return nil, err
+		}
+		ct, ok := changeTypeFromName(changeTypeNames, nd.Type)
+		if !ok {
+			return nil, fmt.Errorf("Invalid difference type %q", nd.Type)
+		}
+		dif, err := decodeJSONDifference(vrw, ct, nd.Path, nd.From, nd.Key, nd.Old, nd.New)
+		if err != nil {
+			return nil, err
+		}
+		patch = append(patch, dif)
+	}
+}
+
+func readJSONPatch(r io.Reader, vrw types.ValueReadWriter) (Patch, error) {
+	ops := []jsonPatchOp{}
+	if err := json.NewDecoder(r).Decode(&ops); err != nil {
+		return nil, err
+	}
+	patch := make(Patch, 0, len(ops))
+	for _, op := range ops {
+		ct, ok := changeTypeFromName(jsonPatchOps, op.Op)
+		if !ok {
+			return nil, fmt.Errorf("Invalid patch op %q", op.Op)
+		}
+		old := op.OldValue
+		if ct == DiffChangeMoved {
+			old = op.Value
+		}
+		dif, err := decodeJSONDifference(vrw, ct, op.Path, op.From, op.Key, old, op.Value)
+		if err != nil {
+			return nil, err
+		}
+		patch = append(patch, dif)
+	}
+	return patch, nil
+}
+
+func changeTypeFromName(names map[types.DiffChangeType]string, name string) (types.DiffChangeType, bool) {
+	for ct, n := range names {
+		if n == name {
+			return ct, true
+		}
+	}
+	return 0, false
+}
+
+func decodeJSONDifference(vrw types.ValueReadWriter, ct types.DiffChangeType, path, from string, key, ov, nv interface{}) (dif Difference, err error) {
+	dif.ChangeType = ct
+	if dif.Path, err = parseJSONPath(path); err != nil {
+		return
+	}
+	if ct == DiffChangeMoved {
+		if dif.OldPath, err = parseJSONPath(from); err != nil {
+			return
+		}
+	}
+	if dif.NewKeyValue, err = decodeJSONValue(vrw, key); err != nil {
+		return
+	}
+	if dif.OldValue, err = decodeJSONValue(vrw, ov); err != nil {
+		return
+	}
+	dif.NewValue, err = decodeJSONValue(vrw, nv)
+	return
+}
+
+func parseJSONPath(s string) (types.Path, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return types.ParsePath(s)
+}
+
+func lastPathPart(p types.Path) types.PathPart {
+	if len(p) == 0 {
+		return nil
+	}
+	return p[len(p)-1]
+}
+
+func encodeJSONValue(v types.Value) (interface{}, error) {
+	switch v := v.(type) {
+	case types.Bool:
+		return bool(v), nil
+	case types.Number:
+		return float64(v), nil
+	case types.String:
+		return string(v), nil
+	case types.List:
+		return encodeJSONValues(func(cb func(v types.Value)) {
+			v.IterAll(func(v types.Value, _ uint64) { cb(v) })
+		})
+	case types.Set:
+		elems, err := encodeJSONValues(func(cb func(v types.Value)) {
+			v.IterAll(func(v types.Value) { cb(v) })
+		})
+		return map[string]interface{}{"_set": elems}, err
+	case types.Map:
+		return encodeJSONMap(v)
+	case types.Struct:
+		o := map[string]interface{}{"_name": v.Name()}
+		var err error
+		v.IterFields(func(name string, fv types.Value) {
+			if err == nil {
+				o[name], err = encodeJSONValue(fv)
+			}
+		})
+		return o, err
+	case types.Ref:
+		return map[string]interface{}{"_ref": v.TargetHash().String()}, nil
+	case types.Blob:
+		data, err := ioutil.ReadAll(v.Reader())
+		return map[string]interface{}{"_blob": base64.StdEncoding.EncodeToString(data)}, err
+	}
+	return nil, fmt.Errorf("Can't write %s as JSON", types.TypeOf(v).Describe())
+}
+
+func encodeJSONValues(iter func(cb func(v types.Value))) ([]interface{}, error) {
+	elems := []interface{}{}
+	var err error
+	iter(func(v types.Value) {
+		if err == nil {
+			var elem interface{}
+			elem, err = encodeJSONValue(v)
+			elems = append(elems, elem)
+		}
+	})
+	return elems, err
+}
+
+func encodeJSONMap(m types.Map) (interface{}, error) {
+	o := map[string]interface{}{}
+	entries := [][]interface{}{}
+	var err error
+	m.IterAll(func(k, v types.Value) {
+		if err != nil {
+			return
+		}
+		var jk, jv interface{}
+		if jk, err = encodeJSONValue(k); err != nil {
+			return
+		}
+		if jv, err = encodeJSONValue(v); err != nil {
+			return
+		}
+		entries = append(entries, []interface{}{jk, jv})
+		if s, ok := k.(types.String); ok && o != nil && !strings.HasPrefix(string(s), "_") {
+			o[string(s)] = jv
+		} else {
+			o = nil
+		}
+	})
+	if o == nil {
+		return map[string]interface{}{"_map": entries}, err
+	}
+	return o, err
+}
+
+func decodeJSONValue(vrw types.ValueReadWriter, o interface{}) (types.Value, error) {
+	switch o := o.(type) {
+	case nil:
+		return nil, nil
+	case bool:
+		return types.Bool(o), nil
+	case float64:
+		return types.Number(o), nil
+	case string:
+		return types.String(o), nil
+	case []interface{}:
+		elems, err := decodeJSONValues(vrw, o)
+		if err != nil {
+			return nil, err
+		}
+		return types.NewList(vrw, elems...), nil
+	case map[string]interface{}:
+		return decodeJSONObject(vrw, o)
+	}
+	return nil, fmt.Errorf("Invalid JSON value %v", o)
+}
+
+func decodeJSONValues(vrw types.ValueReadWriter, os []interface{}) ([]types.Value, error) {
+	vals := make([]types.Value, len(os))
+	for i, o := range os {
+		v, err := decodeJSONValue(vrw, o)
+		if err != nil {
+			return nil, err
+		}
+		if v == nil {
+			return nil, fmt.Errorf("Invalid JSON value null")
+		}
+		vals[i] = v
+	}
+	return vals, nil
+}
+
+func decodeJSONObject(vrw types.ValueReadWriter, o map[string]interface{}) (types.Value, error) {
+	if name, ok := o["_name"].(string); ok {
+		data := types.StructData{}
+		for k, fo := range o {
+			if k == "_name" {
+				continue
+			}
+			fv, err := decodeJSONValues(vrw, []interface{}{fo})
+			if err != nil {
+				return nil, err
+			}
+			data[k] = fv[0]
+		}
+		return types.NewStruct(name, data), nil
+	}
+
+	if len(o) == 1 {
+		switch {
+		case o["_set"] != nil:
+			if elems, ok := o["_set"].([]interface{}); ok {
+				vals, err := decodeJSONValues(vrw, elems)
+				if err != nil {
+					return nil, err
+				}
+				return types.NewSet(vrw, vals...), nil
+			}
+		case o["_map"] != nil:
+			if entries, ok := o["_map"].([]interface{}); ok {
+				return decodeJSONMapEntries(vrw, entries)
+			}
+		case o["_ref"] != nil:
+			if s, ok := o["_ref"].(string); ok {
+				h, ok := hash.MaybeParse(s)
+				if !ok {
+					return nil, fmt.Errorf("Invalid hash %s", s)
+				}
+				v := vrw.ReadValue(h)
+				if v == nil {
+					return nil, fmt.Errorf("Ref target #%s not found", s)
+				}
+				return types.NewRef(v), nil
+			}
+		case o["_blob"] != nil:
+			if s, ok := o["_blob"].(string); ok {
+				data, err := base64.StdEncoding.DecodeString(s)
+				if err != nil {
+					return nil, err
+				}
+				return types.NewBlob(vrw, bytes.NewReader(data)), nil
+			}
+		}
+	}
+
+	kvs := make([]types.Value, 0, len(o)*2)
+	for k, vo := range o {
+		if strings.HasPrefix(k, "_") {
+			return nil, fmt.Errorf("Invalid JSON object field %s", k)
+		}
+		v, err := decodeJSONValue(vrw, vo)
+		if err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, types.String(k), v)
+	}
+	return types.NewMap(vrw, kvs...), nil
+}
+
+func decodeJSONMapEntries(vrw types.ValueReadWriter, entries []interface{}) (types.Value, error) {
+	kvs := make([]types.Value, 0, len(entries)*2)
+	for _, e := range entries {
+		kv, ok := e.([]interface{})
+		if !ok || len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid Map entry %v", e)
+		}
+		vals, err := decodeJSONValues(vrw, kv)
+		if err != nil {
+			return nil, err
+		}
+		kvs = append(kvs, vals...)
+	}
+	return types.NewMap(vrw, kvs...), nil
+}
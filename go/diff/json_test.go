@@ -0,0 +1,86 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package diff
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := newTestValueStore()
+	defer vs.Close()
+
+	for _, format := range []JSONFormat{JSONPatch, NDJSON} {
+		for k1, g1 := range testValues(vs) {
+			for k2, g2 := range testValues(vs) {
+				if k1 == k2 {
+					continue
+				}
+				buf := &bytes.Buffer{}
+				if !assert.NoError(PrintJSON(buf, g1, g2, format, Options{DetectMoves: true})) {
+					continue
+				}
+				patch, err := ReadJSON(buf, vs)
+				if assert.NoError(err, "%s -> %s: %s", k1, k2, buf.String()) {
+					patched, err := ApplyChecked(g1, patch)
+					assert.NoError(err, "%s -> %s: %s", k1, k2, buf.String())
+					assert.True(g2.Equals(patched), "%s -> %s: %s", k1, k2, buf.String())
+				}
+			}
+		}
+	}
+}
+
+func TestJSONFormats(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := newTestValueStore()
+	defer vs.Close()
+
+	s1 := createStruct("S", "name", "a", "tags", createList("x", "y", "z"))
+	s2 := createStruct("S", "tags", createList("z", "x", "y"), "owner", createStruct("P", "id", 1))
+
+	buf := &bytes.Buffer{}
+	assert.NoError(PrintJSON(buf, s1, s2, JSONPatch, Options{DetectMoves: true}))
+	assert.Equal(`[
+{"op":"remove","path":".name","oldValue":"a"},
+{"op":"add","path":".owner","value":{"_name":"P","id":1}},
+{"op":"move","path":".tags[0]","from":".tags[2]","value":"z"}
+]
+`, buf.String())
+
+	buf = &bytes.Buffer{}
+	assert.NoError(PrintJSON(buf, s1, s2, NDJSON, Options{}))
+	assert.Equal(`{"path":".name","type":"removed","old":"a"}
+{"path":".owner","type":"added","new":{"_name":"P","id":1}}
+{"path":".tags[0]","type":"added","new":"z"}
+{"path":".tags[2]","type":"removed","old":"z"}
+`, buf.String())
+
+	patch, err := ReadJSON(strings.NewReader(`{"path": ".name", "type": "modified", "old": "a", "new": {"k": [1, {"_set": [true]}], "_": 1}}`), vs)
+	assert.Error(err)
+	patch, err = ReadJSON(strings.NewReader(`  {"path": ".name", "type": "modified", "old": "a", "new": {"k": [1, {"_set": [true]}]}}`), vs)
+	if assert.NoError(err) {
+		expected := createMap("k", createList(1, types.NewSet(vs, types.Bool(true))))
+		assert.True(expected.Equals(Apply(s1, patch).(types.Struct).Get("name")))
+	}
+
+	_, err = ReadJSON(strings.NewReader(`[{"op": "copy", "path": ".name"}]`), vs)
+	assert.Error(err)
+
+	buf = &bytes.Buffer{}
+	assert.NoError(PrintJSON(buf, s1, s1, JSONPatch, Options{}))
+	assert.Equal("[]\n", buf.String())
+	patch, err = ReadJSON(buf, vs)
+	assert.NoError(err)
+	assert.Empty(patch)
+}
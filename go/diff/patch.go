@@ -7,6 +7,7 @@ package diff
 import (
 	"bytes"
 
+	"github.com/attic-labs/noms/go/internal/changestruct"
 	"github.com/attic-labs/noms/go/types"
 )
 
@@ -23,7 +24,7 @@ func (r Patch) Len() int {
 	return len(r)
 }
 
-var vals = map[types.DiffChangeType]int{types.DiffChangeRemoved: 0, types.DiffChangeModified: 1, types.DiffChangeAdded: 2}
+var vals = map[types.DiffChangeType]int{types.DiffChangeRemoved: 0, types.DiffChangeModified: 1, types.DiffChangeAdded: 2, DiffChangeMoved: 2}
 
 func (r Patch) Less(i, j int) bool {
 	if r[i].Path.Equals(r[j].Path) {
@@ -32,6 +33,82 @@ func (r Patch) Less(i, j int) bool {
 	return pathIsLess(r[i].Path, r[j].Path)
 }
 
+const (
+	differenceStructName = "Difference"
+	differencePathField  = "path"
+	differenceTypeField  = "type"
+	differenceOldField   = "old"
+	differenceNewField   = "new"
+	differenceKeyField   = "key"
+	differenceFromField  = "from"
+)
+
+// changeTypeNames are the names of the DiffChangeTypes of Differences,
+// including moves.
+var changeTypeNames = func() map[types.DiffChangeType]string {
+	names := map[types.DiffChangeType]string{DiffChangeMoved: "moved"}
+	for ct, name := range changestruct.TypeNames {
+		names[ct] = name
+	}
+	return names
+}()
+
+// PatchToValue encodes patch as a Noms List of
+// Struct Difference {path: String, type: String, old?: Value, new?: Value,
+// key?: Value, from?: String}, so it can be committed, synced and applied to
+// another database. type is added, removed, modified or moved. key is the
+// NewKeyValue of Differences that have one, and from is the OldPath of moves.
+// Values that are nil are left out.
+func PatchToValue(vrw types.ValueReadWriter, patch Patch) types.List {
+	vals := make([]types.Value, len(patch))
+	for i, dif := range patch {
+		data := types.StructData{
+			differencePathField: types.String(dif.Path.String()),
+			differenceTypeField: types.String(changeTypeNames[dif.ChangeType]),
+			differenceOldField:  dif.OldValue,
+			differenceNewField:  dif.NewValue,
+			differenceKeyField:  dif.NewKeyValue,
+		}
+		if dif.ChangeType == DiffChangeMoved {
+			data[differenceFromField] = types.String(dif.OldPath.String())
+		}
+		vals[i] = changestruct.New(differenceStructName, data)
+	}
+	return types.NewList(vrw, vals...)
+}
+
+// PatchFromValue decodes a Patch encoded by PatchToValue().
+func PatchFromValue(v types.Value) (Patch, error) {
+	patch := Patch{}
+	err := changestruct.Iter(v, differenceStructName, func(s types.Struct) error {
+		dif, err := differenceFromStruct(s)
+		patch = append(patch, dif)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return patch, nil
+}
+
+func differenceFromStruct(s types.Struct) (dif Difference, err error) {
+	if dif.Path, err = changestruct.PathField(s, differencePathField); err != nil {
+		return
+	}
+	if dif.ChangeType, err = changestruct.TypeField(s, differenceTypeField, changeTypeNames); err != nil {
+		return
+	}
+	if dif.ChangeType == DiffChangeMoved {
+		if dif.OldPath, err = changestruct.PathField(s, differenceFromField); err != nil {
+			return
+		}
+	}
+	dif.OldValue, _ = s.MaybeGet(differenceOldField)
+	dif.NewValue, _ = s.MaybeGet(differenceNewField)
+	dif.NewKeyValue, _ = s.MaybeGet(differenceKeyField)
+	return
+}
+
 // Utility methods on path
 // TODO: Should these be on types.Path & types.PathPart?
 func pathIsLess(p1, p2 types.Path) bool {
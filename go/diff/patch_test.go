@@ -93,3 +93,44 @@ func TestPatchSort(t *testing.T) {
 	sort.Sort(shuffledPaths)
 	assert.Equal(sortedPaths, shuffledPaths)
 }
+
+func TestPatchValueRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := newTestValueStore()
+	defer vs.Close()
+
+	for k1, g1 := range testValues(vs) {
+		for k2, g2 := range testValues(vs) {
+			if k1 == k2 {
+				continue
+			}
+			dChan := make(chan Difference)
+			go func() {
+				DiffWithOptions(g1, g2, dChan, make(chan struct{}), Options{DetectMoves: true})
+				close(dChan)
+			}()
+			patch := Patch{}
+			for dif := range dChan {
+				patch = append(patch, dif)
+			}
+
+			// Read the patch back from another ValueStore, as if it had been synced there.
+			r := vs.WriteValue(PatchToValue(vs, patch))
+			vs.Commit(vs.Root(), vs.Root())
+			vs2 := types.NewValueStore(vs.ChunkStore())
+			decoded, err := PatchFromValue(vs2.ReadValue(r.TargetHash()))
+			if assert.NoError(err, "%s -> %s", k1, k2) {
+				assert.Equal(len(patch), len(decoded))
+				patched, err := ApplyChecked(g1, decoded)
+				assert.NoError(err, "%s -> %s", k1, k2)
+				assert.True(g2.Equals(patched), "%s -> %s", k1, k2)
+			}
+		}
+	}
+
+	_, err := PatchFromValue(types.Number(1))
+	assert.Error(err)
+	_, err = PatchFromValue(types.NewList(vs, types.NewStruct("Difference", types.StructData{"path": types.String(".a"), "type": types.String("changed")})))
+	assert.Error(err)
+}
@@ -27,12 +27,23 @@ type (
 // to |w|. If |leftRight| is true then the left-right diff is used for ordered
 // sequences - see Diff vs DiffLeftRight in Set and Map.
 func PrintDiff(w io.Writer, v1, v2 types.Value, leftRight bool) (err error) {
+	return PrintDiffWithOptions(w, v1, v2, Options{LeftRight: leftRight})
+}
+
+// PrintDiffWithOptions is like PrintDiff, but configured by opts.
+func PrintDiffWithOptions(w io.Writer, v1, v2 types.Value, opts Options) (err error) {
 	// In the case where the diff involves two simple values, just print out the
 	// diff and return. This is needed because the code below assumes that the
 	// values being compared have a parent.
 	if !shouldDescend(v1, v2) {
 		line(w, DEL, nil, v1)
-		return line(w, ADD, nil, v2)
+		err = line(w, ADD, nil, v2)
+		if b1, ok := v1.(types.Blob); ok && err == nil {
+			if b2, ok := v2.(types.Blob); ok {
+				err = writeBlobDiff(w, b1, b2, opts.BlobLines)
+			}
+		}
+		return
 	}
 
 	dChan := make(chan Difference, 16)
@@ -46,7 +57,7 @@ func PrintDiff(w io.Writer, v1, v2 types.Value, leftRight bool) (err error) {
 	// From here on, we can assume that every Difference will have at least one
 	// element in the Path
 	go func() {
-		Diff(v1, v2, dChan, stopChan, leftRight)
+		DiffWithOptions(v1, v2, dChan, stopChan, opts)
 		close(dChan)
 	}()
 
@@ -100,6 +111,11 @@ func PrintDiff(w io.Writer, v1, v2 types.Value, leftRight bool) (err error) {
 		if d.NewValue != nil {
 			err = pfunc(w, ADD, key, d.NewValue)
 		}
+		if b1, ok := d.OldValue.(types.Blob); ok && err == nil {
+			if b2, ok := d.NewValue.(types.Blob); ok {
+				err = writeBlobDiff(w, b1, b2, opts.BlobLines)
+			}
+		}
 		if err != nil {
 			stopDiff()
 			break
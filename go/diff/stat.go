@@ -0,0 +1,270 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	humanize "github.com/dustin/go-humanize"
+)
+
+// Stat summarizes the changes from one Value to another. Values are counted
+// by their fields if they're Structs, entries if they're Maps, bytes if they're
+// Blobs, elements if they're Lists or Sets, and as one Value otherwise.
+type Stat struct {
+	Path types.Path
+	// Adds, Removes and Changes count what was inserted, deleted and changed.
+	Adds, Removes, Changes uint64
+	// OldSize and NewSize count what there was before and after.
+	OldSize, NewSize uint64
+	// OldBytes and NewBytes estimate how much data changed, by adding up the
+	// size of the chunks that only the old or only the new Value has.
+	OldBytes, NewBytes uint64
+	// Children break down the Stat by Struct field or Map key, for the ones
+	// that changed.
+	Children []Stat
+
+	singular, plural string
+}
+
+// SummaryOptions configures PrintSummary.
+type SummaryOptions struct {
+	// Depth is how many levels of Struct fields and Map keys the summary is
+	// broken down by.
+	Depth int
+	// JSON writes the summary as JSON, rather than as text.
+	JSON bool
+}
+
+// Stats returns the Stat of the changes from v1 to v2, broken down by Struct
+// field and Map key to depth levels. Either of v1 and v2 can be nil. The
+// chunks of v1 are read from vr1, and those of v2 from vr2.
+func Stats(vr1, vr2 types.ValueReader, v1, v2 types.Value, depth int) Stat {
+	return stats(vr1, vr2, chunkCache{}, nil, v1, v2, depth)
+}
+
+func stats(vr1, vr2 types.ValueReader, cache chunkCache, p types.Path, v1, v2 types.Value, depth int) Stat {
+	s := Stat{Path: p}
+	switch {
+	case v1 == nil:
+		s.singular, s.plural = units(v2, v2)
+		s.Adds, s.NewSize = statSize(v2), statSize(v2)
+	case v2 == nil:
+		s.singular, s.plural = units(v1, v1)
+		s.Removes, s.OldSize = statSize(v1), statSize(v1)
+	default:
+		s.singular, s.plural = units(v1, v2)
+		ch := make(chan diffSummaryProgress)
+		go func() {
+			diffSummary(ch, v1, v2)
+			close(ch)
+		}()
+		for prog := range ch {
+			s.Adds += prog.Adds
+			s.Removes += prog.Removes
+			s.Changes += prog.Changes
+			s.NewSize += prog.NewSize
+			s.OldSize += prog.OldSize
+		}
+	}
+	s.OldBytes, s.NewBytes = chunkBytes(vr1, vr2, cache, v1, v2)
+
+	if depth == 0 || v1 == nil || v2 == nil || v1.Kind() != v2.Kind() {
+		return s
+	}
+	changeChan := make(chan types.ValueChanged)
+	var pathPart func(k types.Value) types.PathPart
+	switch v1.Kind() {
+	case types.StructKind:
+		pathPart = func(k types.Value) types.PathPart {
+			return types.NewFieldPath(string(k.(types.String)))
+		}
+		go func() {
+			v2.(types.Struct).Diff(v1.(types.Struct), changeChan, nil)
+			close(changeChan)
+		}()
+	case types.MapKind:
+		pathPart = func(k types.Value) types.PathPart {
+			if types.ValueCanBePathIndex(k) {
+				return types.NewIndexPath(k)
+			}
+			return types.NewHashIndexPath(k.Hash())
+		}
+		go func() {
+			v2.(types.Map).Diff(v1.(types.Map), changeChan, nil)
+			close(changeChan)
+		}()
+	default:
+		return s
+	}
+	for change := range changeChan {
+		cp := append(append(types.Path{}, p...), pathPart(change.Key))
+		s.Children = append(s.Children, stats(vr1, vr2, cache, cp, change.OldValue, change.NewValue, depth-1))
+	}
+	return s
+}
+
+// statSize counts v as Stat does.
+func statSize(v types.Value) uint64 {
+	switch v := v.(type) {
+	case types.Struct:
+		return uint64(types.TypeOf(v).Desc.(types.StructDesc).Len())
+	case types.Collection:
+		return v.Len()
+	}
+	return 1
+}
+
+// chunkCache holds the size and refs of the chunks chunkBytes has visited.
+// Stats shares one between the Values it breaks the changes down into, so
+// that a chunk which is under several of them is only read and encoded once.
+type chunkCache map[hash.Hash]chunkInfo
+
+type chunkInfo struct {
+	size uint64
+	refs []types.Ref
+}
+
+func newChunkInfo(v types.Value) chunkInfo {
+	info := chunkInfo{size: uint64(len(types.EncodeValue(v).Data()))}
+	v.WalkRefs(func(r types.Ref) {
+		info.refs = append(info.refs, r)
+	})
+	return info
+}
+
+// chunkBytes adds up the size of the encodings of v1 and v2, and of the chunks
+// that are reachable from only one of them. Chunks are visited from the
+// tallest down, so that a chunk that both can reach is found before either
+// side descends into it. It's an estimate, since a chunk that's shared by a
+// subtree of one side and a pruned subtree of the other is counted.
+func chunkBytes(vr1, vr2 types.ValueReader, cache chunkCache, v1, v2 types.Value) (oldBytes, newBytes uint64) {
+	if v1 != nil && v2 != nil && v1.Equals(v2) {
+		return
+	}
+	q1, q2 := &types.RefByHeight{}, &types.RefByHeight{}
+	enqueue := func(info chunkInfo, q *types.RefByHeight) uint64 {
+		for _, r := range info.refs {
+			q.PushBack(r)
+		}
+		sort.Sort(q)
+		return info.size
+	}
+	visit := func(r types.Ref, vr types.ValueReader, q *types.RefByHeight) uint64 {
+		info, ok := cache[r.TargetHash()]
+		if !ok {
+			info = newChunkInfo(r.TargetValue(vr))
+			cache[r.TargetHash()] = info
+		}
+		return enqueue(info, q)
+	}
+	if v1 != nil {
+		oldBytes = enqueue(newChunkInfo(v1), q1)
+	}
+	if v2 != nil {
+		newBytes = enqueue(newChunkInfo(v2), q2)
+	}
+
+	seen1, seen2 := hash.HashSet{}, hash.HashSet{}
+	for !q1.Empty() || !q2.Empty() {
+		ht := q1.MaxHeight()
+		if q2.MaxHeight() > ht {
+			ht = q2.MaxHeight()
+		}
+		refs1, refs2 := q1.PopRefsOfHeight(ht), q2.PopRefsOfHeight(ht)
+		hashes1, hashes2 := hash.HashSet{}, hash.HashSet{}
+		for _, r := range refs1 {
+			hashes1.Insert(r.TargetHash())
+		}
+		for _, r := range refs2 {
+			hashes2.Insert(r.TargetHash())
+		}
+		for _, r := range refs1 {
+			if h := r.TargetHash(); !hashes2.Has(h) && !seen1.Has(h) {
+				seen1.Insert(h)
+				oldBytes += visit(r, vr1, q1)
+			}
+		}
+		for _, r := range refs2 {
+			if h := r.TargetHash(); !hashes1.Has(h) && !seen2.Has(h) {
+				seen2.Insert(h)
+				newBytes += visit(r, vr2, q2)
+			}
+		}
+	}
+	return
+}
+
+// PrintSummary writes a summary of the diff between value1 and value2 to w,
+// broken down as configured by opts. If both are Commits, their values are
+// compared. The chunks of value1 are read from vr1, and those of value2 from
+// vr2.
+func PrintSummary(w io.Writer, vr1, vr2 types.ValueReader, value1, value2 types.Value, opts SummaryOptions) error {
+	isCommits := datas.IsCommit(value1) && datas.IsCommit(value2)
+	if isCommits {
+		value1 = value1.(types.Struct).Get(datas.ValueField)
+		value2 = value2.(types.Struct).Get(datas.ValueField)
+	}
+	s := Stats(vr1, vr2, value1, value2, opts.Depth)
+
+	if opts.JSON {
+		data, err := json.Marshal(s.toJSON())
+		if err == nil {
+			_, err = w.Write(append(data, '\n'))
+		}
+		return err
+	}
+
+	if isCommits {
+		if err := write(w, []byte("Comparing commit values\n")); err != nil {
+			return err
+		}
+	}
+	return s.write(w, 0)
+}
+
+func (s Stat) write(w io.Writer, indent int) error {
+	acc := diffSummaryProgress{Adds: s.Adds, Removes: s.Removes, Changes: s.Changes, OldSize: s.OldSize, NewSize: s.NewSize}
+	line := fmt.Sprintf("%s, about %s removed and %s added", formatSummary(acc, s.singular, s.plural), humanize.Bytes(s.OldBytes), humanize.Bytes(s.NewBytes))
+	if indent > 0 {
+		line = strings.Repeat("  ", indent) + s.Path.String() + ": " + line
+	}
+	if err := write(w, []byte(line+"\n")); err != nil {
+		return err
+	}
+	for _, c := range s.Children {
+		if err := c.write(w, indent+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonStat struct {
+	Path     string     `json:"path"`
+	Adds     uint64     `json:"adds"`
+	Removes  uint64     `json:"removes"`
+	Changes  uint64     `json:"changes"`
+	OldSize  uint64     `json:"oldSize"`
+	NewSize  uint64     `json:"newSize"`
+	OldBytes uint64     `json:"oldBytes"`
+	NewBytes uint64     `json:"newBytes"`
+	Children []jsonStat `json:"children,omitempty"`
+}
+
+func (s Stat) toJSON() jsonStat {
+	js := jsonStat{s.Path.String(), s.Adds, s.Removes, s.Changes, s.OldSize, s.NewSize, s.OldBytes, s.NewBytes, nil}
+	for _, c := range s.Children {
+		js.Children = append(js.Children, c.toJSON())
+	}
+	return js
+}
@@ -0,0 +1,92 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats(t *testing.T) {
+	assert := assert.New(t)
+
+	vs := newTestValueStore()
+	defer vs.Close()
+
+	kv := []types.Value{}
+	for i := 0; i < 5000; i++ {
+		kv = append(kv, types.Number(i), types.String(strings.Repeat("x", i%100)))
+	}
+	m1 := types.NewMap(vs, kv...)
+	m2 := m1.Edit().Set(types.Number(10), types.String("changed")).Set(types.Number(-1), types.Number(0)).Map()
+	vs.WriteValue(m1)
+	vs.WriteValue(m2)
+
+	s1 := createStruct("S", "name", "a", "rows", m1, "meta", createStruct("M", "date", 1))
+	s2 := createStruct("S", "rows", m2, "meta", createStruct("M", "date", 2, "by", "me"), "owner", createStruct("P", "id", 1))
+
+	s := Stats(vs, vs, s1, s2, 0)
+	assert.Equal(uint64(1), s.Adds)
+	assert.Equal(uint64(1), s.Removes)
+	assert.Equal(uint64(2), s.Changes)
+	assert.Equal(uint64(3), s.OldSize)
+	assert.Equal(uint64(3), s.NewSize)
+	assert.Empty(s.Children)
+
+	// Only the chunks of the Map that changed count, not all of it.
+	assert.True(s.OldBytes > 0 && s.NewBytes > 0)
+	_, mapBytes := chunkBytes(vs, vs, chunkCache{}, nil, m1)
+	assert.True(s.OldBytes*4 < mapBytes, "%d vs %d", s.OldBytes, mapBytes)
+
+	// Chunks are only read once, however deep the changes are broken down.
+	cache := chunkCache{}
+	oldBytes, newBytes := chunkBytes(vs, vs, cache, m1, m2)
+	assert.NotEmpty(cache)
+	cachedOld, cachedNew := chunkBytes(nil, nil, cache, m1, m2)
+	assert.Equal(oldBytes, cachedOld)
+	assert.Equal(newBytes, cachedNew)
+
+	s = Stats(vs, vs, s1, s2, 2)
+	paths := []string{}
+	var collect func(s Stat)
+	collect = func(s Stat) {
+		paths = append(paths, s.Path.String())
+		for _, c := range s.Children {
+			collect(c)
+		}
+	}
+	collect(s)
+	assert.Equal([]string{"", ".meta", ".meta.by", ".meta.date", ".name", ".owner", ".rows", ".rows[-1]", ".rows[10]"}, paths)
+
+	rows := s.Children[3]
+	assert.Equal(uint64(1), rows.Adds)
+	assert.Equal(uint64(1), rows.Changes)
+	assert.Equal(uint64(5000), rows.OldSize)
+	assert.Equal(uint64(5001), rows.NewSize)
+	owner := s.Children[2]
+	assert.Equal(uint64(1), owner.Adds)
+	assert.Equal(uint64(0), owner.OldBytes)
+
+	buf := &bytes.Buffer{}
+	assert.NoError(PrintSummary(buf, vs, vs, s1, s2, SummaryOptions{Depth: 1}))
+	lines := strings.Split(buf.String(), "\n")
+	assert.Len(lines, 6)
+	assert.True(strings.HasPrefix(lines[0], "1 insertion (33.33%), 1 deletion (33.33%), 2 changes (66.67%), (3 fields vs 3 fields), about "), lines[0])
+	assert.True(strings.HasPrefix(lines[3], "  .owner: 1 insertion (100.00%), 0 deletions (0.00%), 0 changes (0.00%), (0 fields vs 1 field), about 0 B removed and "), lines[3])
+
+	buf = &bytes.Buffer{}
+	assert.NoError(PrintSummary(buf, vs, vs, s1, s2, SummaryOptions{Depth: 1, JSON: true}))
+	var js map[string]interface{}
+	assert.NoError(json.Unmarshal(buf.Bytes(), &js))
+	assert.Equal("", js["path"])
+	assert.Equal(float64(2), js["changes"])
+	assert.Len(js["children"], 4)
+	assert.Equal(".meta", js["children"].([]interface{})[0].(map[string]interface{})["path"])
+}
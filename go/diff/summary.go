@@ -21,20 +21,7 @@ func Summary(value1, value2 types.Value) {
 		value2 = value2.(types.Struct).Get(datas.ValueField)
 	}
 
-	var singular, plural string
-	if value1.Kind() == value2.Kind() {
-		switch value1.Kind() {
-		case types.StructKind:
-			singular = "field"
-			plural = "fields"
-		case types.MapKind:
-			singular = "entry"
-			plural = "entries"
-		default:
-			singular = "value"
-			plural = "values"
-		}
-	}
+	singular, plural := units(value1, value2)
 
 	ch := make(chan diffSummaryProgress)
 	go func() {
@@ -57,13 +44,37 @@ func Summary(value1, value2 types.Value) {
 	status.Done()
 }
 
+// units returns the singular and plural names of what's counted when diffing
+// value1 and value2.
+func units(value1, value2 types.Value) (singular, plural string) {
+	if value1.Kind() == value2.Kind() {
+		switch value1.Kind() {
+		case types.StructKind:
+			singular = "field"
+			plural = "fields"
+		case types.MapKind:
+			singular = "entry"
+			plural = "entries"
+		case types.BlobKind:
+			singular = "byte"
+			plural = "bytes"
+		default:
+			singular = "value"
+			plural = "values"
+		}
+	}
+	return
+}
+
 type diffSummaryProgress struct {
 	Adds, Removes, Changes, NewSize, OldSize uint64
 }
 
 func diffSummary(ch chan diffSummaryProgress, v1, v2 types.Value) {
 	if !v1.Equals(v2) {
-		if shouldDescend(v1, v2) {
+		if v1.Kind() == types.BlobKind && v2.Kind() == types.BlobKind {
+			diffSummaryBlob(ch, v1.(types.Blob), v2.(types.Blob))
+		} else if shouldDescend(v1, v2) {
 			switch v1.Kind() {
 			case types.ListKind:
 				diffSummaryList(ch, v1.(types.List), v2.(types.List))
@@ -102,6 +113,18 @@ func diffSummaryList(ch chan<- diffSummaryProgress, v1, v2 types.List) {
 	}
 }
 
+// diffSummaryBlob counts the bytes that were inserted, deleted and changed.
+func diffSummaryBlob(ch chan<- diffSummaryProgress, v1, v2 types.Blob) {
+	ch <- diffSummaryProgress{OldSize: v1.Len(), NewSize: v2.Len()}
+	for _, splice := range blobSplices(v1, v2) {
+		changes := splice.SpRemoved
+		if splice.SpAdded < changes {
+			changes = splice.SpAdded
+		}
+		ch <- diffSummaryProgress{Adds: splice.SpAdded - changes, Removes: splice.SpRemoved - changes, Changes: changes}
+	}
+}
+
 func diffSummaryMap(ch chan<- diffSummaryProgress, v1, v2 types.Map) {
 	diffSummaryValueChanged(ch, v1.Len(), v2.Len(), func(changeChan chan<- types.ValueChanged, stopChan <-chan struct{}) {
 		v2.Diff(v1, changeChan, stopChan)
@@ -152,6 +175,10 @@ func reportChanges(ch chan<- diffSummaryProgress, changeChan chan types.ValueCha
 }
 
 func formatStatus(acc diffSummaryProgress, singular, plural string) {
+	status.Printf("%s", formatSummary(acc, singular, plural))
+}
+
+func formatSummary(acc diffSummaryProgress, singular, plural string) string {
 	pluralize := func(singular, plural string, n uint64) string {
 		var noun string
 		if n != 1 {
@@ -169,5 +196,10 @@ func formatStatus(acc diffSummaryProgress, singular, plural string) {
 	oldValues := pluralize(singular, plural, acc.OldSize)
 	newValues := pluralize(singular, plural, acc.NewSize)
 
-	status.Printf("%s (%.2f%%), %s (%.2f%%), %s (%.2f%%), (%s vs %s)", insertions, (float64(100*acc.Adds) / float64(acc.OldSize)), deletions, (float64(100*acc.Removes) / float64(acc.OldSize)), changes, (float64(100*acc.Changes) / float64(acc.OldSize)), oldValues, newValues)
+	// Something added to nothing is all new.
+	total := acc.OldSize
+	if total == 0 {
+		total = acc.NewSize
+	}
+	return fmt.Sprintf("%s (%.2f%%), %s (%.2f%%), %s (%.2f%%), (%s vs %s)", insertions, (float64(100*acc.Adds) / float64(total)), deletions, (float64(100*acc.Removes) / float64(total)), changes, (float64(100*acc.Changes) / float64(total)), oldValues, newValues)
 }
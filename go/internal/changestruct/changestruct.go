@@ -0,0 +1,80 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package changestruct encodes changes to a Value, such as the Differences of
+// a Patch or the Conflicts of a merge, as a List of Structs that each
+// describe the change at a Path. It's shared by packages diff and merge.
+package changestruct
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// TypeNames are the names DiffChangeTypes are encoded as.
+var TypeNames = map[types.DiffChangeType]string{
+	types.DiffChangeAdded:    "added",
+	types.DiffChangeRemoved:  "removed",
+	types.DiffChangeModified: "modified",
+}
+
+// New returns a Struct named name with the fields in data, leaving out those
+// that are nil, e.g. the old Value of an addition.
+func New(name string, data types.StructData) types.Struct {
+	fields := make(types.StructData, len(data))
+	for field, v := range data {
+		if v != nil {
+			fields[field] = v
+		}
+	}
+	return types.NewStruct(name, fields)
+}
+
+// Iter calls cb with each element of v, which must be a List of Structs named
+// name, stopping at the first error.
+func Iter(v types.Value, name string, cb func(s types.Struct) error) (err error) {
+	l, ok := v.(types.List)
+	if !ok {
+		return fmt.Errorf("%ss must be a List, not %s", name, types.TypeOf(v).Describe())
+	}
+	l.IterAll(func(v types.Value, _ uint64) {
+		if err != nil {
+			return
+		}
+		s, ok := v.(types.Struct)
+		if !ok || s.Name() != name {
+			err = fmt.Errorf("Not a %s: %s", name, types.EncodedValue(v))
+			return
+		}
+		err = cb(s)
+	})
+	return
+}
+
+// PathField returns the Path encoded in field of s by Path.String().
+func PathField(s types.Struct, field string) (types.Path, error) {
+	v, _ := s.MaybeGet(field)
+	str, ok := v.(types.String)
+	if !ok {
+		return nil, fmt.Errorf("%s has no %s", s.Name(), field)
+	}
+	// The empty Path, of a change to the Value itself, doesn't parse.
+	if str == "" {
+		return nil, nil
+	}
+	return types.ParsePath(string(str))
+}
+
+// TypeField returns the DiffChangeType whose name in names is field of s.
+func TypeField(s types.Struct, field string, names map[types.DiffChangeType]string) (types.DiffChangeType, error) {
+	if v, ok := s.MaybeGet(field); ok {
+		for ct, name := range names {
+			if types.String(name).Equals(v) {
+				return ct, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("%s has no valid %s", s.Name(), field)
+}
@@ -0,0 +1,52 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package changestruct
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeStructs(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.TestStorage{}
+	vs := types.NewValueStore(storage.NewView())
+
+	data := types.StructData{"path": types.String(".foo"), "type": types.String("added"), "old": nil, "new": types.Number(1)}
+	s := New("Change", data)
+	_, ok := s.MaybeGet("old")
+	assert.False(ok)
+	_, ok = s.MaybeGet("new")
+	assert.True(ok)
+	// The caller's data is left alone.
+	assert.Len(data, 4)
+
+	p, err := PathField(s, "path")
+	assert.NoError(err)
+	assert.Equal(".foo", p.String())
+	ct, err := TypeField(s, "type", TypeNames)
+	assert.NoError(err)
+	assert.Equal(types.DiffChangeAdded, ct)
+
+	empty, err := PathField(types.NewStruct("Change", types.StructData{"path": types.String("")}), "path")
+	assert.NoError(err)
+	assert.Empty(empty)
+	_, err = PathField(s, "from")
+	assert.EqualError(err, "Change has no from")
+	_, err = TypeField(types.NewStruct("Change", types.StructData{"type": types.String("moved")}), "type", TypeNames)
+	assert.EqualError(err, "Change has no valid type")
+
+	n := 0
+	assert.NoError(Iter(types.NewList(vs, s, s), "Change", func(types.Struct) error {
+		n++
+		return nil
+	}))
+	assert.Equal(2, n)
+	assert.EqualError(Iter(types.Number(1), "Change", nil), "Changes must be a List, not Number")
+	assert.Error(Iter(types.NewList(vs, s, types.Number(1)), "Change", func(types.Struct) error { return nil }))
+}
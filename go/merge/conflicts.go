@@ -0,0 +1,167 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/attic-labs/noms/go/internal/changestruct"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Conflict describes changes to the same Path in both merge candidates that
+// couldn't be merged. Base, Ours and Theirs are the Values at Path in the
+// common ancestor, in the Value being committed (a) and in the current HEAD
+// (b); each is nil if there's no Value there. OursChange and TheirsChange are
+// how Ours and Theirs changed Base.
+type Conflict struct {
+	Path               types.Path
+	Base, Ours, Theirs types.Value
+	OursChange         types.DiffChangeType
+	TheirsChange       types.DiffChangeType
+}
+
+// ErrMergeConflicts is returned by ThreeWayCollect() if any Paths conflict.
+type ErrMergeConflicts struct {
+	Conflicts []Conflict
+}
+
+func (e *ErrMergeConflicts) Error() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%d merge conflicts:", len(e.Conflicts))
+	for _, c := range e.Conflicts {
+		fmt.Fprintf(buf, "\n%s", c.Path)
+	}
+	return buf.String()
+}
+
+// errConflictCollected is returned internally, instead of an
+// '*ErrMergeConflict', once a conflict has been recorded by a collecting
+// merger. The conflicting Value is then left as it is in the parent.
+var errConflictCollected = errors.New("merge conflict collected")
+
+// NewThreeWayCollect creates a new Policy based on ThreeWayCollect using the
+// provided ResolveFunc.
+func NewThreeWayCollect(resolve ResolveFunc) Policy {
+	return NewSemanticThreeWayCollect(nil, resolve)
+}
+
+// NewSemanticThreeWayCollect creates a new Policy based on
+// SemanticThreeWayCollect using the provided SemanticResolveFunc and
+// ResolveFunc.
+func NewSemanticThreeWayCollect(semantic SemanticResolveFunc, resolve ResolveFunc) Policy {
+	return func(a, b, parent types.Value, vrw types.ValueReadWriter, progress chan struct{}) (merged types.Value, err error) {
+		return SemanticThreeWayCollect(a, b, parent, vrw, semantic, resolve, progress)
+	}
+}
+
+// ThreeWayCollect merges a and b like ThreeWay, but doesn't stop at the
+// first conflict that resolve can't resolve. Instead, it leaves the
+// conflicting Value as it is in parent and carries on. If there were any
+// conflicts, it returns the merged Value, with everything that could be
+// merged, together with an '*ErrMergeConflicts' describing all of them.
+func ThreeWayCollect(a, b, parent types.Value, vrw types.ValueReadWriter, resolve ResolveFunc, progress chan struct{}) (merged types.Value, err error) {
+	return SemanticThreeWayCollect(a, b, parent, vrw, nil, resolve, progress)
+}
+
+// SemanticThreeWayCollect merges a and b like SemanticThreeWay, but collects
+// the conflicts that are left like ThreeWayCollect.
+func SemanticThreeWayCollect(a, b, parent types.Value, vrw types.ValueReadWriter, semantic SemanticResolveFunc, resolve ResolveFunc, progress chan struct{}) (merged types.Value, err error) {
+	m := newMerger(vrw, semantic, resolve, progress)
+	m.collect = true
+	merged, err = m.merge(a, b, parent)
+	if err == errConflictCollected {
+		merged, err = parent, nil
+	}
+	if err == nil && len(m.conflicts) > 0 {
+		err = &ErrMergeConflicts{m.conflicts}
+	}
+	return merged, err
+}
+
+// conflict reports that a and b conflict at path. If m is collecting
+// conflicts, it records it and returns errConflictCollected, otherwise it
+// returns err.
+func (m *merger) conflict(path types.Path, a, b, parent types.Value, err *ErrMergeConflict) error {
+	if m.collect {
+		m.conflicts = append(m.conflicts, Conflict{path, parent, a, b, changeType(a, parent), changeType(b, parent)})
+		return errConflictCollected
+	}
+	return err
+}
+
+func changeType(v, parent types.Value) types.DiffChangeType {
+	switch {
+	case v == nil:
+		return types.DiffChangeRemoved
+	case parent == nil:
+		return types.DiffChangeAdded
+	}
+	return types.DiffChangeModified
+}
+
+const (
+	conflictStructName   = "Conflict"
+	conflictPathField    = "path"
+	conflictBaseField    = "base"
+	conflictOursField    = "ours"
+	conflictTheirsField  = "theirs"
+	conflictOursChange   = "oursChange"
+	conflictTheirsChange = "theirsChange"
+)
+
+// ConflictsToValue encodes conflicts as a Noms List of
+// Struct Conflict {path: String, base?: Value, ours?: Value, theirs?: Value,
+// oursChange: String, theirsChange: String}, so they can be committed and
+// resolved later. Values that are nil are left out.
+func ConflictsToValue(vrw types.ValueReadWriter, conflicts []Conflict) types.List {
+	vals := make([]types.Value, len(conflicts))
+	for i, c := range conflicts {
+		vals[i] = changestruct.New(conflictStructName, types.StructData{
+			conflictPathField:    types.String(c.Path.String()),
+			conflictBaseField:    c.Base,
+			conflictOursField:    c.Ours,
+			conflictTheirsField:  c.Theirs,
+			conflictOursChange:   types.String(changestruct.TypeNames[c.OursChange]),
+			conflictTheirsChange: types.String(changestruct.TypeNames[c.TheirsChange]),
+		})
+	}
+	return types.NewList(vrw, vals...)
+}
+
+// ConflictsFromValue decodes conflicts encoded by ConflictsToValue().
+func ConflictsFromValue(v types.Value) ([]Conflict, error) {
+	conflicts := []Conflict{}
+	err := changestruct.Iter(v, conflictStructName, func(s types.Struct) error {
+		c, err := conflictFromStruct(s)
+		conflicts = append(conflicts, c)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conflicts, nil
+}
+
+func conflictFromStruct(s types.Struct) (c Conflict, err error) {
+	if c.Path, err = changestruct.PathField(s, conflictPathField); err != nil {
+		return
+	}
+	c.Base, _ = s.MaybeGet(conflictBaseField)
+	c.Ours, _ = s.MaybeGet(conflictOursField)
+	c.Theirs, _ = s.MaybeGet(conflictTheirsField)
+	if c.OursChange, err = changestruct.TypeField(s, conflictOursChange, changestruct.TypeNames); err != nil {
+		return
+	}
+	c.TheirsChange, err = changestruct.TypeField(s, conflictTheirsChange, changestruct.TypeNames)
+	return
+}
+
+// DescribeChangeType returns a word describing ct, e.g. "added".
+func DescribeChangeType(ct types.DiffChangeType) string {
+	return changestruct.TypeNames[ct]
+}
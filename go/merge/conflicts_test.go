@@ -0,0 +1,80 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThreeWayCollect(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	vs := types.NewValueStore(storage.NewView())
+	defer vs.Close()
+
+	m := func(kv ...types.Value) types.Map {
+		return types.NewMap(vs, kv...)
+	}
+	s := func(str string) types.Value {
+		return types.String(str)
+	}
+	n := func(i int) types.Value {
+		return types.Number(i)
+	}
+
+	parent := m(s("same"), n(1), s("mod-both"), n(1), s("mod-rm"), n(1), s("list"), types.NewList(vs, n(1)), s("nested"), m(s("x"), n(1), s("y"), n(1)))
+	a := m(s("same"), n(1), s("mod-both"), n(2), s("list"), types.NewList(vs, n(2)), s("nested"), m(s("x"), n(2), s("y"), n(2)), s("a-only"), n(1))
+	b := m(s("same"), n(1), s("mod-both"), n(3), s("mod-rm"), n(3), s("list"), types.NewList(vs, n(3)), s("nested"), m(s("x"), n(3), s("y"), n(1)), s("b-only"), n(1))
+
+	// ThreeWay stops at the first conflict.
+	_, err := ThreeWay(a, b, parent, vs, nil, nil)
+	assert.IsType(&ErrMergeConflict{}, err)
+
+	merged, err := ThreeWayCollect(a, b, parent, vs, nil, nil)
+	assert.IsType(&ErrMergeConflicts{}, err)
+	conflicts := err.(*ErrMergeConflicts).Conflicts
+	expected := m(s("same"), n(1), s("mod-both"), n(1), s("mod-rm"), n(1), s("list"), types.NewList(vs, n(1)), s("nested"), m(s("x"), n(1), s("y"), n(2)), s("a-only"), n(1), s("b-only"), n(1))
+	assert.True(expected.Equals(merged), "%s != %s", types.EncodedValue(expected), types.EncodedValue(merged))
+
+	paths := []string{}
+	for _, c := range conflicts {
+		paths = append(paths, c.Path.String())
+	}
+	assert.Equal([]string{`["list"]`, `["mod-both"]`, `["mod-rm"]`, `["nested"]["x"]`}, paths)
+	assert.Equal(Conflict{types.MustParsePath(`["mod-both"]`), n(1), n(2), n(3), types.DiffChangeModified, types.DiffChangeModified}, conflicts[1])
+	assert.Equal(Conflict{types.MustParsePath(`["mod-rm"]`), n(1), nil, n(3), types.DiffChangeRemoved, types.DiffChangeModified}, conflicts[2])
+
+	// Resolved conflicts aren't collected. Overlapping List splices aren't passed to resolve.
+	merged, err = ThreeWayCollect(a, b, parent, vs, Theirs, nil)
+	assert.Equal(&ErrMergeConflicts{conflicts[:1]}, err)
+	assert.True(n(3).Equals(merged.(types.Map).Get(s("mod-both"))))
+
+	// Conflicts between the merged Values themselves have the empty Path.
+	merged, err = ThreeWayCollect(n(1), s("one"), n(0), vs, nil, nil)
+	assert.True(n(0).Equals(merged))
+	assert.Equal(&ErrMergeConflicts{[]Conflict{{types.Path{}, n(0), n(1), s("one"), types.DiffChangeModified, types.DiffChangeModified}}}, err)
+
+	// Conflicts round-trip through Noms.
+	conflicts = append(conflicts, err.(*ErrMergeConflicts).Conflicts...)
+	decoded, err := ConflictsFromValue(ConflictsToValue(vs, conflicts))
+	assert.NoError(err)
+	assert.Equal(len(conflicts), len(decoded))
+	for i, c := range conflicts {
+		assert.Equal(c.Path.String(), decoded[i].Path.String())
+		assert.Equal(c.OursChange, decoded[i].OursChange)
+		assert.Equal(c.TheirsChange, decoded[i].TheirsChange)
+		for j, v := range []types.Value{c.Base, c.Ours, c.Theirs} {
+			dv := []types.Value{decoded[i].Base, decoded[i].Ours, decoded[i].Theirs}[j]
+			assert.True(v == nil && dv == nil || v != nil && v.Equals(dv))
+		}
+	}
+
+	_, err = ConflictsFromValue(n(1))
+	assert.Error(err)
+}
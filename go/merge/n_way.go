@@ -0,0 +1,24 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import "github.com/attic-labs/noms/go/types"
+
+// NWay merges any number of values that all descend from parent, e.g. the
+// Values of an octopus merge. Using policy, it merges each Value in turn into
+// the result of merging the ones before it, against parent. It stops at the
+// first error, returning it along with whatever policy returned.
+func NWay(values []types.Value, parent types.Value, vrw types.ValueReadWriter, policy Policy, progress chan struct{}) (merged types.Value, err error) {
+	if len(values) == 0 {
+		return parent, nil
+	}
+	merged = values[0]
+	for _, v := range values[1:] {
+		if merged, err = policy(merged, v, parent, vrw, progress); err != nil {
+			return merged, err
+		}
+	}
+	return merged, nil
+}
@@ -0,0 +1,53 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNWay(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	vs := types.NewValueStore(storage.NewView())
+	defer vs.Close()
+
+	m := func(kv ...interface{}) types.Value {
+		vals := make([]types.Value, len(kv))
+		for i, v := range kv {
+			switch v := v.(type) {
+			case string:
+				vals[i] = types.String(v)
+			case int:
+				vals[i] = types.Number(v)
+			}
+		}
+		return types.NewMap(vs, vals...)
+	}
+
+	parent := m("a", 1, "b", 1, "c", 1)
+	values := []types.Value{m("a", 2, "b", 1, "c", 1), m("a", 1, "b", 2, "c", 1), m("a", 1, "b", 1, "c", 2, "d", 1)}
+	merged, err := NWay(values, parent, vs, NewThreeWay(nil), nil)
+	assert.NoError(err)
+	assert.True(m("a", 2, "b", 2, "c", 2, "d", 1).Equals(merged))
+
+	merged, err = NWay(values[:1], parent, vs, NewThreeWay(nil), nil)
+	assert.NoError(err)
+	assert.True(values[0].Equals(merged))
+	merged, err = NWay(nil, parent, vs, NewThreeWay(nil), nil)
+	assert.NoError(err)
+	assert.True(parent.Equals(merged))
+
+	values = append(values, m("a", 3, "b", 1, "c", 1))
+	_, err = NWay(values, parent, vs, NewThreeWay(nil), nil)
+	assert.IsType(&ErrMergeConflict{}, err)
+	merged, err = NWay(values, parent, vs, NewThreeWay(Theirs), nil)
+	assert.NoError(err)
+	assert.True(m("a", 3, "b", 2, "c", 2, "d", 1).Equals(merged))
+}
@@ -0,0 +1,130 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"time"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// SemanticResolveFunc is like ResolveFunc, but it's also given parent, the
+// Value at path in the common ancestor, or nil if there's none there. That
+// lets it resolve conflicts by what the Values mean, e.g. by adding up two
+// counters. Unlike a ResolveFunc, it's also called for conflicts between the
+// merged Values themselves, and for Lists whose changes overlap.
+type SemanticResolveFunc func(aChange, bChange types.DiffChangeType, a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool)
+
+// PathResolver applies Resolve to conflicts at Paths that match Pattern.
+type PathResolver struct {
+	Pattern types.PathPattern
+	Resolve SemanticResolveFunc
+}
+
+// PathResolvers resolves a conflict with the first PathResolver whose
+// Pattern matches its Path.
+type PathResolvers []PathResolver
+
+// Resolve is a SemanticResolveFunc. If no Pattern matches path, or the
+// PathResolver can't resolve the conflict, ok is false.
+func (prs PathResolvers) Resolve(aChange, bChange types.DiffChangeType, a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
+	for _, pr := range prs {
+		if pr.Pattern.Match(path) {
+			return pr.Resolve(aChange, bChange, a, b, parent, path)
+		}
+	}
+	return change, merged, false
+}
+
+// Semantic turns resolve into a SemanticResolveFunc, which ignores parent.
+func Semantic(resolve ResolveFunc) SemanticResolveFunc {
+	return func(aChange, bChange types.DiffChangeType, a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
+		return resolve(aChange, bChange, a, b, path)
+	}
+}
+
+// Counter resolves conflicting Numbers as counters: both a and b's changes
+// to parent are added to it. If the counter was added in both, parent is 0.
+func Counter(aChange, bChange types.DiffChangeType, a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
+	aNum, aOk := a.(types.Number)
+	bNum, bOk := b.(types.Number)
+	pNum, pOk := parent.(types.Number)
+	if !aOk || !bOk || !pOk && parent != nil {
+		return change, merged, false
+	}
+	return aChange, aNum + bNum - pNum, true
+}
+
+// Max resolves conflicting Values by taking the greater of a and b, as
+// ordered by Value.Less().
+func Max(aChange, bChange types.DiffChangeType, a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
+	if a == nil || b == nil {
+		return change, merged, false
+	}
+	if a.Less(b) {
+		return bChange, b, true
+	}
+	return aChange, a, true
+}
+
+// Min resolves conflicting Values by taking the lesser of a and b, as
+// ordered by Value.Less().
+func Min(aChange, bChange types.DiffChangeType, a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
+	if a == nil || b == nil {
+		return change, merged, false
+	}
+	if b.Less(a) {
+		return bChange, b, true
+	}
+	return aChange, a, true
+}
+
+// Concat resolves conflicting Lists that were both appended to: the result
+// is parent followed by what was appended to a, then what was appended to b.
+// If either List changed any of parent's elements, it's not resolved.
+func Concat(aChange, bChange types.DiffChangeType, a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
+	aList, aOk := a.(types.List)
+	bList, bOk := b.(types.List)
+	pList, pOk := parent.(types.List)
+	if !aOk || !bOk || !pOk && parent != nil {
+		return change, merged, false
+	}
+	appended := func(l types.List) (types.List, bool) {
+		if !pOk {
+			return l, true
+		}
+		if l.Len() < pList.Len() || !l.Edit().Remove(pList.Len(), l.Len()).List().Equals(pList) {
+			return l, false
+		}
+		return l.Edit().Remove(0, pList.Len()).List(), true
+	}
+	aAppended, aOk := appended(aList)
+	bAppended, bOk := appended(bList)
+	if !aOk || !bOk {
+		return change, merged, false
+	}
+	if pOk {
+		return aChange, pList.Concat(aAppended).Concat(bAppended), true
+	}
+	return aChange, aAppended.Concat(bAppended), true
+}
+
+// LastWriterWins returns a SemanticResolveFunc that resolves conflicts by
+// taking the change made last: a's if aDate is after bDate, and b's if
+// bDate is after aDate. It can't resolve conflicts if the dates are equal
+// or either one is zero.
+func LastWriterWins(aDate, bDate time.Time) SemanticResolveFunc {
+	return func(aChange, bChange types.DiffChangeType, a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
+		switch {
+		case aDate.IsZero() || bDate.IsZero():
+			return change, merged, false
+		case aDate.After(bDate):
+			return aChange, a, true
+		case bDate.After(aDate):
+			return bChange, b, true
+		}
+		return change, merged, false
+	}
+}
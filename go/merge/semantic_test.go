@@ -0,0 +1,115 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSemanticResolvers(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	vs := types.NewValueStore(storage.NewView())
+	defer vs.Close()
+
+	n := func(i int) types.Value {
+		return types.Number(i)
+	}
+	l := func(elems ...types.Value) types.Value {
+		return types.NewList(vs, elems...)
+	}
+	mod := types.DiffChangeModified
+	test := func(resolve SemanticResolveFunc, a, b, parent, expected types.Value) {
+		_, merged, ok := resolve(changeType(a, parent), changeType(b, parent), a, b, parent, types.Path{})
+		if expected == nil {
+			assert.False(ok)
+			return
+		}
+		if assert.True(ok) {
+			assert.True(expected.Equals(merged), "%s != %s", types.EncodedValue(expected), types.EncodedValue(merged))
+		}
+	}
+
+	test(Counter, n(5), n(3), n(1), n(7))
+	test(Counter, n(5), n(3), nil, n(8))
+	test(Counter, n(5), nil, n(1), nil)
+	test(Counter, n(5), types.String("x"), n(1), nil)
+
+	test(Max, n(5), n(3), n(1), n(5))
+	test(Max, types.String("a"), types.String("b"), nil, types.String("b"))
+	test(Max, n(5), nil, n(1), nil)
+	test(Min, n(5), n(3), n(1), n(3))
+	test(Min, n(5), nil, n(1), nil)
+
+	test(Concat, l(n(1), n(2)), l(n(1), n(3), n(4)), l(n(1)), l(n(1), n(2), n(3), n(4)))
+	test(Concat, l(n(2)), l(n(3)), nil, l(n(2), n(3)))
+	test(Concat, l(n(2)), l(n(1), n(3)), l(n(1)), nil)
+	test(Concat, l(), l(n(1), n(3)), l(n(1)), nil)
+
+	earlier, later := time.Unix(1000, 0), time.Unix(2000, 0)
+	test(LastWriterWins(later, earlier), n(5), n(3), n(1), n(5))
+	test(LastWriterWins(earlier, later), n(5), n(3), n(1), n(3))
+	test(LastWriterWins(earlier, earlier), n(5), n(3), n(1), nil)
+	test(LastWriterWins(time.Time{}, later), n(5), n(3), n(1), nil)
+	change, merged, ok := LastWriterWins(later, earlier)(types.DiffChangeRemoved, mod, nil, n(3), n(1), types.Path{})
+	assert.True(ok)
+	assert.Equal(types.DiffChangeRemoved, change)
+	assert.Nil(merged)
+}
+
+func TestSemanticThreeWay(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	vs := types.NewValueStore(storage.NewView())
+	defer vs.Close()
+
+	s := func(kv ...interface{}) types.Value {
+		data := types.StructData{}
+		for i := 0; i < len(kv); i += 2 {
+			data[kv[i].(string)] = kv[i+1].(types.Value)
+		}
+		return types.NewStruct("", data)
+	}
+	n := func(i int) types.Value {
+		return types.Number(i)
+	}
+	l := func(elems ...types.Value) types.Value {
+		return types.NewList(vs, elems...)
+	}
+	counts := func(kv ...types.Value) types.Value {
+		return vs.WriteValue(types.NewMap(vs, kv...))
+	}
+
+	parent := s("counts", counts(types.String("a"), n(1), types.String("b"), n(1)), "log", l(n(1)), "high", n(1), "title", types.String("x"))
+	a := s("counts", counts(types.String("a"), n(2), types.String("b"), n(3)), "log", l(n(1), n(2)), "high", n(5), "title", types.String("y"))
+	b := s("counts", counts(types.String("a"), n(4), types.String("b"), n(1)), "log", l(n(1), n(3)), "high", n(3), "title", types.String("z"))
+
+	resolvers := PathResolvers{
+		{types.MustParsePathPattern(".counts[*]"), Counter},
+		{types.MustParsePathPattern(".log"), Concat},
+		{types.MustParsePathPattern(".high"), Max},
+	}
+	_, err := SemanticThreeWay(a, b, parent, vs, resolvers.Resolve, nil, nil)
+	assert.IsType(&ErrMergeConflict{}, err)
+
+	merged, err := SemanticThreeWay(a, b, parent, vs, resolvers.Resolve, Theirs, nil)
+	assert.NoError(err)
+	expected := s("counts", counts(types.String("a"), n(5), types.String("b"), n(3)), "log", l(n(1), n(2), n(3)), "high", n(5), "title", types.String("z"))
+	assert.True(expected.Equals(merged), "%s != %s", types.EncodedValue(expected), types.EncodedValue(merged))
+
+	merged, err = SemanticThreeWayCollect(a, b, parent, vs, resolvers.Resolve, nil, nil)
+	assert.Equal(&ErrMergeConflicts{[]Conflict{{types.MustParsePath(".title"), types.String("x"), types.String("y"), types.String("z"), types.DiffChangeModified, types.DiffChangeModified}}}, err)
+	assert.True(types.String("x").Equals(merged.(types.Struct).Get("title")))
+
+	// Conflicts between the merged Values themselves are offered too.
+	merged, err = SemanticThreeWay(n(3), n(4), n(1), vs, Counter, nil, nil)
+	assert.NoError(err)
+	assert.True(n(6).Equals(merged))
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// SetPath returns root with the Value at path replaced by v, or removed if v
+// is nil. Like the Paths of merge Conflicts, path doesn't include the Refs
+// between root and the Value: any Ref on the way is followed, and a new Ref
+// to the changed target is written to vrw.
+//
+// Map entries and Set elements addressed by hash, e.g. `[#abcd]`, must
+// already be in root: their key can't be recovered from the hash alone.
+func SetPath(vrw types.ValueReadWriter, root types.Value, path types.Path, v types.Value) (types.Value, error) {
+	if r, ok := root.(types.Ref); ok && len(path) > 0 {
+		target, err := SetPath(vrw, r.TargetValue(vrw), path, v)
+		if err != nil {
+			return nil, err
+		}
+		return vrw.WriteValue(target), nil
+	}
+	if len(path) == 0 {
+		return v, nil
+	}
+	if root == nil {
+		return nil, fmt.Errorf("No value at %s", path)
+	}
+
+	part := path[0]
+	child := v
+	if len(path) > 1 {
+		var err error
+		if child, err = SetPath(vrw, part.Resolve(root, vrw), path[1:], v); err != nil {
+			return nil, err
+		}
+	}
+
+	switch part := part.(type) {
+	case types.FieldPath:
+		if s, ok := root.(types.Struct); ok {
+			if child == nil {
+				return s.Delete(part.Name), nil
+			}
+			return s.Set(part.Name, child), nil
+		}
+	case types.IndexPath:
+		switch root := root.(type) {
+		case types.Map:
+			if child == nil {
+				return root.Edit().Remove(part.Index).Map(), nil
+			}
+			return root.Edit().Set(part.Index, child).Map(), nil
+		case types.Set:
+			return setElement(root, part.Index, child), nil
+		case types.List:
+			idx, ok := part.Index.(types.Number)
+			if !ok || idx < 0 || uint64(idx) >= root.Len() {
+				return nil, fmt.Errorf("No value at %s", types.Path{part})
+			}
+			if child == nil {
+				return root.Edit().RemoveAt(uint64(idx)).List(), nil
+			}
+			return root.Edit().Set(uint64(idx), child).List(), nil
+		}
+	case types.HashIndexPath:
+		key := types.NewHashIndexIntoKeyPath(part.Hash).Resolve(root, vrw)
+		if s, ok := root.(types.Set); ok && key == nil && child != nil {
+			return s.Edit().Insert(child).Set(), nil
+		}
+		if key == nil {
+			return nil, fmt.Errorf("No value at %s", types.Path{part})
+		}
+		switch root := root.(type) {
+		case types.Map:
+			if child == nil {
+				return root.Edit().Remove(key).Map(), nil
+			}
+			return root.Edit().Set(key, child).Map(), nil
+		case types.Set:
+			return setElement(root, key, child), nil
+		}
+	}
+	return nil, fmt.Errorf("Can't set %s in %s", types.Path{part}, types.TypeOf(root).Describe())
+}
+
+// setElement replaces elem in s with v, or removes it if v is nil.
+func setElement(s types.Set, elem, v types.Value) types.Set {
+	se := s.Edit().Remove(elem)
+	if v != nil {
+		se.Insert(v)
+	}
+	return se.Set()
+}
@@ -0,0 +1,65 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetPath(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	vs := types.NewValueStore(storage.NewView())
+	defer vs.Close()
+
+	n := func(i int) types.Value {
+		return types.Number(i)
+	}
+	key := types.NewStruct("Key", types.StructData{"id": n(1)})
+	inner := types.NewMap(vs, types.String("x"), n(1), key, n(2))
+	root := types.NewStruct("", types.StructData{
+		"num":  n(1),
+		"list": types.NewList(vs, n(1), n(2)),
+		"ref":  vs.WriteValue(inner),
+		"set":  types.NewSet(vs, n(1), key),
+	})
+
+	test := func(path string, v types.Value, expected types.Value) {
+		p := types.MustParsePath(path)
+		actual, err := SetPath(vs, root, p, v)
+		assert.NoError(err)
+		resolved := p.Resolve(actual, vs)
+		assert.True(expected == nil && resolved == nil || expected != nil && expected.Equals(resolved), "%s: %s", path, types.EncodedValue(actual))
+	}
+	test(".num", n(2), n(2))
+	test(".num", nil, nil)
+	test(".new", n(3), n(3))
+	test(".list[1]", n(3), n(3))
+	test(`.ref["x"]`, n(3), nil) // The Path of a Ref's target doesn't resolve through the Ref.
+	test(".set[1]", nil, nil)
+
+	actual, err := SetPath(vs, root, types.MustParsePath(`.ref["x"]`), n(3))
+	assert.NoError(err)
+	r := actual.(types.Struct).Get("ref").(types.Ref)
+	assert.True(n(3).Equals(r.TargetValue(vs).(types.Map).Get(types.String("x"))))
+
+	hashPath := ".ref[" + "#" + key.Hash().String() + "]"
+	actual, err = SetPath(vs, root, types.MustParsePath(hashPath), n(4))
+	assert.NoError(err)
+	assert.True(n(4).Equals(actual.(types.Struct).Get("ref").(types.Ref).TargetValue(vs).(types.Map).Get(key)))
+
+	actual, err = SetPath(vs, root, types.Path{}, n(5))
+	assert.NoError(err)
+	assert.True(n(5).Equals(actual))
+
+	for _, path := range []string{".missing.x", ".num.x", ".list[5]", ".ref[#" + n(42).Hash().String() + "]"} {
+		_, err := SetPath(vs, root, types.MustParsePath(path), n(1))
+		assert.Error(err, path)
+	}
+}
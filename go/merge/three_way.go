@@ -133,7 +133,43 @@ func NewThreeWay(resolve ResolveFunc) Policy {
 // a:      [a, d, e]
 // b:      [a, d, e]
 // merged: [a, d, e]
+//
+// Splices also overlap if one side moves elements across the other's
+// changes. If that side only moved elements, and parent's elements are all
+// distinct, the other side's changes are applied to the elements wherever
+// they moved to, e.g.
+//
+// parent: [a, b, c]
+// a:      [c, a, b]
+// b:      [a, d, c, e]
+// merged: [c, e, a, d]
 func ThreeWay(a, b, parent types.Value, vrw types.ValueReadWriter, resolve ResolveFunc, progress chan struct{}) (merged types.Value, err error) {
+	return SemanticThreeWay(a, b, parent, vrw, nil, resolve, progress)
+}
+
+// NewSemanticThreeWay creates a new Policy based on SemanticThreeWay using
+// the provided SemanticResolveFunc and ResolveFunc.
+func NewSemanticThreeWay(semantic SemanticResolveFunc, resolve ResolveFunc) Policy {
+	return func(a, b, parent types.Value, vrw types.ValueReadWriter, progress chan struct{}) (merged types.Value, err error) {
+		return SemanticThreeWay(a, b, parent, vrw, semantic, resolve, progress)
+	}
+}
+
+// SemanticThreeWay merges a and b like ThreeWay, but offers each conflict to
+// semantic first. Only conflicts semantic can't resolve are passed to
+// resolve.
+func SemanticThreeWay(a, b, parent types.Value, vrw types.ValueReadWriter, semantic SemanticResolveFunc, resolve ResolveFunc, progress chan struct{}) (merged types.Value, err error) {
+	return newMerger(vrw, semantic, resolve, progress).merge(a, b, parent)
+}
+
+func newMerger(vrw types.ValueReadWriter, semantic SemanticResolveFunc, resolve ResolveFunc, progress chan struct{}) *merger {
+	if resolve == nil {
+		resolve = None
+	}
+	return &merger{vrw: vrw, semantic: semantic, resolve: resolve, progress: progress}
+}
+
+func (m *merger) merge(a, b, parent types.Value) (merged types.Value, err error) {
 	describe := func(v types.Value) string {
 		if v != nil {
 			return types.TypeOf(v).Describe()
@@ -144,13 +180,11 @@ func ThreeWay(a, b, parent types.Value, vrw types.ValueReadWriter, resolve Resol
 	if a == nil && b == nil {
 		return parent, nil
 	} else if unmergeable(a, b) {
-		return parent, newMergeConflict("Cannot merge %s with %s.", describe(a), describe(b))
-	}
-
-	if resolve == nil {
-		resolve = None
+		if _, merged, ok := m.resolveSemantic(a, b, parent, types.Path{}); ok {
+			return merged, nil
+		}
+		return parent, m.conflict(types.Path{}, a, b, parent, newMergeConflict("Cannot merge %s with %s.", describe(a), describe(b)))
 	}
-	m := &merger{vrw, resolve, progress}
 	return m.threeWay(a, b, parent, types.Path{})
 }
 
@@ -165,8 +199,33 @@ func unmergeable(a, b types.Value) bool {
 
 type merger struct {
 	vrw      types.ValueReadWriter
+	semantic SemanticResolveFunc
 	resolve  ResolveFunc
 	progress chan<- struct{}
+
+	// If collect is true, conflicts are recorded in conflicts instead of
+	// failing the merge. See ThreeWayCollect().
+	collect   bool
+	conflicts []Conflict
+}
+
+// resolveSemantic offers the conflict between a and b at path to m.semantic,
+// if there is one.
+func (m *merger) resolveSemantic(a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
+	if m.semantic == nil {
+		return change, merged, false
+	}
+	return m.semantic(changeType(a, parent), changeType(b, parent), a, b, parent, path)
+}
+
+// tryResolve offers a conflict to m.semantic, and then to m.resolve.
+func (m *merger) tryResolve(aChange, bChange types.DiffChangeType, a, b, parent types.Value, path types.Path) (change types.DiffChangeType, merged types.Value, ok bool) {
+	if m.semantic != nil {
+		if change, merged, ok = m.semantic(aChange, bChange, a, b, parent, path); ok {
+			return
+		}
+	}
+	return m.resolve(aChange, bChange, a, b, path)
 }
 
 func updateProgress(progress chan<- struct{}) {
@@ -185,7 +244,17 @@ func (m *merger) threeWay(a, b, parent types.Value, path types.Path) (merged typ
 	switch a.Kind() {
 	case types.ListKind:
 		if aList, bList, pList, ok := listAssert(m.vrw, a, b, parent); ok {
-			return threeWayListMerge(aList, bList, pList)
+			merged, err := threeWayListMerge(aList, bList, pList)
+			if mc, ok := err.(*ErrMergeConflict); ok {
+				if merged, ok := threeWayListMoveMerge(aList, bList, pList, m.vrw); ok {
+					return merged, nil
+				}
+				if _, merged, ok := m.resolveSemantic(a, b, parent, path); ok {
+					return merged, nil
+				}
+				return parent, m.conflict(path, a, b, parent, mc)
+			}
+			return merged, err
 		}
 
 	case types.MapKind:
@@ -217,7 +286,7 @@ func (m *merger) threeWay(a, b, parent types.Value, path types.Path) (merged typ
 	if parent != nil {
 		pDescription = types.TypeOf(parent).Describe()
 	}
-	return parent, newMergeConflict("Cannot merge %s and %s on top of %s.", types.TypeOf(a).Describe(), types.TypeOf(b).Describe(), pDescription)
+	return parent, m.conflict(path, a, b, parent, newMergeConflict("Cannot merge %s and %s on top of %s.", types.TypeOf(a).Describe(), types.TypeOf(b).Describe(), pDescription))
 }
 
 func (m *merger) threeWayMapMerge(a, b, parent types.Map, path types.Path) (merged types.Value, err error) {
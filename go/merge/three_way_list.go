@@ -8,6 +8,7 @@ import (
 	"fmt"
 
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
@@ -123,6 +124,102 @@ func apply(source, target types.List, offset uint64, s types.Splice) types.List
 	return target.Edit().Splice(s.SpAt+offset, s.SpRemoved, toAdd...).List()
 }
 
+// threeWayListMoveMerge merges a and b if one of them only moved parent's
+// elements around, the other didn't, and all of parent's elements are
+// distinct. The other List's splices are replayed against the moved
+// elements: an element that was modified or removed is modified or removed
+// wherever it moved to, and inserted elements follow the element they
+// followed in parent. Unlike threeWayListMerge, this reads all of a, b and
+// parent into memory.
+func threeWayListMoveMerge(a, b, parent types.List, vrw types.ValueReadWriter) (merged types.List, ok bool) {
+	indices, ok := listIndices(parent)
+	if !ok {
+		return parent, false
+	}
+	aMoved, bMoved := isPermutation(a, parent, indices), isPermutation(b, parent, indices)
+	switch {
+	case aMoved && !bMoved:
+		return replaySplices(a, b, parent, indices, vrw), true
+	case bMoved && !aMoved:
+		return replaySplices(b, a, parent, indices, vrw), true
+	}
+	return parent, false
+}
+
+// listIndices maps the hash of each of l's elements to its index, or returns
+// false if any of them are equal.
+func listIndices(l types.List) (map[hash.Hash]uint64, bool) {
+	indices := make(map[hash.Hash]uint64, l.Len())
+	i := uint64(0)
+	ok := true
+	l.IterAll(func(v types.Value, _ uint64) {
+		h := v.Hash()
+		if _, dup := indices[h]; dup {
+			ok = false
+		}
+		indices[h] = i
+		i++
+	})
+	return indices, ok
+}
+
+// isPermutation returns whether l holds parent's elements in another order.
+func isPermutation(l, parent types.List, indices map[hash.Hash]uint64) bool {
+	if l.Len() != parent.Len() || l.Equals(parent) {
+		return false
+	}
+	seen := make(map[hash.Hash]bool, l.Len())
+	ok := true
+	l.IterAll(func(v types.Value, _ uint64) {
+		h := v.Hash()
+		if _, in := indices[h]; !in || seen[h] {
+			ok = false
+		}
+		seen[h] = true
+	})
+	return ok
+}
+
+func replaySplices(moved, edited, parent types.List, indices map[hash.Hash]uint64, vrw types.ValueReadWriter) types.List {
+	// What each of parent's elements became in edited, by index, if it
+	// changed, and what edited inserted after each index. Insertions at the
+	// start are after -1.
+	changed := map[uint64][]types.Value{}
+	inserted := map[int64][]types.Value{}
+	spliceChan := make(chan types.Splice)
+	go func() {
+		edited.Diff(parent, spliceChan, nil)
+		close(spliceChan)
+	}()
+	for splice := range spliceChan {
+		if splice.SpRemoved == splice.SpAdded {
+			for i := uint64(0); i < splice.SpRemoved; i++ {
+				changed[splice.SpAt+i] = []types.Value{edited.Get(splice.SpFrom + i)}
+			}
+			continue
+		}
+		for i := uint64(0); i < splice.SpRemoved; i++ {
+			changed[splice.SpAt+i] = nil
+		}
+		after := int64(splice.SpAt) - 1
+		for i := uint64(0); i < splice.SpAdded; i++ {
+			inserted[after] = append(inserted[after], edited.Get(splice.SpFrom+i))
+		}
+	}
+
+	vals := append([]types.Value{}, inserted[-1]...)
+	moved.IterAll(func(v types.Value, _ uint64) {
+		i := indices[v.Hash()]
+		if c, ok := changed[i]; ok {
+			vals = append(vals, c...)
+		} else {
+			vals = append(vals, v)
+		}
+		vals = append(vals, inserted[int64(i)]...)
+	})
+	return types.NewList(vrw, vals...)
+}
+
 func describeSplice(s types.Splice) string {
 	return fmt.Sprintf("%d elements removed at %d; adding %d elements", s.SpRemoved, s.SpAt, s.SpAdded)
 }
@@ -137,3 +137,23 @@ func (s *ThreeWayListMergeSuite) TestThreeWayMerge_RemoveOtherSideInsertionPoint
 	s.tryThreeWayConflict(s.create(a), s.create(b), s.create(p), "Overlapping splices: 1 elements removed at 1; adding 0 elements")
 	s.tryThreeWayConflict(s.create(b), s.create(a), s.create(p), "Overlapping splices: 0 elements removed at 1; adding 1 elements")
 }
+
+func (s *ThreeWayListMergeSuite) TestThreeWayMerge_MoveAndEdit() {
+	a := items{"e", "a", "b", "c", "d"}
+	b := items{"a", 1, "c", "d", 2, "e", 3}
+	m := items{"e", 3, "a", 1, "c", "d", 2}
+	s.tryThreeWayMerge(a, b, p, m)
+	s.tryThreeWayMerge(b, a, p, m)
+
+	a = items{"b", "a", "c", "d", "e"}
+	b = items{"a", 0, "c", "d", "e"}
+	m = items{0, "a", "c", "d", "e"}
+	s.tryThreeWayMerge(a, b, p, m)
+	s.tryThreeWayMerge(b, a, p, m)
+}
+
+func (s *ThreeWayListMergeSuite) TestThreeWayMerge_ConflictingMoves() {
+	a := items{"e", "a", "b", "c", "d"}
+	b := items{"b", "a", "c", "d", "e"}
+	s.tryThreeWayConflict(s.create(a), s.create(b), s.create(p), "Overlapping splices")
+}
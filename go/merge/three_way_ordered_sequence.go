@@ -61,6 +61,11 @@ func (m *merger) threeWayOrderedSequenceMerge(a, b, parent candidate, apply appl
 		}
 
 		change, mergedVal, err := m.mergeChanges(aChange, bChange, a, b, parent, apply, path)
+		if err == errConflictCollected {
+			// Leave the conflicting value as it is in parent.
+			aChange, bChange = types.ValueChanged{}, types.ValueChanged{}
+			continue
+		}
 		if err != nil {
 			return parent.getValue(), err
 		}
@@ -75,11 +80,11 @@ func (m *merger) mergeChanges(aChange, bChange types.ValueChanged, a, b, p candi
 	aValue, bValue := a.get(aChange.Key), b.get(bChange.Key)
 	// If the two diffs generate different kinds of changes at the same key, conflict.
 	if aChange.ChangeType != bChange.ChangeType {
-		if change, mergedVal, ok := m.resolve(aChange.ChangeType, bChange.ChangeType, aValue, bValue, path); ok {
+		if change, mergedVal, ok := m.tryResolve(aChange.ChangeType, bChange.ChangeType, aValue, bValue, p.get(aChange.Key), path); ok {
 			// TODO: Correctly encode Old/NewValue with this change report. https://github.com/attic-labs/noms/issues/3467
 			return types.ValueChanged{change, aChange.Key, nil, nil}, mergedVal, nil
 		}
-		return change, nil, newMergeConflict("Conflict:\n%s\nvs\n%s\n", describeChange(aChange), describeChange(bChange))
+		return change, nil, m.conflict(path, aValue, bValue, p.get(aChange.Key), newMergeConflict("Conflict:\n%s\nvs\n%s\n", describeChange(aChange), describeChange(bChange)))
 	}
 
 	if aChange.ChangeType == types.DiffChangeRemoved || aValue.Equals(bValue) {
@@ -97,11 +102,11 @@ func (m *merger) mergeChanges(aChange, bChange types.ValueChanged, a, b, p candi
 		return change, nil, err
 	}
 
-	if change, mergedVal, ok := m.resolve(aChange.ChangeType, bChange.ChangeType, aValue, bValue, path); ok {
+	if change, mergedVal, ok := m.tryResolve(aChange.ChangeType, bChange.ChangeType, aValue, bValue, p.get(aChange.Key), path); ok {
 		// TODO: Correctly encode Old/NewValue with this change report. https://github.com/attic-labs/noms/issues/3467
 		return types.ValueChanged{change, aChange.Key, nil, nil}, mergedVal, nil
 	}
-	return change, nil, newMergeConflict("Conflict:\n%s = %s\nvs\n%s = %s", describeChange(aChange), types.EncodedValue(aValue), describeChange(bChange), types.EncodedValue(bValue))
+	return change, nil, m.conflict(path, aValue, bValue, p.get(aChange.Key), newMergeConflict("Conflict:\n%s = %s\nvs\n%s = %s", describeChange(aChange), types.EncodedValue(aValue), describeChange(bChange), types.EncodedValue(bValue)))
 }
 
 func stopAndDrain(stop chan<- struct{}, drain <-chan types.ValueChanged) {
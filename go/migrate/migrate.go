@@ -0,0 +1,424 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package migrate rewrites Noms values from one struct type to another.
+//
+// A Migration is declared as a mapping between two struct types, From and To.
+// Every struct reachable from a value which is a subtype of From is replaced
+// by a struct of type To whose fields are, by default, copied from the fields
+// of the same name. A field of To can instead be taken from any Path relative
+// to the old struct, which allows fields to be renamed or hoisted out of
+// nested structs. Fields of From which don't appear in To are dropped, and
+// values whose kind differs from that of the field they're copied into are
+// converted between Number, String and Bool where possible.
+//
+// Values whose type shows that they can't contain a struct that needs
+// migrating are returned as they are, without being read. Migrating
+// collections and Refs is memoized by hash, so subtrees are shared between
+// the old and new values.
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/nomdl"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Migration describes how to rewrite structs of type From into structs of
+// type To.
+type Migration struct {
+	From, To *types.Type
+
+	// Fields maps the names of fields of To to the Path, relative to the old
+	// struct, of the value they should take. Fields of To that aren't in
+	// Fields are copied from the field of the same name.
+	Fields map[string]types.Path
+}
+
+// New returns a Migration from structs of type from to structs of type to.
+func New(from, to *types.Type, fields map[string]types.Path) (Migration, error) {
+	if from.TargetKind() != types.StructKind || to.TargetKind() != types.StructKind {
+		return Migration{}, fmt.Errorf("Migrations must be between struct types, not %s and %s", from.Describe(), to.Describe())
+	}
+	desc := to.Desc.(types.StructDesc)
+	for name := range fields {
+		if t, _ := desc.Field(name); t == nil {
+			return Migration{}, fmt.Errorf("%s has no field %s", to.Describe(), name)
+		}
+	}
+	return Migration{from, to, fields}, nil
+}
+
+// Parse reads a Migration declared as JSON, for example:
+//
+//   {
+//     "from": "Struct Person { name: String, age: String }",
+//     "to": "Struct Person { fullName: String, age: Number }",
+//     "fields": { "fullName": ".name" }
+//   }
+//
+// "from" and "to" are nomdl types, and each value of "fields" is a Path.
+func Parse(r io.Reader) (Migration, error) {
+	var decl struct {
+		From, To string
+		Fields   map[string]string
+	}
+	if err := json.NewDecoder(r).Decode(&decl); err != nil {
+		return Migration{}, err
+	}
+
+	from, err := nomdl.ParseType(decl.From)
+	if err != nil {
+		return Migration{}, err
+	}
+	to, err := nomdl.ParseType(decl.To)
+	if err != nil {
+		return Migration{}, err
+	}
+	fields := map[string]types.Path{}
+	for name, p := range decl.Fields {
+		if fields[name], err = types.ParsePath(p); err != nil {
+			return Migration{}, err
+		}
+	}
+	return New(from, to, fields)
+}
+
+// Migrator applies a Migration to values read from, and written to, vrw.
+// The results for collections and Refs are memoized, so using a single
+// Migrator for related values (e.g. successive commits of a dataset)
+// maximizes structural sharing.
+type Migrator struct {
+	m     Migration
+	vrw   types.ValueReadWriter
+	cache map[hash.Hash]types.Value
+	// relevant memoizes, by the hash of a type, whether values of that type
+	// can contain a struct which is a subtype of m.From.
+	relevant map[hash.Hash]bool
+}
+
+func NewMigrator(m Migration, vrw types.ValueReadWriter) *Migrator {
+	return &Migrator{m, vrw, map[hash.Hash]types.Value{}, map[hash.Hash]bool{}}
+}
+
+// Migrate returns v with every struct which is a subtype of m.From replaced
+// by the corresponding struct of type m.To. If nothing in v needs migrating,
+// v itself is returned.
+func (m *Migrator) Migrate(v types.Value) (types.Value, error) {
+	switch v.(type) {
+	case types.Bool, types.Number, types.String, types.Blob, *types.Type:
+		return v, nil
+	}
+	if !m.mayContainFrom(types.TypeOf(v)) {
+		return v, nil
+	}
+
+	if _, ok := v.(types.Struct); ok {
+		return m.migrate(v)
+	}
+	h := v.Hash()
+	if r, ok := m.cache[h]; ok {
+		return r, nil
+	}
+	r, err := m.migrate(v)
+	if err != nil {
+		return nil, err
+	}
+	m.cache[h] = r
+	return r, nil
+}
+
+// mayContainFrom returns whether a value of type t can contain a struct which
+// is a subtype of m.From, i.e. whether t includes a struct type with From's
+// name (if it has one) and all of its required fields.
+func (m *Migrator) mayContainFrom(t *types.Type) bool {
+	h := t.Hash()
+	if r, ok := m.relevant[h]; ok {
+		return r
+	}
+	from := m.m.From.Desc.(types.StructDesc)
+	seen := map[*types.Type]bool{}
+	var walk func(t *types.Type) bool
+	walk = func(t *types.Type) bool {
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+		switch desc := t.Desc.(type) {
+		case types.CompoundDesc:
+			for _, et := range desc.ElemTypes {
+				if walk(et) {
+					return true
+				}
+			}
+		case types.StructDesc:
+			if from.Name == "" || from.Name == desc.Name {
+				matches := true
+				from.IterFields(func(name string, _ *types.Type, optional bool) {
+					if ft, _ := desc.Field(name); !optional && ft == nil {
+						matches = false
+					}
+				})
+				if matches {
+					return true
+				}
+			}
+			found := false
+			desc.IterFields(func(_ string, ft *types.Type, _ bool) {
+				found = found || walk(ft)
+			})
+			return found
+		}
+		return false
+	}
+	r := walk(t)
+	m.relevant[h] = r
+	return r
+}
+
+func (m *Migrator) migrate(v types.Value) (types.Value, error) {
+	switch v := v.(type) {
+	case types.Struct:
+		if types.IsValueSubtypeOf(v, m.m.From) {
+			return m.migrateStruct(v)
+		}
+		changed := map[string]types.Value{}
+		var err error
+		v.IterFields(func(name string, fv types.Value) {
+			if err != nil {
+				return
+			}
+			var nv types.Value
+			if nv, err = m.Migrate(fv); err == nil && !nv.Equals(fv) {
+				changed[name] = nv
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		for name, nv := range changed {
+			v = v.Set(name, nv)
+		}
+		return v, nil
+
+	case types.Ref:
+		target := v.TargetValue(m.vrw)
+		nt, err := m.Migrate(target)
+		if err != nil || nt.Equals(target) {
+			return v, err
+		}
+		return m.vrw.WriteValue(nt), nil
+
+	case types.List:
+		var err error
+		le := v.Edit()
+		v.IterAll(func(ev types.Value, idx uint64) {
+			if err != nil {
+				return
+			}
+			var nv types.Value
+			if nv, err = m.Migrate(ev); err == nil && !nv.Equals(ev) {
+				le.Set(idx, nv)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		return le.List(), nil
+
+	case types.Set:
+		var err error
+		se := v.Edit()
+		v.IterAll(func(ev types.Value) {
+			if err != nil {
+				return
+			}
+			var nv types.Value
+			if nv, err = m.Migrate(ev); err == nil && !nv.Equals(ev) {
+				se.Remove(ev).Insert(nv)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		return se.Set(), nil
+
+	case types.Map:
+		var err error
+		me := v.Edit()
+		v.IterAll(func(k, ev types.Value) {
+			if err != nil {
+				return
+			}
+			var nk, nv types.Value
+			if nk, err = m.Migrate(k); err != nil {
+				return
+			}
+			if nv, err = m.Migrate(ev); err != nil {
+				return
+			}
+			keyChanged := !nk.Equals(k)
+			if keyChanged {
+				me.Remove(k)
+			}
+			if keyChanged || !nv.Equals(ev) {
+				me.Set(nk, nv)
+			}
+		})
+		if err != nil {
+			return nil, err
+		}
+		return me.Map(), nil
+	}
+	return v, nil
+}
+
+func (m *Migrator) migrateStruct(s types.Struct) (types.Value, error) {
+	desc := m.m.To.Desc.(types.StructDesc)
+	data := types.StructData{}
+	var err error
+	desc.IterFields(func(name string, t *types.Type, optional bool) {
+		if err != nil {
+			return
+		}
+		p, ok := m.m.Fields[name]
+		if !ok {
+			p = types.Path{types.NewFieldPath(name)}
+		}
+		fv := p.Resolve(s, m.vrw)
+		if fv == nil {
+			if !optional {
+				err = fmt.Errorf("Can't migrate %s: nothing at %s for required field %s", types.EncodedValueMaxLines(s, 5), p, name)
+			}
+			return
+		}
+		if fv, err = m.Migrate(fv); err != nil {
+			return
+		}
+		if data[name], err = convert(fv, t); err != nil {
+			err = fmt.Errorf("Can't migrate field %s: %s", name, err)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return types.NewStruct(desc.Name, data), nil
+}
+
+// convert returns v as a value of type t, converting between Numbers,
+// Strings and Bools if necessary.
+func convert(v types.Value, t *types.Type) (types.Value, error) {
+	if types.IsValueSubtypeOf(v, t) {
+		return v, nil
+	}
+
+	if t.TargetKind() == types.UnionKind {
+		for _, et := range t.Desc.(types.CompoundDesc).ElemTypes {
+			if cv, err := convert(v, et); err == nil {
+				return cv, nil
+			}
+		}
+	}
+
+	var s string
+	switch v := v.(type) {
+	case types.String:
+		s = string(v)
+	case types.Number:
+		s = strconv.FormatFloat(float64(v), 'g', -1, 64)
+	case types.Bool:
+		s = strconv.FormatBool(bool(v))
+	}
+
+	if s != "" {
+		switch t.TargetKind() {
+		case types.StringKind:
+			return types.String(s), nil
+		case types.NumberKind:
+			if b, ok := v.(types.Bool); ok {
+				if b {
+					return types.Number(1), nil
+				}
+				return types.Number(0), nil
+			}
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				return types.Number(f), nil
+			}
+		case types.BoolKind:
+			if n, ok := v.(types.Number); ok {
+				return types.Bool(n != 0), nil
+			}
+			if b, err := strconv.ParseBool(s); err == nil {
+				return types.Bool(b), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s is not a %s", types.EncodedValueMaxLines(v, 5), t.Describe())
+}
+
+// MigrateHead commits the result of migrating the head value of ds as the new
+// head of ds, using meta as the commit's meta.
+func MigrateHead(ds datas.Dataset, m Migration, meta types.Struct) (datas.Dataset, error) {
+	db := ds.Database()
+	v, ok := ds.MaybeHeadValue()
+	if !ok {
+		return ds, fmt.Errorf("Dataset %s has no head", ds.ID())
+	}
+	nv, err := NewMigrator(m, db).Migrate(v)
+	if err != nil {
+		return ds, err
+	}
+	return db.Commit(ds, nv, datas.CommitOptions{Meta: meta})
+}
+
+// MigrateHistory rewrites every commit reachable from the head of ds,
+// migrating the value of each and preserving their meta and the shape of the
+// commit graph, then sets the head of ds to the rewritten head.
+func MigrateHistory(ds datas.Dataset, m Migration) (datas.Dataset, error) {
+	db := ds.Database()
+	headRef, ok := ds.MaybeHeadRef()
+	if !ok {
+		return ds, fmt.Errorf("Dataset %s has no head", ds.ID())
+	}
+
+	// Collect every commit, then rewrite them parents first.
+	commits := types.RefSlice{}
+	seen := hash.HashSet{}
+	for stack := (types.RefSlice{headRef}); len(stack) > 0; {
+		r := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen.Has(r.TargetHash()) {
+			continue
+		}
+		seen.Insert(r.TargetHash())
+		commits = append(commits, r)
+		r.TargetValue(db).(types.Struct).Get(datas.ParentsField).(types.Set).IterAll(func(p types.Value) {
+			stack = append(stack, p.(types.Ref))
+		})
+	}
+	sort.Slice(commits, func(i, j int) bool { return commits[i].Height() < commits[j].Height() })
+
+	migrator := NewMigrator(m, db)
+	rewritten := map[hash.Hash]types.Ref{}
+	for _, r := range commits {
+		c := r.TargetValue(db).(types.Struct)
+		nv, err := migrator.Migrate(c.Get(datas.ValueField))
+		if err != nil {
+			return ds, err
+		}
+		parents := types.NewSet(db).Edit()
+		c.Get(datas.ParentsField).(types.Set).IterAll(func(p types.Value) {
+			parents.Insert(rewritten[p.(types.Ref).TargetHash()])
+		})
+		rewritten[r.TargetHash()] = db.WriteValue(datas.NewCommit(nv, parents.Set(), c.Get(datas.MetaField).(types.Struct)))
+	}
+	return db.SetHead(ds, rewritten[headRef.TargetHash()])
+}
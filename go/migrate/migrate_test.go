@@ -0,0 +1,119 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package migrate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const personMigration = `{
+	"from": "Struct Person { name: Struct { first: String, last: String }, age: String, nick?: String }",
+	"to": "Struct Person { first: String, age: Number, nick?: String }",
+	"fields": { "first": ".name.first" }
+}`
+
+func newPerson(first, last, age string) types.Struct {
+	return types.NewStruct("Person", types.StructData{
+		"name": types.NewStruct("", types.StructData{"first": types.String(first), "last": types.String(last)}),
+		"age":  types.String(age),
+	})
+}
+
+func TestParse(t *testing.T) {
+	assert := assert.New(t)
+
+	m, err := Parse(strings.NewReader(personMigration))
+	assert.NoError(err)
+	assert.Equal("Person", m.To.Desc.(types.StructDesc).Name)
+	assert.Equal(".name.first", m.Fields["first"].String())
+
+	_, err = Parse(strings.NewReader(`{"from": "Number", "to": "String"}`))
+	assert.Error(err)
+	_, err = Parse(strings.NewReader(`{"from": "Struct {}", "to": "Struct {}", "fields": {"x": ".y"}}`))
+	assert.Error(err)
+}
+
+func TestMigrate(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase((&chunks.TestStorage{}).NewView())
+	defer db.Close()
+
+	m, err := Parse(strings.NewReader(personMigration))
+	assert.NoError(err)
+	migrator := NewMigrator(m, db)
+
+	unrelated := types.NewList(db, types.Number(1), types.String("two"))
+	v := types.NewMap(db,
+		types.String("people"), types.NewList(db, newPerson("Ada", "Lovelace", "36"), newPerson("Alan", "Turing", "41")),
+		types.String("other"), unrelated,
+		types.String("ref"), db.WriteValue(newPerson("Grace", "Hopper", "85")),
+	)
+
+	migrated, err := migrator.Migrate(v)
+	assert.NoError(err)
+	mm := migrated.(types.Map)
+
+	expected := types.NewStruct("Person", types.StructData{"first": types.String("Ada"), "age": types.Number(36)})
+	assert.True(expected.Equals(mm.Get(types.String("people")).(types.List).Get(0)))
+	assert.True(unrelated.Equals(mm.Get(types.String("other"))))
+
+	r := mm.Get(types.String("ref")).(types.Ref)
+	assert.True(types.Number(85).Equals(r.TargetValue(db).(types.Struct).Get("age")))
+
+	// Values without anything to migrate are returned as is.
+	same, err := migrator.Migrate(unrelated)
+	assert.NoError(err)
+	assert.True(unrelated.Equals(same))
+
+	// Neither they, nor structs, are memoized.
+	_, ok := migrator.cache[unrelated.Hash()]
+	assert.False(ok)
+	for _, r := range migrator.cache {
+		assert.NotEqual(types.StructKind, r.Kind())
+	}
+
+	_, err = migrator.Migrate(newPerson("Bad", "Age", "forty"))
+	assert.Error(err)
+}
+
+func TestMigrateHistory(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase((&chunks.TestStorage{}).NewView())
+	defer db.Close()
+
+	m, err := Parse(strings.NewReader(personMigration))
+	assert.NoError(err)
+
+	ds := db.GetDataset("people")
+	ds, err = db.CommitValue(ds, newPerson("Ada", "Lovelace", "36"))
+	assert.NoError(err)
+	meta := types.NewStruct("Meta", types.StructData{"message": types.String("older")})
+	ds, err = db.Commit(ds, newPerson("Ada", "Lovelace", "37"), datas.CommitOptions{Meta: meta})
+	assert.NoError(err)
+
+	head, err := MigrateHead(ds, m, types.EmptyStruct)
+	assert.NoError(err)
+	assert.True(types.Number(37).Equals(head.HeadValue().(types.Struct).Get("age")))
+	assert.Equal(uint64(3), head.HeadRef().Height())
+
+	ds, err = db.SetHead(head, ds.HeadRef())
+	assert.NoError(err)
+	ds, err = MigrateHistory(ds, m)
+	assert.NoError(err)
+	assert.Equal(uint64(2), ds.HeadRef().Height())
+	assert.True(types.Number(37).Equals(ds.HeadValue().(types.Struct).Get("age")))
+	assert.True(meta.Equals(ds.Head().Get(datas.MetaField)))
+
+	parents := ds.Head().Get(datas.ParentsField).(types.Set)
+	assert.Equal(uint64(1), parents.Len())
+	parent := parents.First().(types.Ref).TargetValue(db).(types.Struct)
+	assert.True(types.Number(36).Equals(parent.Get(datas.ValueField).(types.Struct).Get("age")))
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"regexp"
+	"time"
 
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/hash"
@@ -16,6 +17,8 @@ import (
 
 var datasetCapturePrefixRe = regexp.MustCompile("^(" + datas.DatasetRe.String() + ")")
 
+var asOfCapturePrefixRe = regexp.MustCompile("^@(" + datas.AsOfRe.String() + ")")
+
 // AbsolutePath describes the location of a Value within a Noms database.
 //
 // To locate a value relative to some other value, see Path. To locate a value
@@ -30,6 +33,10 @@ type AbsolutePath struct {
 	// Hash is the hash this AbsolutePath is rooted at. Only one of Dataset and
 	// Hash should be set.
 	Hash hash.Hash
+	// AsOf, if set, is the time at which to resolve Dataset. Then the
+	// AbsolutePath is rooted at the Commit that was the Dataset's Head at that
+	// time, see datas.Dataset.AsOf().
+	AsOf time.Time
 	// Path is the relative path from Dataset or Hash. This can be empty. In
 	// that case, the AbsolutePath describes the value at either Dataset or
 	// Hash.
@@ -44,6 +51,7 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 
 	var h hash.Hash
 	var dataset string
+	var asOf time.Time
 	var pathStr string
 
 	if str[0] == '#' {
@@ -68,10 +76,19 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 
 		dataset = datasetParts[1]
 		pathStr = str[len(dataset):]
+
+		if asOfParts := asOfCapturePrefixRe.FindStringSubmatch(pathStr); asOfParts != nil {
+			var err error
+			asOf, err = datas.ParseAsOf(asOfParts[1])
+			if err != nil {
+				return AbsolutePath{}, err
+			}
+			pathStr = pathStr[len(asOfParts[0]):]
+		}
 	}
 
 	if len(pathStr) == 0 {
-		return AbsolutePath{Hash: h, Dataset: dataset}, nil
+		return AbsolutePath{Hash: h, Dataset: dataset, AsOf: asOf}, nil
 	}
 
 	path, err := types.ParsePath(pathStr)
@@ -79,7 +96,7 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 		return AbsolutePath{}, err
 	}
 
-	return AbsolutePath{Hash: h, Dataset: dataset, Path: path}, nil
+	return AbsolutePath{Hash: h, Dataset: dataset, AsOf: asOf, Path: path}, nil
 }
 
 // Resolve returns the Value reachable by 'p' in 'db'.
@@ -87,6 +104,9 @@ func (p AbsolutePath) Resolve(db datas.Database) (val types.Value) {
 	if len(p.Dataset) > 0 {
 		var ok bool
 		ds := db.GetDataset(p.Dataset)
+		if !p.AsOf.IsZero() {
+			ds = ds.AsOf(p.AsOf)
+		}
 		if val, ok = ds.MaybeHead(); !ok {
 			val = nil
 		}
@@ -113,6 +133,9 @@ func (p AbsolutePath) String() (str string) {
 
 	if len(p.Dataset) > 0 {
 		str = p.Dataset
+		if !p.AsOf.IsZero() {
+			str += "@" + p.AsOf.Format(time.RFC3339Nano)
+		}
 	} else if !p.Hash.IsEmpty() {
 		str = "#" + p.Hash.String()
 	} else {
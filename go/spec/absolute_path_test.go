@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/datas"
@@ -28,6 +29,8 @@ func TestAbsolutePathToAndFromString(t *testing.T) {
 	h := types.Number(42).Hash() // arbitrary hash
 	test(fmt.Sprintf("foo.bar[#%s]", h.String()))
 	test(fmt.Sprintf("#%s.bar[42]", h.String()))
+	test("foo@2017-01-01T00:00:00Z.value")
+	test("foo@2017-01-01T12:30:00.5-07:00")
 }
 
 func TestAbsolutePaths(t *testing.T) {
@@ -81,6 +84,45 @@ func TestAbsolutePaths(t *testing.T) {
 	resolvesTo(nil, "#"+types.String("baz").Hash().String()+"[0]")
 }
 
+func TestAbsolutePathAsOf(t *testing.T) {
+	assert := assert.New(t)
+	storage := &chunks.MemoryStorage{}
+	db := datas.NewDatabase(storage.NewView())
+
+	commit := func(ds datas.Dataset, v types.Value, date string) datas.Dataset {
+		meta, err := CreateCommitMetaStruct(db, date, "", nil, nil)
+		assert.NoError(err)
+		ds, err = db.Commit(ds, v, datas.CommitOptions{Meta: meta})
+		assert.NoError(err)
+		return ds
+	}
+	ds := commit(db.GetDataset("ds"), types.String("jan"), "2017-01-01T00:00:00Z")
+	ds = commit(ds, types.String("feb"), "2017-02-01T00:00:00Z")
+
+	resolvesTo := func(exp types.Value, str string) {
+		p, err := NewAbsolutePath(str)
+		assert.NoError(err)
+		act := p.Resolve(db)
+		if exp == nil {
+			assert.Nil(act)
+		} else {
+			assert.True(exp.Equals(act), "%s Expected %s Actual %s", str, types.EncodedValue(exp), types.EncodedValue(act))
+		}
+	}
+
+	resolvesTo(types.String("feb"), "ds.value")
+	resolvesTo(types.String("feb"), "ds@2017-03-01.value")
+	resolvesTo(types.String("feb"), "ds@2017-02-01T00:00Z.value")
+	resolvesTo(types.String("jan"), "ds@2017-01-31T23:59:59Z.value")
+	resolvesTo(types.String("jan"), "ds@2017-01-31T15:59-08:00.value")
+	resolvesTo(nil, "ds@2016-12-31.value")
+
+	p, err := NewAbsolutePath("ds@2017-01-01T00:00Z")
+	assert.NoError(err)
+	assert.Equal("ds", p.Dataset)
+	assert.True(p.AsOf.Equal(time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)))
+}
+
 func TestReadAbsolutePaths(t *testing.T) {
 	assert := assert.New(t)
 	storage := &chunks.MemoryStorage{}
@@ -126,4 +168,5 @@ func TestAbsolutePathParseErrors(t *testing.T) {
 	test("#abc", "Invalid hash: abc")
 	invHash := strings.Repeat("z", hash.StringLen)
 	test("#"+invHash, "Invalid hash: "+invHash)
+	test("foo@2017-13-01", "Invalid timestamp: 2017-13-01")
 }
@@ -13,6 +13,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
@@ -35,6 +36,10 @@ type SpecOptions struct {
 	// Authorization token for requests. For example, if the database is HTTP
 	// this will used for an `Authorization: Bearer ${authorization}` header.
 	Authorization string
+
+	// HTTP configures how requests to an HTTP database are retried and timed
+	// out. If nil, datas.DefaultHTTPChunkStoreOptions are used.
+	HTTP *datas.HTTPChunkStoreOptions
 }
 
 // Spec locates a Noms database, dataset, or value globally. Spec caches
@@ -159,7 +164,8 @@ func (sp Spec) String() string {
 
 // GetDatabase returns the Database instance that this Spec's DatabaseName
 // describes. The same Database instance is returned every time, unless Close
-// is called. If the Spec is closed, it is re-opened with a new Database.
+// is called. If the Spec is closed, it is re-opened with a new Database. It
+// panics if a remote Database can't be reached, see datas.CatchRemoteErrors.
 func (sp Spec) GetDatabase() datas.Database {
 	if *sp.db == nil {
 		*sp.db = sp.createDatabase()
@@ -202,10 +208,14 @@ func parseAWSSpec(awsURL string) chunks.ChunkStore {
 // GetDataset returns the current Dataset instance for this Spec's Database.
 // GetDataset is live, so if Commit is called on this Spec's Database later, a
 // new up-to-date Dataset will returned on the next call to GetDataset.  If
-// this is not a Dataset spec, returns nil.
+// this is not a Dataset spec, returns nil. If the Spec's Path has an AsOf
+// time, the Dataset is as it was at that time.
 func (sp Spec) GetDataset() (ds datas.Dataset) {
 	if sp.Path.Dataset != "" {
 		ds = sp.GetDatabase().GetDataset(sp.Path.Dataset)
+		if !sp.Path.AsOf.IsZero() {
+			ds = ds.AsOf(sp.Path.AsOf)
+		}
 	}
 	return
 }
@@ -237,20 +247,12 @@ func (sp Spec) Href() string {
 // of the database at the current moment in time.  Returns itself if the
 // PathSpec is already "pinned".
 func (sp Spec) Pin() (Spec, bool) {
-	var ds datas.Dataset
-
-	if !sp.Path.IsEmpty() {
-		if !sp.Path.Hash.IsEmpty() {
-			// Spec is already pinned.
-			return sp, true
-		}
-
-		ds = sp.GetDatabase().GetDataset(sp.Path.Dataset)
-	} else {
-		ds = sp.GetDataset()
+	if !sp.Path.Hash.IsEmpty() {
+		// Spec is already pinned.
+		return sp, true
 	}
 
-	commit, ok := ds.MaybeHead()
+	commit, ok := sp.GetDataset().MaybeHead()
 	if !ok {
 		return Spec{}, false
 	}
@@ -258,6 +260,7 @@ func (sp Spec) Pin() (Spec, bool) {
 	r := sp
 	r.Path.Hash = commit.Hash()
 	r.Path.Dataset = ""
+	r.Path.AsOf = time.Time{}
 
 	return r, true
 }
@@ -275,7 +278,13 @@ func (sp Spec) Close() error {
 func (sp Spec) createDatabase() datas.Database {
 	switch sp.Protocol {
 	case "http", "https":
-		return datas.NewDatabase(datas.NewHTTPChunkStore(sp.Href(), sp.Options.Authorization))
+		opts := datas.DefaultHTTPChunkStoreOptions
+		if sp.Options.HTTP != nil {
+			opts = *sp.Options.HTTP
+		}
+		cs, err := datas.NewHTTPChunkStoreWithOptions(sp.Href(), sp.Options.Authorization, opts)
+		d.PanicIfError(err)
+		return datas.NewDatabase(cs)
 	case "aws":
 		return datas.NewDatabase(parseAWSSpec(sp.Href()))
 	case "nbs":
@@ -448,6 +448,32 @@ func TestPinDatasetSpec(t *testing.T) {
 	assert.Equal(types.Number(43), unpinned.GetDataset().HeadValue())
 }
 
+func TestPinAsOfPathSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	unpinned, err := ForPath("mem::foo@2017-01-15.value")
+	assert.NoError(err)
+	defer unpinned.Close()
+
+	db := unpinned.GetDatabase()
+	ds := db.GetDataset("foo")
+	for i, date := range []string{"2017-01-01T00:00:00Z", "2017-02-01T00:00:00Z"} {
+		meta := types.NewStruct("Meta", types.StructData{"date": types.String(date)})
+		ds, err = db.Commit(ds, types.Number(i), datas.CommitOptions{Meta: meta})
+		assert.NoError(err)
+	}
+
+	pinned, ok := unpinned.Pin()
+	assert.True(ok)
+	defer pinned.Close()
+
+	first := ds.Head().Get(datas.ParentsField).(types.Set).First().(types.Ref)
+	assert.Equal(first.TargetHash(), pinned.Path.Hash)
+	assert.True(pinned.Path.AsOf.IsZero())
+	assert.Equal(types.Number(0), pinned.GetValue())
+	assert.Equal(types.Number(0), unpinned.GetDataset().HeadValue())
+}
+
 func TestAlreadyPinnedPathSpec(t *testing.T) {
 	assert := assert.New(t)
 
@@ -79,8 +79,10 @@ func (b Blob) ReadAt(p []byte, off int64) (n int, err error) {
 	return
 }
 
+// Reader returns a BlobReader over b. It doesn't prefetch anything until
+// SetPrefetch() is called, so callers that read b sequentially should call it.
 func (b Blob) Reader() *BlobReader {
-	return &BlobReader{b, 0}
+	return &BlobReader{b: b}
 }
 
 func (b Blob) Copy(w io.Writer) (n int64) {
@@ -141,6 +143,27 @@ func (b Blob) Concat(other Blob) Blob {
 	return newBlob(seq)
 }
 
+// Diff streams the diff from last to b to the changes channel, as Splices of
+// bytes. Only the prolly tree chunks that differ are compared byte by byte, so
+// it's cheap for large Blobs that share most of their chunks. Caller can close
+// closeChan to cancel the diff operation.
+func (b Blob) Diff(last Blob, changes chan<- Splice, closeChan <-chan struct{}) {
+	if b.Equals(last) {
+		return
+	}
+	bLen, lastLen := b.Len(), last.Len()
+	if bLen == 0 {
+		changes <- Splice{0, lastLen, 0, 0} // everything removed
+		return
+	}
+	if lastLen == 0 {
+		changes <- Splice{0, 0, bLen, 0} // everything added
+		return
+	}
+
+	indexedSequenceDiff(last.seq, 0, b.seq, 0, changes, closeChan, DEFAULT_MAX_SPLICE_MATRIX_SIZE)
+}
+
 func (b Blob) newChunker(cur *sequenceCursor, vrw ValueReadWriter) *sequenceChunker {
 	return newSequenceChunker(cur, 0, vrw, makeBlobLeafChunkFn(vrw), newIndexedMetaSequenceChunkFn(BlobKind, vrw), hashValueByte)
 }
@@ -201,17 +224,111 @@ func (b Blob) writeTo(w nomsWriter) {
 	b.seq.writeTo(w)
 }
 
+// BlobReader implements io.ReadSeeker over a Blob. To make sequential reads
+// efficient, once a Read() starts where the previous one ended, it loads a
+// window of blocks of the Blob concurrently, ahead of the current position.
+// Seeking within the window reuses it, while seeking outside of it drops it
+// until reads are sequential again, so random access only loads the bytes
+// requested. See SetPrefetch().
 type BlobReader struct {
 	b   Blob
 	pos int64
+
+	blockSize  uint64
+	maxBlocks  int
+	window     []*blobBlock // consecutive blocks, the first of which contains pos
+	sequential bool         // whether the last Read() ended at pos
+}
+
+type blobBlock struct {
+	start uint64
+	data  []byte
+	done  chan struct{}
+	err   error // set before done is closed
+}
+
+func newBlobBlock(b Blob, start, length uint64) *blobBlock {
+	bb := &blobBlock{start: start, data: make([]byte, length), done: make(chan struct{})}
+	go func() {
+		defer close(bb.done)
+		n, err := b.ReadAt(bb.data, int64(start))
+		if n == len(bb.data) {
+			err = nil
+		} else if err == nil || err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		bb.err = err
+	}()
+	return bb
+}
+
+func (bb *blobBlock) end() uint64 {
+	return bb.start + uint64(len(bb.data))
+}
+
+// SetPrefetch makes cbr load up to |blocks| blocks of |blockSize| bytes
+// ahead of the current position. If |blocks| is 0, nothing is prefetched
+// and each Read() loads just the bytes requested.
+func (cbr *BlobReader) SetPrefetch(blockSize uint64, blocks int) {
+	d.PanicIfTrue(blocks < 0 || blocks > 0 && blockSize == 0)
+	cbr.blockSize, cbr.maxBlocks, cbr.window = blockSize, blocks, nil
 }
 
 func (cbr *BlobReader) Read(p []byte) (n int, err error) {
-	n, err = cbr.b.ReadAt(p, cbr.pos)
+	pos := uint64(cbr.pos)
+	if cbr.maxBlocks == 0 || !cbr.sequential && !cbr.inWindow(pos) {
+		n, err = cbr.b.ReadAt(p, cbr.pos)
+		cbr.pos += int64(n)
+		cbr.sequential = true
+		return
+	}
+
+	if pos >= cbr.b.Len() {
+		return 0, io.EOF
+	}
+	cbr.fillWindow(pos)
+	bb := cbr.window[0]
+	<-bb.done
+	if bb.err != nil {
+		cbr.window = nil
+		return 0, bb.err
+	}
+	n = copy(p, bb.data[pos-bb.start:])
 	cbr.pos += int64(n)
+	if uint64(cbr.pos) == cbr.b.Len() {
+		err = io.EOF
+	}
 	return
 }
 
+func (cbr *BlobReader) inWindow(pos uint64) bool {
+	return len(cbr.window) > 0 && pos >= cbr.window[0].start && pos < cbr.window[len(cbr.window)-1].end()
+}
+
+// fillWindow makes the first block of the window the one containing pos, and
+// starts loading blocks after it until the window is full.
+func (cbr *BlobReader) fillWindow(pos uint64) {
+	for len(cbr.window) > 0 && pos >= cbr.window[0].end() {
+		cbr.window = cbr.window[1:]
+	}
+	if len(cbr.window) > 0 && pos < cbr.window[0].start {
+		cbr.window = nil
+	}
+
+	next := pos
+	if len(cbr.window) > 0 {
+		next = cbr.window[len(cbr.window)-1].end()
+	}
+	for len(cbr.window) < cbr.maxBlocks && next < cbr.b.Len() {
+		length := cbr.b.Len() - next
+		if length > cbr.blockSize {
+			length = cbr.blockSize
+		}
+		cbr.window = append(cbr.window, newBlobBlock(cbr.b, next, length))
+		next += length
+	}
+}
+
 func (cbr *BlobReader) Seek(offset int64, whence int) (int64, error) {
 	abs := int64(cbr.pos)
 
@@ -230,6 +347,9 @@ func (cbr *BlobReader) Seek(offset int64, whence int) (int64, error) {
 		return 0, errors.New("Blob.Reader.Seek: negative position")
 	}
 
+	if abs != cbr.pos {
+		cbr.sequential = false
+	}
 	cbr.pos = int64(abs)
 	return abs, nil
 }
@@ -296,7 +416,7 @@ func readBlob(r io.Reader, vrw ValueReadWriter) Blob {
 	// TODO: The code below is temporary. It's basically a custom leaf-level chunker for blobs. There are substational perf gains by doing it this way as it avoids the cost of boxing every single byte which is chunked.
 	chunkBuff := [8192]byte{}
 	chunkBytes := chunkBuff[:]
-	rv := newRollingValueHasher(0)
+	rv := newRollingValueHasher(vrw, 0)
 	offset := 0
 	addByte := func(b byte) bool {
 		if offset >= len(chunkBytes) {
@@ -235,6 +235,60 @@ func TestBlobSplice(t *testing.T) {
 	assert.Equal(buf.String(), "Yes, it's hard to satisfy arv")
 }
 
+func TestBlobDiff(t *testing.T) {
+	assert := assert.New(t)
+	vrw := newTestValueStore()
+
+	diff := func(b1, b2 Blob) (splices []Splice) {
+		changes := make(chan Splice)
+		go func() {
+			b2.Diff(b1, changes, nil)
+			close(changes)
+		}()
+		for splice := range changes {
+			splices = append(splices, splice)
+		}
+		return
+	}
+	// apply replays splices, which are in ascending order, on the bytes of b1.
+	apply := func(b1, b2 Blob, splices []Splice) []byte {
+		data1, _ := ioutil.ReadAll(b1.Reader())
+		data2, _ := ioutil.ReadAll(b2.Reader())
+		res, last := []byte{}, uint64(0)
+		for _, sp := range splices {
+			res = append(res, data1[last:sp.SpAt]...)
+			res = append(res, data2[sp.SpFrom:sp.SpFrom+sp.SpAdded]...)
+			last = sp.SpAt + sp.SpRemoved
+		}
+		return append(res, data1[last:]...)
+	}
+
+	data := make([]byte, 1<<20)
+	r := rand.New(rand.NewSource(42))
+	r.Read(data)
+	b1 := NewBlob(vrw, bytes.NewReader(data))
+	b2 := b1.Edit().Splice(300000, 3, []byte("hello")).Splice(700000, 0, []byte("world")).Blob()
+	assert.True(b1.seq.treeLevel() > 0)
+
+	splices := diff(b1, b2)
+	changed := uint64(0)
+	for _, sp := range splices {
+		changed += sp.SpRemoved + sp.SpAdded
+	}
+	assert.True(changed < 20, "%v", splices)
+	data2, _ := ioutil.ReadAll(b2.Reader())
+	assert.Equal(data2, apply(b1, b2, splices))
+
+	assert.Empty(diff(b1, b1))
+	empty := NewEmptyBlob(vrw)
+	assert.Equal([]Splice{{0, 0, b1.Len(), 0}}, diff(empty, b1))
+	assert.Equal([]Splice{{0, b1.Len(), 0, 0}}, diff(b1, empty))
+
+	small1 := NewBlob(vrw, strings.NewReader("abcdef"))
+	small2 := NewBlob(vrw, strings.NewReader("abXdefg"))
+	assert.Equal([]Splice{{2, 1, 1, 2}, {6, 0, 1, 6}}, diff(small1, small2))
+}
+
 func TestBlobConcat(t *testing.T) {
 	assert := assert.New(t)
 
@@ -328,3 +382,98 @@ func TestStreamingParallelBlob(t *testing.T) {
 	blob.Copy(outBuff)
 	assert.True(bytes.Compare(buff, outBuff.Bytes()) == 0)
 }
+
+func TestBlobReaderPrefetch(t *testing.T) {
+	assert := assert.New(t)
+	vrw := newTestValueStore()
+
+	buff := randomBuff(16)
+	b := NewBlob(vrw, bytes.NewReader(buff))
+
+	for _, blocks := range []int{0, 1, 3} {
+		r := b.Reader()
+		r.SetPrefetch(1000, blocks)
+
+		// Sequential reads of various sizes, some spanning blocks.
+		actual := &bytes.Buffer{}
+		_, err := io.CopyBuffer(actual, struct{ io.Reader }{r}, make([]byte, 777))
+		assert.NoError(err)
+		assert.Equal(buff, actual.Bytes())
+
+		// Seeks both within and outside of the window.
+		rnd := rand.New(rand.NewSource(int64(blocks)))
+		for i := 0; i < 50; i++ {
+			start := rnd.Int63n(int64(len(buff)))
+			if i%2 == 1 {
+				start = int64(rnd.Intn(2000))
+			}
+			count := rnd.Int63n(3000)
+			if start+count > int64(len(buff)) {
+				count = int64(len(buff)) - start
+			}
+			_, err := r.Seek(start, 0)
+			assert.NoError(err)
+			p := make([]byte, count)
+			_, err = io.ReadFull(r, p)
+			assert.NoError(err)
+			assert.Equal(buff[start:start+count], p)
+		}
+
+		_, err = r.Seek(0, 2)
+		assert.NoError(err)
+		n, err := r.Read(make([]byte, 10))
+		assert.Equal(0, n)
+		assert.Equal(io.EOF, err)
+	}
+}
+
+func TestBlobReaderNoPrefetchByDefault(t *testing.T) {
+	assert := assert.New(t)
+	vrw := newTestValueStore()
+
+	buff := randomBuff(16)
+	r := NewBlob(vrw, bytes.NewReader(buff)).Reader()
+	p := make([]byte, 100)
+	for i := 0; i < 3; i++ {
+		_, err := io.ReadFull(r, p)
+		assert.NoError(err)
+		assert.Equal(buff[i*100:(i+1)*100], p)
+	}
+	assert.Empty(r.window)
+}
+
+func TestBlobReaderPrefetchOnlyWhenSequential(t *testing.T) {
+	assert := assert.New(t)
+	vrw := newTestValueStore()
+
+	buff := randomBuff(16)
+	b := NewBlob(vrw, bytes.NewReader(buff))
+	r := b.Reader()
+	r.SetPrefetch(1000, 3)
+
+	// Random access only loads what's read.
+	p := make([]byte, 100)
+	_, err := r.Seek(5000, 0)
+	assert.NoError(err)
+	_, err = io.ReadFull(r, p)
+	assert.NoError(err)
+	assert.Equal(buff[5000:5100], p)
+	assert.Empty(r.window)
+
+	// Reading on from there starts the window.
+	_, err = io.ReadFull(r, p)
+	assert.NoError(err)
+	assert.Equal(buff[5100:5200], p)
+	assert.Len(r.window, 3)
+
+	// A block that failed to load fails the Read.
+	_, err = r.Seek(0, 0)
+	assert.NoError(err)
+	_, err = io.ReadFull(r, p)
+	assert.NoError(err)
+	failed := &blobBlock{start: 100, data: make([]byte, 1000), done: make(chan struct{}), err: io.ErrUnexpectedEOF}
+	close(failed.done)
+	r.window = []*blobBlock{failed}
+	_, err = r.Read(p)
+	assert.Equal(io.ErrUnexpectedEOF, err)
+}
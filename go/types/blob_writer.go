@@ -0,0 +1,167 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"errors"
+	"io"
+)
+
+const blobWriterZeroFillSize = 1 << 13
+
+// BlobWriter writes a Blob incrementally. Bytes written past the end of the
+// Blob are streamed through a chunker, so only the chunk being built is held
+// in memory and completed chunks are written to the ValueReadWriter as soon
+// as they're available. Writes which overwrite existing bytes are spliced
+// in. Writing past the end of the Blob fills the gap with zeros.
+//
+// Appended bytes are chunked by a goroutine, which Blob() waits for. Once
+// writing is done, either Blob() or Close() must be called, or that goroutine
+// leaks.
+//
+// BlobWriter implements io.Writer, io.WriterAt, io.Seeker and io.Closer. It
+// isn't threadsafe.
+type BlobWriter struct {
+	vrw  ValueReadWriter
+	base Blob
+	pos  int64
+
+	// Bytes being appended to base are written to pw, from which they're
+	// chunked into the Blob which will be sent on appended.
+	pw          *io.PipeWriter
+	appended    chan Blob
+	appendedLen uint64
+}
+
+// NewBlobWriter returns a BlobWriter which writes a new Blob to vrw.
+func NewBlobWriter(vrw ValueReadWriter) *BlobWriter {
+	return NewEmptyBlob(vrw).Writer()
+}
+
+// Writer returns a BlobWriter which writes to a copy of b, starting at
+// offset 0.
+func (b Blob) Writer() *BlobWriter {
+	return &BlobWriter{vrw: b.valueReadWriter(), base: b}
+}
+
+// Len returns the length of the Blob being written.
+func (w *BlobWriter) Len() uint64 {
+	return w.base.Len() + w.appendedLen
+}
+
+// Blob returns the Blob written so far. Writing may continue afterwards.
+func (w *BlobWriter) Blob() Blob {
+	w.finishAppend()
+	return w.base
+}
+
+// Close finishes chunking any bytes appended since the last call to Blob(),
+// and drops them. The BlobWriter mustn't be used afterwards.
+func (w *BlobWriter) Close() error {
+	if w.pw != nil {
+		w.pw.Close()
+		<-w.appended
+		w.pw, w.appended, w.appendedLen = nil, nil, 0
+	}
+	return nil
+}
+
+func (w *BlobWriter) Write(p []byte) (n int, err error) {
+	n, err = w.WriteAt(p, w.pos)
+	w.pos += int64(n)
+	return
+}
+
+func (w *BlobWriter) WriteAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("Blob.Writer.WriteAt: negative offset")
+	}
+
+	start := uint64(off)
+	if length := w.Len(); start > length {
+		if err = w.appendZeros(start - length); err != nil {
+			return
+		}
+	}
+
+	if start < w.Len() {
+		w.finishAppend()
+		overwrite := w.base.Len() - start
+		if overwrite > uint64(len(p)) {
+			overwrite = uint64(len(p))
+		}
+		w.base = w.base.Edit().Splice(start, overwrite, p[:overwrite]).Blob()
+		n, p = int(overwrite), p[overwrite:]
+	}
+
+	if len(p) > 0 {
+		var appended int
+		appended, err = w.append(p)
+		n += appended
+	}
+	return
+}
+
+func (w *BlobWriter) Seek(offset int64, whence int) (int64, error) {
+	abs := w.pos
+
+	switch whence {
+	case 0:
+		abs = offset
+	case 1:
+		abs += offset
+	case 2:
+		abs = int64(w.Len()) + offset
+	default:
+		return 0, errors.New("Blob.Writer.Seek: invalid whence")
+	}
+
+	if abs < 0 {
+		return 0, errors.New("Blob.Writer.Seek: negative position")
+	}
+
+	w.pos = abs
+	return abs, nil
+}
+
+func (w *BlobWriter) append(p []byte) (int, error) {
+	if w.pw == nil {
+		pr, pw := io.Pipe()
+		appended := make(chan Blob, 1)
+		go func() {
+			appended <- readBlob(pr, w.vrw)
+		}()
+		w.pw, w.appended = pw, appended
+	}
+	n, err := w.pw.Write(p)
+	w.appendedLen += uint64(n)
+	return n, err
+}
+
+func (w *BlobWriter) appendZeros(count uint64) error {
+	zeros := make([]byte, blobWriterZeroFillSize)
+	for count > 0 {
+		l := count
+		if l > blobWriterZeroFillSize {
+			l = blobWriterZeroFillSize
+		}
+		if _, err := w.append(zeros[:l]); err != nil {
+			return err
+		}
+		count -= l
+	}
+	return nil
+}
+
+// finishAppend waits for any bytes being appended to be chunked, and
+// concatenates the result onto w.base.
+func (w *BlobWriter) finishAppend() {
+	if w.pw == nil {
+		return
+	}
+	w.pw.Close()
+	w.base = w.base.Concat(<-w.appended)
+	w.pw, w.appended, w.appendedLen = nil, nil, 0
+}
@@ -0,0 +1,124 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobWriterStreaming(t *testing.T) {
+	assert := assert.New(t)
+	vrw := newTestValueStore()
+
+	buff := randomBuff(18)
+	w := NewBlobWriter(vrw)
+	for i := 0; i < len(buff); i += 1000 {
+		end := i + 1000
+		if end > len(buff) {
+			end = len(buff)
+		}
+		n, err := w.Write(buff[i:end])
+		assert.NoError(err)
+		assert.Equal(end-i, n)
+	}
+	assert.Equal(uint64(len(buff)), w.Len())
+
+	// The Blob is chunked just as if it had been read all at once.
+	b := w.Blob()
+	assert.True(NewBlob(vrw, bytes.NewReader(buff)).Equals(b))
+
+	// Its chunks were written as they were completed.
+	b.WalkRefs(func(r Ref) {
+		assert.NotNil(vrw.ReadValue(r.TargetHash()))
+	})
+}
+
+func TestBlobWriterWriteAt(t *testing.T) {
+	assert := assert.New(t)
+	vrw := newTestValueStore()
+
+	buff := randomBuff(16)
+	expected := append([]byte{}, buff...)
+	w := NewBlob(vrw, bytes.NewReader(buff)).Writer()
+
+	write := func(p []byte, off int) {
+		n, err := w.WriteAt(p, int64(off))
+		assert.NoError(err)
+		assert.Equal(len(p), n)
+
+		if gap := off - len(expected); gap > 0 {
+			expected = append(expected, make([]byte, gap)...)
+		}
+		for i, c := range p {
+			if off+i < len(expected) {
+				expected[off+i] = c
+			} else {
+				expected = append(expected, c)
+			}
+		}
+	}
+
+	write([]byte("overwrite"), 10)
+	write([]byte("append"), len(expected))
+	write([]byte("straddle the end"), len(expected)-4)
+	write([]byte("after a gap"), len(expected)+5000)
+	write([]byte("back to the start"), 0)
+	write([]byte("more appending"), len(expected))
+
+	b := w.Blob()
+	assert.Equal(uint64(len(expected)), b.Len())
+	actual, err := ioutil.ReadAll(b.Reader())
+	assert.NoError(err)
+	assert.Equal(expected, actual)
+	assert.True(NewBlob(vrw, bytes.NewReader(expected)).Equals(b))
+
+	_, err = w.WriteAt([]byte("x"), -1)
+	assert.Error(err)
+}
+
+func TestBlobWriterSeek(t *testing.T) {
+	assert := assert.New(t)
+	vrw := newTestValueStore()
+
+	w := NewBlob(vrw, bytes.NewBufferString("hello world")).Writer()
+	_, err := w.Seek(0, 2)
+	assert.NoError(err)
+	io.WriteString(w, "!")
+	_, err = w.Seek(-6, 1)
+	assert.NoError(err)
+	io.WriteString(w, "W")
+	pos, err := w.Seek(0, 0)
+	assert.NoError(err)
+	assert.Equal(int64(0), pos)
+	io.WriteString(w, "H")
+
+	actual, err := ioutil.ReadAll(w.Blob().Reader())
+	assert.NoError(err)
+	assert.Equal("Hello World!", string(actual))
+
+	_, err = w.Seek(-1, 0)
+	assert.Error(err)
+}
+
+func TestBlobWriterClose(t *testing.T) {
+	assert := assert.New(t)
+	vrw := newTestValueStore()
+
+	w := NewBlob(vrw, bytes.NewBufferString("hello")).Writer()
+	_, err := w.WriteAt([]byte(" world"), 5)
+	assert.NoError(err)
+	assert.NotNil(w.appended)
+
+	// The chunking goroutine is done with, and the appended bytes dropped.
+	assert.NoError(w.Close())
+	assert.Nil(w.appended)
+	assert.Equal(uint64(5), w.Len())
+	assert.NoError(w.Close())
+}
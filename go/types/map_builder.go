@@ -0,0 +1,429 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/attic-labs/noms/go/d"
+)
+
+const (
+	defaultSortBufferSize = 1 << 26 // 64MB
+	sortedRunReadAhead    = 1 << 7
+	defaultChunkBatchSize = 1 << 14
+)
+
+// MapBuilder builds a Map out of key/value pairs which may be added in any
+// order, and whose total size may exceed available memory. Pairs are buffered
+// in memory until the buffer is full, at which point they are sorted and
+// spilled to a temporary file as a sorted run. Map() merges all the runs,
+// decoding them concurrently, and splits the merged entries into batches of
+// consecutive keys which are chunked in parallel and then concatenated. Chunk
+// boundaries only depend on the entries, so the Map is the same as one built
+// by NewMap. If the same key is Set more than once, the last Set wins.
+//
+// Set() is threadsafe, but Map() should only be called once, after all calls
+// to Set() have completed.
+type MapBuilder struct {
+	s *externalSorter
+}
+
+// NewMapBuilder returns a MapBuilder which writes the resulting Map to vrw.
+func NewMapBuilder(vrw ValueReadWriter) *MapBuilder {
+	return newMapBuilder(vrw, "", defaultSortBufferSize)
+}
+
+func newMapBuilder(vrw ValueReadWriter, dir string, maxBuffered uint64) *MapBuilder {
+	return &MapBuilder{newExternalSorter(vrw, dir, maxBuffered, defaultChunkBatchSize)}
+}
+
+// Set adds the mapping k -> v to the Map being built.
+func (b *MapBuilder) Set(k, v Value) {
+	d.PanicIfTrue(k == nil || v == nil)
+	b.s.add(k, v)
+}
+
+// Map returns the Map containing every key/value pair passed to Set() and
+// removes any temporary files used to build it.
+func (b *MapBuilder) Map() Map {
+	newChunker := func(cur *sequenceCursor, vrw ValueReadWriter) *sequenceChunker {
+		return newSequenceChunker(cur, 0, vrw, makeMapLeafChunkFn(vrw), newOrderedMetaSequenceChunkFn(MapKind, vrw), mapHashValueBytes)
+	}
+	return newMap(b.s.chunk(newChunker, func(k, v Value) sequenceItem {
+		return mapEntry{k, v}
+	}).(orderedSequence))
+}
+
+// SetBuilder is the Set counterpart of MapBuilder: it builds a Set out of
+// values which may be inserted in any order, and whose total size may exceed
+// available memory.
+type SetBuilder struct {
+	s *externalSorter
+}
+
+// NewSetBuilder returns a SetBuilder which writes the resulting Set to vrw.
+func NewSetBuilder(vrw ValueReadWriter) *SetBuilder {
+	return newSetBuilder(vrw, "", defaultSortBufferSize)
+}
+
+func newSetBuilder(vrw ValueReadWriter, dir string, maxBuffered uint64) *SetBuilder {
+	return &SetBuilder{newExternalSorter(vrw, dir, maxBuffered, defaultChunkBatchSize)}
+}
+
+// Insert adds v to the Set being built.
+func (b *SetBuilder) Insert(v Value) {
+	d.PanicIfTrue(v == nil)
+	b.s.add(v, nil)
+}
+
+// Set returns the Set containing every value passed to Insert() and removes
+// any temporary files used to build it.
+func (b *SetBuilder) Set() Set {
+	newChunker := func(cur *sequenceCursor, vrw ValueReadWriter) *sequenceChunker {
+		return newSequenceChunker(cur, 0, vrw, makeSetLeafChunkFn(vrw), newOrderedMetaSequenceChunkFn(SetKind, vrw), hashValueBytes)
+	}
+	return newSet(b.s.chunk(newChunker, func(k, v Value) sequenceItem {
+		return k
+	}).(orderedSequence))
+}
+
+type sortedRunEntry struct {
+	key, value Value // value is nil when building Sets
+	seq        uint64
+	kData      []byte
+	vData      []byte
+}
+
+// sortedRunEntrySlice sorts by key, and then newest first.
+type sortedRunEntrySlice []sortedRunEntry
+
+func (s sortedRunEntrySlice) Len() int      { return len(s) }
+func (s sortedRunEntrySlice) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s sortedRunEntrySlice) Less(i, j int) bool {
+	return sortedRunEntryLess(s[i], s[j])
+}
+
+func sortedRunEntryLess(a, b sortedRunEntry) bool {
+	if a.key.Less(b.key) {
+		return true
+	}
+	if b.key.Less(a.key) {
+		return false
+	}
+	return a.seq > b.seq
+}
+
+// externalSorter implements the sorting shared by MapBuilder and SetBuilder.
+type externalSorter struct {
+	vrw         ValueReadWriter
+	dir         string
+	maxBuffered uint64
+	batchSize   int
+
+	mu           sync.Mutex
+	buffered     sortedRunEntrySlice
+	bufferedSize uint64
+	nextSeq      uint64
+	runs         []string
+	spillSem     chan struct{} // at most one run is spilled at a time
+	spillErr     error         // guarded by spillSem
+	readErr      error         // guarded by mu
+	finished     bool
+}
+
+func newExternalSorter(vrw ValueReadWriter, dir string, maxBuffered uint64, batchSize int) *externalSorter {
+	d.PanicIfTrue(vrw == nil)
+	return &externalSorter{
+		vrw:         vrw,
+		dir:         dir,
+		maxBuffered: maxBuffered,
+		batchSize:   batchSize,
+		spillSem:    make(chan struct{}, 1),
+	}
+}
+
+func (s *externalSorter) add(k, v Value) {
+	e := sortedRunEntry{key: k, value: v, kData: EncodeValue(k).Data()}
+	if v != nil {
+		e.vData = EncodeValue(v).Data()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.finished {
+		d.Panic("Can't add to a builder after it has been built")
+	}
+	e.seq = s.nextSeq
+	s.nextSeq++
+	s.buffered = append(s.buffered, e)
+	s.bufferedSize += uint64(len(e.kData) + len(e.vData))
+
+	if s.bufferedSize >= s.maxBuffered {
+		f, err := ioutil.TempFile(s.dir, "noms-sorted-run")
+		d.PanicIfError(err)
+		s.runs = append(s.runs, f.Name())
+
+		entries := s.buffered
+		s.buffered, s.bufferedSize = nil, 0
+		s.spillSem <- struct{}{}
+		go func() {
+			defer func() { <-s.spillSem }()
+			s.spill(f, entries)
+		}()
+	}
+}
+
+// spill sorts entries and writes them to f, which it closes. Each entry is
+// written as the uvarint encoded length of the key, the encoded key, then the
+// length of the value and the encoded value.
+func (s *externalSorter) spill(f *os.File, entries sortedRunEntrySlice) {
+	defer f.Close()
+	sort.Sort(entries)
+
+	w := bufio.NewWriter(f)
+	lenBuf := make([]byte, binary.MaxVarintLen64)
+	writeBytes := func(b []byte) {
+		n := binary.PutUvarint(lenBuf, uint64(len(b)))
+		w.Write(lenBuf[:n])
+		w.Write(b)
+	}
+	for i, e := range entries {
+		if i > 0 && e.key.Equals(entries[i-1].key) {
+			continue // an older entry for the same key
+		}
+		writeBytes(e.kData)
+		writeBytes(e.vData)
+	}
+
+	// Only one spill runs at a time, and finish() waits for it to complete
+	// before reading spillErr.
+	if err := w.Flush(); err != nil && s.spillErr == nil {
+		s.spillErr = err
+	}
+}
+
+// finish merges the spilled runs with whatever remains buffered, and calls cb
+// for every distinct key, in order, along with the last value added for it.
+func (s *externalSorter) finish(cb func(k, v Value)) {
+	s.mu.Lock()
+	s.finished = true
+	s.mu.Unlock()
+
+	// Wait for any outstanding spill.
+	s.spillSem <- struct{}{}
+	defer func() { <-s.spillSem }()
+
+	defer func() {
+		for _, name := range s.runs {
+			os.Remove(name)
+		}
+	}()
+	d.PanicIfError(s.spillErr)
+
+	sort.Sort(s.buffered)
+	buffered := make(chan sortedRunEntry, sortedRunReadAhead)
+	go func() {
+		defer close(buffered)
+		for _, e := range s.buffered {
+			buffered <- sortedRunEntry{key: e.key, value: e.value}
+		}
+	}()
+
+	// The buffered run is the newest, so it's given the highest seq; a run's
+	// seq breaks ties between equal keys from different runs.
+	runs := sortedRunHeap{}
+	pushRun := func(seq uint64, entries <-chan sortedRunEntry) {
+		if e, ok := <-entries; ok {
+			e.seq = seq
+			runs = append(runs, &sortedRun{e, entries})
+		}
+	}
+	for i, name := range s.runs {
+		f, err := os.Open(name)
+		d.PanicIfError(err)
+		defer f.Close()
+		pushRun(uint64(i), s.readRun(f))
+	}
+	pushRun(uint64(len(s.runs)), buffered)
+	heap.Init(&runs)
+
+	var last Value
+	for len(runs) > 0 {
+		r := runs[0]
+		if last == nil || !r.current.key.Equals(last) {
+			cb(r.current.key, r.current.value)
+			last = r.current.key
+		}
+
+		if e, ok := <-r.entries; ok {
+			e.seq = r.current.seq
+			r.current = e
+			heap.Fix(&runs, 0)
+		} else {
+			heap.Pop(&runs)
+		}
+	}
+
+	// A run which failed to be read ended early, so what cb was given may be incomplete.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d.PanicIfError(s.readErr)
+}
+
+// chunk calls finish, and chunks the items that item makes of its entries in
+// batches of batchSize, each in its own goroutine and with its own chunker
+// from newChunker. At most GOMAXPROCS batches are chunked at once. The batches'
+// sequences are concatenated in order as they complete.
+func (s *externalSorter) chunk(newChunker newSequenceChunkerFn, item func(k, v Value) sequenceItem) sequence {
+	type batch struct {
+		seq       sequence
+		recovered interface{}
+	}
+	batches := make(chan chan batch, runtime.GOMAXPROCS(0))
+	var recovered interface{}
+	go func() {
+		defer close(batches)
+		defer func() { recovered = recover() }()
+		items := make([]sequenceItem, 0, s.batchSize)
+		flush := func() {
+			res := make(chan batch, 1)
+			batches <- res
+			go func(items []sequenceItem) {
+				var b batch
+				defer func() {
+					b.recovered = recover()
+					res <- b
+				}()
+				ch := newChunker(nil, s.vrw)
+				for _, it := range items {
+					ch.Append(it)
+				}
+				b.seq = ch.Done()
+			}(items)
+			items = make([]sequenceItem, 0, s.batchSize)
+		}
+		s.finish(func(k, v Value) {
+			items = append(items, item(k, v))
+			if len(items) == s.batchSize {
+				flush()
+			}
+		})
+		if len(items) > 0 {
+			flush()
+		}
+	}()
+
+	var seq sequence
+	var failed interface{}
+	for res := range batches {
+		b := <-res
+		switch {
+		case failed != nil:
+			// Drain the remaining batches before panicking.
+		case b.recovered != nil:
+			failed = b.recovered
+		case seq == nil:
+			seq = b.seq
+		default:
+			seq = concat(seq, b.seq, newChunker)
+		}
+	}
+	// recovered is written before batches is closed.
+	if failed == nil {
+		failed = recovered
+	}
+	if failed != nil {
+		panic(failed)
+	}
+	if seq == nil {
+		return newChunker(nil, s.vrw).Done()
+	}
+	return seq
+}
+
+// readRun decodes the entries of a spilled run in a separate goroutine, so
+// that all runs are decoded concurrently while being merged. If reading the
+// run fails, the entries end early, and the error is kept in readErr for
+// finish() to panic with on its own goroutine.
+func (s *externalSorter) readRun(f *os.File) <-chan sortedRunEntry {
+	entries := make(chan sortedRunEntry, sortedRunReadAhead)
+	go func() {
+		defer close(entries)
+		r := bufio.NewReader(f)
+		readBytes := func() ([]byte, error) {
+			l, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+			b := make([]byte, l)
+			_, err = io.ReadFull(r, b)
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return b, err
+		}
+
+		err := d.Try(func() {
+			for {
+				kData, err := readBytes()
+				if err == io.EOF {
+					return
+				}
+				d.PanicIfError(err)
+				vData, err := readBytes()
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				d.PanicIfError(err)
+				e := sortedRunEntry{key: DecodeFromBytes(kData, s.vrw)}
+				if len(vData) > 0 {
+					e.value = DecodeFromBytes(vData, s.vrw)
+				}
+				entries <- e
+			}
+		})
+		if err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.readErr == nil {
+				s.readErr = err
+			}
+		}
+	}()
+	return entries
+}
+
+type sortedRun struct {
+	current sortedRunEntry
+	entries <-chan sortedRunEntry
+}
+
+type sortedRunHeap []*sortedRun
+
+func (h sortedRunHeap) Len() int      { return len(h) }
+func (h sortedRunHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+func (h sortedRunHeap) Less(i, j int) bool {
+	return sortedRunEntryLess(h[i].current, h[j].current)
+}
+
+func (h *sortedRunHeap) Push(r interface{}) {
+	*h = append(*h, r.(*sortedRun))
+}
+
+func (h *sortedRunHeap) Pop() interface{} {
+	old := *h
+	r := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return r
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMapBuilder(t *testing.T) {
+	assert := assert.New(t)
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	dir, err := ioutil.TempDir("", "map-builder")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	vs := newTestValueStore()
+	const n = 5000
+	perm := rand.New(rand.NewSource(0)).Perm(n)
+
+	// A tiny buffer forces many sorted runs to be spilled,
+	b := newMapBuilder(vs, dir, 1<<10)
+	// and small batches to be chunked in parallel and concatenated.
+	b.s.batchSize = 97
+	me := NewMap(vs).Edit()
+	for _, i := range perm {
+		b.Set(Number(i), String("first"))
+		me.Set(Number(i), String("first"))
+	}
+	for i := 0; i < n; i += 3 {
+		b.Set(Number(i), Number(i*2)) // overwrites win
+		me.Set(Number(i), Number(i*2))
+	}
+
+	m := b.Map()
+	expected := me.Map()
+	assert.Equal(uint64(n), m.Len())
+	assert.True(expected.Equals(m))
+
+	// Runs are cleaned up.
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(err)
+	assert.Empty(files)
+}
+
+func TestMapBuilderInMemory(t *testing.T) {
+	assert := assert.New(t)
+	vs := newTestValueStore()
+
+	b := NewMapBuilder(vs)
+	b.Set(String("b"), Number(2))
+	b.Set(String("a"), Number(1))
+	b.Set(String("b"), Number(3))
+	assert.True(NewMap(vs, String("a"), Number(1), String("b"), Number(3)).Equals(b.Map()))
+	assert.Panics(func() { b.Set(String("c"), Number(4)) })
+
+	assert.True(NewMap(vs).Equals(NewMapBuilder(vs).Map()))
+}
+
+func TestMapBuilderReadRunError(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "map-builder")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	vs := newTestValueStore()
+	b := newMapBuilder(vs, dir, 1<<10)
+	for i := 0; i < 1000; i++ {
+		b.Set(Number(i), String("value"))
+	}
+	// Wait for the last spill, then cut a run short.
+	b.s.spillSem <- struct{}{}
+	<-b.s.spillSem
+	assert.True(len(b.s.runs) > 1)
+	assert.NoError(os.Truncate(b.s.runs[0], 100))
+
+	// The error is raised by Map(), rather than crashing the goroutine reading the run.
+	err = d.Try(func() { b.Map() })
+	assert.Equal(io.ErrUnexpectedEOF, d.Unwrap(err))
+}
+
+func TestMapBuilderConcurrent(t *testing.T) {
+	assert := assert.New(t)
+	vs := newTestValueStore()
+
+	b := newMapBuilder(vs, "", 1<<8)
+	wg := sync.WaitGroup{}
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := g; i < 1000; i += 4 {
+				b.Set(Number(i), NewList(vs, Number(i)))
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	m := b.Map()
+	assert.Equal(uint64(1000), m.Len())
+	assert.True(NewList(vs, Number(999)).Equals(m.Get(Number(999))))
+}
+
+func TestSetBuilder(t *testing.T) {
+	assert := assert.New(t)
+	smallTestChunks()
+	defer normalProductionChunks()
+
+	vs := newTestValueStore()
+	b := newSetBuilder(vs, "", 1<<10)
+	b.s.batchSize = 97
+	values := generateNumbersAsValues(3000)
+	for _, i := range rand.New(rand.NewSource(0)).Perm(len(values)) {
+		b.Insert(values[i])
+		b.Insert(values[i])
+	}
+	assert.True(NewSet(vs, values...).Equals(b.Set()))
+}
@@ -333,6 +333,7 @@ func (ms metaSequence) getCompositeChildSequence(start uint64, length uint64) se
 	metaItems := []metaTuple{}
 	mapItems := []mapEntry{}
 	valueItems := []Value{}
+	blobData := []byte{}
 
 	childIsMeta := false
 	isIndexedSequence := false
@@ -340,8 +341,8 @@ func (ms metaSequence) getCompositeChildSequence(start uint64, length uint64) se
 		isIndexedSequence = true
 	}
 
-	// TODO: This looks strange. The children can only be a meta sequence or one of map/set/list
-	// (why not blob?). We cannot mix map, set and list here and we know based on ms.Kind what
+	// TODO: This looks strange. The children can only be a meta sequence or one of map/set/list/blob.
+	// We cannot mix map, set and list here and we know based on ms.Kind what
 	// we are expecting.
 	// https://github.com/attic-labs/noms/issues/3706
 	output := ms.getChildren(start, start+length)
@@ -357,6 +358,8 @@ func (ms metaSequence) getCompositeChildSequence(start uint64, length uint64) se
 			valueItems = append(valueItems, t.values()...)
 		case listLeafSequence:
 			valueItems = append(valueItems, t.values()...)
+		case blobLeafSequence:
+			blobData = append(blobData, t.data()...)
 		default:
 			panic("unreachable")
 		}
@@ -370,6 +373,10 @@ func (ms metaSequence) getCompositeChildSequence(start uint64, length uint64) se
 		return newListLeafSequence(ms.vrw, valueItems...)
 	}
 
+	if BlobKind == ms.Kind() {
+		return newBlobLeafSequence(ms.vrw, blobData)
+	}
+
 	if MapKind == ms.Kind() {
 		return newMapLeafSequence(ms.vrw, mapItems...)
 	}
@@ -0,0 +1,92 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const (
+	anyFieldPattern = `.*`
+	anyIndexPattern = `[*]`
+	anyFieldRe      = `\.[a-zA-Z0-9_]+`
+	anyIndexRe      = `\[(?:"(?:[^"\\]|\\.)*"|[^"\]]*)\](?:@key)?`
+)
+
+var pathPatternPartRe = regexp.MustCompile(`^(?:\.(?:\*|[a-zA-Z0-9_]+)|\[(?:\*|"(?:[^"\\]|\\.)*"|[^"\]]*)\](?:@key)?)`)
+
+// PathPattern matches Paths. It's spelled like a Path, except that `.*`
+// matches any field, and `[*]` matches any index, e.g. `.rows[*].price`.
+type PathPattern struct {
+	str   string
+	parts []*regexp.Regexp
+}
+
+// ParsePathPattern parses str into a PathPattern, or returns an error if str
+// isn't a Path once its wildcards are filled in.
+func ParsePathPattern(str string) (PathPattern, error) {
+	filled := strings.Replace(strings.Replace(str, anyIndexPattern, "[0]", -1), anyFieldPattern, ".f", -1)
+	if _, err := ParsePath(filled); err != nil {
+		return PathPattern{}, fmt.Errorf("Invalid path pattern %s: %s", str, err)
+	}
+	parts := []*regexp.Regexp{}
+	for rest := str; rest != ""; {
+		part := pathPatternPartRe.FindString(rest)
+		if part == "" {
+			return PathPattern{}, fmt.Errorf("Invalid path pattern %s: unexpected %s", str, rest)
+		}
+		rest = rest[len(part):]
+		re := regexp.QuoteMeta(part)
+		if part == anyFieldPattern {
+			re = anyFieldRe
+		} else if strings.HasPrefix(part, anyIndexPattern) {
+			re = anyIndexRe + regexp.QuoteMeta(part[len(anyIndexPattern):])
+		}
+		parts = append(parts, regexp.MustCompile("^"+re+"$"))
+	}
+	return PathPattern{str, parts}, nil
+}
+
+// MustParsePathPattern parses str into a PathPattern, or panics if parsing
+// failed.
+func MustParsePathPattern(str string) PathPattern {
+	pp, err := ParsePathPattern(str)
+	if err != nil {
+		panic(err)
+	}
+	return pp
+}
+
+// Match returns whether pp matches all of p.
+func (pp PathPattern) Match(p Path) bool {
+	return len(p) == len(pp.parts) && pp.matchParts(p)
+}
+
+// MatchPrefix returns whether pp matches p or one of its ancestors, i.e.
+// whether p is at or under a Path that pp matches.
+func (pp PathPattern) MatchPrefix(p Path) bool {
+	return len(p) >= len(pp.parts) && pp.matchParts(p[:len(pp.parts)])
+}
+
+// MatchUnder returns whether pp could match a Path under p, i.e. whether p is
+// an ancestor of some Path that pp matches.
+func (pp PathPattern) MatchUnder(p Path) bool {
+	return len(p) < len(pp.parts) && pp.matchParts(p)
+}
+
+func (pp PathPattern) matchParts(p Path) bool {
+	for i, part := range p {
+		if !pp.parts[i].MatchString(part.String()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (pp PathPattern) String() string {
+	return pp.str
+}
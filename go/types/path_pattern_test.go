@@ -0,0 +1,62 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPathPattern(t *testing.T) {
+	assert := assert.New(t)
+
+	test := func(pattern, path string, match bool) {
+		assert.Equal(match, MustParsePathPattern(pattern).Match(MustParsePath(path)), "%s %s", pattern, path)
+	}
+	test(".foo", ".foo", true)
+	test(".foo", ".foo.bar", false)
+	test(".foo", ".fo", false)
+	test(".*", ".foo", true)
+	test(".*", ".foo.bar", false)
+	test(".*.bar", ".foo.bar", true)
+	test(`.rows[*].price`, `.rows[0].price`, true)
+	test(`.rows[*].price`, `.rows["a]b"].price`, true)
+	test(`.rows[*].price`, `.rows[#`+String("a").Hash().String()+`].price`, true)
+	test(`.rows[*].price`, `.rows[0][1].price`, false)
+	test(`.rows[*].price`, `.rows.price`, false)
+	test(`.rows["a"]`, `.rows["a"]`, true)
+	test(`.rows["a"]`, `.rows["b"]`, false)
+	test(`[*]`, `[true]`, true)
+
+	for _, s := range []string{"", "foo", ".rows[*", ".*.", "[**]"} {
+		_, err := ParsePathPattern(s)
+		assert.Error(err, s)
+	}
+	assert.Equal(".rows[*]", MustParsePathPattern(".rows[*]").String())
+}
+
+func TestPathPatternPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	test := func(pattern, path string, prefix, under bool) {
+		pp, p := MustParsePathPattern(pattern), MustParsePath(path)
+		assert.Equal(prefix, pp.MatchPrefix(p), "%s prefix %s", pattern, path)
+		assert.Equal(under, pp.MatchUnder(p), "%s under %s", pattern, path)
+	}
+	test(".meta", ".meta", true, false)
+	test(".meta", ".meta.date", true, false)
+	test(".meta", ".metadata", false, false)
+	test(".meta", ".value", false, false)
+	test(`.rows[*].price`, `.rows`, false, true)
+	test(`.rows[*].price`, `.rows[1]`, false, true)
+	test(`.rows[*].price`, `.rows[1].price`, true, false)
+	test(`.rows[*].price`, `.rows[1].price.cents`, true, false)
+	test(`.rows[*].price`, `.rows[1].name`, false, false)
+	test(`.rows[*].price`, `.cols`, false, false)
+	test(`.rows["[*]"]`, `.rows["[*]"]`, true, false)
+	test(`.rows["[*]"]`, `.rows["a"]`, false, false)
+	assert.True(MustParsePathPattern(".*").MatchUnder(nil))
+}
@@ -5,6 +5,7 @@
 package types
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/attic-labs/noms/go/sloppy"
@@ -26,10 +27,61 @@ var (
 	chunkConfigMu = &sync.Mutex{}
 )
 
-func chunkingConfig() (pattern, window uint32) {
+const (
+	minChunkTargetSize = uint32(1 << 6)
+	maxChunkTargetSize = uint32(1 << 20)
+	maxChunkWindow     = uint32(1 << 10)
+)
+
+// ChunkConfig describes the rolling hash used to pick chunk boundaries when
+// Noms splits a collection into a prolly-tree. The same data chunked with two
+// different ChunkConfigs yields differently shaped trees, and therefore
+// different hashes, so every writer of a database must agree on it.
+type ChunkConfig struct {
+	// TargetSize is the average size, in bytes, of the chunks produced. It
+	// must be a power of two.
+	TargetSize uint32
+
+	// Window is the number of bytes the rolling hash considers at once.
+	Window uint32
+}
+
+// DefaultChunkConfig is the ChunkConfig used by databases which don't record
+// one of their own.
+var DefaultChunkConfig = ChunkConfig{defaultChunkPattern + 1, defaultChunkWindow}
+
+// Validate returns an error if c can't be used to chunk values.
+func (c ChunkConfig) Validate() error {
+	if c.TargetSize < minChunkTargetSize || c.TargetSize > maxChunkTargetSize || c.TargetSize&(c.TargetSize-1) != 0 {
+		return fmt.Errorf("Invalid chunk target size %d: must be a power of two between %d and %d", c.TargetSize, minChunkTargetSize, maxChunkTargetSize)
+	}
+	if c.Window == 0 || c.Window > maxChunkWindow {
+		return fmt.Errorf("Invalid chunk window %d: must be between 1 and %d", c.Window, maxChunkWindow)
+	}
+	return nil
+}
+
+func (c ChunkConfig) pattern() uint32 {
+	return c.TargetSize - 1
+}
+
+func chunkingConfig() ChunkConfig {
 	chunkConfigMu.Lock()
 	defer chunkConfigMu.Unlock()
-	return chunkPattern, chunkWindow
+	return ChunkConfig{chunkPattern + 1, chunkWindow}
+}
+
+// chunkConfigurer is implemented by ValueReadWriters, e.g. ValueStore, which
+// carry the ChunkConfig of the database they write to.
+type chunkConfigurer interface {
+	ChunkConfig() ChunkConfig
+}
+
+func chunkConfigFor(vr ValueReader) ChunkConfig {
+	if cc, ok := vr.(chunkConfigurer); ok {
+		return cc.ChunkConfig()
+	}
+	return chunkingConfig()
 }
 
 func smallTestChunks() {
@@ -63,15 +115,15 @@ func hashValueByte(item sequenceItem, rv *rollingValueHasher) {
 	rv.HashByte(item.(byte))
 }
 
-func newRollingValueHasher(salt byte) *rollingValueHasher {
-	pattern, window := chunkingConfig()
+func newRollingValueHasher(vr ValueReader, salt byte) *rollingValueHasher {
+	cfg := chunkConfigFor(vr)
 	w := newBinaryNomsWriter()
 
 	rv := &rollingValueHasher{
 		bw:      w,
-		bz:      buzhash.NewBuzHash(window),
-		pattern: pattern,
-		window:  window,
+		bz:      buzhash.NewBuzHash(cfg.Window),
+		pattern: cfg.pattern(),
+		window:  cfg.Window,
 		salt:    salt,
 	}
 
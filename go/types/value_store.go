@@ -53,6 +53,8 @@ type ValueStore struct {
 	unresolvedRefs       hash.HashSet
 	enforceCompleteness  bool
 	decodedChunks        *sizecache.SizeCache
+	chunkConfigMu        sync.RWMutex
+	chunkConfig          ChunkConfig
 
 	versOnce sync.Once
 }
@@ -113,6 +115,27 @@ func (lvs *ValueStore) ChunkStore() chunks.ChunkStore {
 	return lvs.cs
 }
 
+// SetChunkConfig sets the ChunkConfig used to chunk every collection written
+// through lvs. It panics if cfg is invalid.
+func (lvs *ValueStore) SetChunkConfig(cfg ChunkConfig) {
+	d.PanicIfError(cfg.Validate())
+	lvs.chunkConfigMu.Lock()
+	defer lvs.chunkConfigMu.Unlock()
+	lvs.chunkConfig = cfg
+}
+
+// ChunkConfig returns the ChunkConfig used to chunk collections written
+// through lvs. Unless SetChunkConfig has been called, this is
+// DefaultChunkConfig.
+func (lvs *ValueStore) ChunkConfig() ChunkConfig {
+	lvs.chunkConfigMu.RLock()
+	defer lvs.chunkConfigMu.RUnlock()
+	if (lvs.chunkConfig == ChunkConfig{}) {
+		return chunkingConfig()
+	}
+	return lvs.chunkConfig
+}
+
 // ReadValue reads and decodes a value from lvs. It is not considered an error
 // for the requested chunk to be empty; in this case, the function simply
 // returns nil.
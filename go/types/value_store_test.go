@@ -261,3 +261,28 @@ type badVersionStore struct {
 func (b *badVersionStore) Version() string {
 	return "BAD"
 }
+
+func TestChunkConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(DefaultChunkConfig.Validate())
+	assert.NoError(ChunkConfig{1 << 16, 64}.Validate())
+	assert.Error(ChunkConfig{1000, 64}.Validate())
+	assert.Error(ChunkConfig{1 << 30, 64}.Validate())
+	assert.Error(ChunkConfig{1 << 12, 0}.Validate())
+
+	vs := newTestValueStore()
+	assert.Equal(DefaultChunkConfig, vs.ChunkConfig())
+	assert.Panics(func() { vs.SetChunkConfig(ChunkConfig{1000, 64}) })
+
+	vs.SetChunkConfig(ChunkConfig{1 << 8, 64})
+	l := NewList(vs, generateNumbersAsValues(300)...)
+	assert.False(l.sequence().isLeaf())
+
+	// Explicitly configured ValueStores ignore the test-only global config.
+	smallTestChunks()
+	defer normalProductionChunks()
+	vs2 := newTestValueStore()
+	vs2.SetChunkConfig(DefaultChunkConfig)
+	assert.True(NewList(vs2, generateNumbersAsValues(300)...).sequence().isLeaf())
+}
@@ -0,0 +1,68 @@
+// Copyright 2017 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package signing parses and formats the ed25519 keys with which Commits are
+// signed, and the signatures, see datas.SignCommit().
+package signing
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/ed25519"
+)
+
+// KeySet maps the names of trusted signers to their public keys.
+type KeySet map[string]ed25519.PublicKey
+
+// Name returns the name of key, if it's in ks.
+func (ks KeySet) Name(key ed25519.PublicKey) (string, bool) {
+	for name, k := range ks {
+		if bytes.Equal(k, key) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// ParsePublicKey decodes a base64-encoded ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("Invalid ed25519 public key: %s", s)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// ParsePrivateKey decodes a base64-encoded ed25519 private key.
+func ParsePrivateKey(s string) (ed25519.PrivateKey, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != ed25519.PrivateKeySize {
+		return nil, errors.New("Invalid ed25519 private key")
+	}
+	return ed25519.PrivateKey(b), nil
+}
+
+// FormatKey base64-encodes an ed25519 public or private key, as
+// ParsePublicKey() and ParsePrivateKey() expect.
+func FormatKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// FormatSignature base64-encodes an ed25519 signature, as ParseSignature()
+// expects.
+func FormatSignature(sig []byte) string {
+	return base64.StdEncoding.EncodeToString(sig)
+}
+
+// ParseSignature decodes a base64-encoded ed25519 signature.
+func ParseSignature(s string) ([]byte, error) {
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(b) != ed25519.SignatureSize {
+		return nil, errors.New("Invalid ed25519 signature")
+	}
+	return b, nil
+}
@@ -190,6 +190,9 @@ func ReadToMap(r *csv.Reader, structName string, headersRaw []string, primaryKey
 	temp, fieldOrder, kindMap := MakeStructTemplateFromHeaders(headersRaw, structName, kinds)
 	pkIndices := getPkIndices(primaryKeys, headersRaw)
 	d.Chk.True(len(pkIndices) >= 1, "No primary key defined when reading into map")
+	if len(pkIndices) == 1 {
+		return readToFlatMap(r, temp, headersRaw, fieldOrder, kindMap, pkIndices, vrw)
+	}
 	gb := types.NewGraphBuilder(vrw, types.MapKind)
 
 	for {
@@ -207,3 +210,23 @@ func ReadToMap(r *csv.Reader, structName string, headersRaw []string, primaryKey
 	}
 	return gb.Build().(types.Map)
 }
+
+// readToFlatMap reads rows keyed by a single primary key using a
+// types.MapBuilder, so that rows don't need to be sorted and needn't fit in
+// memory.
+func readToFlatMap(r *csv.Reader, temp types.StructTemplate, headersRaw []string, fieldOrder []int, kindMap []types.NomsKind, pkIndices []int, vrw types.ValueReadWriter) types.Map {
+	mb := types.NewMapBuilder(vrw)
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			panic(err)
+		}
+
+		fields := readFieldsFromRow(row, headersRaw, fieldOrder, kindMap)
+		_, mapKey := primaryKeyValuesFromFields(fields, fieldOrder, pkIndices)
+		mb.Set(mapKey, temp.NewStruct(fields))
+	}
+	return mb.Map()
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/jsontonoms"
 	"github.com/attic-labs/noms/go/util/progressreader"
 	"github.com/attic-labs/noms/go/util/status"
@@ -29,6 +30,7 @@ import (
 
 func main() {
 	performCommit := flag.Bool("commit", true, "commit the data to head of the dataset (otherwise only write the data to the dataset)")
+	destType := flag.String("dest-type", "value", "the destination type to import to. can be 'value' or 'map:<field>', where the input must be an array of objects which are streamed into a map keyed by <field>, and need not fit in memory")
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "usage: %s <url> <dataset>\n", os.Args[0])
 		flag.PrintDefaults()
@@ -52,6 +54,16 @@ func main() {
 		flag.Usage()
 	}
 
+	keyField := ""
+	if strings.HasPrefix(*destType, "map:") {
+		keyField = strings.TrimPrefix(*destType, "map:")
+		if keyField == "" {
+			d.CheckError(errors.New("dest-type map requires a key field"))
+		}
+	} else if *destType != "value" {
+		d.CheckError(fmt.Errorf("Invalid dest-type: %s", *destType))
+	}
+
 	var r io.Reader
 	if strings.HasPrefix(url, "http") {
 		res, err := http.Get(url)
@@ -72,14 +84,23 @@ func main() {
 		r = f
 	}
 
-	var jsonObject interface{}
 	start := time.Now()
 	r = progressreader.New(r, func(seen uint64) {
 		elapsed := time.Since(start).Seconds()
 		rate := uint64(float64(seen) / elapsed)
 		status.Printf("%s decoded in %ds (%s/s)...", humanize.Bytes(seen), int(elapsed), humanize.Bytes(rate))
 	})
-	err = json.NewDecoder(r).Decode(&jsonObject)
+
+	var value types.Value
+	if keyField != "" {
+		value, err = readToMap(json.NewDecoder(r), keyField, db)
+	} else {
+		var jsonObject interface{}
+		err = json.NewDecoder(r).Decode(&jsonObject)
+		if err == nil {
+			value = jsontonoms.NomsValueFromDecodedJSON(db, jsonObject, true)
+		}
+	}
 	if err != nil {
 		log.Fatalln("Error decoding JSON: ", err)
 	}
@@ -89,10 +110,38 @@ func main() {
 		additionalMetaInfo := map[string]string{"url": url}
 		meta, err := spec.CreateCommitMetaStruct(ds.Database(), "", "", additionalMetaInfo, nil)
 		d.CheckErrorNoUsage(err)
-		_, err = db.Commit(ds, jsontonoms.NomsValueFromDecodedJSON(db, jsonObject, true), datas.CommitOptions{Meta: meta})
+		_, err = db.Commit(ds, value, datas.CommitOptions{Meta: meta})
 		d.PanicIfError(err)
 	} else {
-		ref := db.WriteValue(jsontonoms.NomsValueFromDecodedJSON(db, jsonObject, true))
+		ref := db.WriteValue(value)
 		fmt.Fprintf(os.Stdout, "#%s\n", ref.TargetHash().String())
 	}
 }
+
+// readToMap streams a JSON array of objects into a Map keyed by the value of
+// each object's keyField. Objects are decoded one at a time and accumulated
+// in a types.MapBuilder, so the input needn't be sorted or fit in memory.
+func readToMap(dec *json.Decoder, keyField string, vrw types.ValueReadWriter) (types.Value, error) {
+	if t, err := dec.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('[') {
+		return nil, errors.New("dest-type map requires a JSON array of objects")
+	}
+
+	mb := types.NewMapBuilder(vrw)
+	for dec.More() {
+		var obj map[string]interface{}
+		if err := dec.Decode(&obj); err != nil {
+			return nil, err
+		}
+		k, ok := obj[keyField]
+		if !ok {
+			return nil, fmt.Errorf("object has no field %s", keyField)
+		}
+		mb.Set(jsontonoms.NomsValueFromDecodedJSON(vrw, k, true), jsontonoms.NomsValueFromDecodedJSON(vrw, obj, true))
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	return mb.Map(), nil
+}
@@ -98,6 +98,9 @@ type nNode struct {
 	name   string
 	key    hash.Hash
 	inode  types.Struct
+
+	// For Files, writes since the data of inode was last updated.
+	writer *types.BlobWriter
 }
 
 type mount func(fs pathfs.FileSystem)
@@ -290,6 +293,7 @@ func (fs *nomsFS) Truncate(path string, size uint64, context *fuse.Context) fuse
 	np.nLock.Lock()
 	defer np.nLock.Unlock()
 
+	fs.flushWrites(np)
 	inode := np.inode
 	attr := inode.Get("attr").(types.Struct)
 	file := inode.Get("contents").(types.Struct)
@@ -446,6 +450,7 @@ func (nfile nomsFile) Read(dest []byte, off int64) (fuse.ReadResult, fuse.Status
 	nfile.node.nLock.Lock()
 	defer nfile.node.nLock.Unlock()
 
+	nfile.fs.flushWrites(nfile.node)
 	file := nfile.node.inode.Get("contents")
 
 	d.Chk.Equal(nodeType(nfile.node.inode), "File")
@@ -470,26 +475,32 @@ func (nfile nomsFile) Write(data []byte, off int64) (uint32, fuse.Status) {
 	inode := nfile.node.inode
 	d.Chk.Equal(nodeType(inode), "File")
 
-	attr := inode.Get("attr").(types.Struct)
-	file := inode.Get("contents").(types.Struct)
-	ref := file.Get("data").(types.Ref)
-	blob := ref.TargetValue(nfile.fs.db).(types.Blob)
+	if nfile.node.writer == nil {
+		blob := inode.Get("contents").(types.Struct).Get("data").(types.Ref).TargetValue(nfile.fs.db).(types.Blob)
+		nfile.node.writer = blob.Writer()
+	}
+	d.PanicIfFalse(nfile.node.writer.Len() >= uint64(off))
 
-	ll := uint64(blob.Len())
-	oo := uint64(off)
-	d.PanicIfFalse(ll >= oo)
-	del := uint64(len(data))
-	if ll-oo < del {
-		del = ll - oo
+	// Writes are streamed into the file's Blob, which isn't stored in the inode until it's next needed.
+	n, err := nfile.node.writer.WriteAt(data, off)
+	if err != nil {
+		return uint32(n), fuse.EIO
 	}
 
-	blob = blob.Edit().Splice(uint64(off), del, data).Blob()
-	ref = nfile.fs.db.WriteValue(blob)
-	file = file.Set("data", ref)
+	nfile.fs.bufferNode(nfile.node, inode.Set("attr", updateMtime(inode.Get("attr").(types.Struct))))
 
-	nfile.fs.bufferNode(nfile.node, inode.Set("contents", file).Set("attr", updateMtime(attr)))
+	return uint32(n), fuse.OK
+}
 
-	return uint32(len(data)), fuse.OK
+// flushWrites stores the Blob written by any writes to np since they were last flushed in its inode. The caller must hold np.nLock.
+func (fs *nomsFS) flushWrites(np *nNode) {
+	if np.writer == nil {
+		return
+	}
+	file := np.inode.Get("contents").(types.Struct)
+	file = file.Set("data", fs.db.WriteValue(np.writer.Blob()))
+	fs.bufferNode(np, np.inode.Set("contents", file))
+	np.writer = nil
 }
 
 func (nfile nomsFile) Flush() fuse.Status {
@@ -498,6 +509,7 @@ func (nfile nomsFile) Flush() fuse.Status {
 	defer nfile.fs.mdLock.Unlock()
 	defer nfile.node.nLock.Unlock()
 
+	nfile.fs.flushWrites(nfile.node)
 	np := nfile.fs.nodes[nfile.node.key]
 	if np == nfile.node {
 		nfile.fs.commitNode(nfile.node)
@@ -766,7 +778,11 @@ func (fs *nomsFS) GetAttr(path string, context *fuse.Context) (*fuse.Attr, fuse.
 		return nil, code
 	}
 
+	np.nLock.Lock()
+	fs.flushWrites(np)
 	inode := np.inode
+	np.nLock.Unlock()
+
 	attr := inode.Get("attr").(types.Struct)
 	contents := inode.Get("contents").(types.Struct)
 
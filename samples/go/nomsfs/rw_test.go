@@ -78,6 +78,35 @@ func (s *fuseTestSuite) TestBigFile() {
 	assertAttr(s, testfs, "shining.txt", 0644|fuse.S_IFREG, size)
 }
 
+func (s *fuseTestSuite) TestStreamingWrites() {
+	datasetName := "TestStreamingWrites"
+	str := spec.CreateValueSpecString("nbs", s.DBDir, datasetName)
+
+	var testfs pathfs.FileSystem
+
+	start(str, func(fs pathfs.FileSystem) { testfs = fs })
+
+	file, code := testfs.Create("stream", uint32(os.O_CREATE|os.O_RDWR), 0644, nil)
+	assert.Equal(s.T(), fuse.OK, code)
+
+	line := []byte("All work and no play makes Jack a dull boy.\n")
+	expected := &bytes.Buffer{}
+	for i := 0; i < 1000; i++ {
+		n, code := file.Write(line, int64(expected.Len()))
+		assert.Equal(s.T(), fuse.OK, code)
+		assert.Equal(s.T(), uint32(len(line)), n)
+		expected.Write(line)
+	}
+	assert.Equal(s.T(), fuse.OK, file.Flush())
+	assertAttr(s, testfs, "stream", 0644|fuse.S_IFREG, uint64(expected.Len()))
+
+	data := make([]byte, expected.Len())
+	rr, code := file.Read(data, 0)
+	assert.Equal(s.T(), fuse.OK, code)
+	assert.Equal(s.T(), expected.Len(), rr.Size())
+	assert.Equal(s.T(), expected.Bytes(), data)
+}
+
 func (s *fuseTestSuite) TestOverwrite() {
 	datasetName := "TestOverwrite"
 	str := spec.CreateValueSpecString("nbs", s.DBDir, datasetName)